@@ -0,0 +1,389 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MelloB1989/karma/config"
+	"github.com/openai/openai-go/v3/shared"
+	"gopkg.in/yaml.v3"
+)
+
+// AgentConfig is a declarative KarmaAI definition, loaded from YAML or JSON
+// so prompts and models can change without a redeploy.
+//
+//	name: support
+//	model: gpt-5-mini
+//	provider: openai
+//	system_message: |
+//	  You are the support assistant for ${COMPANY_NAME:-Acme}.
+//	temperature: 0.3
+//	tools: [lookup_order, refund_order]
+//	mcp_servers:
+//	  - url: ${DOCS_MCP_URL}
+//	    auth_token: ${DOCS_MCP_TOKEN}
+//	rate_limit: {requests_per_minute: 60, behavior: wait}
+//	fallbacks:
+//	  - {model: claude-4.5-haiku, provider: anthropic}
+//
+// ${VAR} in a string value is replaced with the environment variable VAR and
+// fails to load when it is unset; ${VAR:-default} falls back to default. Tools are looked up by
+// name in the registry filled by RegisterTool.
+type AgentConfig struct {
+	Name              string           `json:"name,omitempty"`
+	Model             BaseModel        `json:"model"`
+	Provider          Provider         `json:"provider"`
+	CustomModelString string           `json:"custom_model_string,omitempty"`
+	SystemMessage     string           `json:"system_message,omitempty"`
+	Context           string           `json:"context,omitempty"`
+	UserPrePrompt     string           `json:"user_pre_prompt,omitempty"`
+	Temperature       *float32         `json:"temperature,omitempty"`
+	TopP              *float32         `json:"top_p,omitempty"`
+	TopK              *int             `json:"top_k,omitempty"`
+	MaxTokens         int              `json:"max_tokens,omitempty"`
+	ReasoningEffort   string           `json:"reasoning_effort,omitempty"`
+	ResponseType      string           `json:"response_type,omitempty"`
+	MaxToolPasses     int              `json:"max_tool_passes,omitempty"`
+	ContextWindow     int              `json:"context_window,omitempty"`
+	RequestTimeout    string           `json:"request_timeout,omitempty"`
+	Tools             []string         `json:"tools,omitempty"`
+	MCPServers        []MCPServer      `json:"mcp_servers,omitempty"`
+	RateLimit         *RateLimitConfig `json:"rate_limit,omitempty"`
+	// BaseURL and APIKey point the agent at an OpenAI-compatible endpoint.
+	// See WithCustomProvider.
+	BaseURL   string          `json:"base_url,omitempty"`
+	APIKey    string          `json:"api_key,omitempty"`
+	TenantID  string          `json:"tenant_id,omitempty"`
+	Fallbacks []FallbackModel `json:"fallbacks,omitempty"`
+}
+
+// FallbackModel is a fallback entry in an AgentConfig.
+type FallbackModel struct {
+	Model             BaseModel `json:"model"`
+	Provider          Provider  `json:"provider"`
+	CustomModelString string    `json:"custom_model_string,omitempty"`
+}
+
+func (f FallbackModel) config() ModelConfig {
+	return ModelConfig{BaseModel: f.Model, Provider: f.Provider, CustomModelString: f.CustomModelString}
+}
+
+var (
+	toolRegistryMu sync.RWMutex
+	toolRegistry   = map[string]GoFunctionTool{}
+)
+
+// RegisterTool makes tool available to AgentConfig files by its name. Safe to
+// call from init() and concurrently.
+func RegisterTool(tool GoFunctionTool) {
+	toolRegistryMu.Lock()
+	defer toolRegistryMu.Unlock()
+	toolRegistry[tool.Name] = tool
+}
+
+func lookupTool(name string) (GoFunctionTool, bool) {
+	toolRegistryMu.RLock()
+	defer toolRegistryMu.RUnlock()
+	t, ok := toolRegistry[name]
+	return t, ok
+}
+
+// LoadAgentConfig reads a config file from disk.
+func LoadAgentConfig(file string) (*AgentConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseAgentConfigFile(file, data)
+}
+
+// LoadAgentConfigFS reads a config file from fsys, e.g. an embed.FS.
+func LoadAgentConfigFS(fsys fs.FS, file string) (*AgentConfig, error) {
+	data, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	return parseAgentConfigFile(file, data)
+}
+
+// LoadAgentConfigsFS reads every .yaml, .yml and .json file in dir of fsys,
+// keyed by their name field, or by file name without extension when it is
+// empty. All configs are validated, so a bad file fails startup.
+func LoadAgentConfigsFS(fsys fs.FS, dir string) (map[string]*AgentConfig, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]*AgentConfig)
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		c, err := LoadAgentConfigFS(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if c.Name == "" {
+			c.Name = strings.TrimSuffix(e.Name(), ext)
+		}
+		if _, dup := out[c.Name]; dup {
+			return nil, fmt.Errorf("%s: agent %q is defined twice", e.Name(), c.Name)
+		}
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		out[c.Name] = c
+	}
+	return out, nil
+}
+
+func parseAgentConfigFile(file string, data []byte) (*AgentConfig, error) {
+	c, err := ParseAgentConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return c, nil
+}
+
+// ParseAgentConfig parses a YAML or JSON config after interpolating
+// environment variables. Unknown keys are rejected.
+func ParseAgentConfig(data []byte) (*AgentConfig, error) {
+	// YAML is a superset of JSON. Decoding through JSON reuses the json tags
+	// KarmaAI's types already carry.
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, errors.New("agent config is empty")
+	}
+	var missing []string
+	raw = expandEnv(raw, &missing)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("agent config references unset environment variables: %s", strings.Join(missing, ", "))
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.DisallowUnknownFields()
+	var c AgentConfig
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} and ${VAR:-default} in the string values of a
+// decoded config, so a value can never change the document's structure. Other
+// dollar signs are left alone, so prompts can mention prices. Unset variables
+// without a default are added to missing.
+func expandEnv(v any, missing *[]string) any {
+	switch t := v.(type) {
+	case string:
+		return envRef.ReplaceAllStringFunc(t, func(ref string) string {
+			m := envRef.FindStringSubmatch(ref)
+			if v := config.GetEnvRaw(m[1]); v != "" {
+				return v
+			}
+			if m[2] != "" {
+				return m[3]
+			}
+			*missing = append(*missing, m[1])
+			return ""
+		})
+	case map[string]any:
+		for k, val := range t {
+			t[k] = expandEnv(val, missing)
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = expandEnv(val, missing)
+		}
+	}
+	return v
+}
+
+// Validate reports every problem in c at once.
+func (c *AgentConfig) Validate() error {
+	var errs []error
+	if c.Model == "" {
+		errs = append(errs, errors.New("model is required"))
+	}
+	if c.Provider == "" {
+		errs = append(errs, errors.New("provider is required"))
+	} else if !knownProvider(c.Provider) && c.BaseURL == "" {
+		errs = append(errs, fmt.Errorf("provider %q is not built in or registered, and no base_url is set", c.Provider))
+	}
+	if c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > 2) {
+		errs = append(errs, fmt.Errorf("temperature %v is outside 0-2", *c.Temperature))
+	}
+	if c.TopP != nil && (*c.TopP < 0 || *c.TopP > 1) {
+		errs = append(errs, fmt.Errorf("top_p %v is outside 0-1", *c.TopP))
+	}
+	if c.MaxTokens < 0 || c.MaxToolPasses < 0 || c.ContextWindow < 0 {
+		errs = append(errs, errors.New("max_tokens, max_tool_passes and context_window must not be negative"))
+	}
+	if c.RequestTimeout != "" {
+		if _, err := time.ParseDuration(c.RequestTimeout); err != nil {
+			errs = append(errs, fmt.Errorf("request_timeout: %w", err))
+		}
+	}
+	switch shared.ReasoningEffort(c.ReasoningEffort) {
+	case "", shared.ReasoningEffortNone, shared.ReasoningEffortMinimal, shared.ReasoningEffortLow,
+		shared.ReasoningEffortMedium, shared.ReasoningEffortHigh, shared.ReasoningEffortXhigh:
+	default:
+		errs = append(errs, fmt.Errorf("reasoning_effort %q is not a known effort", c.ReasoningEffort))
+	}
+	for _, name := range c.Tools {
+		if _, ok := lookupTool(name); !ok {
+			errs = append(errs, fmt.Errorf("tool %q is not registered", name))
+		}
+	}
+	for i, s := range c.MCPServers {
+		if s.URL == "" {
+			errs = append(errs, fmt.Errorf("mcp_servers[%d] has no url", i))
+		}
+	}
+	if rl := c.RateLimit; rl != nil {
+		if rl.RequestsPerMinute < 0 || rl.TokensPerMinute < 0 {
+			errs = append(errs, errors.New("rate_limit values must not be negative"))
+		}
+		switch rl.Behavior {
+		case "", RateLimitBehaviorWait, RateLimitBehaviorError:
+		default:
+			errs = append(errs, fmt.Errorf("rate_limit behavior %q is not wait or error", rl.Behavior))
+		}
+	}
+	for i, f := range c.Fallbacks {
+		if f.Model == "" || f.Provider == "" {
+			errs = append(errs, fmt.Errorf("fallbacks[%d] needs model and provider", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func knownProvider(p Provider) bool {
+	switch p {
+	case OpenAI, Anthropic, Bedrock, Google, XAI, Groq, FireworksAI, OpenRouter, Sarvam, TogetherAI, NvidiaNIM, Codex:
+		return true
+	}
+	_, ok := lookupCustomProvider(p)
+	return ok
+}
+
+// Options validates c and converts it to KarmaAI options.
+func (c *AgentConfig) Options() ([]Option, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	var opts []Option
+	if c.CustomModelString != "" {
+		opts = append(opts, SetCustomModelVariant(c.CustomModelString))
+	}
+	if c.SystemMessage != "" {
+		opts = append(opts, WithSystemMessage(c.SystemMessage))
+	}
+	if c.Context != "" {
+		opts = append(opts, WithContext(c.Context))
+	}
+	if c.UserPrePrompt != "" {
+		opts = append(opts, WithUserPrePrompt(c.UserPrePrompt))
+	}
+	if c.Temperature != nil {
+		opts = append(opts, WithTemperature(*c.Temperature))
+	}
+	if c.TopP != nil {
+		opts = append(opts, WithTopP(*c.TopP))
+	}
+	if c.TopK != nil {
+		opts = append(opts, WithTopK(*c.TopK))
+	}
+	if c.MaxTokens > 0 {
+		opts = append(opts, WithMaxTokens(c.MaxTokens))
+	}
+	if c.ReasoningEffort != "" {
+		opts = append(opts, WithReasoningEffort(shared.ReasoningEffort(c.ReasoningEffort)))
+	}
+	if c.ResponseType != "" {
+		opts = append(opts, WithResponseType(c.ResponseType))
+	}
+	if c.MaxToolPasses > 0 {
+		opts = append(opts, WithMaxToolPasses(c.MaxToolPasses))
+	}
+	if c.ContextWindow > 0 {
+		opts = append(opts, WithContextWindow(c.ContextWindow))
+	}
+	if c.RequestTimeout != "" {
+		d, _ := time.ParseDuration(c.RequestTimeout)
+		opts = append(opts, WithRequestTimeout(d))
+	}
+	for _, name := range c.Tools {
+		tool, _ := lookupTool(name)
+		opts = append(opts, AddGoFunctionTool(tool))
+	}
+	for _, s := range c.MCPServers {
+		opts = append(opts, AddMCPServer(s))
+	}
+	if len(c.Tools) > 0 || len(c.MCPServers) > 0 {
+		opts = append(opts, WithToolsEnabled())
+	}
+	if rl := c.RateLimit; rl != nil {
+		behavior := rl.Behavior
+		if behavior == "" {
+			behavior = RateLimitBehaviorWait
+		}
+		if rl.RequestsPerMinute > 0 {
+			opts = append(opts, WithRateLimit(rl.RequestsPerMinute, behavior))
+		}
+		if rl.TokensPerMinute > 0 {
+			opts = append(opts, WithTokenRateLimit(rl.TokensPerMinute, behavior))
+		}
+	}
+	if c.BaseURL != "" {
+		opts = append(opts, WithCustomProvider(c.BaseURL, c.APIKey))
+	}
+	if c.TenantID != "" {
+		opts = append(opts, WithTenant(c.TenantID))
+	}
+	for _, f := range c.Fallbacks {
+		opts = append(opts, WithFallbackModels(f.config()))
+	}
+	return opts, nil
+}
+
+// New builds a KarmaAI from c. extra options are applied last, e.g.
+// ConfigureAnalytics or WithCredentialResolver.
+func (c *AgentConfig) New(extra ...Option) (*KarmaAI, error) {
+	opts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	return NewKarmaAI(c.Model, c.Provider, append(opts, extra...)...), nil
+}
+
+// NewKarmaAIFromFile loads, validates and builds the agent defined in file.
+func NewKarmaAIFromFile(file string, extra ...Option) (*KarmaAI, error) {
+	c, err := LoadAgentConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	kai, err := c.New(extra...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return kai, nil
+}
@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+func TestParseAgentConfig(t *testing.T) {
+	t.Setenv("KARMA_TEST_COMPANY", "Initech")
+	RegisterTool(NewGoFunctionTool("lookup_order", "Look up an order", nil, func(ctx context.Context, p FuncParams) (string, error) { return "", nil }))
+
+	c, err := ParseAgentConfig([]byte(`
+model: gpt-5-mini
+provider: openai
+system_message: Support for ${KARMA_TEST_COMPANY}, plans from $5. Region ${KARMA_TEST_REGION:-eu}.
+temperature: 0.2
+tools: [lookup_order]
+request_timeout: 45s
+rate_limit: {requests_per_minute: 30}
+fallbacks:
+  - {model: claude-4.5-haiku, provider: anthropic}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SystemMessage != "Support for Initech, plans from $5. Region eu." {
+		t.Fatalf("system message = %q", c.SystemMessage)
+	}
+	kai, err := c.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kai.Model.BaseModel != GPT5Mini || kai.Temperature != 0.2 || !kai.ToolsEnabled || len(kai.GoFunctionTools) != 1 {
+		t.Fatalf("kai = %+v", kai)
+	}
+	if kai.RequestTimeout.Seconds() != 45 || kai.RateLimit == nil || kai.RateLimit.RequestsPerMinute != 30 {
+		t.Fatalf("timeout/rate limit = %v %+v", kai.RequestTimeout, kai.RateLimit)
+	}
+	if len(kai.Fallbacks) != 1 || kai.Fallbacks[0].Provider != Anthropic {
+		t.Fatalf("fallbacks = %+v", kai.Fallbacks)
+	}
+}
+
+func TestParseAgentConfigEnvIsData(t *testing.T) {
+	t.Setenv("KARMA_TEST_TOKEN", "abc #secret")
+	t.Setenv("KARMA_TEST_MODEL", "gpt-5-mini\nprovider: anthropic")
+	c, err := ParseAgentConfig([]byte(`
+model: ${KARMA_TEST_MODEL}
+provider: openai
+mcp_servers:
+  - url: https://docs.example.com/mcp
+    auth_token: ${KARMA_TEST_TOKEN}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MCPServers[0].AuthToken != "abc #secret" {
+		t.Errorf("auth token = %q", c.MCPServers[0].AuthToken)
+	}
+	if c.Model != "gpt-5-mini\nprovider: anthropic" || c.Provider != OpenAI {
+		t.Errorf("model = %q, provider = %q", c.Model, c.Provider)
+	}
+}
+
+func TestParseAgentConfigErrors(t *testing.T) {
+	if _, err := ParseAgentConfig([]byte(`{"model": "x", "provider": "openai", "sytem_message": "typo"}`)); err == nil {
+		t.Error("unknown keys should be rejected")
+	}
+	if _, err := ParseAgentConfig([]byte(`model: ${KARMA_TEST_UNSET_VAR}`)); err == nil || !strings.Contains(err.Error(), "KARMA_TEST_UNSET_VAR") {
+		t.Errorf("unset variable = %v", err)
+	}
+
+	c, err := ParseAgentConfig([]byte(`{"provider": "nowhere", "temperature": 3, "tools": ["missing_tool"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Validate()
+	for _, want := range []string{"model is required", `"nowhere"`, "temperature", `"missing_tool"`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want mention of %s", err, want)
+		}
+	}
+}
+
+func TestLoadAgentConfigsFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"agents/triage.yaml":  {Data: []byte("model: gpt-5-nano\nprovider: openai\n")},
+		"agents/writer.json":  {Data: []byte(`{"name": "copywriter", "model": "gpt-5", "provider": "openai"}`)},
+		"agents/README.md":    {Data: []byte("ignored")},
+		"agents/nested/x.yml": {Data: []byte("ignored")},
+	}
+	agents, err := LoadAgentConfigsFS(fsys, "agents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(agents) != 2 || agents["triage"] == nil || agents["copywriter"] == nil {
+		t.Fatalf("agents = %v", agents)
+	}
+}
+
+func TestFallbackModels(t *testing.T) {
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		seen = append(seen, body.Model)
+		if body.Model == "primary" {
+			http.Error(w, `{"error":{"message":"overloaded"}}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","object":"chat.completion","model":"backup","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	kai := NewKarmaAI("primary", "local-test", WithCustomProvider(srv.URL, "key"),
+		WithFallbackModels(ModelConfig{BaseModel: "backup", Provider: "local-test"}))
+	res, err := kai.ChatCompletion(models.AIChatHistory{Messages: []models.AIMessage{{Role: models.User, Message: "hi"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.AIResponse != "ok" || len(seen) != 2 || seen[1] != "backup" {
+		t.Fatalf("response %q after %v", res.AIResponse, seen)
+	}
+	if kai.Model.BaseModel != "primary" {
+		t.Fatal("the primary model should be untouched")
+	}
+
+	if res, err := kai.GenerateFromSinglePrompt("hi"); err != nil || res.AIResponse != "ok" || len(seen) != 4 {
+		t.Fatalf("single prompt fallback = %v, %v after %v", res, err, seen)
+	}
+
+	history := &models.AIChatHistory{Messages: []models.AIMessage{{Role: models.User, Message: "hi"}}}
+	restore := kai.historyRestorer(history)
+	history.Messages = append(history.Messages, models.AIMessage{Role: models.Assistant, ToolCalls: []models.OpenAIToolCall{{ID: "call_1"}}})
+	restore()
+	if len(history.Messages) != 1 {
+		t.Fatalf("a failed attempt's tool calls were replayed: %+v", history.Messages)
+	}
+}
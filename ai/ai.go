@@ -3,20 +3,38 @@ package ai
 import (
 	"errors"
 
-	"github.com/MelloB1989/karma/config"
 	"github.com/MelloB1989/karma/models"
 )
 
 func (kai *KarmaAI) ChatCompletion(messages models.AIChatHistory) (*models.AIChatResponse, error) {
+	return kai.withFallbacks(nil, func(kai *KarmaAI, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+		return kai.chatCompletion(messages)
+	})
+}
+
+func (kai *KarmaAI) chatCompletion(messages models.AIChatHistory) (*models.AIChatResponse, error) {
+	kai, search := kai.withSearchCollector()
 	kai.setBasicProperties()
 	m := kai.addUserPreprompt(&messages)
+	if err := kai.preflight(*m); err != nil {
+		kai.removeUserPrePrompt(m)
+		return nil, err
+	}
+	if err := kai.resolveCredentials(); err != nil {
+		kai.removeUserPrePrompt(m)
+		return nil, err
+	}
 
 	var response *models.AIChatResponse
 	var err error
 
 	switch kai.Model.GetModelProvider() {
 	case OpenAI:
-		response, err = kai.handleOpenAIChatCompletion(m)
+		if kai.useResponsesAPI() {
+			response, err = kai.handleOpenAIResponsesCompletion(m)
+		} else {
+			response, err = kai.handleOpenAIChatCompletion(m)
+		}
 	case Bedrock:
 		response, err = kai.handleBedrockChatCompletion(*m)
 	case Google:
@@ -24,19 +42,19 @@ func (kai *KarmaAI) ChatCompletion(messages models.AIChatHistory) (*models.AICha
 	case Anthropic:
 		response, err = kai.handleAnthropicChatCompletion(*m)
 	case XAI:
-		response, err = kai.handleOpenAICompatibleChatCompletion(m, XAI_API, config.GetEnvRaw("XAI_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(m, kai.baseURL(XAI_API), kai.apiKey("XAI_API_KEY"))
 	case Groq:
-		response, err = kai.handleOpenAICompatibleChatCompletion(m, GROQ_API, config.GetEnvRaw("GROQ_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(m, kai.baseURL(GROQ_API), kai.apiKey("GROQ_API_KEY"))
 	case Sarvam:
-		response, err = kai.handleOpenAICompatibleChatCompletion(m, SARVAM_API, config.GetEnvRaw("SARVAM_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(m, kai.baseURL(SARVAM_API), kai.apiKey("SARVAM_API_KEY"))
 	case FireworksAI:
-		response, err = kai.handleOpenAICompatibleChatCompletion(m, FIREWORKS_API, config.GetEnvRaw("FIREWORKS_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(m, kai.baseURL(FIREWORKS_API), kai.apiKey("FIREWORKS_API_KEY"))
 	case OpenRouter:
-		response, err = kai.handleOpenAICompatibleChatCompletion(m, OPENROUTER_API, config.GetEnvRaw("OPENROUTER_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(m, kai.baseURL(OPENROUTER_API), kai.apiKey("OPENROUTER_API_KEY"))
 	case TogetherAI:
-		response, err = kai.handleOpenAICompatibleChatCompletion(m, TOGETHER_API, config.GetEnvRaw("TOGETHER_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(m, kai.baseURL(TOGETHER_API), kai.apiKey("TOGETHER_API_KEY"))
 	case NvidiaNIM:
-		response, err = kai.handleOpenAICompatibleChatCompletion(m, NVIDIA_NIM_API, config.GetEnvRaw("NVIDIA_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(m, kai.baseURL(NVIDIA_NIM_API), kai.apiKey("NVIDIA_API_KEY"))
 	case Codex:
 		response, err = kai.handleCodexChatCompletion(m)
 	default:
@@ -47,6 +65,8 @@ func (kai *KarmaAI) ChatCompletion(messages models.AIChatHistory) (*models.AICha
 		}
 	}
 
+	search.attach(response)
+
 	// Handle analytics and errors asynchronously after getting the response
 	if response != nil {
 		kai.captureResponse(*m, *response)
@@ -61,6 +81,13 @@ func (kai *KarmaAI) ChatCompletion(messages models.AIChatHistory) (*models.AICha
 }
 
 func (kai *KarmaAI) GenerateFromSinglePrompt(prompt string) (*models.AIChatResponse, error) {
+	return kai.withFallbacks(nil, func(kai *KarmaAI, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+		return kai.generateFromSinglePrompt(prompt)
+	})
+}
+
+func (kai *KarmaAI) generateFromSinglePrompt(prompt string) (*models.AIChatResponse, error) {
+	kai, search := kai.withSearchCollector()
 	kai.setBasicProperties()
 
 	singleMessage := models.AIChatHistory{
@@ -71,13 +98,23 @@ func (kai *KarmaAI) GenerateFromSinglePrompt(prompt string) (*models.AIChatRespo
 			},
 		},
 	}
+	if err := kai.preflight(singleMessage); err != nil {
+		return nil, err
+	}
+	if err := kai.resolveCredentials(); err != nil {
+		return nil, err
+	}
 
 	var response *models.AIChatResponse
 	var err error
 
 	switch kai.Model.GetModelProvider() {
 	case OpenAI:
-		response, err = kai.handleOpenAIChatCompletion(&singleMessage)
+		if kai.useResponsesAPI() {
+			response, err = kai.handleOpenAIResponsesCompletion(&singleMessage)
+		} else {
+			response, err = kai.handleOpenAIChatCompletion(&singleMessage)
+		}
 	case Bedrock:
 		response, err = kai.handleBedrockSinglePrompt(singleMessage)
 	case Google:
@@ -85,19 +122,19 @@ func (kai *KarmaAI) GenerateFromSinglePrompt(prompt string) (*models.AIChatRespo
 	case Anthropic:
 		response, err = kai.handleAnthropicSinglePrompt(prompt)
 	case XAI:
-		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, XAI_API, config.GetEnvRaw("XAI_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, kai.baseURL(XAI_API), kai.apiKey("XAI_API_KEY"))
 	case Groq:
-		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, GROQ_API, config.GetEnvRaw("GROQ_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, kai.baseURL(GROQ_API), kai.apiKey("GROQ_API_KEY"))
 	case Sarvam:
-		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, SARVAM_API, config.GetEnvRaw("SARVAM_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, kai.baseURL(SARVAM_API), kai.apiKey("SARVAM_API_KEY"))
 	case FireworksAI:
-		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, FIREWORKS_API, config.GetEnvRaw("FIREWORKS_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, kai.baseURL(FIREWORKS_API), kai.apiKey("FIREWORKS_API_KEY"))
 	case OpenRouter:
-		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, OPENROUTER_API, config.GetEnvRaw("OPENROUTER_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, kai.baseURL(OPENROUTER_API), kai.apiKey("OPENROUTER_API_KEY"))
 	case TogetherAI:
-		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, TOGETHER_API, config.GetEnvRaw("TOGETHER_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, kai.baseURL(TOGETHER_API), kai.apiKey("TOGETHER_API_KEY"))
 	case NvidiaNIM:
-		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, NVIDIA_NIM_API, config.GetEnvRaw("NVIDIA_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(&singleMessage, kai.baseURL(NVIDIA_NIM_API), kai.apiKey("NVIDIA_API_KEY"))
 	case Codex:
 		response, err = kai.handleCodexChatCompletion(&singleMessage)
 	default:
@@ -108,6 +145,8 @@ func (kai *KarmaAI) GenerateFromSinglePrompt(prompt string) (*models.AIChatRespo
 		}
 	}
 
+	search.attach(response)
+
 	// Handle analytics and errors asynchronously after getting the response
 	if response != nil {
 		kai.captureResponse(singleMessage, *response)
@@ -120,15 +159,34 @@ func (kai *KarmaAI) GenerateFromSinglePrompt(prompt string) (*models.AIChatRespo
 }
 
 func (kai *KarmaAI) ChatCompletionStream(messages models.AIChatHistory, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+	return kai.withFallbacks(callback, func(kai *KarmaAI, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+		return kai.chatCompletionStream(messages, callback)
+	})
+}
+
+func (kai *KarmaAI) chatCompletionStream(messages models.AIChatHistory, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+	kai, search := kai.withSearchCollector()
 	kai.setBasicProperties()
 	m := kai.addUserPreprompt(&messages)
+	if err := kai.preflight(*m); err != nil {
+		kai.removeUserPrePrompt(m)
+		return nil, err
+	}
+	if err := kai.resolveCredentials(); err != nil {
+		kai.removeUserPrePrompt(m)
+		return nil, err
+	}
 
 	var response *models.AIChatResponse
 	var err error
 
 	switch kai.Model.GetModelProvider() {
 	case OpenAI:
-		response, err = kai.handleOpenAIStreamCompletion(m, callback)
+		if kai.useResponsesAPI() {
+			response, err = kai.handleOpenAIResponsesStreamCompletion(m, callback)
+		} else {
+			response, err = kai.handleOpenAIStreamCompletion(m, callback)
+		}
 	case Bedrock:
 		response, err = kai.handleBedrockStreamCompletion(*m, callback)
 	case Google:
@@ -136,19 +194,19 @@ func (kai *KarmaAI) ChatCompletionStream(messages models.AIChatHistory, callback
 	case Anthropic:
 		response, err = kai.handleAnthropicStreamCompletion(*m, callback)
 	case XAI:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, XAI_API, config.GetEnvRaw("XAI_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, kai.baseURL(XAI_API), kai.apiKey("XAI_API_KEY"))
 	case Groq:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, GROQ_API, config.GetEnvRaw("GROQ_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, kai.baseURL(GROQ_API), kai.apiKey("GROQ_API_KEY"))
 	case Sarvam:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, SARVAM_API, config.GetEnvRaw("SARVAM_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, kai.baseURL(SARVAM_API), kai.apiKey("SARVAM_API_KEY"))
 	case FireworksAI:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, FIREWORKS_API, config.GetEnvRaw("FIREWORKS_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, kai.baseURL(FIREWORKS_API), kai.apiKey("FIREWORKS_API_KEY"))
 	case OpenRouter:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, OPENROUTER_API, config.GetEnvRaw("OPENROUTER_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, kai.baseURL(OPENROUTER_API), kai.apiKey("OPENROUTER_API_KEY"))
 	case TogetherAI:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, TOGETHER_API, config.GetEnvRaw("TOGETHER_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, kai.baseURL(TOGETHER_API), kai.apiKey("TOGETHER_API_KEY"))
 	case NvidiaNIM:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, NVIDIA_NIM_API, config.GetEnvRaw("NVIDIA_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(m, callback, kai.baseURL(NVIDIA_NIM_API), kai.apiKey("NVIDIA_API_KEY"))
 	case Codex:
 		response, err = kai.handleCodexStreamCompletion(m, callback)
 	default:
@@ -159,6 +217,8 @@ func (kai *KarmaAI) ChatCompletionStream(messages models.AIChatHistory, callback
 		}
 	}
 
+	search.attach(response)
+
 	// Handle analytics and errors asynchronously after getting the response
 	if response != nil {
 		kai.captureResponse(*m, *response)
@@ -173,18 +233,40 @@ func (kai *KarmaAI) ChatCompletionStream(messages models.AIChatHistory, callback
 }
 
 func (kai *KarmaAI) ChatCompletionManaged(history *models.AIChatHistory) (*models.AIChatResponse, error) {
+	restore := kai.historyRestorer(history)
+	return kai.withFallbacks(nil, func(kai *KarmaAI, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+		restore()
+		return kai.chatCompletionManaged(history)
+	})
+}
+
+func (kai *KarmaAI) chatCompletionManaged(history *models.AIChatHistory) (*models.AIChatResponse, error) {
+	kai, search := kai.withSearchCollector()
 	if history == nil {
 		return nil, errors.New("history is nil")
 	}
 	kai.setBasicProperties()
+	kai.applyAutoSummary(history)
 	kai.addUserPreprompt(history)
+	if err := kai.preflight(*history); err != nil {
+		kai.removeUserPrePrompt(history)
+		return nil, err
+	}
+	if err := kai.resolveCredentials(); err != nil {
+		kai.removeUserPrePrompt(history)
+		return nil, err
+	}
 
 	var response *models.AIChatResponse
 	var err error
 
 	switch kai.Model.GetModelProvider() {
 	case OpenAI:
-		response, err = kai.handleOpenAIChatCompletion(history)
+		if kai.useResponsesAPI() {
+			response, err = kai.handleOpenAIResponsesCompletion(history)
+		} else {
+			response, err = kai.handleOpenAIChatCompletion(history)
+		}
 	case Bedrock:
 		response, err = kai.handleBedrockChatCompletion(*history)
 	case Google:
@@ -192,19 +274,19 @@ func (kai *KarmaAI) ChatCompletionManaged(history *models.AIChatHistory) (*model
 	case Anthropic:
 		response, err = kai.handleAnthropicChatCompletion(*history)
 	case XAI:
-		response, err = kai.handleOpenAICompatibleChatCompletion(history, XAI_API, config.GetEnvRaw("XAI_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(history, kai.baseURL(XAI_API), kai.apiKey("XAI_API_KEY"))
 	case Groq:
-		response, err = kai.handleOpenAICompatibleChatCompletion(history, GROQ_API, config.GetEnvRaw("GROQ_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(history, kai.baseURL(GROQ_API), kai.apiKey("GROQ_API_KEY"))
 	case Sarvam:
-		response, err = kai.handleOpenAICompatibleChatCompletion(history, SARVAM_API, config.GetEnvRaw("SARVAM_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(history, kai.baseURL(SARVAM_API), kai.apiKey("SARVAM_API_KEY"))
 	case FireworksAI:
-		response, err = kai.handleOpenAICompatibleChatCompletion(history, FIREWORKS_API, config.GetEnvRaw("FIREWORKS_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(history, kai.baseURL(FIREWORKS_API), kai.apiKey("FIREWORKS_API_KEY"))
 	case OpenRouter:
-		response, err = kai.handleOpenAICompatibleChatCompletion(history, OPENROUTER_API, config.GetEnvRaw("OPENROUTER_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(history, kai.baseURL(OPENROUTER_API), kai.apiKey("OPENROUTER_API_KEY"))
 	case TogetherAI:
-		response, err = kai.handleOpenAICompatibleChatCompletion(history, TOGETHER_API, config.GetEnvRaw("TOGETHER_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(history, kai.baseURL(TOGETHER_API), kai.apiKey("TOGETHER_API_KEY"))
 	case NvidiaNIM:
-		response, err = kai.handleOpenAICompatibleChatCompletion(history, NVIDIA_NIM_API, config.GetEnvRaw("NVIDIA_API_KEY"))
+		response, err = kai.handleOpenAICompatibleChatCompletion(history, kai.baseURL(NVIDIA_NIM_API), kai.apiKey("NVIDIA_API_KEY"))
 	case Codex:
 		response, err = kai.handleCodexChatCompletion(history)
 	default:
@@ -215,6 +297,8 @@ func (kai *KarmaAI) ChatCompletionManaged(history *models.AIChatHistory) (*model
 		}
 	}
 
+	search.attach(response)
+
 	// Handle analytics and errors asynchronously after getting the response
 	if response != nil {
 		kai.captureResponse(*history, *response)
@@ -224,23 +308,48 @@ func (kai *KarmaAI) ChatCompletionManaged(history *models.AIChatHistory) (*model
 	}
 
 	kai.removeUserPrePrompt(history)
+	if err == nil {
+		kai.scheduleAutoSummary(history, response)
+	}
 
 	return response, err
 }
 
 func (kai *KarmaAI) ChatCompletionStreamManaged(history *models.AIChatHistory, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+	restore := kai.historyRestorer(history)
+	return kai.withFallbacks(callback, func(kai *KarmaAI, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+		restore()
+		return kai.chatCompletionStreamManaged(history, callback)
+	})
+}
+
+func (kai *KarmaAI) chatCompletionStreamManaged(history *models.AIChatHistory, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+	kai, search := kai.withSearchCollector()
 	if history == nil {
 		return nil, errors.New("history is nil")
 	}
 	kai.setBasicProperties()
+	kai.applyAutoSummary(history)
 	kai.addUserPreprompt(history)
+	if err := kai.preflight(*history); err != nil {
+		kai.removeUserPrePrompt(history)
+		return nil, err
+	}
+	if err := kai.resolveCredentials(); err != nil {
+		kai.removeUserPrePrompt(history)
+		return nil, err
+	}
 
 	var response *models.AIChatResponse
 	var err error
 
 	switch kai.Model.GetModelProvider() {
 	case OpenAI:
-		response, err = kai.handleOpenAIStreamCompletion(history, callback)
+		if kai.useResponsesAPI() {
+			response, err = kai.handleOpenAIResponsesStreamCompletion(history, callback)
+		} else {
+			response, err = kai.handleOpenAIStreamCompletion(history, callback)
+		}
 	case Bedrock:
 		response, err = kai.handleBedrockStreamCompletion(*history, callback)
 	case Google:
@@ -248,19 +357,19 @@ func (kai *KarmaAI) ChatCompletionStreamManaged(history *models.AIChatHistory, c
 	case Anthropic:
 		response, err = kai.handleAnthropicStreamCompletion(*history, callback)
 	case XAI:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, XAI_API, config.GetEnvRaw("XAI_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, kai.baseURL(XAI_API), kai.apiKey("XAI_API_KEY"))
 	case Groq:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, GROQ_API, config.GetEnvRaw("GROQ_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, kai.baseURL(GROQ_API), kai.apiKey("GROQ_API_KEY"))
 	case Sarvam:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, SARVAM_API, config.GetEnvRaw("SARVAM_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, kai.baseURL(SARVAM_API), kai.apiKey("SARVAM_API_KEY"))
 	case FireworksAI:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, FIREWORKS_API, config.GetEnvRaw("FIREWORKS_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, kai.baseURL(FIREWORKS_API), kai.apiKey("FIREWORKS_API_KEY"))
 	case OpenRouter:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, OPENROUTER_API, config.GetEnvRaw("OPENROUTER_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, kai.baseURL(OPENROUTER_API), kai.apiKey("OPENROUTER_API_KEY"))
 	case TogetherAI:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, TOGETHER_API, config.GetEnvRaw("TOGETHER_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, kai.baseURL(TOGETHER_API), kai.apiKey("TOGETHER_API_KEY"))
 	case NvidiaNIM:
-		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, NVIDIA_NIM_API, config.GetEnvRaw("NVIDIA_API_KEY"))
+		response, err = kai.handleOpenAICompatibleStreamCompletion(history, callback, kai.baseURL(NVIDIA_NIM_API), kai.apiKey("NVIDIA_API_KEY"))
 	case Codex:
 		response, err = kai.handleCodexStreamCompletion(history, callback)
 	default:
@@ -271,6 +380,8 @@ func (kai *KarmaAI) ChatCompletionStreamManaged(history *models.AIChatHistory, c
 		}
 	}
 
+	search.attach(response)
+
 	// Handle analytics and errors asynchronously after getting the response
 	if response != nil {
 		kai.captureResponse(*history, *response)
@@ -280,12 +391,18 @@ func (kai *KarmaAI) ChatCompletionStreamManaged(history *models.AIChatHistory, c
 	}
 
 	kai.removeUserPrePrompt(history)
+	if err == nil {
+		kai.scheduleAutoSummary(history, response)
+	}
 
 	return response, err
 }
 
 func (kai *KarmaAI) GetEmbeddings(text string) (*models.AIEmbeddingResponse, error) {
 	kai.setBasicProperties()
+	if err := kai.resolveCredentials(); err != nil {
+		return nil, err
+	}
 	switch kai.Model.GetModelProvider() {
 	case OpenAI:
 		return kai.handleOpenAIEmbeddingGeneration(text)
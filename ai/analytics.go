@@ -105,6 +105,7 @@ func (kai *KarmaAI) SendEvent() {
 		maps.Copy(propertiesCopy, kai.Analytics.properties)
 	}
 	kai.Analytics.mu.RUnlock()
+	kai.redactProperties(propertiesCopy)
 
 	if kai.Analytics.client != nil {
 		kai.Analytics.client.Enqueue(posthog.Capture{
@@ -123,7 +124,7 @@ func (kai *KarmaAI) SendErrorEvent(err error) {
 			return
 		}
 
-		kai.SetAnalyticProperty(AIError, err)
+		kai.SetAnalyticProperty(AIError, kai.redact(err.Error()))
 		kai.SetAnalyticProperty(AIIsError, true)
 
 		kai.Analytics.mu.RLock()
@@ -132,6 +133,7 @@ func (kai *KarmaAI) SendErrorEvent(err error) {
 			maps.Copy(propertiesCopy, kai.Analytics.properties)
 		}
 		kai.Analytics.mu.RUnlock()
+		kai.redactProperties(propertiesCopy)
 
 		if kai.Analytics.client != nil {
 			kai.Analytics.client.Enqueue(posthog.Capture{
@@ -172,6 +174,35 @@ func (kai *KarmaAI) SetAnalyticProperties(props map[AIProperty]any) {
 	}
 }
 
+// clone copies the analytics settings and properties so a per-request copy
+// of an instance can set properties without touching the original's.
+func (a *Analytics) clone() *Analytics {
+	if a == nil {
+		return nil
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return &Analytics{
+		DistinctID:         a.DistinctID,
+		TraceId:            a.TraceId,
+		CaptureUserPrompts: a.CaptureUserPrompts,
+		CaptureAIResponses: a.CaptureAIResponses,
+		CaptureToolCalls:   a.CaptureToolCalls,
+		on:                 a.on,
+		client:             a.client,
+		properties:         maps.Clone(a.properties),
+	}
+}
+
+// withAnalyticProperties returns a shallow copy of kai whose analytics also
+// carry props, leaving kai's properties untouched.
+func (kai *KarmaAI) withAnalyticProperties(props map[AIProperty]any) *KarmaAI {
+	c := *kai
+	c.Analytics = kai.Analytics.clone()
+	c.SetAnalyticProperties(props)
+	return &c
+}
+
 func (kai *KarmaAI) DeleteAnalyticProperty(property AIProperty) {
 	kai.Analytics.mu.Lock()
 	defer kai.Analytics.mu.Unlock()
@@ -179,3 +210,13 @@ func (kai *KarmaAI) DeleteAnalyticProperty(property AIProperty) {
 		delete(kai.Analytics.properties, string(property))
 	}
 }
+
+// redactProperties scrubs the instance's credentials from string properties
+// before they leave the process.
+func (kai *KarmaAI) redactProperties(props map[string]any) {
+	for k, v := range props {
+		if str, ok := v.(string); ok {
+			props[k] = kai.redact(str)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	mcp "github.com/MelloB1989/karma/ai/mcp_client"
@@ -528,6 +529,30 @@ type KarmaAI struct {
 	MaxToolPasses   int                             `json:"max_tool_passes"`
 	RateLimit       *RateLimitConfig                `json:"rate_limit"`
 	RequestTimeout  time.Duration                   `json:"request_timeout"`
+	// ContextWindow, when > 0, rejects requests whose estimated prompt plus
+	// MaxTokens would not fit. See WithContextWindow.
+	ContextWindow int `json:"context_window,omitempty"`
+	// Cache configures provider prompt caching. See WithPromptCaching.
+	Cache *CacheOptions `json:"cache,omitempty"`
+	// Responses routes the OpenAI provider through the Responses API. See
+	// WithResponsesAPI.
+	Responses *ResponsesOptions `json:"responses,omitempty"`
+	// WebSearch enables web search. See WithWebSearch.
+	WebSearch *WebSearchOptions `json:"web_search,omitempty"`
+	// CredentialResolver supplies per-tenant provider keys. Nil uses the
+	// global resolver, then environment variables. See WithCredentialResolver.
+	CredentialResolver CredentialResolver `json:"-"`
+	// TenantID is passed to the credential resolver. See WithTenant.
+	TenantID string `json:"tenant_id,omitempty"`
+	// creds is a pointer so instances can be copied per fallback attempt.
+	creds *atomic.Pointer[Credentials]
+	// AutoSummary generates titles and summaries for managed histories. See
+	// WithAutoSummary.
+	AutoSummary *AutoSummaryOptions `json:"auto_summary,omitempty"`
+	summaries   *summaryState
+	// Fallbacks are tried in order when a chat completion fails. See
+	// WithFallbackModels.
+	Fallbacks []ModelConfig `json:"fallbacks,omitempty"`
 	// Deprecated: Use MCPServers instead
 	MCPServers []MCPServer `json:"mcp_servers"`
 	// BedrockAPIKey is an Amazon Bedrock API key (bearer token). When set, the
@@ -861,6 +886,7 @@ func NewKarmaAI(baseModel BaseModel, provider Provider, options ...Option) *Karm
 		MaxToolPasses:  4,
 		SpecialConfig:  make(map[SpecialConfig]any),
 		RequestTimeout: time.Minute * 30, //Default timeout to 30 minutes
+		creds:          new(atomic.Pointer[Credentials]),
 	}
 
 	for _, option := range options {
@@ -0,0 +1,92 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/MelloB1989/karma/apis/claude"
+	"github.com/MelloB1989/karma/apis/gemini"
+	"github.com/MelloB1989/karma/internal/openai"
+)
+
+// CacheOptions configures prompt caching across providers.
+//
+//   - Anthropic caches by default; Disabled turns it off.
+//   - OpenAI caches automatically; Key is sent as prompt_cache_key so requests
+//     sharing a prefix land on the same cache, and Retention sets
+//     prompt_cache_retention ("in-memory" or "24h").
+//   - Google uses explicit cachedContents for the system prompt, tools and
+//     Documents, kept alive for TTL and reused across requests.
+//
+// Cache hits are reported in AIChatResponse.CacheReadTokens and
+// CacheWriteTokens.
+type CacheOptions struct {
+	Disabled bool `json:"disabled"`
+	// Key is the OpenAI prompt_cache_key. Empty derives a stable key from the
+	// model, system message and tool names.
+	Key string `json:"key,omitempty"`
+	// Retention is the OpenAI prompt_cache_retention.
+	Retention string `json:"retention,omitempty"`
+	// TTL is the lifetime of Gemini cachedContents. Zero means one hour.
+	TTL time.Duration `json:"ttl,omitempty"`
+	// Documents are long, static texts placed ahead of the conversation on
+	// Gemini and cached when large enough. Other providers ignore them; use
+	// Context there.
+	Documents []string `json:"documents,omitempty"`
+}
+
+// WithPromptCaching configures provider prompt caching; see CacheOptions.
+func WithPromptCaching(opts CacheOptions) Option {
+	return func(kai *KarmaAI) {
+		kai.Cache = &opts
+	}
+}
+
+// promptCacheKey is the caller's key, or one derived from the parts of the
+// prompt that stay the same across requests.
+func (kai *KarmaAI) promptCacheKey() string {
+	if kai.Cache != nil && kai.Cache.Key != "" {
+		return kai.Cache.Key
+	}
+	h := sha256.New()
+	h.Write([]byte(kai.Model.GetModelString()))
+	h.Write([]byte{0})
+	h.Write([]byte(kai.SystemMessage))
+	for _, tool := range kai.GoFunctionTools {
+		h.Write([]byte{0})
+		h.Write([]byte(tool.Name))
+	}
+	for _, tool := range kai.MCPTools {
+		h.Write([]byte{0})
+		h.Write([]byte(tool.ToolName))
+	}
+	return "karma-" + hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+func (kai *KarmaAI) applyCacheToOpenAI(o *openai.OpenAI) {
+	// prompt_cache_key is an OpenAI extension; compatible providers may reject it.
+	if kai.Cache == nil || kai.Cache.Disabled || kai.Model.GetModelProvider() != OpenAI {
+		return
+	}
+	o.PromptCacheKey = kai.promptCacheKey()
+	o.PromptCacheRetention = kai.Cache.Retention
+}
+
+func (kai *KarmaAI) applyCacheToClaude(cc *claude.ClaudeClient) {
+	if kai.Cache == nil {
+		return
+	}
+	cc.Cache.Disabled = kai.Cache.Disabled
+}
+
+func (kai *KarmaAI) applyCacheToGemini(g *gemini.Gemini) {
+	if kai.Cache == nil {
+		return
+	}
+	g.Cache = gemini.CachePolicy{
+		Enabled:   !kai.Cache.Disabled,
+		TTL:       kai.Cache.TTL,
+		Documents: kai.Cache.Documents,
+	}
+}
@@ -0,0 +1,38 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/MelloB1989/karma/internal/openai"
+)
+
+func TestPromptCacheKeyIsStable(t *testing.T) {
+	a := NewKarmaAI(GPT4oMini, OpenAI, WithSystemMessage("you are helpful"), WithPromptCaching(CacheOptions{}))
+	b := NewKarmaAI(GPT4oMini, OpenAI, WithSystemMessage("you are helpful"), WithPromptCaching(CacheOptions{}))
+	c := NewKarmaAI(GPT4oMini, OpenAI, WithSystemMessage("you are terse"), WithPromptCaching(CacheOptions{}))
+
+	if a.promptCacheKey() != b.promptCacheKey() {
+		t.Error("identical configs should share a cache key")
+	}
+	if a.promptCacheKey() == c.promptCacheKey() {
+		t.Error("different system messages should not share a cache key")
+	}
+
+	custom := NewKarmaAI(GPT4oMini, OpenAI, WithPromptCaching(CacheOptions{Key: "tenant-42"}))
+	if got := custom.promptCacheKey(); got != "tenant-42" {
+		t.Errorf("promptCacheKey = %q, want tenant-42", got)
+	}
+}
+
+func TestPromptCacheKeyOnlySentToOpenAI(t *testing.T) {
+	o := openai.NewOpenAI("llama", "", 1, 100)
+	NewKarmaAI(Llama33_70B, Groq, WithPromptCaching(CacheOptions{})).applyCacheToOpenAI(o)
+	if o.PromptCacheKey != "" {
+		t.Error("prompt_cache_key should not be sent to OpenAI-compatible providers")
+	}
+
+	NewKarmaAI(GPT4oMini, OpenAI, WithPromptCaching(CacheOptions{Retention: "24h"})).applyCacheToOpenAI(o)
+	if o.PromptCacheKey == "" || o.PromptCacheRetention != "24h" {
+		t.Errorf("expected cache key and retention, got %q %q", o.PromptCacheKey, o.PromptCacheRetention)
+	}
+}
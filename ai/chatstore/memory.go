@@ -0,0 +1,127 @@
+package chatstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+// MemoryStore keeps chats in process memory. Histories are copied in and out,
+// so callers can keep mutating what they saved.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	chats map[string]*memoryChat
+}
+
+type memoryChat struct {
+	info    ChatInfo
+	history models.AIChatHistory
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{chats: make(map[string]*memoryChat)}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, userID string, history *models.AIChatHistory) error {
+	prepare(history)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(userID, history, "", "")
+	return nil
+}
+
+// put stores a copy of history. Callers hold the lock.
+func (s *MemoryStore) put(userID string, history *models.AIChatHistory, parent, forkedAt string) {
+	info := infoOf(userID, history, time.Now())
+	if prev, ok := s.chats[history.ChatId]; ok {
+		info.ParentChatId, info.ForkedAt = prev.info.ParentChatId, prev.info.ForkedAt
+	}
+	if parent != "" {
+		info.ParentChatId, info.ForkedAt = parent, forkedAt
+	}
+	s.chats[history.ChatId] = &memoryChat{info: info, history: copyHistory(history)}
+}
+
+func (s *MemoryStore) Load(ctx context.Context, chatID string) (*models.AIChatHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+	h := copyHistory(&c.history)
+	return &h, nil
+}
+
+func (s *MemoryStore) Append(ctx context.Context, chatID string, messages ...models.AIMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return ErrChatNotFound
+	}
+	c.history.Messages = append(c.history.Messages, messages...)
+	c.info.MessageCount = len(c.history.Messages)
+	c.info.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, userID string, page Page) ([]ChatInfo, error) {
+	return s.find(userID, page, func(ChatInfo) bool { return true }), nil
+}
+
+func (s *MemoryStore) Search(ctx context.Context, userID, query string, page Page) ([]ChatInfo, error) {
+	return s.find(userID, page, func(info ChatInfo) bool { return titleMatches(info.Title, query) }), nil
+}
+
+func (s *MemoryStore) find(userID string, page Page, keep func(ChatInfo) bool) []ChatInfo {
+	s.mu.RLock()
+	var out []ChatInfo
+	for _, c := range s.chats {
+		if c.info.UserId == userID && keep(c.info) {
+			out = append(out, c.info)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].UpdatedAt.Equal(out[j].UpdatedAt) {
+			return out[i].ChatId < out[j].ChatId
+		}
+		return out[i].UpdatedAt.After(out[j].UpdatedAt)
+	})
+	return window(out, page)
+}
+
+func (s *MemoryStore) Fork(ctx context.Context, chatID, messageID string) (*models.AIChatHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+	fork, err := forkOf(&c.history, messageID)
+	if err != nil {
+		return nil, err
+	}
+	s.put(c.info.UserId, fork, chatID, messageID)
+	return fork, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, chatID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.chats[chatID]; !ok {
+		return ErrChatNotFound
+	}
+	delete(s.chats, chatID)
+	return nil
+}
+
+func copyHistory(h *models.AIChatHistory) models.AIChatHistory {
+	c := *h
+	c.Messages = append([]models.AIMessage(nil), h.Messages...)
+	return c
+}
@@ -0,0 +1,275 @@
+package chatstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/v2/orm"
+)
+
+// PostgresSchema creates the tables used by PostgresStore. Run it once with
+// Migrate or through your own migrations.
+const PostgresSchema = `
+CREATE TABLE IF NOT EXISTS karma_chats (
+	chat_id        TEXT PRIMARY KEY,
+	user_id        TEXT NOT NULL,
+	title          TEXT NOT NULL DEFAULT '',
+	description    TEXT NOT NULL DEFAULT '',
+	system_msg     TEXT NOT NULL DEFAULT '',
+	context        TEXT NOT NULL DEFAULT '',
+	parent_chat_id TEXT NOT NULL DEFAULT '',
+	forked_at      TEXT NOT NULL DEFAULT '',
+	created_at     TIMESTAMPTZ NOT NULL,
+	updated_at     TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS karma_chats_user_updated ON karma_chats (user_id, updated_at DESC);
+CREATE TABLE IF NOT EXISTS karma_chat_messages (
+	id        BIGSERIAL PRIMARY KEY,
+	chat_id   TEXT NOT NULL REFERENCES karma_chats (chat_id) ON DELETE CASCADE,
+	unique_id TEXT NOT NULL,
+	message   JSONB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS karma_chat_messages_chat ON karma_chat_messages (chat_id, id);
+`
+
+type chatRow struct {
+	TableName    struct{}  `karma_table:"karma_chats"`
+	ChatId       string    `json:"chat_id" karma:"primary"`
+	UserId       string    `json:"user_id"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	SystemMsg    string    `json:"system_msg"`
+	Context      string    `json:"context"`
+	ParentChatId string    `json:"parent_chat_id"`
+	ForkedAt     string    `json:"forked_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	MessageCount int       `json:"message_count" karma:"ignore"`
+}
+
+type messageRow struct {
+	TableName struct{}         `karma_table:"karma_chat_messages"`
+	Id        int64            `json:"id" karma:"primary"`
+	ChatId    string           `json:"chat_id"`
+	UniqueId  string           `json:"unique_id"`
+	Message   models.AIMessage `json:"message" db:"message"`
+}
+
+func (r chatRow) info() ChatInfo {
+	return ChatInfo{
+		ChatId:       r.ChatId,
+		UserId:       r.UserId,
+		Title:        r.Title,
+		Description:  r.Description,
+		ParentChatId: r.ParentChatId,
+		ForkedAt:     r.ForkedAt,
+		MessageCount: r.MessageCount,
+		CreatedAt:    r.CreatedAt,
+		UpdatedAt:    r.UpdatedAt,
+	}
+}
+
+// PostgresStore stores chats in the karma_chats and karma_chat_messages
+// tables through the v2 ORM. Query caching is not used, since chats change
+// with every turn.
+type PostgresStore struct {
+	chats    *orm.ORM
+	messages *orm.ORM
+}
+
+// NewPostgresStore opens the store with the given ORM options, e.g.
+// orm.WithDatabasePrefix or orm.WithDB.
+func NewPostgresStore(opts ...orm.Options) *PostgresStore {
+	return &PostgresStore{
+		chats:    orm.Load(&chatRow{}, opts...),
+		messages: orm.Load(&messageRow{}, opts...),
+	}
+}
+
+// Migrate creates the tables if they do not exist.
+func (s *PostgresStore) Migrate(ctx context.Context) error {
+	_, err := s.chats.ExecuteRawContext(ctx, PostgresSchema)
+	return err
+}
+
+// Close releases the ORM instances. The shared connection pool stays open.
+func (s *PostgresStore) Close() {
+	s.chats.Close()
+	s.messages.Close()
+}
+
+// saveQuery upserts the chat and replaces its messages in one statement, so a
+// failed save leaves the previous version intact. Forks keep their parent on
+// later saves.
+const saveQuery = `
+WITH chat AS (
+	INSERT INTO karma_chats (chat_id, user_id, title, description, system_msg, context, parent_chat_id, forked_at, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())
+	ON CONFLICT (chat_id) DO UPDATE SET
+		user_id = EXCLUDED.user_id,
+		title = EXCLUDED.title,
+		description = EXCLUDED.description,
+		system_msg = EXCLUDED.system_msg,
+		context = EXCLUDED.context,
+		updated_at = now()
+	RETURNING chat_id
+), cleared AS (
+	DELETE FROM karma_chat_messages WHERE chat_id = $1
+)
+INSERT INTO karma_chat_messages (chat_id, unique_id, message)
+SELECT chat.chat_id, m.value->>'unique_id', m.value
+FROM chat, jsonb_array_elements($10::jsonb) WITH ORDINALITY AS m(value, ord)
+ORDER BY m.ord`
+
+const appendQuery = `
+WITH chat AS (
+	UPDATE karma_chats SET updated_at = now() WHERE chat_id = $1 RETURNING chat_id
+)
+INSERT INTO karma_chat_messages (chat_id, unique_id, message)
+SELECT chat.chat_id, m.value->>'unique_id', m.value
+FROM chat, jsonb_array_elements($2::jsonb) WITH ORDINALITY AS m(value, ord)
+ORDER BY m.ord`
+
+const listQuery = `
+SELECT c.*, (SELECT count(*) FROM karma_chat_messages m WHERE m.chat_id = c.chat_id) AS message_count
+FROM karma_chats c
+WHERE c.user_id = $1 %s
+ORDER BY c.updated_at DESC, c.chat_id
+LIMIT $2 OFFSET $3`
+
+func (s *PostgresStore) Save(ctx context.Context, userID string, history *models.AIChatHistory) error {
+	prepare(history)
+	return s.save(ctx, userID, history, "", "")
+}
+
+func (s *PostgresStore) save(ctx context.Context, userID string, h *models.AIChatHistory, parent, forkedAt string) error {
+	messages, err := json.Marshal(nonNil(h.Messages))
+	if err != nil {
+		return err
+	}
+	_, err = s.chats.ExecuteRawContext(ctx, saveQuery,
+		h.ChatId, userID, h.Title, h.Description, h.SystemMsg, h.Context,
+		parent, forkedAt, h.CreatedAt, string(messages))
+	return err
+}
+
+func (s *PostgresStore) chat(ctx context.Context, chatID string) (*chatRow, error) {
+	var rows []chatRow
+	err := s.chats.QueryRawContext(ctx, `SELECT * FROM karma_chats WHERE chat_id = $1`, chatID).Scan(&rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrChatNotFound
+	}
+	return &rows[0], nil
+}
+
+func (s *PostgresStore) Load(ctx context.Context, chatID string) (*models.AIChatHistory, error) {
+	c, err := s.chat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	var rows []messageRow
+	if err := s.messages.QueryRawContext(ctx, `SELECT message FROM karma_chat_messages WHERE chat_id = $1 ORDER BY id`, chatID).Scan(&rows); err != nil {
+		return nil, err
+	}
+	h := &models.AIChatHistory{
+		ChatId:      c.ChatId,
+		CreatedAt:   c.CreatedAt,
+		Title:       c.Title,
+		Description: c.Description,
+		SystemMsg:   c.SystemMsg,
+		Context:     c.Context,
+		Messages:    make([]models.AIMessage, 0, len(rows)),
+	}
+	for _, r := range rows {
+		h.Messages = append(h.Messages, r.Message)
+	}
+	return h, nil
+}
+
+func (s *PostgresStore) Append(ctx context.Context, chatID string, messages ...models.AIMessage) error {
+	if len(messages) == 0 {
+		_, err := s.chat(ctx, chatID)
+		return err
+	}
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+	res, err := s.messages.ExecuteRawContext(ctx, appendQuery, chatID, string(data))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrChatNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) List(ctx context.Context, userID string, page Page) ([]ChatInfo, error) {
+	return s.list(ctx, fmt.Sprintf(listQuery, ""), userID, page.limit(), page.offset())
+}
+
+func (s *PostgresStore) Search(ctx context.Context, userID, query string, page Page) ([]ChatInfo, error) {
+	q := fmt.Sprintf(listQuery, `AND c.title ILIKE $4 ESCAPE '\'`)
+	return s.list(ctx, q, userID, page.limit(), page.offset(), "%"+escapeLike(query)+"%")
+}
+
+func (s *PostgresStore) list(ctx context.Context, query string, args ...any) ([]ChatInfo, error) {
+	var rows []chatRow
+	if err := s.chats.QueryRawContext(ctx, query, args...).Scan(&rows); err != nil {
+		return nil, err
+	}
+	out := make([]ChatInfo, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, r.info())
+	}
+	return out, nil
+}
+
+func (s *PostgresStore) Fork(ctx context.Context, chatID, messageID string) (*models.AIChatHistory, error) {
+	c, err := s.chat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	h, err := s.Load(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	fork, err := forkOf(h, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(ctx, c.UserId, fork, chatID, messageID); err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, chatID string) error {
+	res, err := s.chats.ExecuteRawContext(ctx, `DELETE FROM karma_chats WHERE chat_id = $1`, chatID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrChatNotFound
+	}
+	return nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func nonNil(messages []models.AIMessage) []models.AIMessage {
+	if messages == nil {
+		return []models.AIMessage{}
+	}
+	return messages
+}
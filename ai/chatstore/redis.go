@@ -0,0 +1,267 @@
+package chatstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultRedisPrefix = "karma:chats:"
+
+// RedisStore keeps each chat as a JSON header plus a list of messages, and a
+// sorted set per user ordered by last update.
+//
+// Keys, under the prefix:
+//
+//	chat:{chatID}           chat header (JSON)
+//	chat:{chatID}:messages  list of messages (JSON)
+//	user:{userID}           sorted set of chat ids scored by update time
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// redisChat is the stored header. Messages, MessageCount and UpdatedAt live
+// in the message list and the user index.
+type redisChat struct {
+	Info    ChatInfo             `json:"info"`
+	History models.AIChatHistory `json:"history"`
+}
+
+// NewRedisStore uses client, or connects with REDIS_URL when it is nil. The
+// optional prefix replaces "karma:chats:".
+func NewRedisStore(client *redis.Client, prefix ...string) *RedisStore {
+	if client == nil {
+		client = utils.RedisConnect()
+	}
+	p := defaultRedisPrefix
+	if len(prefix) > 0 && prefix[0] != "" {
+		p = prefix[0]
+	}
+	return &RedisStore{client: client, prefix: p}
+}
+
+func (s *RedisStore) chatKey(chatID string) string {
+	return s.prefix + "chat:" + chatID
+}
+
+func (s *RedisStore) messagesKey(chatID string) string {
+	return s.prefix + "chat:" + chatID + ":messages"
+}
+
+func (s *RedisStore) userKey(userID string) string {
+	return s.prefix + "user:" + userID
+}
+
+func (s *RedisStore) Save(ctx context.Context, userID string, history *models.AIChatHistory) error {
+	prepare(history)
+	info := infoOf(userID, history, time.Now())
+	prevOwner := ""
+	if prev, err := s.header(ctx, history.ChatId); err == nil {
+		info.ParentChatId, info.ForkedAt = prev.Info.ParentChatId, prev.Info.ForkedAt
+		prevOwner = prev.Info.UserId
+	} else if !errors.Is(err, ErrChatNotFound) {
+		return err
+	}
+	return s.write(ctx, info, history, prevOwner)
+}
+
+// write stores the chat and indexes it under its owner, dropping it from
+// prevOwner's index when the chat changed hands.
+func (s *RedisStore) write(ctx context.Context, info ChatInfo, history *models.AIChatHistory, prevOwner string) error {
+	header := redisChat{Info: info, History: *history}
+	header.History.Messages = nil
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	messages, err := encodeMessages(history.Messages)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.chatKey(info.ChatId), data, 0)
+		pipe.Del(ctx, s.messagesKey(info.ChatId))
+		if len(messages) > 0 {
+			pipe.RPush(ctx, s.messagesKey(info.ChatId), messages...)
+		}
+		if prevOwner != "" && prevOwner != info.UserId {
+			pipe.ZRem(ctx, s.userKey(prevOwner), info.ChatId)
+		}
+		pipe.ZAdd(ctx, s.userKey(info.UserId), redis.Z{Score: score(info.UpdatedAt), Member: info.ChatId})
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) header(ctx context.Context, chatID string) (*redisChat, error) {
+	data, err := s.client.Get(ctx, s.chatKey(chatID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrChatNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c redisChat
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *RedisStore) Load(ctx context.Context, chatID string) (*models.AIChatHistory, error) {
+	c, err := s.header(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := s.client.LRange(ctx, s.messagesKey(chatID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	h := c.History
+	h.Messages = make([]models.AIMessage, 0, len(raw))
+	for _, r := range raw {
+		var m models.AIMessage
+		if err := json.Unmarshal([]byte(r), &m); err != nil {
+			return nil, err
+		}
+		h.Messages = append(h.Messages, m)
+	}
+	return &h, nil
+}
+
+func (s *RedisStore) Append(ctx context.Context, chatID string, messages ...models.AIMessage) error {
+	c, err := s.header(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	encoded, err := encodeMessages(messages)
+	if err != nil || len(encoded) == 0 {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, s.messagesKey(chatID), encoded...)
+		pipe.ZAdd(ctx, s.userKey(c.Info.UserId), redis.Z{Score: score(time.Now()), Member: chatID})
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) List(ctx context.Context, userID string, page Page) ([]ChatInfo, error) {
+	start := int64(page.offset())
+	entries, err := s.client.ZRevRangeWithScores(ctx, s.userKey(userID), start, start+int64(page.limit())-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.infos(ctx, entries)
+}
+
+// Search scans the user's index, since Redis has no title index.
+func (s *RedisStore) Search(ctx context.Context, userID, query string, page Page) ([]ChatInfo, error) {
+	entries, err := s.client.ZRevRangeWithScores(ctx, s.userKey(userID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	all, err := s.infos(ctx, entries)
+	if err != nil {
+		return nil, err
+	}
+	matched := all[:0]
+	for _, info := range all {
+		if titleMatches(info.Title, query) {
+			matched = append(matched, info)
+		}
+	}
+	return window(matched, page), nil
+}
+
+// infos loads the headers and message counts of index entries, skipping
+// chats deleted in the meantime.
+func (s *RedisStore) infos(ctx context.Context, entries []redis.Z) ([]ChatInfo, error) {
+	if len(entries) == 0 {
+		return []ChatInfo{}, nil
+	}
+	headers := make([]*redis.StringCmd, len(entries))
+	counts := make([]*redis.IntCmd, len(entries))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, e := range entries {
+			id := e.Member.(string)
+			headers[i] = pipe.Get(ctx, s.chatKey(id))
+			counts[i] = pipe.LLen(ctx, s.messagesKey(id))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	out := make([]ChatInfo, 0, len(entries))
+	for i, e := range entries {
+		data, err := headers[i].Bytes()
+		if err != nil {
+			continue
+		}
+		var c redisChat
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, err
+		}
+		c.Info.UpdatedAt = time.UnixMilli(int64(e.Score))
+		c.Info.MessageCount = int(counts[i].Val())
+		out = append(out, c.Info)
+	}
+	return out, nil
+}
+
+func (s *RedisStore) Fork(ctx context.Context, chatID, messageID string) (*models.AIChatHistory, error) {
+	c, err := s.header(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	h, err := s.Load(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	fork, err := forkOf(h, messageID)
+	if err != nil {
+		return nil, err
+	}
+	info := infoOf(c.Info.UserId, fork, time.Now())
+	info.ParentChatId, info.ForkedAt = chatID, messageID
+	if err := s.write(ctx, info, fork, ""); err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, chatID string) error {
+	c, err := s.header(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.chatKey(chatID), s.messagesKey(chatID))
+		pipe.ZRem(ctx, s.userKey(c.Info.UserId), chatID)
+		return nil
+	})
+	return err
+}
+
+func encodeMessages(messages []models.AIMessage) ([]any, error) {
+	out := make([]any, 0, len(messages))
+	for _, m := range messages {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, string(data))
+	}
+	return out, nil
+}
+
+func score(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
@@ -0,0 +1,139 @@
+// Package chatstore persists AIChatHistory conversations, so apps built on
+// ChatCompletionManaged do not each have to re-implement chat storage.
+//
+// Three backends are provided: Postgres through the v2 ORM, Redis, and an
+// in-memory store for tests and single-process apps. All of them support
+// forking a conversation at a message, which is how edit-and-regenerate is
+// modelled: the fork holds the history up to that message and grows
+// independently of the original.
+package chatstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/utils"
+)
+
+var (
+	// ErrChatNotFound is returned for an unknown chat id.
+	ErrChatNotFound = errors.New("chatstore: chat not found")
+	// ErrMessageNotFound is returned by Fork for a message id that is not in
+	// the chat.
+	ErrMessageNotFound = errors.New("chatstore: message not found")
+)
+
+// DefaultPageSize is used when Page.Limit is not set.
+const DefaultPageSize = 20
+
+// ChatStore saves and loads conversations keyed by AIChatHistory.ChatId.
+type ChatStore interface {
+	// Save creates or replaces a chat owned by userID. A history without a
+	// ChatId gets a generated one, written back to history.
+	Save(ctx context.Context, userID string, history *models.AIChatHistory) error
+	// Load returns the full history of a chat.
+	Load(ctx context.Context, chatID string) (*models.AIChatHistory, error)
+	// Append adds messages to the end of a chat.
+	Append(ctx context.Context, chatID string, messages ...models.AIMessage) error
+	// List returns a user's chats, most recently updated first.
+	List(ctx context.Context, userID string, page Page) ([]ChatInfo, error)
+	// Search lists a user's chats whose title contains query, ignoring case.
+	Search(ctx context.Context, userID, query string, page Page) ([]ChatInfo, error)
+	// Fork copies a chat into a new one holding the messages before
+	// messageID, so that message can be edited or regenerated. The original
+	// chat is left untouched.
+	Fork(ctx context.Context, chatID, messageID string) (*models.AIChatHistory, error)
+	// Delete removes a chat and its messages. Forks are kept.
+	Delete(ctx context.Context, chatID string) error
+}
+
+// ChatInfo describes a stored chat without its messages.
+type ChatInfo struct {
+	ChatId       string    `json:"chat_id"`
+	UserId       string    `json:"user_id"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	ParentChatId string    `json:"parent_chat_id,omitempty"` // set on forks
+	ForkedAt     string    `json:"forked_at,omitempty"`      // message id the fork was taken at
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Page selects a window of results.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+func (p Page) limit() int {
+	if p.Limit <= 0 {
+		return DefaultPageSize
+	}
+	return p.Limit
+}
+
+func (p Page) offset() int {
+	if p.Offset < 0 {
+		return 0
+	}
+	return p.Offset
+}
+
+// window applies p to a slice that is already sorted.
+func window[T any](items []T, p Page) []T {
+	start := p.offset()
+	if start >= len(items) {
+		return []T{}
+	}
+	end := min(start+p.limit(), len(items))
+	return items[start:end]
+}
+
+// prepare fills in the chat id and creation time of a history being saved.
+func prepare(history *models.AIChatHistory) {
+	if history.ChatId == "" {
+		history.ChatId = utils.GenerateID(16)
+	}
+	if history.CreatedAt.IsZero() {
+		history.CreatedAt = time.Now()
+	}
+}
+
+// forkOf builds the history of a fork of h taken at messageID.
+func forkOf(h *models.AIChatHistory, messageID string) (*models.AIChatHistory, error) {
+	at := -1
+	for i, m := range h.Messages {
+		if m.UniqueId == messageID {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return nil, ErrMessageNotFound
+	}
+	fork := *h
+	fork.ChatId = utils.GenerateID(16)
+	fork.CreatedAt = time.Now()
+	fork.Messages = append([]models.AIMessage(nil), h.Messages[:at]...)
+	return &fork, nil
+}
+
+func infoOf(userID string, h *models.AIChatHistory, updated time.Time) ChatInfo {
+	return ChatInfo{
+		ChatId:       h.ChatId,
+		UserId:       userID,
+		Title:        h.Title,
+		Description:  h.Description,
+		MessageCount: len(h.Messages),
+		CreatedAt:    h.CreatedAt,
+		UpdatedAt:    updated,
+	}
+}
+
+func titleMatches(title, query string) bool {
+	return strings.Contains(strings.ToLower(title), strings.ToLower(query))
+}
@@ -0,0 +1,184 @@
+package chatstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/v2/orm"
+	"github.com/jmoiron/sqlx"
+)
+
+func msg(id string, role models.AIRoles, text string) models.AIMessage {
+	return models.AIMessage{UniqueId: id, Role: role, Message: text}
+}
+
+func TestMemoryStoreSaveLoadAppend(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	h := &models.AIChatHistory{Title: "Trip plan", Messages: []models.AIMessage{msg("m1", models.User, "hi")}}
+	if err := s.Save(ctx, "u1", h); err != nil {
+		t.Fatal(err)
+	}
+	if h.ChatId == "" || h.CreatedAt.IsZero() {
+		t.Fatal("Save should assign a chat id and creation time")
+	}
+	h.Messages[0].Message = "mutated"
+
+	if err := s.Append(ctx, h.ChatId, msg("m2", models.Assistant, "hello")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Load(ctx, h.ChatId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Messages) != 2 || got.Messages[0].Message != "hi" || got.Title != "Trip plan" {
+		t.Fatalf("loaded %+v", got)
+	}
+	if _, err := s.Load(ctx, "missing"); !errors.Is(err, ErrChatNotFound) {
+		t.Fatalf("Load(missing) = %v", err)
+	}
+	if err := s.Append(ctx, "missing", msg("x", models.User, "")); !errors.Is(err, ErrChatNotFound) {
+		t.Fatalf("Append(missing) = %v", err)
+	}
+}
+
+func TestMemoryStoreListAndSearch(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	for i := range 5 {
+		h := &models.AIChatHistory{ChatId: fmt.Sprintf("c%d", i), Title: fmt.Sprintf("Chat %d", i)}
+		if i == 3 {
+			h.Title = "Go generics"
+		}
+		s.Save(ctx, "u1", h)
+		time.Sleep(time.Millisecond)
+	}
+	s.Save(ctx, "u2", &models.AIChatHistory{ChatId: "other", Title: "go away"})
+
+	first, _ := s.List(ctx, "u1", Page{Limit: 2})
+	second, _ := s.List(ctx, "u1", Page{Limit: 2, Offset: 2})
+	if len(first) != 2 || first[0].ChatId != "c4" || second[0].ChatId != "c2" {
+		t.Fatalf("pages = %+v / %+v", first, second)
+	}
+	if rest, _ := s.List(ctx, "u1", Page{Offset: 10}); len(rest) != 0 {
+		t.Fatalf("past the end = %+v", rest)
+	}
+
+	found, _ := s.Search(ctx, "u1", "GO", Page{})
+	if len(found) != 1 || found[0].ChatId != "c3" {
+		t.Fatalf("search = %+v", found)
+	}
+}
+
+func TestMemoryStoreFork(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	h := &models.AIChatHistory{ChatId: "orig", Title: "Poem", SystemMsg: "be lyrical", Messages: []models.AIMessage{
+		msg("m1", models.User, "write a poem"),
+		msg("m2", models.Assistant, "roses..."),
+		msg("m3", models.User, "shorter"),
+	}}
+	s.Save(ctx, "u1", h)
+
+	fork, err := s.Fork(ctx, "orig", "m2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.ChatId == "orig" || len(fork.Messages) != 1 || fork.SystemMsg != "be lyrical" {
+		t.Fatalf("fork = %+v", fork)
+	}
+	s.Append(ctx, fork.ChatId, msg("m4", models.Assistant, "violets..."))
+
+	orig, _ := s.Load(ctx, "orig")
+	if len(orig.Messages) != 3 {
+		t.Fatal("forking must not change the original")
+	}
+	chats, _ := s.List(ctx, "u1", Page{})
+	if chats[0].ChatId != fork.ChatId || chats[0].ParentChatId != "orig" || chats[0].ForkedAt != "m2" || chats[0].MessageCount != 2 {
+		t.Fatalf("fork info = %+v", chats[0])
+	}
+
+	if _, err := s.Fork(ctx, "orig", "nope"); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("Fork(unknown message) = %v", err)
+	}
+	if err := s.Delete(ctx, "orig"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(ctx, fork.ChatId); err != nil {
+		t.Fatal("deleting the parent must keep its forks")
+	}
+}
+
+func TestPostgresStoreLoad(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := NewPostgresStore(orm.WithDB(sqlx.NewDb(db, "sqlmock")))
+
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery(`SELECT \* FROM karma_chats WHERE chat_id = \$1`).WithArgs("c1").
+		WillReturnRows(sqlmock.NewRows([]string{"chat_id", "user_id", "title", "system_msg", "created_at"}).
+			AddRow("c1", "u1", "Hello", "sys", created))
+	mock.ExpectQuery(`SELECT message FROM karma_chat_messages`).WithArgs("c1").
+		WillReturnRows(sqlmock.NewRows([]string{"message"}).
+			AddRow([]byte(`{"unique_id":"m1","role":"user","message":"hi"}`)).
+			AddRow([]byte(`{"unique_id":"m2","role":"assistant","message":"hey"}`)))
+
+	h, err := s.Load(context.Background(), "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Title != "Hello" || h.SystemMsg != "sys" || !h.CreatedAt.Equal(created) {
+		t.Fatalf("chat = %+v", h)
+	}
+	if len(h.Messages) != 2 || h.Messages[1].Role != models.Assistant || h.Messages[1].Message != "hey" {
+		t.Fatalf("messages = %+v", h.Messages)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostgresStoreAppendUnknownChat(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := NewPostgresStore(orm.WithDB(sqlx.NewDb(db, "sqlmock")))
+
+	mock.ExpectExec(`UPDATE karma_chats SET updated_at`).WithArgs("nope", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err = s.Append(context.Background(), "nope", msg("m1", models.User, "hi"))
+	if !errors.Is(err, ErrChatNotFound) {
+		t.Fatalf("Append = %v", err)
+	}
+}
+
+func TestPostgresStoreHonoursContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := NewPostgresStore(orm.WithDB(sqlx.NewDb(db, "sqlmock")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Load(ctx, "c1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Load = %v, want context.Canceled", err)
+	}
+	if err := s.Save(ctx, "u1", &models.AIChatHistory{ChatId: "c1"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Save = %v, want context.Canceled", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,207 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+// AnthropicMessages is the system prompt and messages of a Messages API
+// request.
+type AnthropicMessages struct {
+	System   AnthropicContent   `json:"system,omitempty"`
+	Messages []AnthropicMessage `json:"messages"`
+}
+
+// AnthropicMessage is one user or assistant turn.
+type AnthropicMessage struct {
+	Role    string           `json:"role"`
+	Content AnthropicContent `json:"content"`
+}
+
+// AnthropicContent is a list of content blocks. It is always encoded as a
+// list, which the SDK's parameter types require, and a plain string is also
+// accepted when decoding.
+type AnthropicContent []AnthropicBlock
+
+// AnthropicBlock is a text, image, tool_use or tool_result block.
+type AnthropicBlock struct {
+	Type   string           `json:"type"`
+	Text   string           `json:"text,omitempty"`
+	Source *AnthropicSource `json:"source,omitempty"`
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// tool_result
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   AnthropicContent `json:"content,omitempty"`
+	IsError   bool             `json:"is_error,omitempty"`
+}
+
+// AnthropicSource is an image source, either base64 data or a URL.
+type AnthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+func (c AnthropicContent) MarshalJSON() ([]byte, error) {
+	if c == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]AnthropicBlock(c))
+}
+
+func (c *AnthropicContent) UnmarshalJSON(data []byte) error {
+	var blocks []AnthropicBlock
+	text, isText, err := decodeStringOr(data, &blocks)
+	if err != nil {
+		return err
+	}
+	if isText {
+		*c = textContent(text, func(t string) AnthropicBlock { return AnthropicBlock{Type: "text", Text: t} })
+		return nil
+	}
+	*c = blocks
+	return nil
+}
+
+func (c AnthropicContent) text() string {
+	var texts []string
+	for _, b := range c {
+		if b.Type == "text" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ToAnthropic renders history as Messages API input. Tool results become
+// tool_result blocks in a user turn, with consecutive results sharing one
+// turn. Images must be data URLs or http(s) URLs.
+func ToAnthropic(history models.AIChatHistory) (*AnthropicMessages, error) {
+	out := &AnthropicMessages{Messages: make([]AnthropicMessage, 0, len(history.Messages))}
+	if s := systemPrompt(history); s != "" {
+		out.System = AnthropicContent{{Type: "text", Text: s}}
+	}
+	for _, m := range history.Messages {
+		switch {
+		case m.Role == models.System:
+			continue
+		case isToolResult(m):
+			block := AnthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallId}
+			if m.Message != "" {
+				block.Content = AnthropicContent{{Type: "text", Text: m.Message}}
+			}
+			last := len(out.Messages) - 1
+			if last >= 0 && out.Messages[last].Role == "user" && isToolResultTurn(out.Messages[last].Content) {
+				out.Messages[last].Content = append(out.Messages[last].Content, block)
+				continue
+			}
+			out.Messages = append(out.Messages, AnthropicMessage{Role: "user", Content: AnthropicContent{block}})
+		case m.Role == models.Assistant:
+			msg := AnthropicMessage{Role: "assistant"}
+			if m.Message != "" || len(m.ToolCalls) == 0 {
+				msg.Content = append(msg.Content, AnthropicBlock{Type: "text", Text: m.Message})
+			}
+			for _, tc := range m.ToolCalls {
+				input, err := toolInput(tc)
+				if err != nil {
+					return nil, err
+				}
+				msg.Content = append(msg.Content, AnthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+			out.Messages = append(out.Messages, msg)
+		default:
+			msg := AnthropicMessage{Role: "user"}
+			for _, img := range m.Images {
+				src, err := anthropicImage(img)
+				if err != nil {
+					return nil, err
+				}
+				msg.Content = append(msg.Content, AnthropicBlock{Type: "image", Source: src})
+			}
+			if m.Message != "" || len(msg.Content) == 0 {
+				msg.Content = append(msg.Content, AnthropicBlock{Type: "text", Text: m.Message})
+			}
+			out.Messages = append(out.Messages, msg)
+		}
+	}
+	return out, nil
+}
+
+func isToolResultTurn(c AnthropicContent) bool {
+	for _, b := range c {
+		if b.Type != "tool_result" {
+			return false
+		}
+	}
+	return len(c) > 0
+}
+
+// IsAnthropicImage reports whether ref is an image ToAnthropic accepts: a
+// base64 data URL or an http(s) URL.
+func IsAnthropicImage(ref string) bool {
+	_, err := anthropicImage(ref)
+	return err == nil
+}
+
+func anthropicImage(ref string) (*AnthropicSource, error) {
+	if mimeType, data, ok := parseDataURL(ref); ok {
+		return &AnthropicSource{Type: "base64", MediaType: mimeType, Data: data}, nil
+	}
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		return &AnthropicSource{Type: "url", URL: ref}, nil
+	}
+	return nil, fmt.Errorf("convert: anthropic images must be base64 data URLs or http(s) URLs, got %.40q", ref)
+}
+
+// FromAnthropic parses Messages API input. Each tool_result block becomes a
+// tool message; other blocks in the same user turn follow as a user message.
+func FromAnthropic(a *AnthropicMessages) (*models.AIChatHistory, error) {
+	history := &models.AIChatHistory{SystemMsg: a.System.text()}
+	for i, msg := range a.Messages {
+		switch msg.Role {
+		case "assistant":
+			m := newMessage(models.Assistant, msg.Content.text())
+			for _, b := range msg.Content {
+				if b.Type == "tool_use" {
+					m.ToolCalls = append(m.ToolCalls, newToolCall(b.ID, b.Name, toolArguments(b.Input)))
+				}
+			}
+			history.Messages = append(history.Messages, m)
+		case "user":
+			var rest AnthropicContent
+			for _, b := range msg.Content {
+				if b.Type != "tool_result" {
+					rest = append(rest, b)
+					continue
+				}
+				m := newMessage(models.Tool, b.Content.text())
+				m.ToolCallId = b.ToolUseID
+				history.Messages = append(history.Messages, m)
+			}
+			if len(rest) == 0 && len(msg.Content) > 0 {
+				continue
+			}
+			m := newMessage(models.User, rest.text())
+			for _, b := range rest {
+				if b.Type == "image" && b.Source != nil {
+					if b.Source.Type == "url" {
+						m.Images = append(m.Images, b.Source.URL)
+					} else {
+						m.Images = append(m.Images, dataURL(b.Source.MediaType, b.Source.Data))
+					}
+				}
+			}
+			history.Messages = append(history.Messages, m)
+		default:
+			return nil, fmt.Errorf("convert: anthropic message %d has unknown role %q", i, msg.Role)
+		}
+	}
+	return history, nil
+}
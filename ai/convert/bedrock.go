@@ -0,0 +1,203 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+// BedrockConverse is the system prompt and messages of a Converse request.
+type BedrockConverse struct {
+	System   []BedrockSystemBlock `json:"system,omitempty"`
+	Messages []BedrockMessage     `json:"messages"`
+}
+
+type BedrockSystemBlock struct {
+	Text string `json:"text"`
+}
+
+// BedrockMessage is one user or assistant turn.
+type BedrockMessage struct {
+	Role    string         `json:"role"`
+	Content []BedrockBlock `json:"content"`
+}
+
+// BedrockBlock holds exactly one of its fields.
+type BedrockBlock struct {
+	Text       string             `json:"text,omitempty"`
+	Image      *BedrockImage      `json:"image,omitempty"`
+	ToolUse    *BedrockToolUse    `json:"toolUse,omitempty"`
+	ToolResult *BedrockToolResult `json:"toolResult,omitempty"`
+}
+
+// BedrockImage is an image given as base64 bytes or an S3 location.
+type BedrockImage struct {
+	Format string             `json:"format"`
+	Source BedrockImageSource `json:"source"`
+}
+
+type BedrockImageSource struct {
+	Bytes      string             `json:"bytes,omitempty"`
+	S3Location *BedrockS3Location `json:"s3Location,omitempty"`
+}
+
+type BedrockS3Location struct {
+	URI         string `json:"uri"`
+	BucketOwner string `json:"bucketOwner,omitempty"`
+}
+
+type BedrockToolUse struct {
+	ToolUseID string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+type BedrockToolResult struct {
+	ToolUseID string                     `json:"toolUseId"`
+	Content   []BedrockToolResultContent `json:"content"`
+	Status    string                     `json:"status,omitempty"`
+}
+
+type BedrockToolResultContent struct {
+	Text string          `json:"text,omitempty"`
+	JSON json.RawMessage `json:"json,omitempty"`
+}
+
+// ToBedrockConverse renders history as Converse messages. Tool results become
+// toolResult blocks in a user turn, with consecutive results sharing one turn.
+// Images must be data URLs or s3:// URIs, since Converse cannot fetch URLs.
+func ToBedrockConverse(history models.AIChatHistory) (*BedrockConverse, error) {
+	out := &BedrockConverse{Messages: make([]BedrockMessage, 0, len(history.Messages))}
+	if s := systemPrompt(history); s != "" {
+		out.System = []BedrockSystemBlock{{Text: s}}
+	}
+	for _, m := range history.Messages {
+		switch {
+		case m.Role == models.System:
+			continue
+		case isToolResult(m):
+			block := BedrockBlock{ToolResult: &BedrockToolResult{
+				ToolUseID: m.ToolCallId,
+				Content:   []BedrockToolResultContent{{Text: m.Message}},
+			}}
+			last := len(out.Messages) - 1
+			if last >= 0 && out.Messages[last].Role == "user" && out.Messages[last].Content[0].ToolResult != nil {
+				out.Messages[last].Content = append(out.Messages[last].Content, block)
+				continue
+			}
+			out.Messages = append(out.Messages, BedrockMessage{Role: "user", Content: []BedrockBlock{block}})
+		case m.Role == models.Assistant:
+			msg := BedrockMessage{Role: "assistant"}
+			if m.Message != "" || len(m.ToolCalls) == 0 {
+				msg.Content = append(msg.Content, BedrockBlock{Text: m.Message})
+			}
+			for _, tc := range m.ToolCalls {
+				input, err := toolInput(tc)
+				if err != nil {
+					return nil, err
+				}
+				msg.Content = append(msg.Content, BedrockBlock{ToolUse: &BedrockToolUse{ToolUseID: tc.ID, Name: tc.Function.Name, Input: input}})
+			}
+			out.Messages = append(out.Messages, msg)
+		default:
+			msg := BedrockMessage{Role: "user"}
+			if m.Message != "" || len(m.Images) == 0 {
+				msg.Content = append(msg.Content, BedrockBlock{Text: m.Message})
+			}
+			for _, img := range m.Images {
+				image, err := bedrockImage(img)
+				if err != nil {
+					return nil, err
+				}
+				msg.Content = append(msg.Content, BedrockBlock{Image: image})
+			}
+			out.Messages = append(out.Messages, msg)
+		}
+	}
+	return out, nil
+}
+
+func bedrockImage(ref string) (*BedrockImage, error) {
+	if mimeType, data, ok := parseDataURL(ref); ok {
+		return &BedrockImage{Format: bedrockImageFormat(mimeType), Source: BedrockImageSource{Bytes: data}}, nil
+	}
+	if strings.HasPrefix(ref, "s3://") {
+		return &BedrockImage{Format: bedrockImageFormat(mimeFromRef(ref)), Source: BedrockImageSource{S3Location: &BedrockS3Location{URI: ref}}}, nil
+	}
+	return nil, fmt.Errorf("convert: bedrock images must be base64 data URLs or s3:// URIs, got %.40q", ref)
+}
+
+func bedrockImageFormat(mimeType string) string {
+	_, sub, _ := strings.Cut(mimeType, "/")
+	if sub == "jpg" {
+		return "jpeg"
+	}
+	return sub
+}
+
+// FromBedrockConverse parses Converse messages. Each toolResult block becomes
+// a tool message; other blocks in the same user turn follow as a user message.
+func FromBedrockConverse(b *BedrockConverse) (*models.AIChatHistory, error) {
+	history := &models.AIChatHistory{}
+	system := make([]string, 0, len(b.System))
+	for _, s := range b.System {
+		system = append(system, s.Text)
+	}
+	history.SystemMsg = strings.Join(system, "\n\n")
+
+	for i, msg := range b.Messages {
+		var texts, images []string
+		var calls []models.OpenAIToolCall
+		results := 0
+		for _, block := range msg.Content {
+			switch {
+			case block.ToolUse != nil:
+				calls = append(calls, newToolCall(block.ToolUse.ToolUseID, block.ToolUse.Name, toolArguments(block.ToolUse.Input)))
+			case block.ToolResult != nil:
+				results++
+				m := newMessage(models.Tool, bedrockResultText(block.ToolResult.Content))
+				m.ToolCallId = block.ToolResult.ToolUseID
+				history.Messages = append(history.Messages, m)
+			case block.Image != nil:
+				if loc := block.Image.Source.S3Location; loc != nil {
+					images = append(images, loc.URI)
+				} else {
+					images = append(images, dataURL("image/"+block.Image.Format, block.Image.Source.Bytes))
+				}
+			default:
+				texts = append(texts, block.Text)
+			}
+		}
+		text := strings.Join(texts, "\n")
+		switch msg.Role {
+		case "assistant":
+			m := newMessage(models.Assistant, text)
+			m.ToolCalls = calls
+			history.Messages = append(history.Messages, m)
+		case "user":
+			if results > 0 && text == "" && len(images) == 0 {
+				continue
+			}
+			m := newMessage(models.User, text)
+			m.Images = images
+			history.Messages = append(history.Messages, m)
+		default:
+			return nil, fmt.Errorf("convert: bedrock message %d has unknown role %q", i, msg.Role)
+		}
+	}
+	return history, nil
+}
+
+func bedrockResultText(content []BedrockToolResultContent) string {
+	texts := make([]string, 0, len(content))
+	for _, c := range content {
+		if len(c.JSON) > 0 {
+			texts = append(texts, string(c.JSON))
+		} else {
+			texts = append(texts, c.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,201 @@
+// Package convert translates models.AIChatHistory to and from the native
+// message formats of the providers karma talks to: OpenAI Chat Completions,
+// the OpenAI Responses API, Anthropic Messages, Gemini Contents and Bedrock
+// Converse.
+//
+// The wire types mirror the JSON request bodies, so a logged request can be
+// unmarshalled straight into them (unknown fields are ignored) and an exported
+// conversation can be sent as is. System prompts, images, tool calls and tool
+// results survive a round trip through every format. Conversion is offline:
+// images referenced by URL are passed by reference and never fetched.
+//
+// History.Context, Files and message metadata have no wire equivalent and are
+// not exported. Imported messages get a fresh UniqueId and Timestamp.
+//
+// The OpenAI, Anthropic and Gemini chat handlers build their requests with
+// these converters and Decode the result into their SDK's parameter types.
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/utils"
+)
+
+// Format names a provider wire format.
+type Format string
+
+const (
+	FormatOpenAIChat      Format = "openai_chat"
+	FormatOpenAIResponses Format = "openai_responses"
+	FormatAnthropic       Format = "anthropic"
+	FormatGemini          Format = "gemini"
+	FormatBedrockConverse Format = "bedrock_converse"
+)
+
+// Export renders history as the JSON body of a request in format f.
+func Export(f Format, history models.AIChatHistory) ([]byte, error) {
+	var (
+		v   any
+		err error
+	)
+	switch f {
+	case FormatOpenAIChat:
+		v, err = ToOpenAIChat(history)
+	case FormatOpenAIResponses:
+		v, err = ToOpenAIResponses(history)
+	case FormatAnthropic:
+		v, err = ToAnthropic(history)
+	case FormatGemini:
+		v, err = ToGemini(history)
+	case FormatBedrockConverse:
+		v, err = ToBedrockConverse(history)
+	default:
+		return nil, fmt.Errorf("convert: unknown format %q", f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// Import parses a request body in format f into a chat history.
+func Import(f Format, data []byte) (*models.AIChatHistory, error) {
+	switch f {
+	case FormatOpenAIChat:
+		var c OpenAIChat
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("convert: decode %s: %w", f, err)
+		}
+		return FromOpenAIChat(&c)
+	case FormatOpenAIResponses:
+		var r OpenAIResponses
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("convert: decode %s: %w", f, err)
+		}
+		return FromOpenAIResponses(&r)
+	case FormatAnthropic:
+		var m AnthropicMessages
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("convert: decode %s: %w", f, err)
+		}
+		return FromAnthropic(&m)
+	case FormatGemini:
+		var g GeminiContents
+		if err := json.Unmarshal(data, &g); err != nil {
+			return nil, fmt.Errorf("convert: decode %s: %w", f, err)
+		}
+		return FromGemini(&g)
+	case FormatBedrockConverse:
+		var b BedrockConverse
+		if err := json.Unmarshal(data, &b); err != nil {
+			return nil, fmt.Errorf("convert: decode %s: %w", f, err)
+		}
+		return FromBedrockConverse(&b)
+	}
+	return nil, fmt.Errorf("convert: unknown format %q", f)
+}
+
+// Decode re-decodes v, one of this package's wire values, into out, a type
+// with the same JSON shape such as a provider SDK's request parameters.
+func Decode(v, out any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("convert: encode %T: %w", v, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("convert: decode into %T: %w", out, err)
+	}
+	return nil
+}
+
+// systemPrompt joins SystemMsg and any system messages, for formats that take
+// the system prompt outside the message list.
+func systemPrompt(history models.AIChatHistory) string {
+	parts := []string{}
+	if s := strings.TrimSpace(history.SystemMsg); s != "" {
+		parts = append(parts, history.SystemMsg)
+	}
+	for _, m := range history.Messages {
+		if m.Role == models.System && strings.TrimSpace(m.Message) != "" {
+			parts = append(parts, m.Message)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func isToolResult(m models.AIMessage) bool {
+	return m.Role == models.Tool || m.Role == models.Function
+}
+
+func newMessage(role models.AIRoles, text string) models.AIMessage {
+	return models.AIMessage{
+		Role:      role,
+		Message:   text,
+		Timestamp: time.Now(),
+		UniqueId:  utils.GenerateID(16),
+	}
+}
+
+func newToolCall(id, name, arguments string) models.OpenAIToolCall {
+	tc := models.OpenAIToolCall{ID: id, Type: "function"}
+	tc.Function.Name = name
+	tc.Function.Arguments = arguments
+	return tc
+}
+
+// toolInput turns a tool call's argument string into the JSON object formats
+// other than OpenAI expect.
+func toolInput(tc models.OpenAIToolCall) (json.RawMessage, error) {
+	args := strings.TrimSpace(tc.Function.Arguments)
+	if args == "" {
+		return json.RawMessage("{}"), nil
+	}
+	if !json.Valid([]byte(args)) {
+		return nil, fmt.Errorf("convert: tool call %q (%s) has invalid JSON arguments", tc.ID, tc.Function.Name)
+	}
+	return json.RawMessage(args), nil
+}
+
+// toolArguments is the inverse of toolInput.
+func toolArguments(input json.RawMessage) string {
+	if len(input) == 0 || string(input) == "null" {
+		return "{}"
+	}
+	return string(input)
+}
+
+// parseDataURL splits a base64 data URL into its MIME type and payload.
+func parseDataURL(ref string) (mimeType, data string, ok bool) {
+	if !strings.HasPrefix(ref, "data:") {
+		return "", "", false
+	}
+	meta, payload, found := strings.Cut(ref[len("data:"):], ",")
+	if !found || !strings.HasSuffix(meta, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(meta, ";base64"), payload, true
+}
+
+func dataURL(mimeType, data string) string {
+	return "data:" + mimeType + ";base64," + data
+}
+
+// mimeFromRef guesses an image MIME type from a URL's extension.
+func mimeFromRef(ref string) string {
+	u := ref
+	if i := strings.IndexAny(u, "?#"); i != -1 {
+		u = u[:i]
+	}
+	if t := mime.TypeByExtension(strings.ToLower(path.Ext(u))); t != "" {
+		t, _, _ = strings.Cut(t, ";")
+		return t
+	}
+	return "image/jpeg"
+}
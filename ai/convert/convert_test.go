@@ -0,0 +1,132 @@
+package convert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+const pixel = "data:image/png;base64,iVBORw0KGgo="
+
+func sampleHistory() models.AIChatHistory {
+	call := newToolCall("call_1", "get_weather", `{"city":"Pune"}`)
+	return models.AIChatHistory{
+		SystemMsg: "You are terse.",
+		Messages: []models.AIMessage{
+			{Role: models.User, Message: "Weather here?", Images: []string{pixel}},
+			{Role: models.Assistant, ToolCalls: []models.OpenAIToolCall{call}},
+			{Role: models.Tool, ToolCallId: "call_1", Message: "31C and sunny"},
+			{Role: models.Assistant, Message: "31C, sunny."},
+		},
+	}
+}
+
+// essentials strips what a round trip is not expected to keep.
+type essential struct {
+	Role       models.AIRoles
+	Message    string
+	Images     []string
+	ToolCallId string
+	Calls      []string
+}
+
+func essentials(h *models.AIChatHistory) []essential {
+	out := make([]essential, 0, len(h.Messages))
+	for _, m := range h.Messages {
+		e := essential{Role: m.Role, Message: m.Message, Images: m.Images, ToolCallId: m.ToolCallId}
+		for _, tc := range m.ToolCalls {
+			e.Calls = append(e.Calls, tc.ID+" "+tc.Function.Name+" "+tc.Function.Arguments)
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := sampleHistory()
+	want := essentials(&in)
+	for _, f := range []Format{FormatOpenAIChat, FormatOpenAIResponses, FormatAnthropic, FormatGemini, FormatBedrockConverse} {
+		t.Run(string(f), func(t *testing.T) {
+			data, err := Export(f, in)
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			out, err := Import(f, data)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if out.SystemMsg != in.SystemMsg {
+				t.Errorf("SystemMsg = %q", out.SystemMsg)
+			}
+			if got := essentials(out); !reflect.DeepEqual(got, want) {
+				t.Errorf("messages differ\n got: %+v\nwant: %+v\nwire: %s", got, want, data)
+			}
+			for _, m := range out.Messages {
+				if m.UniqueId == "" || m.Timestamp.IsZero() {
+					t.Fatal("imported messages need an id and timestamp")
+				}
+			}
+		})
+	}
+}
+
+func TestImportAnthropicLog(t *testing.T) {
+	body := `{
+		"model": "claude-sonnet-4-5",
+		"system": [{"type": "text", "text": "Be brief."}],
+		"messages": [
+			{"role": "user", "content": "Add 2 and 3"},
+			{"role": "assistant", "content": [
+				{"type": "text", "text": "Calling add."},
+				{"type": "tool_use", "id": "toolu_1", "name": "add", "input": {"a": 2, "b": 3}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": "5"},
+				{"type": "text", "text": "Thanks"}
+			]}
+		]
+	}`
+	h, err := Import(FormatAnthropic, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.SystemMsg != "Be brief." || len(h.Messages) != 4 {
+		t.Fatalf("history = %+v", h)
+	}
+	call := h.Messages[1].ToolCalls
+	if len(call) != 1 || call[0].Function.Arguments != `{"a": 2, "b": 3}` {
+		t.Fatalf("tool calls = %+v", call)
+	}
+	if h.Messages[2].Role != models.Tool || h.Messages[2].Message != "5" || h.Messages[3].Message != "Thanks" {
+		t.Fatalf("tool result turn = %+v / %+v", h.Messages[2], h.Messages[3])
+	}
+}
+
+func TestImportGeminiMatchesResponsesByName(t *testing.T) {
+	body := `{"contents": [
+		{"role": "user", "parts": [{"text": "hi"}]},
+		{"role": "model", "parts": [{"functionCall": {"name": "lookup", "args": {"q": "x"}}}]},
+		{"role": "user", "parts": [{"functionResponse": {"name": "lookup", "response": {"hits": 2}}}]}
+	]}`
+	h, err := Import(FormatGemini, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := h.Messages[1].ToolCalls[0].ID
+	if id == "" || h.Messages[2].ToolCallId != id || h.Messages[2].Message != `{"hits": 2}` {
+		t.Fatalf("history = %+v", essentials(h))
+	}
+}
+
+func TestExportRejectsUnreachableImages(t *testing.T) {
+	h := models.AIChatHistory{Messages: []models.AIMessage{{Role: models.User, Message: "look", Images: []string{"https://example.com/cat.png"}}}}
+	if _, err := ToBedrockConverse(h); err == nil || !strings.Contains(err.Error(), "s3://") {
+		t.Fatalf("expected an error for a URL image, got %v", err)
+	}
+	a, err := ToAnthropic(h)
+	if err != nil || a.Messages[0].Content[0].Source.URL != "https://example.com/cat.png" {
+		t.Fatalf("anthropic should pass URLs by reference: %+v, %v", a, err)
+	}
+}
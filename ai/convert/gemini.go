@@ -0,0 +1,221 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/utils"
+)
+
+// GeminiContents is the system instruction and contents of a
+// generateContent request.
+type GeminiContents struct {
+	SystemInstruction *GeminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []GeminiContent `json:"contents"`
+}
+
+// GeminiContent is one user or model turn.
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart holds exactly one of its fields.
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FileData         *GeminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiBlob is inline media; Data is base64.
+type GeminiBlob struct {
+	MIMEType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type GeminiFileData struct {
+	MIMEType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type GeminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type GeminiFunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+// ToGemini renders history as Gemini contents. Tool results become
+// functionResponse parts named after the call they answer; results that are
+// not a JSON object are wrapped as {"output": ...}, as the Gemini handler
+// does. Data URLs are sent inline and other images as fileData.
+func ToGemini(history models.AIChatHistory) (*GeminiContents, error) {
+	out := &GeminiContents{Contents: make([]GeminiContent, 0, len(history.Messages))}
+	if s := systemPrompt(history); s != "" {
+		out.SystemInstruction = &GeminiContent{Parts: []GeminiPart{{Text: s}}}
+	}
+	callNames := map[string]string{}
+	for _, m := range history.Messages {
+		switch {
+		case m.Role == models.System:
+			continue
+		case isToolResult(m):
+			name := callNames[m.ToolCallId]
+			if name == "" {
+				name = "function_response"
+			}
+			part := GeminiPart{FunctionResponse: &GeminiFunctionResponse{
+				ID:       m.ToolCallId,
+				Name:     name,
+				Response: geminiResponse(m.Message),
+			}}
+			last := len(out.Contents) - 1
+			if last >= 0 && out.Contents[last].Role == "user" && out.Contents[last].Parts[0].FunctionResponse != nil {
+				out.Contents[last].Parts = append(out.Contents[last].Parts, part)
+				continue
+			}
+			out.Contents = append(out.Contents, GeminiContent{Role: "user", Parts: []GeminiPart{part}})
+		case m.Role == models.Assistant:
+			content := GeminiContent{Role: "model"}
+			if m.Message != "" || len(m.ToolCalls) == 0 {
+				content.Parts = append(content.Parts, GeminiPart{Text: m.Message})
+			}
+			for _, tc := range m.ToolCalls {
+				args, err := toolInput(tc)
+				if err != nil {
+					return nil, err
+				}
+				callNames[tc.ID] = tc.Function.Name
+				content.Parts = append(content.Parts, GeminiPart{FunctionCall: &GeminiFunctionCall{ID: tc.ID, Name: tc.Function.Name, Args: args}})
+			}
+			out.Contents = append(out.Contents, content)
+		default:
+			content := GeminiContent{Role: "user"}
+			if m.Message != "" || len(m.Images) == 0 {
+				content.Parts = append(content.Parts, GeminiPart{Text: m.Message})
+			}
+			for _, img := range m.Images {
+				if mimeType, data, ok := parseDataURL(img); ok {
+					content.Parts = append(content.Parts, GeminiPart{InlineData: &GeminiBlob{MIMEType: mimeType, Data: data}})
+				} else {
+					content.Parts = append(content.Parts, GeminiPart{FileData: &GeminiFileData{MIMEType: mimeFromRef(img), FileURI: img}})
+				}
+			}
+			out.Contents = append(out.Contents, content)
+		}
+	}
+	return out, nil
+}
+
+func geminiResponse(text string) json.RawMessage {
+	trimmed := strings.TrimSpace(text)
+	if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed)
+	}
+	wrapped, _ := json.Marshal(map[string]string{"output": text})
+	return wrapped
+}
+
+// geminiResponseText is the inverse of geminiResponse.
+func geminiResponseText(raw json.RawMessage) string {
+	var wrapped map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &wrapped); err == nil && len(wrapped) == 1 {
+		var s string
+		if err := json.Unmarshal(wrapped["output"], &s); err == nil {
+			return s
+		}
+	}
+	return string(raw)
+}
+
+// FromGemini parses Gemini contents. Calls without an id get a generated one,
+// and responses without an id are matched to the oldest open call of the same
+// name. Thought parts are skipped.
+func FromGemini(g *GeminiContents) (*models.AIChatHistory, error) {
+	history := &models.AIChatHistory{}
+	if g.SystemInstruction != nil {
+		history.SystemMsg, _ = geminiParts(g.SystemInstruction.Parts)
+	}
+	pending := map[string][]string{}
+	for i, content := range g.Contents {
+		text, images := geminiParts(content.Parts)
+		switch content.Role {
+		case "model":
+			m := newMessage(models.Assistant, text)
+			for _, p := range content.Parts {
+				if p.FunctionCall == nil {
+					continue
+				}
+				id := p.FunctionCall.ID
+				if id == "" {
+					id = "call_" + utils.GenerateID(16)
+				}
+				pending[p.FunctionCall.Name] = append(pending[p.FunctionCall.Name], id)
+				m.ToolCalls = append(m.ToolCalls, newToolCall(id, p.FunctionCall.Name, toolArguments(p.FunctionCall.Args)))
+			}
+			history.Messages = append(history.Messages, m)
+		case "", "user", "function", "tool":
+			responses := 0
+			for _, p := range content.Parts {
+				r := p.FunctionResponse
+				if r == nil {
+					continue
+				}
+				responses++
+				id := r.ID
+				if open := pending[r.Name]; id == "" && len(open) > 0 {
+					id = open[0]
+				}
+				pending[r.Name] = removeID(pending[r.Name], id)
+				m := newMessage(models.Tool, geminiResponseText(r.Response))
+				m.ToolCallId = id
+				history.Messages = append(history.Messages, m)
+			}
+			if responses > 0 && text == "" && len(images) == 0 {
+				continue
+			}
+			m := newMessage(models.User, text)
+			m.Images = images
+			history.Messages = append(history.Messages, m)
+		default:
+			return nil, fmt.Errorf("convert: gemini content %d has unknown role %q", i, content.Role)
+		}
+	}
+	return history, nil
+}
+
+func geminiParts(parts []GeminiPart) (string, []string) {
+	var texts, images []string
+	for _, p := range parts {
+		switch {
+		case p.Thought:
+			continue
+		case p.InlineData != nil:
+			images = append(images, dataURL(p.InlineData.MIMEType, p.InlineData.Data))
+		case p.FileData != nil:
+			images = append(images, p.FileData.FileURI)
+		case p.FunctionCall == nil && p.FunctionResponse == nil:
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), images
+}
+
+func removeID(ids []string, id string) []string {
+	for i, v := range ids {
+		if v == id {
+			return append(ids[:i:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
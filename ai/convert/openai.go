@@ -0,0 +1,314 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+// OpenAIChat is the message list of a Chat Completions request.
+type OpenAIChat struct {
+	Messages []OpenAIChatMessage `json:"messages"`
+}
+
+// OpenAIChatMessage is one Chat Completions message.
+type OpenAIChatMessage struct {
+	Role       string                  `json:"role"`
+	Content    OpenAIContent           `json:"content"`
+	Name       string                  `json:"name,omitempty"`
+	ToolCalls  []models.OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string                  `json:"tool_call_id,omitempty"`
+}
+
+// OpenAIContent is message content. It is encoded as a plain string when it
+// is a single text part and accepts either form when decoding.
+type OpenAIContent []OpenAIContentPart
+
+// OpenAIContentPart is a text or image_url content part.
+type OpenAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+type OpenAIImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (c OpenAIContent) MarshalJSON() ([]byte, error) {
+	if len(c) == 0 {
+		return []byte(`""`), nil
+	}
+	if len(c) == 1 && c[0].Type == "text" {
+		return json.Marshal(c[0].Text)
+	}
+	return json.Marshal([]OpenAIContentPart(c))
+}
+
+func (c *OpenAIContent) UnmarshalJSON(data []byte) error {
+	var parts []OpenAIContentPart
+	text, isText, err := decodeStringOr(data, &parts)
+	if err != nil {
+		return err
+	}
+	if isText {
+		*c = textContent(text, func(t string) OpenAIContentPart { return OpenAIContentPart{Type: "text", Text: t} })
+		return nil
+	}
+	*c = parts
+	return nil
+}
+
+// decodeStringOr decodes data as a string, or else into parts. null decodes
+// to an empty string.
+func decodeStringOr(data []byte, parts any) (string, bool, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "null" {
+		return "", true, nil
+	}
+	if strings.HasPrefix(trimmed, `"`) {
+		var s string
+		err := json.Unmarshal(data, &s)
+		return s, true, err
+	}
+	return "", false, json.Unmarshal(data, parts)
+}
+
+func textContent[T any](text string, part func(string) T) []T {
+	if text == "" {
+		return nil
+	}
+	return []T{part(text)}
+}
+
+// ToOpenAIChat renders history as Chat Completions messages. SystemMsg
+// becomes the leading system message.
+func ToOpenAIChat(history models.AIChatHistory) (*OpenAIChat, error) {
+	out := &OpenAIChat{Messages: make([]OpenAIChatMessage, 0, len(history.Messages)+1)}
+	if strings.TrimSpace(history.SystemMsg) != "" {
+		out.Messages = append(out.Messages, OpenAIChatMessage{
+			Role:    string(models.System),
+			Content: OpenAIContent{{Type: "text", Text: history.SystemMsg}},
+		})
+	}
+	for _, m := range history.Messages {
+		msg := OpenAIChatMessage{Role: string(m.Role)}
+		switch {
+		case isToolResult(m):
+			msg.Role = string(models.Tool)
+			msg.ToolCallID = m.ToolCallId
+		case m.Role == models.Assistant:
+			// Copied so callers can adjust them without touching history,
+			// and without the streaming-only index.
+			for _, tc := range m.ToolCalls {
+				tc.Index = nil
+				msg.ToolCalls = append(msg.ToolCalls, tc)
+			}
+		}
+		if m.Message != "" {
+			msg.Content = append(msg.Content, OpenAIContentPart{Type: "text", Text: m.Message})
+		}
+		for _, img := range m.Images {
+			msg.Content = append(msg.Content, OpenAIContentPart{Type: "image_url", ImageURL: &OpenAIImageURL{URL: img}})
+		}
+		out.Messages = append(out.Messages, msg)
+	}
+	return out, nil
+}
+
+// FromOpenAIChat parses Chat Completions messages. Leading system and
+// developer messages become SystemMsg; later ones stay in the history.
+func FromOpenAIChat(c *OpenAIChat) (*models.AIChatHistory, error) {
+	history := &models.AIChatHistory{}
+	var system []string
+	for i, msg := range c.Messages {
+		text, images := openAIParts(msg.Content)
+		switch msg.Role {
+		case "system", "developer":
+			if len(history.Messages) == 0 {
+				system = append(system, text)
+				continue
+			}
+			history.Messages = append(history.Messages, newMessage(models.System, text))
+		case "user":
+			m := newMessage(models.User, text)
+			m.Images = images
+			history.Messages = append(history.Messages, m)
+		case "assistant":
+			m := newMessage(models.Assistant, text)
+			for _, tc := range msg.ToolCalls {
+				m.ToolCalls = append(m.ToolCalls, newToolCall(tc.ID, tc.Function.Name, tc.Function.Arguments))
+			}
+			history.Messages = append(history.Messages, m)
+		case "tool", "function":
+			m := newMessage(models.Tool, text)
+			m.ToolCallId = msg.ToolCallID
+			history.Messages = append(history.Messages, m)
+		default:
+			return nil, fmt.Errorf("convert: openai message %d has unknown role %q", i, msg.Role)
+		}
+	}
+	history.SystemMsg = strings.Join(system, "\n\n")
+	return history, nil
+}
+
+func openAIParts(content OpenAIContent) (string, []string) {
+	var texts, images []string
+	for _, p := range content {
+		switch {
+		case p.Type == "text" || p.Type == "input_text" || p.Type == "output_text":
+			texts = append(texts, p.Text)
+		case p.ImageURL != nil:
+			images = append(images, p.ImageURL.URL)
+		}
+	}
+	return strings.Join(texts, "\n"), images
+}
+
+// OpenAIResponses is the instructions and input of a Responses API request.
+type OpenAIResponses struct {
+	Instructions string          `json:"instructions,omitempty"`
+	Input        []ResponsesItem `json:"input"`
+}
+
+// ResponsesItem is one input item: a message, a function_call or a
+// function_call_output. Type may be empty for messages.
+type ResponsesItem struct {
+	Type      string           `json:"type,omitempty"`
+	Role      string           `json:"role,omitempty"`
+	Content   ResponsesContent `json:"content,omitempty"`
+	CallID    string           `json:"call_id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Arguments string           `json:"arguments,omitempty"`
+	Output    string           `json:"output,omitempty"`
+}
+
+// ResponsesContent is message content, encoded as a plain string when it is a
+// single text part.
+type ResponsesContent []ResponsesContentPart
+
+// ResponsesContentPart is an input_text, output_text or input_image part.
+type ResponsesContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+func (c ResponsesContent) MarshalJSON() ([]byte, error) {
+	if len(c) == 1 && c[0].Type != "input_image" {
+		return json.Marshal(c[0].Text)
+	}
+	return json.Marshal([]ResponsesContentPart(c))
+}
+
+func (c *ResponsesContent) UnmarshalJSON(data []byte) error {
+	var parts []ResponsesContentPart
+	text, isText, err := decodeStringOr(data, &parts)
+	if err != nil {
+		return err
+	}
+	if isText {
+		*c = textContent(text, func(t string) ResponsesContentPart { return ResponsesContentPart{Type: "input_text", Text: t} })
+		return nil
+	}
+	*c = parts
+	return nil
+}
+
+// ToOpenAIResponses renders history as Responses API input. System messages
+// are folded into Instructions.
+func ToOpenAIResponses(history models.AIChatHistory) (*OpenAIResponses, error) {
+	out := &OpenAIResponses{
+		Instructions: systemPrompt(history),
+		Input:        make([]ResponsesItem, 0, len(history.Messages)),
+	}
+	for _, m := range history.Messages {
+		switch {
+		case m.Role == models.System:
+			continue
+		case isToolResult(m):
+			out.Input = append(out.Input, ResponsesItem{Type: "function_call_output", CallID: m.ToolCallId, Output: m.Message})
+		case m.Role == models.Assistant:
+			if m.Message != "" || len(m.ToolCalls) == 0 {
+				out.Input = append(out.Input, ResponsesItem{
+					Type:    "message",
+					Role:    string(models.Assistant),
+					Content: ResponsesContent{{Type: "output_text", Text: m.Message}},
+				})
+			}
+			for _, tc := range m.ToolCalls {
+				out.Input = append(out.Input, ResponsesItem{
+					Type:      "function_call",
+					CallID:    tc.ID,
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				})
+			}
+		default:
+			item := ResponsesItem{Type: "message", Role: string(m.Role)}
+			if m.Message != "" || len(m.Images) == 0 {
+				item.Content = append(item.Content, ResponsesContentPart{Type: "input_text", Text: m.Message})
+			}
+			for _, img := range m.Images {
+				item.Content = append(item.Content, ResponsesContentPart{Type: "input_image", ImageURL: img})
+			}
+			out.Input = append(out.Input, item)
+		}
+	}
+	return out, nil
+}
+
+// FromOpenAIResponses parses Responses API input. Function calls are attached
+// to the assistant message before them; reasoning and other item types are
+// skipped.
+func FromOpenAIResponses(r *OpenAIResponses) (*models.AIChatHistory, error) {
+	history := &models.AIChatHistory{SystemMsg: r.Instructions}
+	for i, item := range r.Input {
+		switch item.Type {
+		case "function_call":
+			last := len(history.Messages) - 1
+			if last < 0 || history.Messages[last].Role != models.Assistant {
+				history.Messages = append(history.Messages, newMessage(models.Assistant, ""))
+				last++
+			}
+			history.Messages[last].ToolCalls = append(history.Messages[last].ToolCalls, newToolCall(item.CallID, item.Name, item.Arguments))
+		case "function_call_output":
+			m := newMessage(models.Tool, item.Output)
+			m.ToolCallId = item.CallID
+			history.Messages = append(history.Messages, m)
+		case "", "message":
+			text, images := responsesParts(item.Content)
+			switch item.Role {
+			case "system", "developer":
+				if history.SystemMsg == "" && len(history.Messages) == 0 {
+					history.SystemMsg = text
+					continue
+				}
+				history.Messages = append(history.Messages, newMessage(models.System, text))
+			case "user", "assistant":
+				m := newMessage(models.AIRoles(item.Role), text)
+				m.Images = images
+				history.Messages = append(history.Messages, m)
+			default:
+				return nil, fmt.Errorf("convert: responses item %d has unknown role %q", i, item.Role)
+			}
+		}
+	}
+	return history, nil
+}
+
+func responsesParts(content ResponsesContent) (string, []string) {
+	var texts, images []string
+	for _, p := range content {
+		switch p.Type {
+		case "input_text", "output_text", "text":
+			texts = append(texts, p.Text)
+		case "input_image":
+			images = append(images, p.ImageURL)
+		}
+	}
+	return strings.Join(texts, "\n"), images
+}
@@ -0,0 +1,321 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MelloB1989/karma/apis/claude"
+	"github.com/MelloB1989/karma/config"
+	"github.com/MelloB1989/karma/internal/openai"
+)
+
+// CredentialRequest describes the credentials a request needs.
+type CredentialRequest struct {
+	Provider Provider
+	// Model is the provider model string.
+	Model string
+	// TenantID is the tenant set with WithTenant, empty for none.
+	TenantID string
+	// EnvVar is the environment variable karma reads when no resolver is
+	// configured or the resolver returns no key.
+	EnvVar string
+}
+
+// Credentials are the provider credentials for one tenant. Empty fields fall
+// back to the instance configuration and then the environment.
+type Credentials struct {
+	APIKey string
+	// BaseURL overrides the provider endpoint (OpenAI, Anthropic and
+	// OpenAI-compatible providers).
+	BaseURL string
+	// Region is the AWS region for Bedrock.
+	Region string
+	// ProjectID and Location select a Vertex AI project for Google.
+	ProjectID string
+	Location  string
+	// ExpiresAt bounds how long a CachedCredentialResolver keeps these. Zero
+	// uses the cache TTL.
+	ExpiresAt time.Time
+}
+
+// String redacts the key so credentials are safe to log with %v.
+func (c Credentials) String() string {
+	return fmt.Sprintf("{APIKey:%s BaseURL:%s Region:%s ProjectID:%s Location:%s}",
+		RedactSecret(c.APIKey), c.BaseURL, c.Region, c.ProjectID, c.Location)
+}
+
+// GoString redacts the key for %#v.
+func (c Credentials) GoString() string {
+	return "ai.Credentials" + c.String()
+}
+
+// CredentialResolver supplies provider credentials per request, so each tenant
+// can bring its own keys. Returning nil credentials and a nil error falls back
+// to the environment. Resolvers are called on every request; wrap slow ones in
+// a CachedCredentialResolver.
+//
+// Codex authenticates with the local ChatGPT login and does not consult the
+// resolver.
+type CredentialResolver interface {
+	ResolveCredentials(ctx context.Context, req CredentialRequest) (*Credentials, error)
+}
+
+// CredentialResolverFunc adapts a function to CredentialResolver.
+type CredentialResolverFunc func(ctx context.Context, req CredentialRequest) (*Credentials, error)
+
+func (f CredentialResolverFunc) ResolveCredentials(ctx context.Context, req CredentialRequest) (*Credentials, error) {
+	return f(ctx, req)
+}
+
+var globalCredentialResolver atomic.Pointer[CredentialResolver]
+
+// SetGlobalCredentialResolver sets the resolver used by instances without
+// their own. Pass nil to go back to environment variables.
+func SetGlobalCredentialResolver(r CredentialResolver) {
+	if r == nil {
+		globalCredentialResolver.Store(nil)
+		return
+	}
+	globalCredentialResolver.Store(&r)
+}
+
+// WithCredentialResolver sets the resolver for this instance, overriding the
+// global one.
+func WithCredentialResolver(r CredentialResolver) Option {
+	return func(kai *KarmaAI) {
+		kai.CredentialResolver = r
+	}
+}
+
+// WithTenant sets the tenant passed to the credential resolver.
+func WithTenant(tenantID string) Option {
+	return func(kai *KarmaAI) {
+		kai.TenantID = tenantID
+	}
+}
+
+// providerKeyEnv is the environment variable each built-in provider reads its
+// key from.
+var providerKeyEnv = map[Provider]string{
+	OpenAI:      "OPENAI_KEY",
+	Anthropic:   "ANTHROPIC_API_KEY",
+	Google:      "GEMINI_API_KEY",
+	Bedrock:     "AWS_BEARER_TOKEN_BEDROCK",
+	XAI:         "XAI_API_KEY",
+	Groq:        "GROQ_API_KEY",
+	Sarvam:      "SARVAM_API_KEY",
+	FireworksAI: "FIREWORKS_API_KEY",
+	OpenRouter:  "OPENROUTER_API_KEY",
+	TogetherAI:  "TOGETHER_API_KEY",
+	NvidiaNIM:   "NVIDIA_API_KEY",
+}
+
+func (kai *KarmaAI) credentialResolver() CredentialResolver {
+	if kai.CredentialResolver != nil {
+		return kai.CredentialResolver
+	}
+	if r := globalCredentialResolver.Load(); r != nil {
+		return *r
+	}
+	return nil
+}
+
+// resolveCredentials asks the resolver for this request's credentials and
+// keeps them on the instance. They depend only on provider, model and tenant,
+// so concurrent requests on one instance resolve the same credentials.
+func (kai *KarmaAI) resolveCredentials() error {
+	r := kai.credentialResolver()
+	if kai.creds == nil {
+		if r != nil {
+			return errors.New("credential resolvers need an instance created with NewKarmaAI")
+		}
+		return nil
+	}
+	if r == nil {
+		kai.creds.Store(nil)
+		return nil
+	}
+	provider := kai.Model.GetModelProvider()
+	envVar := providerKeyEnv[provider]
+	if pp, ok := kai.resolveCustomProvider(); ok {
+		envVar = pp.APIKeyEnv
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	creds, err := r.ResolveCredentials(ctx, CredentialRequest{
+		Provider: provider,
+		Model:    kai.Model.GetModelString(),
+		TenantID: kai.TenantID,
+		EnvVar:   envVar,
+	})
+	if err != nil {
+		return fmt.Errorf("resolve credentials for %s: %s", provider, kai.redact(err.Error()))
+	}
+	kai.creds.Store(creds)
+	return nil
+}
+
+// credentials returns the resolved credentials, never nil.
+func (kai *KarmaAI) credentials() *Credentials {
+	if kai.creds == nil {
+		return &Credentials{}
+	}
+	if c := kai.creds.Load(); c != nil {
+		return c
+	}
+	return &Credentials{}
+}
+
+// apiKey is the resolved key, or the value of envVar.
+func (kai *KarmaAI) apiKey(envVar string) string {
+	if key := kai.credentials().APIKey; key != "" {
+		return key
+	}
+	return config.GetEnvRaw(envVar)
+}
+
+// baseURL is the resolved base URL, or def.
+func (kai *KarmaAI) baseURL(def string) string {
+	if u := kai.credentials().BaseURL; u != "" {
+		return u
+	}
+	return def
+}
+
+// CachedCredentialResolver caches another resolver's answers per provider,
+// model and tenant. Share one across instances so the cache is effective.
+type CachedCredentialResolver struct {
+	next    CredentialResolver
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[CredentialRequest]cachedCredentials
+}
+
+type cachedCredentials struct {
+	creds     *Credentials
+	expiresAt time.Time
+}
+
+// NewCachedCredentialResolver wraps next with a cache. ttl <= 0 means five
+// minutes.
+func NewCachedCredentialResolver(next CredentialResolver, ttl time.Duration) *CachedCredentialResolver {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	return &CachedCredentialResolver{
+		next:    next,
+		ttl:     ttl,
+		entries: make(map[CredentialRequest]cachedCredentials),
+	}
+}
+
+func (c *CachedCredentialResolver) ResolveCredentials(ctx context.Context, req CredentialRequest) (*Credentials, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[req]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.creds, nil
+	}
+
+	creds, err := c.next.ResolveCredentials(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	expires := now.Add(c.ttl)
+	if creds != nil && !creds.ExpiresAt.IsZero() && creds.ExpiresAt.Before(expires) {
+		expires = creds.ExpiresAt
+	}
+	c.mu.Lock()
+	c.entries[req] = cachedCredentials{creds: creds, expiresAt: expires}
+	c.mu.Unlock()
+	return creds, nil
+}
+
+// Invalidate drops cached credentials for a tenant, for example after a key
+// rotation. An empty tenantID clears the whole cache.
+func (c *CachedCredentialResolver) Invalidate(tenantID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for req := range c.entries {
+		if tenantID == "" || req.TenantID == tenantID {
+			delete(c.entries, req)
+		}
+	}
+}
+
+// RedactSecret masks a secret for display, keeping the last four characters
+// of long values.
+func RedactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) < 12 {
+		return "[REDACTED]"
+	}
+	return "[REDACTED]..." + secret[len(secret)-4:]
+}
+
+// RedactSecrets replaces each of secrets found in text with its redacted
+// form. Values shorter than eight characters are left alone.
+func RedactSecrets(text string, secrets ...string) string {
+	for _, secret := range secrets {
+		if len(secret) >= 8 && strings.Contains(text, secret) {
+			text = strings.ReplaceAll(text, secret, RedactSecret(secret))
+		}
+	}
+	return text
+}
+
+// redact scrubs the keys this instance sends from text bound for logs and
+// analytics.
+func (kai *KarmaAI) redact(text string) string {
+	return RedactSecrets(text, kai.credentials().APIKey, kai.BedrockAPIKey)
+}
+
+// bedrockAPIKey and bedrockRegion prefer the tenant's credentials over the
+// instance's static configuration.
+func (kai *KarmaAI) bedrockAPIKey() string {
+	return firstNonEmpty(kai.credentials().APIKey, kai.BedrockAPIKey)
+}
+
+func (kai *KarmaAI) bedrockRegion() string {
+	return firstNonEmpty(kai.credentials().Region, kai.BedrockRegion)
+}
+
+func (kai *KarmaAI) applyCredentialsToClaude(cc *claude.ClaudeClient) {
+	creds := kai.credentials()
+	cc.UseCredentials(creds.APIKey, creds.BaseURL)
+}
+
+// applyCredentialsToOpenAI points the OpenAI provider at the tenant's key.
+// OpenAI-compatible providers get theirs through apiKey and baseURL.
+func (kai *KarmaAI) applyCredentialsToOpenAI(o *openai.OpenAI) {
+	creds := kai.credentials()
+	if kai.Model.GetModelProvider() != OpenAI || (creds.APIKey == "" && creds.BaseURL == "") {
+		return
+	}
+	o.UseCredentials(creds.APIKey, creds.BaseURL)
+}
+
+func (kai *KarmaAI) openAICredentialOptions() []openai.CompatibleOptions {
+	creds := kai.credentials()
+	if creds.APIKey == "" {
+		return nil
+	}
+	return []openai.CompatibleOptions{{BaseURL: kai.baseURL("https://api.openai.com/v1"), API_Key: creds.APIKey}}
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
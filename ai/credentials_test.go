@@ -0,0 +1,142 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCredentialResolverSuppliesTenantKeys(t *testing.T) {
+	var got CredentialRequest
+	resolver := CredentialResolverFunc(func(ctx context.Context, req CredentialRequest) (*Credentials, error) {
+		got = req
+		return &Credentials{APIKey: "gsk-tenant-" + req.TenantID}, nil
+	})
+	kai := NewKarmaAI(Llama33_70B, Groq, WithCredentialResolver(resolver), WithTenant("acme"))
+	if err := kai.resolveCredentials(); err != nil {
+		t.Fatalf("resolveCredentials: %v", err)
+	}
+	if got.Provider != Groq || got.TenantID != "acme" || got.EnvVar != "GROQ_API_KEY" {
+		t.Fatalf("resolver saw %+v", got)
+	}
+	if key := kai.apiKey("GROQ_API_KEY"); key != "gsk-tenant-acme" {
+		t.Fatalf("apiKey = %q", key)
+	}
+	if url := kai.baseURL(GROQ_API); url != GROQ_API {
+		t.Fatalf("baseURL = %q, want the default", url)
+	}
+}
+
+// A resolver with nothing for the tenant falls back to the environment.
+func TestCredentialResolverNilFallsBackToEnv(t *testing.T) {
+	t.Setenv("GROQ_API_KEY", "from-env")
+	resolver := CredentialResolverFunc(func(ctx context.Context, req CredentialRequest) (*Credentials, error) {
+		return nil, nil
+	})
+	kai := NewKarmaAI(Llama33_70B, Groq, WithCredentialResolver(resolver))
+	if err := kai.resolveCredentials(); err != nil {
+		t.Fatalf("resolveCredentials: %v", err)
+	}
+	if key := kai.apiKey("GROQ_API_KEY"); key != "from-env" {
+		t.Fatalf("apiKey = %q, want from-env", key)
+	}
+}
+
+func TestCachedCredentialResolver(t *testing.T) {
+	calls := 0
+	cached := NewCachedCredentialResolver(CredentialResolverFunc(func(ctx context.Context, req CredentialRequest) (*Credentials, error) {
+		calls++
+		return &Credentials{APIKey: fmt.Sprintf("key-%s-%d", req.TenantID, calls)}, nil
+	}), 0)
+
+	a := CredentialRequest{Provider: OpenAI, TenantID: "a"}
+	b := CredentialRequest{Provider: OpenAI, TenantID: "b"}
+	first, _ := cached.ResolveCredentials(context.Background(), a)
+	again, _ := cached.ResolveCredentials(context.Background(), a)
+	if calls != 1 || first.APIKey != again.APIKey {
+		t.Fatalf("expected a cache hit, calls=%d", calls)
+	}
+	cached.ResolveCredentials(context.Background(), b)
+	if calls != 2 {
+		t.Fatalf("tenants must not share entries, calls=%d", calls)
+	}
+
+	cached.Invalidate("a")
+	cached.ResolveCredentials(context.Background(), a)
+	cached.ResolveCredentials(context.Background(), b)
+	if calls != 3 {
+		t.Fatalf("only tenant a should be re-resolved, calls=%d", calls)
+	}
+}
+
+func TestCredentialsAreRedacted(t *testing.T) {
+	secret := "sk-live-0123456789abcdef"
+	creds := Credentials{APIKey: secret, Region: "us-east-1"}
+	for _, out := range []string{fmt.Sprint(creds), fmt.Sprintf("%+v", creds), fmt.Sprintf("%#v", creds)} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("secret leaked: %s", out)
+		}
+	}
+
+	resolver := CredentialResolverFunc(func(ctx context.Context, req CredentialRequest) (*Credentials, error) {
+		return &Credentials{APIKey: secret}, nil
+	})
+	kai := NewKarmaAI(GPT4oMini, OpenAI, WithCredentialResolver(resolver))
+	if err := kai.resolveCredentials(); err != nil {
+		t.Fatalf("resolveCredentials: %v", err)
+	}
+	msg := kai.redact("401: invalid key " + secret)
+	if strings.Contains(msg, secret) || !strings.HasSuffix(msg, "cdef") {
+		t.Fatalf("redact = %q", msg)
+	}
+	other := NewKarmaAI(GPT4oMini, OpenAI)
+	if got := other.redact("key " + secret); got != "key "+secret {
+		t.Fatalf("an instance should only redact its own keys, got %q", got)
+	}
+
+	rotated := false
+	rotating := NewKarmaAI(GPT4oMini, OpenAI, WithCredentialResolver(CredentialResolverFunc(func(ctx context.Context, req CredentialRequest) (*Credentials, error) {
+		if rotated {
+			return nil, errors.New("vault rejected " + secret)
+		}
+		return &Credentials{APIKey: secret}, nil
+	})))
+	if err := rotating.resolveCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	rotated = true
+	if err := rotating.resolveCredentials(); err == nil || strings.Contains(err.Error(), secret) {
+		t.Fatalf("resolver error should be returned redacted, got %v", err)
+	}
+}
+
+// The tenant's credentials win over static instance configuration.
+func TestTenantCredentialsBeatInstanceConfig(t *testing.T) {
+	resolver := CredentialResolverFunc(func(ctx context.Context, req CredentialRequest) (*Credentials, error) {
+		return &Credentials{APIKey: "tenant-key-" + req.TenantID, Region: "eu-west-1"}, nil
+	})
+	kai := NewKarmaAI(Llama33_70B, Bedrock, WithCredentialResolver(resolver), WithTenant("acme"),
+		WithBedrockAPIKey("static-key"), WithBedrockRegion("us-east-1"))
+	if err := kai.resolveCredentials(); err != nil {
+		t.Fatalf("resolveCredentials: %v", err)
+	}
+	if kai.bedrockAPIKey() != "tenant-key-acme" || kai.bedrockRegion() != "eu-west-1" {
+		t.Fatalf("bedrock = (%q, %q)", kai.bedrockAPIKey(), kai.bedrockRegion())
+	}
+
+	static := NewKarmaAI(Llama33_70B, Bedrock, WithBedrockAPIKey("static-key"))
+	if static.bedrockAPIKey() != "static-key" {
+		t.Fatalf("without a tenant the instance key is used, got %q", static.bedrockAPIKey())
+	}
+
+	custom := NewKarmaAI(Llama33_70B, Provider("inhouse"), WithCredentialResolver(resolver), WithTenant("acme"),
+		WithCustomProvider("https://llm.internal/v1", "static-key"))
+	if err := custom.resolveCredentials(); err != nil {
+		t.Fatalf("resolveCredentials: %v", err)
+	}
+	if url, key, ok := custom.resolveOpenAICompatibleEndpoint(); !ok || url != "https://llm.internal/v1" || key != "tenant-key-acme" {
+		t.Fatalf("custom endpoint = (%q, %q, %v)", url, key, ok)
+	}
+}
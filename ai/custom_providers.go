@@ -126,13 +126,15 @@ func (kai *KarmaAI) resolveCustomProvider() (CustomProvider, bool) {
 // resolveOpenAICompatibleEndpoint resolves the base URL and API key to use
 // for a provider not natively known to the dispatch switch in ai.go: the
 // per-instance WithCustomProvider override takes precedence, then the
-// CustomProvider registry. ok is false if neither is configured.
+// CustomProvider registry, with the tenant's credentials ahead of either. ok
+// is false if neither is configured.
 func (kai *KarmaAI) resolveOpenAICompatibleEndpoint() (baseURL, apiKey string, ok bool) {
+	creds := kai.credentials()
 	if kai.CustomProviderBaseURL != "" {
-		return kai.CustomProviderBaseURL, kai.CustomProviderAPIKey, true
+		return firstNonEmpty(creds.BaseURL, kai.CustomProviderBaseURL), firstNonEmpty(creds.APIKey, kai.CustomProviderAPIKey), true
 	}
 	if pp, found := kai.resolveCustomProvider(); found {
-		return pp.BaseURL(), pp.ResolveAPIKey(), true
+		return firstNonEmpty(creds.BaseURL, pp.BaseURL()), firstNonEmpty(creds.APIKey, pp.ResolveAPIKey()), true
 	}
 	return "", "", false
 }
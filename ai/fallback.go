@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"sync/atomic"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+// WithFallbackModels retries failed completions on each model in turn. All
+// other settings, including a WithCustomProvider endpoint, are shared with
+// the primary model. Streams only fall back if the failed attempt had not
+// delivered any chunk yet, and managed histories are restored first, so a
+// fallback never sees the tool calls and results of a failed attempt.
+func WithFallbackModels(fallbacks ...ModelConfig) Option {
+	return func(kai *KarmaAI) {
+		kai.Fallbacks = append(kai.Fallbacks, fallbacks...)
+	}
+}
+
+// withModel returns a shallow copy of kai that uses model and has its own
+// credentials and analytics properties, leaving kai untouched for
+// concurrent requests.
+func (kai *KarmaAI) withModel(model ModelConfig) *KarmaAI {
+	c := *kai
+	c.Model = model
+	c.creds = new(atomic.Pointer[Credentials])
+	c.Analytics = kai.Analytics.clone()
+	return &c
+}
+
+// withFallbacks runs call with kai, then with a copy of kai per fallback
+// model until one succeeds. callback is passed through, wrapped to track
+// whether the caller has seen output.
+func (kai *KarmaAI) withFallbacks(callback func(chunk models.StreamedResponse) error, call func(kai *KarmaAI, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error)) (*models.AIChatResponse, error) {
+	if len(kai.Fallbacks) == 0 {
+		return call(kai, callback)
+	}
+	streamed := false
+	if callback != nil {
+		inner := callback
+		callback = func(chunk models.StreamedResponse) error {
+			streamed = true
+			return inner(chunk)
+		}
+	}
+
+	res, err := call(kai, callback)
+	if err == nil || streamed || errors.Is(err, ErrContextLengthExceeded) {
+		return res, err
+	}
+	failed := kai
+	for _, m := range kai.Fallbacks {
+		log.Printf("karma: %s failed, falling back to %s: %v", failed.Model.GetModelString(), m.GetModelString(), failed.redact(err.Error()))
+		failed = kai.withModel(m)
+		res, err = call(failed, callback)
+		if err == nil || streamed {
+			return res, err
+		}
+	}
+	return res, err
+}
+
+// historyRestorer snapshots history and returns a func that puts it back, so
+// each attempt starts from the caller's history. It is a no-op without
+// fallbacks.
+func (kai *KarmaAI) historyRestorer(history *models.AIChatHistory) func() {
+	if history == nil || len(kai.Fallbacks) == 0 {
+		return func() {}
+	}
+	saved := *history
+	saved.Messages = slices.Clone(history.Messages)
+	return func() {
+		*history = saved
+		history.Messages = slices.Clone(saved.Messages)
+	}
+}
@@ -13,17 +13,10 @@ func (f *F) EnableGrokLiveSearch(opts struct {
 	MaxSearchResults int              `json:"max_search_results"`
 	Sources          []map[string]any `json:"sources"`
 }) {
-	search_params := map[string]any{
-		"mode":             "auto",
-		"return_citations": opts.ReturnCitations,
-	}
-	if len(opts.Sources) > 0 {
-		search_params["sources"] = opts.Sources
-	}
 	if opts.MaxSearchResults == 0 {
 		opts.MaxSearchResults = 5
 	}
-	f.optionalFields["search_parameters"] = search_params
+	f.optionalFields["search_parameters"] = grokSearchParameters(opts.ReturnCitations, opts.MaxSearchResults, opts.Sources)
 }
 
 /* Example:
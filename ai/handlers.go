@@ -83,8 +83,8 @@ func (kai *KarmaAI) bedrockConverseParams(messages models.AIChatHistory) bedrock
 		Temperature: float32(kai.Temperature),
 		TopP:        float32(kai.TopP),
 		TopK:        int(kai.TopK),
-		APIKey:      kai.BedrockAPIKey,
-		Region:      kai.BedrockRegion,
+		APIKey:      kai.bedrockAPIKey(),
+		Region:      kai.bedrockRegion(),
 	}
 }
 
@@ -152,7 +152,11 @@ func (kai *KarmaAI) handleGeminiChatCompletion(messages *models.AIChatHistory) (
 		return nil, err
 	}
 
-	return buildGeminiChatResponse(chat, start)
+	res, err := buildGeminiChatResponse(chat, start)
+	if res != nil {
+		res.CacheWriteTokens = g.LastCacheWriteTokens()
+	}
+	return res, err
 }
 
 func (kai *KarmaAI) handleGeminiStreamCompletion(messages *models.AIChatHistory, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
@@ -170,11 +174,16 @@ func (kai *KarmaAI) handleGeminiStreamCompletion(messages *models.AIChatHistory,
 		return nil, err
 	}
 
-	return buildGeminiChatResponse(chat, start)
+	res, err := buildGeminiChatResponse(chat, start)
+	if res != nil {
+		res.CacheWriteTokens = g.LastCacheWriteTokens()
+	}
+	return res, err
 }
 
 func (kai *KarmaAI) handleAnthropicSinglePrompt(prompt string) (*models.AIChatResponse, error) {
 	cc := claude.NewClaudeClient(int(kai.MaxTokens), anthropic.Model(kai.Model.GetModelString()), float64(kai.Temperature), float64(kai.TopP), float64(kai.TopK), kai.SystemMessage)
+	kai.applyCredentialsToClaude(cc)
 	cc.RequestGate = kai.enforceRateLimit
 	if len(kai.MCPTools) > 0 {
 		log.Println("MCPTools are not supported for Single Prompts, please create a conversation!")
@@ -274,7 +283,7 @@ func (kai *KarmaAI) handleOpenAIEmbeddingGeneration(text string) (*models.AIEmbe
 		ctx, cancel = context.WithTimeout(context.Background(), kai.RequestTimeout)
 		defer cancel()
 	}
-	embeddings, err := openai.GenerateEmbeddingsWithContext(ctx, text, string(kai.Model.BaseModel))
+	embeddings, err := openai.GenerateEmbeddingsWithContext(ctx, text, string(kai.Model.BaseModel), kai.openAICredentialOptions()...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
 	}
@@ -305,7 +314,7 @@ func (kai *KarmaAI) handleBedrockEmbeddingGeneration(text string) (*models.AIEmb
 		context.Background(),
 		text,
 		kai.Model.GetModelString(),
-		bedrock.ClientOptions{Region: kai.BedrockRegion, APIKey: kai.BedrockAPIKey},
+		bedrock.ClientOptions{Region: kai.bedrockRegion(), APIKey: kai.bedrockAPIKey()},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Bedrock embeddings: %w", err)
@@ -325,9 +334,12 @@ func (kai *KarmaAI) handleBedrockEmbeddingGeneration(text string) (*models.AIEmb
 func (kai *KarmaAI) configureOpenAIClient(o *openai.OpenAI) {
 	kai.configureOpenaiClientForMCP(o)
 	o.ExtraFields = kai.Features.optionalFields
+	kai.applyWebSearchToOpenAI(o)
 	o.ReasoningEffort = kai.ReasoningEffort
 	o.RequestGate = kai.enforceRateLimit
 	o.RequestTimeout = kai.RequestTimeout
+	kai.applyCredentialsToOpenAI(o)
+	kai.applyCacheToOpenAI(o)
 	o.ApplyRequestTimeout()
 }
 
@@ -370,6 +382,12 @@ func buildOpenAIChatResponse(chat *oai.ChatCompletion, startTime time.Time) (*mo
 		InputTokens:  int(chat.Usage.PromptTokens),
 		OutputTokens: int(chat.Usage.CompletionTokens),
 		TimeTaken:    int(time.Since(startTime).Milliseconds()),
+		// OpenAI caches automatically and does not bill cache writes separately.
+		CacheReadTokens: int(chat.Usage.PromptTokensDetails.CachedTokens),
+	}
+
+	if raw, ok := chat.JSON.ExtraFields["citations"]; ok {
+		res.Citations = grokCitations(raw.Raw())
 	}
 
 	if len(chat.Choices[0].Message.ToolCalls) > 0 {
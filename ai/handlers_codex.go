@@ -31,6 +31,7 @@ func (kai *KarmaAI) handleCodexChatCompletion(history *models.AIChatHistory) (*m
 	instructions := kai.codexInstructions(history)
 	messages := kai.codexMessages(history)
 	tools, toolNames := kai.codexTools()
+	tools = append(tools, kai.codexWebSearchTools()...)
 	execEnabled := kai.ToolsEnabled && kai.UseMCPExecution && len(tools) > 0
 
 	maxPasses := kai.MaxToolPasses
@@ -88,6 +89,7 @@ func (kai *KarmaAI) handleCodexStreamCompletion(history *models.AIChatHistory, c
 	defer cancel()
 
 	tools, toolNames := kai.codexTools()
+	tools = append(tools, kai.codexWebSearchTools()...)
 	req := codex.BuildRequest(codex.RequestOptions{
 		Model:           kai.Model.GetModelString(),
 		Instructions:    kai.codexInstructions(history),
@@ -413,6 +415,9 @@ func codexResult(r *codex.Result, nameMap map[string]string, start time.Time) *m
 		OutputTokens: r.Usage.OutputTokens,
 		Tokens:       r.Usage.InputTokens + r.Usage.OutputTokens,
 		TimeTaken:    int(time.Since(start).Milliseconds()),
+		Reasoning:    r.Reasoning,
+		ResponseID:   r.ResponseID,
+		Citations:    codexCitations(r.Citations),
 	}
 	for _, tc := range r.ToolCalls {
 		res.ToolCalls = append(res.ToolCalls, models.ToolCall{
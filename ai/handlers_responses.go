@@ -0,0 +1,370 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/internal/codex"
+	internalopenai "github.com/MelloB1989/karma/internal/openai"
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/utils"
+)
+
+// ResponseIDMetadataKey is the AIMessage.Metadata key under which the OpenAI
+// Responses backend records the response id of each assistant turn it appends
+// to a managed history. The next request chains from the latest one.
+const ResponseIDMetadataKey = "openai_response_id"
+
+// ResponsesOptions switches the OpenAI provider from Chat Completions to the
+// Responses API (/v1/responses).
+type ResponsesOptions struct {
+	// Chain stores responses server-side and continues conversations with
+	// previous_response_id, so only new messages are sent each turn.
+	Chain bool `json:"chain"`
+	// PreviousResponseID chains onto a known response. Only the messages after
+	// the last assistant message in the history are sent. Implies Chain.
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
+	// ReasoningSummary requests reasoning summaries ("auto", "concise" or
+	// "detailed"), returned in AIChatResponse.Reasoning.
+	ReasoningSummary string `json:"reasoning_summary,omitempty"`
+	// Built-in tools run by OpenAI. They are sent whether or not ToolsEnabled
+	// is set, since nothing executes locally.
+	WebSearch       *ResponsesWebSearch  `json:"web_search,omitempty"`
+	FileSearch      *ResponsesFileSearch `json:"file_search,omitempty"`
+	CodeInterpreter bool                 `json:"code_interpreter"`
+}
+
+// ResponsesWebSearch configures the built-in web_search tool.
+type ResponsesWebSearch struct {
+	SearchContextSize string         `json:"search_context_size,omitempty"` // "low" | "medium" | "high"
+	UserLocation      map[string]any `json:"user_location,omitempty"`
+	AllowedDomains    []string       `json:"allowed_domains,omitempty"`
+}
+
+// ResponsesFileSearch configures the built-in file_search tool.
+type ResponsesFileSearch struct {
+	VectorStoreIDs []string `json:"vector_store_ids"`
+	MaxNumResults  int      `json:"max_num_results,omitempty"`
+}
+
+// WithResponsesAPI routes OpenAI requests through the Responses API.
+func WithResponsesAPI(opts ResponsesOptions) Option {
+	return func(kai *KarmaAI) {
+		kai.Responses = &opts
+		kai.syncWebSearchTool()
+	}
+}
+
+// WithResponsesWebSearch enables the Responses API web_search tool.
+func WithResponsesWebSearch(opts ResponsesWebSearch) Option {
+	return func(kai *KarmaAI) {
+		kai.responsesOptions().WebSearch = &opts
+	}
+}
+
+// WithResponsesFileSearch enables the Responses API file_search tool over the
+// given vector stores.
+func WithResponsesFileSearch(vectorStoreIDs ...string) Option {
+	return func(kai *KarmaAI) {
+		kai.responsesOptions().FileSearch = &ResponsesFileSearch{VectorStoreIDs: vectorStoreIDs}
+	}
+}
+
+// WithResponsesCodeInterpreter enables the Responses API code_interpreter tool
+// with an automatically managed container.
+func WithResponsesCodeInterpreter() Option {
+	return func(kai *KarmaAI) {
+		kai.responsesOptions().CodeInterpreter = true
+	}
+}
+
+func (kai *KarmaAI) responsesOptions() *ResponsesOptions {
+	if kai.Responses == nil {
+		kai.Responses = &ResponsesOptions{}
+		kai.syncWebSearchTool()
+	}
+	return kai.Responses
+}
+
+// useResponsesAPI reports whether OpenAI requests go to the Responses API.
+// Only WithResponsesAPI (or one of its tool options) selects it; WithWebSearch
+// alone keeps Chat Completions with the fallback search tool.
+func (kai *KarmaAI) useResponsesAPI() bool {
+	return kai.Responses != nil
+}
+
+// responsesConfig is the effective Responses configuration, with WithWebSearch
+// mapped onto the built-in web_search tool.
+func (kai *KarmaAI) responsesConfig() *ResponsesOptions {
+	var opts ResponsesOptions
+	if kai.Responses != nil {
+		opts = *kai.Responses
+	}
+	if opts.WebSearch == nil && kai.nativeWebSearch() {
+		opts.WebSearch = kai.WebSearch.responsesTool()
+	}
+	return &opts
+}
+
+func (o *ResponsesOptions) store() bool {
+	return o.Chain || o.PreviousResponseID != ""
+}
+
+// builtinTools renders the configured built-in tools in the Responses format.
+func (o *ResponsesOptions) builtinTools() []codex.Tool {
+	var tools []codex.Tool
+	if o.WebSearch != nil {
+		t := codex.Tool{
+			Type:              "web_search",
+			SearchContextSize: o.WebSearch.SearchContextSize,
+			UserLocation:      o.WebSearch.UserLocation,
+		}
+		if len(o.WebSearch.AllowedDomains) > 0 {
+			t.Filters = map[string]any{"allowed_domains": o.WebSearch.AllowedDomains}
+		}
+		tools = append(tools, t)
+	}
+	if o.FileSearch != nil && len(o.FileSearch.VectorStoreIDs) > 0 {
+		tools = append(tools, codex.Tool{
+			Type:           "file_search",
+			VectorStoreIDs: o.FileSearch.VectorStoreIDs,
+			MaxNumResults:  o.FileSearch.MaxNumResults,
+		})
+	}
+	if o.CodeInterpreter {
+		tools = append(tools, codex.Tool{Type: "code_interpreter", Container: map[string]any{"type": "auto"}})
+	}
+	return tools
+}
+
+// responsesChain returns the response id to chain from and the index of the
+// first history message the server has not seen yet.
+func (kai *KarmaAI) responsesChain(history *models.AIChatHistory) (string, int) {
+	opts := kai.responsesConfig()
+	if !opts.store() {
+		return "", 0
+	}
+	for i := len(history.Messages) - 1; i >= 0; i-- {
+		if id, ok := history.Messages[i].Metadata[ResponseIDMetadataKey].(string); ok && id != "" {
+			return id, i + 1
+		}
+	}
+	if opts.PreviousResponseID != "" {
+		for i := len(history.Messages) - 1; i >= 0; i-- {
+			if history.Messages[i].Role == models.Assistant {
+				return opts.PreviousResponseID, i + 1
+			}
+		}
+		return opts.PreviousResponseID, 0
+	}
+	return "", 0
+}
+
+func (kai *KarmaAI) newResponsesClient() *internalopenai.ResponsesClient {
+	creds := kai.credentials()
+	client := internalopenai.NewResponsesClient(creds.BaseURL, creds.APIKey, kai.RequestTimeout)
+	client.RequestGate = kai.enforceRateLimit
+	return client
+}
+
+func (kai *KarmaAI) responsesRequest(instructions string, messages []codex.Message, tools []codex.Tool, prevID string) *codex.ResponsesRequest {
+	opts := kai.responsesConfig()
+	req := codex.BuildRequest(codex.RequestOptions{
+		Model:            kai.Model.GetModelString(),
+		Instructions:     instructions,
+		Messages:         messages,
+		Tools:            append(opts.builtinTools(), tools...),
+		ReasoningEffort:  kai.codexReasoningEffort(),
+		ReasoningSummary: opts.ReasoningSummary,
+	})
+	req.Store = opts.store()
+	req.PreviousResponseID = prevID
+	req.MaxOutputTokens = kai.MaxTokens
+	req.Text = responsesTextFormat(kai.ResponseType)
+	if kai.codexReasoningEffort() == "" {
+		// Reasoning models reject sampling parameters.
+		if kai.Temperature > 0 {
+			t := float64(kai.Temperature)
+			req.Temperature = &t
+		}
+		if kai.TopP > 0 && kai.TopP < 1 {
+			p := float64(kai.TopP)
+			req.TopP = &p
+		}
+	}
+	if kai.Cache != nil && !kai.Cache.Disabled {
+		req.PromptCacheKey = kai.promptCacheKey()
+	}
+	return req
+}
+
+// responsesTextFormat maps WithResponseType onto the Responses text format:
+// a JSON schema object requests structured output, and "application/json" (or
+// "json") requests JSON mode.
+func responsesTextFormat(responseType string) *codex.TextFormat {
+	responseType = strings.TrimSpace(responseType)
+	var f codex.TextFormat
+	switch {
+	case responseType == "":
+		return nil
+	case strings.HasPrefix(responseType, "{"):
+		var schema map[string]any
+		if err := json.Unmarshal([]byte(responseType), &schema); err != nil {
+			return nil
+		}
+		strict := true
+		f.Format.Type, f.Format.Name, f.Format.Schema, f.Format.Strict = "json_schema", "response", schema, &strict
+	case responseType == "application/json", responseType == "json", responseType == "json_object":
+		f.Format.Type = "json_object"
+	default:
+		return nil
+	}
+	return &f
+}
+
+// handleOpenAIResponsesCompletion runs a chat completion on the Responses API,
+// executing Go function and MCP tools locally between passes.
+func (kai *KarmaAI) handleOpenAIResponsesCompletion(history *models.AIChatHistory) (*models.AIChatResponse, error) {
+	start := time.Now()
+	client := kai.newResponsesClient()
+	ctx, cancel := kai.codexContext()
+	defer cancel()
+
+	prevID, from := kai.responsesChain(history)
+	instructions := kai.codexInstructions(history)
+	messages := kai.codexMessages(&models.AIChatHistory{Messages: history.Messages[from:]})
+	tools, toolNames := kai.codexTools()
+	execEnabled := kai.ToolsEnabled && kai.UseMCPExecution && len(tools) > 0
+
+	maxPasses := kai.MaxToolPasses
+	if maxPasses <= 0 {
+		maxPasses = 1
+	}
+
+	var final *codex.Result
+	for pass := 0; pass <= maxPasses; pass++ {
+		result, err := client.Generate(ctx, kai.responsesRequest(instructions, messages, tools, prevID), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		final = result
+
+		if len(result.ToolCalls) == 0 || !execEnabled {
+			break
+		}
+		outputs := kai.runResponsesTools(ctx, history, result, toolNames)
+		if kai.responsesConfig().store() && result.ResponseID != "" {
+			// The stored response already holds the conversation and the
+			// tool calls; only the outputs need to be sent.
+			prevID, messages = result.ResponseID, outputs
+		} else {
+			messages = append(messages, codexAssistantTurn(result))
+			messages = append(messages, outputs...)
+		}
+	}
+
+	appendResponsesTurn(history, final, toolNames)
+	return responsesResult(final, toolNames, start), nil
+}
+
+// handleOpenAIResponsesStreamCompletion streams text and reasoning-summary
+// deltas via callback. Tool calls are surfaced in the returned response and
+// recorded in history without local execution.
+func (kai *KarmaAI) handleOpenAIResponsesStreamCompletion(history *models.AIChatHistory, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+	start := time.Now()
+	client := kai.newResponsesClient()
+	ctx, cancel := kai.codexContext()
+	defer cancel()
+
+	prevID, from := kai.responsesChain(history)
+	tools, toolNames := kai.codexTools()
+	req := kai.responsesRequest(
+		kai.codexInstructions(history),
+		kai.codexMessages(&models.AIChatHistory{Messages: history.Messages[from:]}),
+		tools,
+		prevID,
+	)
+	onText := func(delta string) error {
+		return callback(models.StreamedResponse{AIResponse: delta, TimeTaken: -1})
+	}
+	onReasoning := func(delta string) error {
+		return callback(models.StreamedResponse{Reasoning: delta, TimeTaken: -1})
+	}
+	result, err := client.Generate(ctx, req, onText, onReasoning)
+	if err != nil {
+		return nil, err
+	}
+	appendResponsesTurn(history, result, toolNames)
+	return responsesResult(result, toolNames, start), nil
+}
+
+// runResponsesTools executes every tool call of result, recording the calls and
+// their outputs in history, and returns the outputs as tool messages.
+func (kai *KarmaAI) runResponsesTools(ctx context.Context, history *models.AIChatHistory, result *codex.Result, toolNames map[string]string) []codex.Message {
+	history.Messages = append(history.Messages, models.AIMessage{
+		Role:      models.Assistant,
+		Message:   result.Text,
+		ToolCalls: responsesToolCalls(result, toolNames),
+		Timestamp: time.Now(),
+		UniqueId:  utils.GenerateID(16),
+	})
+
+	outputs := make([]codex.Message, 0, len(result.ToolCalls))
+	for _, tc := range result.ToolCalls {
+		out, err := kai.executeCodexTool(ctx, restoreToolName(toolNames, tc.Name), tc.Arguments)
+		if err != nil {
+			out = fmt.Sprintf("Error: %v", err)
+		}
+		outputs = append(outputs, codex.Message{Role: "tool", ToolCallID: tc.ID, Content: out})
+		history.Messages = append(history.Messages, models.AIMessage{
+			Role:       models.Tool,
+			Message:    out,
+			ToolCallId: tc.ID,
+			Timestamp:  time.Now(),
+			UniqueId:   utils.GenerateID(16),
+		})
+	}
+	return outputs
+}
+
+// responsesToolCalls converts the tool calls of r for the history, restoring
+// the original tool names.
+func responsesToolCalls(r *codex.Result, toolNames map[string]string) []models.OpenAIToolCall {
+	var calls []models.OpenAIToolCall
+	for _, tc := range r.ToolCalls {
+		call := models.OpenAIToolCall{ID: tc.ID, Type: "function"}
+		call.Function.Name = restoreToolName(toolNames, tc.Name)
+		call.Function.Arguments = tc.Arguments
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// appendResponsesTurn records the final assistant turn with any tool calls
+// left unexecuted, tagged with its response id so the next request can chain
+// from it.
+func appendResponsesTurn(history *models.AIChatHistory, r *codex.Result, toolNames map[string]string) {
+	if r == nil {
+		return
+	}
+	msg := models.AIMessage{
+		Role:      models.Assistant,
+		Message:   r.Text,
+		ToolCalls: responsesToolCalls(r, toolNames),
+		Timestamp: time.Now(),
+		UniqueId:  utils.GenerateID(16),
+	}
+	if r.ResponseID != "" {
+		msg.Metadata = map[string]any{ResponseIDMetadataKey: r.ResponseID}
+	}
+	history.Messages = append(history.Messages, msg)
+}
+
+func responsesResult(r *codex.Result, nameMap map[string]string, start time.Time) *models.AIChatResponse {
+	res := codexResult(r, nameMap, start)
+	res.CacheReadTokens = r.Usage.CachedTokens
+	return res
+}
@@ -0,0 +1,460 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/apis/gemini"
+	"github.com/MelloB1989/karma/apis/segmind"
+	"github.com/MelloB1989/karma/config"
+	"github.com/MelloB1989/karma/internal/openai"
+	"github.com/MelloB1989/karma/models"
+)
+
+// ImageInput is an input image given as bytes, a file path or a URL. Set
+// exactly one of them.
+type ImageInput struct {
+	Bytes []byte
+	Path  string
+	URL   string
+	// MimeType is detected from the content when empty.
+	MimeType string
+}
+
+func ImageFromBytes(data []byte) ImageInput { return ImageInput{Bytes: data} }
+func ImageFromFile(path string) ImageInput  { return ImageInput{Path: path} }
+func ImageFromURL(url string) ImageInput    { return ImageInput{URL: url} }
+
+var imageDownloadClient = &http.Client{Timeout: time.Minute}
+
+// imageMaxBytes bounds downloaded input images.
+const imageMaxBytes = 50 << 20
+
+// load returns the image bytes and MIME type.
+func (in ImageInput) load() ([]byte, string, error) {
+	var data []byte
+	var err error
+	switch {
+	case len(in.Bytes) > 0:
+		data = in.Bytes
+	case in.Path != "":
+		data, err = os.ReadFile(in.Path)
+	case in.URL != "":
+		data, err = downloadImage(in.URL)
+	default:
+		return nil, "", errors.New("image input has no bytes, path or url")
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	mime := in.MimeType
+	if mime == "" {
+		mime = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mime, "image/") {
+		return nil, "", fmt.Errorf("input is %s, not an image", mime)
+	}
+	return data, mime, nil
+}
+
+func downloadImage(url string) ([]byte, error) {
+	resp, err := imageDownloadClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download image: status code %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, imageMaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	if len(data) > imageMaxBytes {
+		return nil, fmt.Errorf("failed to download image: larger than %d bytes", imageMaxBytes)
+	}
+	return data, nil
+}
+
+type ImageEditMode string
+
+const (
+	// ImageEditModeEdit changes the images as the prompt describes.
+	ImageEditModeEdit ImageEditMode = "edit"
+	// ImageEditModeInpaint regenerates the masked area.
+	ImageEditModeInpaint ImageEditMode = "inpaint"
+	// ImageEditModeOutpaint extends the image beyond its borders.
+	ImageEditModeOutpaint ImageEditMode = "outpaint"
+	// ImageEditModeVariation creates a similar image; Prompt is optional.
+	ImageEditModeVariation ImageEditMode = "variation"
+	// ImageEditModeRemoveBackground keeps the subject on a transparent or
+	// plain background.
+	ImageEditModeRemoveBackground ImageEditMode = "remove_background"
+)
+
+// OutpaintExpansion is how many pixels to add on each side.
+type OutpaintExpansion struct {
+	Left, Right, Top, Bottom int
+}
+
+func (e OutpaintExpansion) empty() bool {
+	return e.Left <= 0 && e.Right <= 0 && e.Top <= 0 && e.Bottom <= 0
+}
+
+// ImageEditRequest is a request for EditImage.
+type ImageEditRequest struct {
+	Mode   ImageEditMode
+	Prompt string
+	// Images are edited together; most modes use only the first.
+	Images []ImageInput
+	// Mask marks the area to regenerate with transparent pixels, as in the
+	// OpenAI API. It is converted for providers that use black and white
+	// masks. Required for ImageEditModeInpaint.
+	Mask *ImageInput
+	// Expand is required for ImageEditModeOutpaint.
+	Expand OutpaintExpansion
+	// Strength is how far Segmind image-to-image edits may move away from
+	// the input, from 0 to 1. Zero uses 0.6.
+	Strength float64
+}
+
+func (r ImageEditRequest) validate() error {
+	if len(r.Images) == 0 {
+		return errors.New("image edit needs at least one input image")
+	}
+	switch r.Mode {
+	case ImageEditModeEdit:
+		if r.Prompt == "" {
+			return errors.New("image edit needs a prompt")
+		}
+	case ImageEditModeInpaint:
+		if r.Mask == nil {
+			return errors.New("inpainting needs a mask")
+		}
+	case ImageEditModeOutpaint:
+		if r.Expand.empty() {
+			return errors.New("outpainting needs a positive expansion on at least one side")
+		}
+	case ImageEditModeVariation, ImageEditModeRemoveBackground:
+	default:
+		return fmt.Errorf("unknown image edit mode %q", r.Mode)
+	}
+	if r.Strength < 0 || r.Strength > 1 {
+		return fmt.Errorf("image edit strength %v is outside 0 to 1", r.Strength)
+	}
+	return nil
+}
+
+// InpaintImage regenerates the transparent area of mask in img.
+func (ki *KarmaImageGen) InpaintImage(img, mask ImageInput, prompt string) (*models.AIImageResponse, error) {
+	return ki.EditImage(ImageEditRequest{Mode: ImageEditModeInpaint, Prompt: prompt, Images: []ImageInput{img}, Mask: &mask})
+}
+
+// OutpaintImage extends img by expand, guided by prompt.
+func (ki *KarmaImageGen) OutpaintImage(img ImageInput, expand OutpaintExpansion, prompt string) (*models.AIImageResponse, error) {
+	return ki.EditImage(ImageEditRequest{Mode: ImageEditModeOutpaint, Prompt: prompt, Images: []ImageInput{img}, Expand: expand})
+}
+
+// ImageVariation creates a variation of img.
+func (ki *KarmaImageGen) ImageVariation(img ImageInput) (*models.AIImageResponse, error) {
+	return ki.EditImage(ImageEditRequest{Mode: ImageEditModeVariation, Images: []ImageInput{img}})
+}
+
+// RemoveBackground removes the background of img.
+func (ki *KarmaImageGen) RemoveBackground(img ImageInput) (*models.AIImageResponse, error) {
+	return ki.EditImage(ImageEditRequest{Mode: ImageEditModeRemoveBackground, Images: []ImageInput{img}})
+}
+
+// EditImage edits images with GPT_1_IMAGE, DALL_E_2, the Gemini image models
+// or Segmind. Modes a provider has no endpoint for are expressed as
+// instructions where the model follows them, and rejected otherwise. When the
+// provider blocks the request, the response carries its safety verdict
+// together with the error.
+func (ki *KarmaImageGen) EditImage(req ImageEditRequest) (*models.AIImageResponse, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	in, err := loadEditInputs(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case ki.Model == GPT_1_IMAGE || ki.Model == DALL_E_2:
+		return ki.editOpenAIImage(req, in)
+	case ki.Model == GEMINI_NANO_BANANA || ki.Model == GEMINI_3_PRO_IMAGE:
+		return ki.editGeminiImage(req, in)
+	case strings.HasPrefix(string(ki.Model), "segmind-"):
+		return ki.editSegmindImage(req, in)
+	}
+	return nil, fmt.Errorf("image editing is not supported for %s", ki.Model)
+}
+
+// editInputs are the loaded images. For outpainting the first image is
+// already padded and mask covers the new border.
+type editInputs struct {
+	images []openai.ImageFile
+	mask   *openai.ImageFile
+}
+
+func loadEditInputs(req ImageEditRequest) (*editInputs, error) {
+	in := &editInputs{}
+	for i, img := range req.Images {
+		data, mime, err := img.load()
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i, err)
+		}
+		in.images = append(in.images, openai.ImageFile{Data: data, MimeType: mime})
+	}
+	if req.Mask != nil {
+		data, mime, err := req.Mask.load()
+		if err != nil {
+			return nil, fmt.Errorf("mask: %w", err)
+		}
+		in.mask = &openai.ImageFile{Data: data, MimeType: mime}
+	}
+	if req.Mode == ImageEditModeOutpaint {
+		canvas, mask, err := padImage(in.images[0].Data, req.Expand)
+		if err != nil {
+			return nil, err
+		}
+		in.images[0] = openai.ImageFile{Data: canvas, MimeType: "image/png"}
+		in.mask = &openai.ImageFile{Data: mask, MimeType: "image/png"}
+	}
+	return in, nil
+}
+
+const (
+	outpaintInstruction   = "Extend the scene naturally into the empty border around the image, matching its style and lighting."
+	variationInstruction  = "Create a variation of this image with the same subject, composition and style."
+	backgroundInstruction = "Remove the background, keeping the main subject exactly as it is."
+)
+
+// instruction is the prompt for modes that providers take as text.
+func (ki *KarmaImageGen) instruction(req ImageEditRequest) string {
+	var base string
+	switch req.Mode {
+	case ImageEditModeOutpaint:
+		base = outpaintInstruction
+	case ImageEditModeVariation:
+		base = variationInstruction
+	case ImageEditModeRemoveBackground:
+		base = backgroundInstruction
+	}
+	return strings.TrimSpace(strings.Join([]string{ki.UserPrePrompt, base, req.Prompt}, " "))
+}
+
+func (ki *KarmaImageGen) outputDir() string {
+	if ki.OutputDirectory == "" {
+		return "./images"
+	}
+	return ki.OutputDirectory
+}
+
+func (ki *KarmaImageGen) editOpenAIImage(req ImageEditRequest, in *editInputs) (*models.AIImageResponse, error) {
+	params := openai.ImageEditParams{
+		Prompt: ki.instruction(req),
+		Model:  string(ki.Model),
+		Images: in.images,
+		Mask:   in.mask,
+	}
+	switch req.Mode {
+	case ImageEditModeVariation:
+		// dall-e-2 has a variations endpoint; GPT image models take it as an edit.
+		params.Variation = ki.Model == DALL_E_2
+	case ImageEditModeRemoveBackground:
+		if ki.Model == DALL_E_2 {
+			return nil, errors.New("dall-e-2 cannot remove backgrounds")
+		}
+		params.Background = "transparent"
+	}
+	return openai.EditImage(params, ki.outputDir())
+}
+
+func (ki *KarmaImageGen) editGeminiImage(req ImageEditRequest, in *editInputs) (*models.AIImageResponse, error) {
+	prompt := ki.instruction(req)
+	images := make([]gemini.InputImage, 0, len(in.images)+1)
+	for _, img := range in.images {
+		images = append(images, gemini.InputImage{Data: img.Data, MIMEType: img.MimeType})
+	}
+	// Gemini has no mask input: send it as a black and white image and say
+	// what it is.
+	if in.mask != nil && req.Mode != ImageEditModeOutpaint {
+		mask, err := blackWhiteMask(in.mask.Data)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, gemini.InputImage{Data: mask, MIMEType: "image/png"})
+		prompt += " The last image is a mask: change only the area that is white in the mask and keep everything else identical."
+	}
+	return gemini.EditImageWithConfig(prompt, string(ki.Model), ki.outputDir(), images, ki.geminiOptions()...)
+}
+
+// segmindEditStrength is the image-to-image strength when the request sets
+// none.
+const segmindEditStrength = 0.6
+
+// segmindEdit picks the Segmind model for req and builds its request body.
+func (ki *KarmaImageGen) segmindEdit(req ImageEditRequest, in *editInputs) (segmind.SegmindModels, map[string]any, error) {
+	image := base64.StdEncoding.EncodeToString(in.images[0].Data)
+	var model segmind.SegmindModels
+	data := map[string]any{"image": image, "base64": true}
+
+	switch {
+	case req.Mode == ImageEditModeRemoveBackground:
+		model = segmind.SegmindBackgroundRemovalAPI
+	case in.mask != nil:
+		mask, err := blackWhiteMask(in.mask.Data)
+		if err != nil {
+			return "", nil, err
+		}
+		model = segmind.SegmindInpaintAPI
+		data["mask"] = base64.StdEncoding.EncodeToString(mask)
+		data["prompt"] = ki.instruction(req)
+		data["negative_prompt"] = ki.NegativePrompt
+	default:
+		// Edits without a mask and variations are image-to-image.
+		model = segmind.SegmindImg2ImgAPI
+		data["prompt"] = ki.instruction(req)
+		data["negative_prompt"] = ki.NegativePrompt
+		data["strength"] = segmindEditStrength
+		if req.Strength > 0 {
+			data["strength"] = req.Strength
+		}
+	}
+	return model, data, nil
+}
+
+func (ki *KarmaImageGen) editSegmindImage(req ImageEditRequest, in *editInputs) (*models.AIImageResponse, error) {
+	model, data, err := ki.segmindEdit(req, in)
+	if err != nil {
+		return nil, err
+	}
+	url, err := segmind.NewSegmind(model, segmind.WithOutputDir(ki.outputDir())).RequestEditImage(data)
+	if err != nil {
+		return nil, err
+	}
+	// Segmind does not rewrite prompts, so report the one that was sent,
+	// which includes the mode instruction.
+	res := &models.AIImageResponse{FilePath: *url}
+	if prompt, ok := data["prompt"].(string); ok {
+		res.RevisedPrompt = prompt
+	}
+	if strings.HasPrefix(*url, "https://") || strings.HasPrefix(*url, "http://") {
+		res.ImageHostedUrl, res.FilePath = *url, ""
+	}
+	return res, nil
+}
+
+// padImage returns img on a larger transparent canvas and an OpenAI style
+// mask whose transparent pixels cover the new border.
+func padImage(data []byte, e OutpaintExpansion) (canvas, mask []byte, err error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode image for outpainting: %w", err)
+	}
+	b := src.Bounds()
+	left, top := max(e.Left, 0), max(e.Top, 0)
+	size := image.Rect(0, 0, b.Dx()+left+max(e.Right, 0), b.Dy()+top+max(e.Bottom, 0))
+	inner := image.Rect(left, top, left+b.Dx(), top+b.Dy())
+
+	c := image.NewNRGBA(size)
+	draw.Draw(c, inner, src, b.Min, draw.Src)
+	m := image.NewNRGBA(size)
+	draw.Draw(m, inner, image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	if canvas, err = encodePNG(c); err != nil {
+		return nil, nil, err
+	}
+	if mask, err = encodePNG(m); err != nil {
+		return nil, nil, err
+	}
+	return canvas, mask, nil
+}
+
+// blackWhiteMask converts an OpenAI style mask (transparent = regenerate) to
+// white for the area to regenerate on black.
+func blackWhiteMask(data []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode mask: %w", err)
+	}
+	b := src.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := src.At(x, y).RGBA(); a < 0x8000 {
+				out.SetGray(x-b.Min.X, y-b.Min.Y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return encodePNG(out)
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// geminiOptions builds the Gemini image options from the instance settings.
+func (ki *KarmaImageGen) geminiOptions() []gemini.ImageGenOption {
+	var opts []gemini.ImageGenOption
+
+	// Check for API key first (from SpecialConfig or environment)
+	if apiKey, ok := ki.SpecialConfig[GoogleAPIKey].(string); ok && apiKey != "" {
+		opts = append(opts, gemini.WithAPIKey(apiKey))
+	} else {
+		// Check for Vertex AI configuration
+		projectID := config.GetEnvRaw("GOOGLE_PROJECT_ID")
+		location := config.GetEnvRaw("GOOGLE_LOCATION")
+
+		// Override with SpecialConfig if set
+		if configProjectID, ok := ki.SpecialConfig[GoogleProjectID].(string); ok && configProjectID != "" {
+			projectID = configProjectID
+		}
+		if configLocation, ok := ki.SpecialConfig[GoogleLocation].(string); ok && configLocation != "" {
+			location = configLocation
+		}
+
+		if projectID != "" && location != "" {
+			opts = append(opts, gemini.WithVertexAI(projectID, location))
+		}
+		// If neither API key nor Vertex AI config, GenImageWithConfig will try env vars
+	}
+
+	// Add image generation settings if configured
+	if ki.AspectRatio != "" {
+		opts = append(opts, gemini.WithAspectRatio(ki.AspectRatio))
+	}
+	if ki.ImageSize != "" {
+		opts = append(opts, gemini.WithImageSize(ki.ImageSize))
+	}
+	if ki.MimeType != "" {
+		opts = append(opts, gemini.WithMimeType(ki.MimeType))
+	}
+	if ki.PersonGeneration != "" {
+		opts = append(opts, gemini.WithPersonGeneration(ki.PersonGeneration))
+	}
+	if ki.Temperature > 0 {
+		opts = append(opts, gemini.WithTemperatureImg(ki.Temperature))
+	}
+	if ki.DisableSafetyFilters {
+		opts = append(opts, gemini.WithDisabledSafetyFilters())
+	}
+	return opts
+}
@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MelloB1989/karma/apis/segmind"
+	"github.com/MelloB1989/karma/internal/openai"
+)
+
+func testPNG(t *testing.T, w, h int, fill color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, fill)
+		}
+	}
+	data, err := encodePNG(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestImageInputLoad(t *testing.T) {
+	data := testPNG(t, 2, 2, color.White)
+	path := filepath.Join(t.TempDir(), "in.png")
+	os.WriteFile(path, data, 0644)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(data) }))
+	defer srv.Close()
+
+	for _, in := range []ImageInput{ImageFromBytes(data), ImageFromFile(path), ImageFromURL(srv.URL)} {
+		got, mime, err := in.load()
+		if err != nil || mime != "image/png" || !bytes.Equal(got, data) {
+			t.Fatalf("load(%+v) = %d bytes, %q, %v", in, len(got), mime, err)
+		}
+	}
+	if _, _, err := ImageFromBytes([]byte("plain text")).load(); err == nil {
+		t.Fatal("non-image input should fail")
+	}
+}
+
+func TestDownloadImageLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, imageMaxBytes+1))
+	}))
+	defer srv.Close()
+	if _, err := downloadImage(srv.URL); err == nil {
+		t.Fatal("oversized download should fail")
+	}
+}
+
+func TestSegmindEditStrength(t *testing.T) {
+	ki := NewKarmaImageGen(SEGMIND_SDXL)
+	in := &editInputs{images: []openai.ImageFile{{Data: testPNG(t, 1, 1, color.White)}}}
+	for strength, want := range map[float64]float64{0: segmindEditStrength, 0.25: 0.25} {
+		model, data, err := ki.segmindEdit(ImageEditRequest{Mode: ImageEditModeEdit, Prompt: "x", Strength: strength}, in)
+		if err != nil || model != segmind.SegmindImg2ImgAPI || data["strength"] != want {
+			t.Errorf("strength %v: model %s, data strength %v, err %v", strength, model, data["strength"], err)
+		}
+	}
+}
+
+func TestImageEditValidation(t *testing.T) {
+	img := []ImageInput{ImageFromBytes(testPNG(t, 1, 1, color.White))}
+	for _, req := range []ImageEditRequest{
+		{Mode: ImageEditModeEdit, Prompt: "x"},
+		{Mode: ImageEditModeEdit, Images: img},
+		{Mode: ImageEditModeInpaint, Images: img, Prompt: "x"},
+		{Mode: ImageEditModeOutpaint, Images: img},
+		{Mode: "sharpen", Images: img},
+		{Mode: ImageEditModeVariation, Images: img, Strength: 1.5},
+	} {
+		if err := req.validate(); err == nil {
+			t.Errorf("validate(%+v) should fail", req)
+		}
+	}
+	if _, err := NewKarmaImageGen(DALL_E_3).ImageVariation(img[0]); err == nil {
+		t.Error("dall-e-3 editing should be unsupported")
+	}
+}
+
+func TestPadImageAndMasks(t *testing.T) {
+	canvasData, maskData, err := padImage(testPNG(t, 4, 2, color.White), OutpaintExpansion{Left: 2, Bottom: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	canvas, _, _ := image.Decode(bytes.NewReader(canvasData))
+	if b := canvas.Bounds(); b.Dx() != 6 || b.Dy() != 5 {
+		t.Fatalf("canvas = %v", b)
+	}
+	if _, _, _, a := canvas.At(0, 0).RGBA(); a != 0 {
+		t.Fatal("padding should be transparent")
+	}
+	if r, _, _, a := canvas.At(2, 0).RGBA(); r != 0xffff || a != 0xffff {
+		t.Fatal("original should be drawn at the left offset")
+	}
+
+	bw, err := blackWhiteMask(maskData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _, _ := image.Decode(bytes.NewReader(bw))
+	if g := color.GrayModel.Convert(m.At(0, 4)).(color.Gray); g.Y != 255 {
+		t.Fatal("padding should be white in a black and white mask")
+	}
+	if g := color.GrayModel.Convert(m.At(3, 1)).(color.Gray); g.Y != 0 {
+		t.Fatal("original area should be black in a black and white mask")
+	}
+}
@@ -1,13 +1,24 @@
 package ai
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/apis/aws/bedrock"
 
 	"github.com/MelloB1989/karma/apis/gemini"
 	"github.com/MelloB1989/karma/apis/segmind"
 	"github.com/MelloB1989/karma/config"
+	"github.com/MelloB1989/karma/files"
 	"github.com/MelloB1989/karma/internal/openai"
 	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/utils"
 )
 
 // Image Models
@@ -32,13 +43,22 @@ const (
 	SEGMIND_MIDJOURNEY  ImageModels = "segmind-midjourney"
 	SEGMIND_SDXL        ImageModels = "segmind-sdxl-txt2img"
 	SEGMIND_SD15        ImageModels = "segmind-sd15-txt2img"
+	// Amazon Bedrock Models
+	TITAN_IMAGE_V1       ImageModels = "amazon.titan-image-generator-v1"
+	TITAN_IMAGE_V2       ImageModels = "amazon.titan-image-generator-v2:0"
+	NOVA_CANVAS          ImageModels = "amazon.nova-canvas-v1:0"
+	STABLE_DIFFUSION_XL  ImageModels = "stability.stable-diffusion-xl-v1"
+	STABLE_IMAGE_CORE    ImageModels = "stability.stable-image-core-v1:1"
+	STABLE_DIFFUSION_3_5 ImageModels = "stability.sd3-5-large-v1:0"
 	// Models to be supported in future:
 	// Gemini20FlashPreviewImageGen ImageModels = "gemini-2.0-flash-preview-image-generation"
-	// StableDiffusionXLV1          ImageModels = "stability.stable-diffusion-xl-v1:0"
-	// TitanImageGeneratorV1        ImageModels = "amazon.titan-image-generator-v1:0"
-	// TitanImageGeneratorV2        ImageModels = "amazon.titan-image-generator-v2:0"
 )
 
+// IsBedrock reports whether the model is served through Amazon Bedrock.
+func (m ImageModels) IsBedrock() bool {
+	return strings.HasPrefix(string(m), "amazon.") || strings.HasPrefix(string(m), "stability.")
+}
+
 type KarmaImageGen struct {
 	UserPrePrompt   string // User's pre-prompt for image generation
 	NegativePrompt  string // User's negative prompt for image generation
@@ -54,6 +74,19 @@ type KarmaImageGen struct {
 	PersonGeneration     string  // e.g., "ALLOW_ALL", "BLOCK_ALL", "BLOCK_ONLY_ADULTS"
 	Temperature          float32 // Temperature for generation
 	DisableSafetyFilters bool    // Disable safety filters
+	// Bedrock image generation settings
+	Seed     *int64  // Fixed seed for reproducible results
+	Width    int     // Output width in pixels
+	Height   int     // Output height in pixels
+	CfgScale float64 // Prompt adherence
+	Quality  string  // "standard" or "premium" (Titan, Nova Canvas)
+	// BedrockAPIKey and BedrockRegion override the Bedrock defaults, as on KarmaAI.
+	BedrockAPIKey string
+	BedrockRegion string
+	// Storage, when set, receives every image from Generate.
+	Storage           *files.KarmaFiles
+	StorageVisibility files.FileVisibility
+	SignedURLExpiry   time.Duration // Signed URL lifetime; private uploads default to an hour
 }
 
 type ImageGenOptions func(*KarmaImageGen)
@@ -142,6 +175,64 @@ func WithImgDisabledSafetyFilters() ImageGenOptions {
 	}
 }
 
+// WithImgSeed fixes the seed so the same prompt reproduces the same image
+func WithImgSeed(seed int64) ImageGenOptions {
+	return func(k *KarmaImageGen) {
+		k.Seed = &seed
+	}
+}
+
+// WithImgResolution sets the output width and height in pixels
+func WithImgResolution(width, height int) ImageGenOptions {
+	return func(k *KarmaImageGen) {
+		k.Width = width
+		k.Height = height
+	}
+}
+
+// WithImgCfgScale sets how strictly the image follows the prompt
+func WithImgCfgScale(scale float64) ImageGenOptions {
+	return func(k *KarmaImageGen) {
+		k.CfgScale = scale
+	}
+}
+
+// WithImgQuality sets the quality tier ("standard" or "premium")
+func WithImgQuality(quality string) ImageGenOptions {
+	return func(k *KarmaImageGen) {
+		k.Quality = quality
+	}
+}
+
+// WithImgBedrockAPIKey sets an Amazon Bedrock API key (bearer token)
+func WithImgBedrockAPIKey(apiKey string) ImageGenOptions {
+	return func(k *KarmaImageGen) {
+		k.BedrockAPIKey = apiKey
+	}
+}
+
+// WithImgBedrockRegion overrides the AWS region used for Bedrock requests
+func WithImgBedrockRegion(region string) ImageGenOptions {
+	return func(k *KarmaImageGen) {
+		k.BedrockRegion = region
+	}
+}
+
+// WithImgStorage uploads images from Generate through kf with the given visibility
+func WithImgStorage(kf *files.KarmaFiles, visibility files.FileVisibility) ImageGenOptions {
+	return func(k *KarmaImageGen) {
+		k.Storage = kf
+		k.StorageVisibility = visibility
+	}
+}
+
+// WithImgSignedURL adds a signed URL valid for expiry to each uploaded image
+func WithImgSignedURL(expiry time.Duration) ImageGenOptions {
+	return func(k *KarmaImageGen) {
+		k.SignedURLExpiry = expiry
+	}
+}
+
 func (ki *KarmaImageGen) GenerateImages(prompt string) (*models.AIImageResponse, error) {
 	// Set default output directory if not specified
 	outputDir := ki.OutputDirectory
@@ -229,57 +320,92 @@ func (ki *KarmaImageGen) GenerateImages(prompt string) (*models.AIImageResponse,
 		}
 		return &models.AIImageResponse{FilePath: *url}, nil
 	}
+	if ki.Model.IsBedrock() {
+		return ki.genBedrockImage(prompt, outputDir)
+	}
 	return nil, errors.New("unsupported model")
 }
 
-// genGeminiImage generates images using Google/Gemini with SpecialConfig support
-func (ki *KarmaImageGen) genGeminiImage(prompt, outputDir string) (*models.AIImageResponse, error) {
-	// Build options from configuration
-	var opts []gemini.ImageGenOption
-
-	// Check for API key first (from SpecialConfig or environment)
-	if apiKey, ok := ki.SpecialConfig[GoogleAPIKey].(string); ok && apiKey != "" {
-		opts = append(opts, gemini.WithAPIKey(apiKey))
-	} else {
-		// Check for Vertex AI configuration
-		projectID := config.GetEnvRaw("GOOGLE_PROJECT_ID")
-		location := config.GetEnvRaw("GOOGLE_LOCATION")
-
-		// Override with SpecialConfig if set
-		if configProjectID, ok := ki.SpecialConfig[GoogleProjectID].(string); ok && configProjectID != "" {
-			projectID = configProjectID
-		}
-		if configLocation, ok := ki.SpecialConfig[GoogleLocation].(string); ok && configLocation != "" {
-			location = configLocation
-		}
-
-		if projectID != "" && location != "" {
-			opts = append(opts, gemini.WithVertexAI(projectID, location))
+// genBedrockImage generates one image with a Titan, Nova Canvas or
+// Stability model. GenerateImages returns a single image, so only one is
+// requested whatever N is; use Generate for several.
+func (ki *KarmaImageGen) genBedrockImage(prompt, outputDir string) (*models.AIImageResponse, error) {
+	res, err := ki.bedrockImages(strings.TrimSpace(ki.UserPrePrompt+" "+prompt), 1)
+	if err != nil {
+		if res != nil && res.Safety != nil {
+			return &models.AIImageResponse{Safety: res.Safety}, err
 		}
-		// If neither API key nor Vertex AI config, GenImageWithConfig will try env vars
+		return nil, err
 	}
+	if len(res.Images) == 0 {
+		return nil, errors.New("bedrock returned no images")
+	}
+	path, err := saveImage(res.Images[0].Data, outputDir)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AIImageResponse{FilePath: path}, nil
+}
 
-	// Add image generation settings if configured
-	if ki.AspectRatio != "" {
-		opts = append(opts, gemini.WithAspectRatio(ki.AspectRatio))
+// bedrockImages generates n images from prompt, keeping those that passed
+// the content filters.
+func (ki *KarmaImageGen) bedrockImages(prompt string, n int) (*models.AIImageResult, error) {
+	params := bedrock.ImageParams{
+		Prompt:         prompt,
+		NegativePrompt: ki.NegativePrompt,
+		Width:          ki.Width,
+		Height:         ki.Height,
+		Seed:           ki.Seed,
+		NumberOfImages: n,
+		CfgScale:       ki.CfgScale,
+		Quality:        ki.Quality,
+		AspectRatio:    ki.AspectRatio,
 	}
-	if ki.ImageSize != "" {
-		opts = append(opts, gemini.WithImageSize(ki.ImageSize))
+	images, err := bedrock.GenerateImages(context.Background(), string(ki.Model), params, ki.bedrockOptions())
+	res := &models.AIImageResult{}
+	if errors.Is(err, bedrock.ErrContentFiltered) {
+		res.Safety = &models.AIImageSafety{Blocked: true, Reason: "content_filtered"}
+		return res, err
 	}
-	if ki.MimeType != "" {
-		opts = append(opts, gemini.WithMimeType(ki.MimeType))
+	if err != nil {
+		return nil, err
 	}
-	if ki.PersonGeneration != "" {
-		opts = append(opts, gemini.WithPersonGeneration(ki.PersonGeneration))
+	for _, img := range images {
+		if len(img.Data) == 0 {
+			continue
+		}
+		out := models.AIImage{Data: img.Data, Seed: ki.Seed}
+		if img.Seed != 0 {
+			seed := img.Seed
+			out.Seed = &seed
+		}
+		res.Images = append(res.Images, out)
 	}
-	if ki.Temperature > 0 {
-		opts = append(opts, gemini.WithTemperatureImg(ki.Temperature))
+	return res, nil
+}
+
+func (ki *KarmaImageGen) bedrockOptions() bedrock.ClientOptions {
+	return bedrock.ClientOptions{Region: ki.BedrockRegion, APIKey: ki.BedrockAPIKey}
+}
+
+func saveImage(data []byte, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	extension := ".png"
+	if strings.Contains(http.DetectContentType(data), "jpeg") {
+		extension = ".jpg"
 	}
-	if ki.DisableSafetyFilters {
-		opts = append(opts, gemini.WithDisabledSafetyFilters())
+	path := filepath.Join(dir, utils.GenerateID(16)+extension)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
 	}
+	return path, nil
+}
 
-	return gemini.GenImageWithConfig(ki.UserPrePrompt+" "+prompt, string(ki.Model), outputDir, opts...)
+// genGeminiImage generates images using Google/Gemini with SpecialConfig support
+func (ki *KarmaImageGen) genGeminiImage(prompt, outputDir string) (*models.AIImageResponse, error) {
+	return gemini.GenImageWithConfig(ki.UserPrePrompt+" "+prompt, string(ki.Model), outputDir, ki.geminiOptions()...)
 }
 
 // GenerateImagesWithInputImages generates images using input images (useful for models like Nano Banana)
@@ -0,0 +1,169 @@
+package ai
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/apis/gemini"
+	"github.com/MelloB1989/karma/apis/segmind"
+	"github.com/MelloB1989/karma/config"
+	"github.com/MelloB1989/karma/files"
+	"github.com/MelloB1989/karma/internal/openai"
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/utils"
+)
+
+var segmindImageAPIs = map[ImageModels]segmind.SegmindModels{
+	SEGMIND_SD:          segmind.SegmindSDAPI,
+	SEGMIND_PROTOVIS:    segmind.SegmindProtovisAPI,
+	SEGMIND_SAMARITAN:   segmind.SegmindSamaritanAPI,
+	SEGMIND_DREAMSHAPER: segmind.SegmindDreamshaperAPI,
+	SEGMIND_FLUX:        segmind.SegmindFluxAPI,
+	SEGMIND_MIDJOURNEY:  segmind.SegmindMidjourneyAPI,
+	SEGMIND_SDXL:        segmind.SegmindSDXLAPI,
+	SEGMIND_SD15:        segmind.SegmindSD15API,
+}
+
+// Generate creates N images (at least one) and returns them in memory. Nothing
+// is written to the output directory, so it works on read-only filesystems.
+// When Storage is set each image is uploaded and its URL filled in.
+func (ki *KarmaImageGen) Generate(prompt string) (*models.AIImageResult, error) {
+	res, err := ki.generate(strings.TrimSpace(ki.UserPrePrompt + " " + prompt))
+	if err != nil {
+		return res, err
+	}
+	for i := range res.Images {
+		describeImage(&res.Images[i])
+	}
+	if ki.Storage != nil {
+		for i := range res.Images {
+			if err := ki.store(&res.Images[i]); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, nil
+}
+
+func (ki *KarmaImageGen) generate(prompt string) (*models.AIImageResult, error) {
+	n := max(ki.N, 1)
+	switch ki.Model {
+	case GPT_1_IMAGE, DALL_E_2, DALL_E_3:
+		return openAIImageResult(openai.GenImages(prompt, string(ki.Model), n))
+	case GROK_2_IMAGE:
+		return openAIImageResult(openai.GenImages(prompt, string(ki.Model), n, openai.CompatibleOptions{
+			BaseURL: XAI_API,
+			API_Key: config.GetEnvRaw("XAI_API_KEY"),
+		}))
+	case GEMINI_NANO_BANANA, GEMINI_3_PRO_IMAGE:
+		res := &models.AIImageResult{}
+		for len(res.Images) < n {
+			data, err := gemini.GenerateImageData(prompt, string(ki.Model), nil, ki.geminiOptions()...)
+			if data != nil {
+				res.Safety = data.Safety
+			}
+			if err != nil {
+				return res, err
+			}
+			res.Text = data.Text
+			for _, img := range data.Images {
+				res.Images = append(res.Images, models.AIImage{Data: img.Data, MimeType: img.MIMEType})
+			}
+		}
+		return res, nil
+	case SEGMIND_NANO_BANANA:
+		return nil, errors.New("segmind nano banana needs input images, use GenerateImagesWithInputImages")
+	}
+
+	if api, ok := segmindImageAPIs[ki.Model]; ok {
+		seg := segmind.NewSegmind(api)
+		res := &models.AIImageResult{}
+		for range n {
+			data, err := seg.RequestCreateImageBytes(prompt)
+			if err != nil {
+				return nil, err
+			}
+			res.Images = append(res.Images, models.AIImage{Data: data})
+		}
+		return res, nil
+	}
+
+	if ki.Model.IsBedrock() {
+		return ki.bedrockImages(prompt, n)
+	}
+	return nil, errors.New("unsupported model")
+}
+
+func openAIImageResult(images []openai.GeneratedImage, err error) (*models.AIImageResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	res := &models.AIImageResult{RevisedPrompt: images[0].RevisedPrompt}
+	for _, img := range images {
+		res.Images = append(res.Images, models.AIImage{Data: img.Data, MimeType: img.MimeType, URL: img.URL})
+	}
+	return res, nil
+}
+
+// describeImage fills in the MIME type and dimensions from the image bytes.
+func describeImage(img *models.AIImage) {
+	if img.MimeType == "" || img.MimeType == "application/octet-stream" {
+		img.MimeType = http.DetectContentType(img.Data)
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data)); err == nil {
+		img.Width, img.Height = cfg.Width, cfg.Height
+	}
+}
+
+// store uploads img through Storage and records where it went.
+func (ki *KarmaImageGen) store(img *models.AIImage) error {
+	ext := ".png"
+	switch {
+	case strings.Contains(img.MimeType, "jpeg"):
+		ext = ".jpg"
+	case strings.Contains(img.MimeType, "webp"):
+		ext = ".webp"
+	}
+	name := utils.GenerateID(16) + ext
+	header, err := files.BytesToMultipartFileHeader(img.Data, name)
+	if err != nil {
+		return fmt.Errorf("failed to prepare image upload: %w", err)
+	}
+
+	visibility := ki.StorageVisibility
+	if visibility == "" {
+		visibility = files.Public
+	}
+	location, err := ki.Storage.HandleSingleFileUploadWithOptions(header, files.UploadOptions{
+		Visibility:   visibility,
+		NoFilePrefix: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload image: %w", err)
+	}
+
+	// Local signed URLs resolve names against LocalUploadDir; S3 wants the key
+	objectKey := name
+	if ki.Storage.UploadMode == files.S3 {
+		img.URL = location
+		objectKey = ki.Storage.PathPrefix + "/" + name
+	} else {
+		img.FilePath = location
+	}
+
+	expiry := ki.SignedURLExpiry
+	if expiry == 0 && visibility == files.Private {
+		expiry = time.Hour
+	}
+	if expiry > 0 {
+		if img.SignedURL, err = ki.Storage.GetFileSignedURL(objectKey, expiry); err != nil {
+			return fmt.Errorf("failed to sign image URL: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/MelloB1989/karma/files"
+)
+
+func TestGenerateToStorage(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString(testPNG(t, 3, 2, color.White))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"created":1,"data":[{"b64_json":%q},{"b64_json":%q}]}`, png, png)
+	}))
+	defer srv.Close()
+	t.Setenv("OPENAI_BASE_URL", srv.URL)
+
+	store := files.NewKarmaFile("", files.LOCAL)
+	store.LocalUploadDir = t.TempDir()
+	ki := NewKarmaImageGen(GPT_1_IMAGE, WithNImages(2), WithImgStorage(store, files.Private))
+
+	res, err := ki.Generate("a white square")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Images) != 2 {
+		t.Fatalf("got %d images", len(res.Images))
+	}
+	for _, img := range res.Images {
+		if img.MimeType != "image/png" || img.Width != 3 || img.Height != 2 || len(img.Data) == 0 {
+			t.Fatalf("image = %+v", img)
+		}
+		if _, err := os.Stat(img.FilePath); err != nil {
+			t.Fatalf("upload missing: %v", err)
+		}
+		if !strings.HasPrefix(img.SignedURL, "file://") {
+			t.Fatalf("private upload should be signed, got %q", img.SignedURL)
+		}
+	}
+}
+
+func TestBedrockImageCounts(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString(testPNG(t, 2, 2, color.White))
+	var requested []float64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Config map[string]float64 `json:"imageGenerationConfig"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		n := int(body.Config["numberOfImages"])
+		requested = append(requested, body.Config["numberOfImages"])
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"images": slices.Repeat([]string{png}, n)})
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL_BEDROCK_RUNTIME", srv.URL)
+
+	ki := NewKarmaImageGen(NOVA_CANVAS, WithNImages(3), WithImgBedrockAPIKey("test"), WithOutputDirectory(t.TempDir()))
+	res, err := ki.Generate("a white square")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Images) != 3 {
+		t.Fatalf("Generate returned %d images, want 3", len(res.Images))
+	}
+	one, err := ki.GenerateImages("a white square")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(one.FilePath); err != nil {
+		t.Fatal(err)
+	}
+	if len(requested) != 2 || requested[0] != 3 || requested[1] != 1 {
+		t.Fatalf("requested images = %v, want [3 1]", requested)
+	}
+}
@@ -0,0 +1,441 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/apigen/fiberspec"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// ErrMemoryNotFound is returned when a memory id does not exist for a user.
+var ErrMemoryNotFound = errors.New("memory not found")
+
+// AdminFilter narrows MemoryAdmin.List. Category, Lifespan and Status accept
+// comma separated values; an empty Scope lists every scope.
+type AdminFilter struct {
+	Category string `json:"category"`
+	Lifespan string `json:"lifespan"`
+	Status   string `json:"status"`
+	Scope    string `json:"scope"`
+}
+
+func (f AdminFilter) matches(m Memory) bool {
+	if f.Category != "" && !matchesAny(string(m.Category), f.Category) {
+		return false
+	}
+	if f.Lifespan != "" && !matchesAny(string(m.Lifespan), f.Lifespan) {
+		return false
+	}
+	if f.Status != "" && !matchesAny(string(m.Status), f.Status) {
+		return false
+	}
+	return f.Scope == "" || m.Namespace == f.Scope
+}
+
+// MemoryHistory is a memory with the memories it replaced and those that
+// replaced it, oldest first.
+type MemoryHistory struct {
+	Memory       Memory   `json:"memory"`
+	Supersedes   []Memory `json:"supersedes"`
+	SupersededBy []Memory `json:"superseded_by"`
+}
+
+// MemoryEdit is a partial update; nil fields are left unchanged.
+type MemoryEdit struct {
+	Summary     *string           `json:"summary,omitempty"`
+	RawText     *string           `json:"raw_text,omitempty"`
+	Category    *MemoryCategory   `json:"category,omitempty"`
+	Importance  *int              `json:"importance,omitempty"`
+	Lifespan    *MemoryLifespan   `json:"lifespan,omitempty"`
+	Mutability  *MemoryMutability `json:"mutability,omitempty"`
+	Status      *MemoryStatus     `json:"status,omitempty"`
+	ForgetScore *float64          `json:"forget_score,omitempty"`
+}
+
+// MemoryAdmin inspects and edits any user's memories, for support tooling.
+// Each call works on its own view of the user, so it can share a KarmaMemory
+// that serves chat traffic.
+type MemoryAdmin struct {
+	mem *KarmaMemory
+}
+
+func NewMemoryAdmin(mem *KarmaMemory) *MemoryAdmin {
+	return &MemoryAdmin{mem: mem}
+}
+
+// List returns userID's memories matching f, oldest first.
+func (a *MemoryAdmin) List(userID string, f AdminFilter) ([]Memory, error) {
+	all, err := a.mem.forUser(userID).listAllMemories()
+	if err != nil {
+		return nil, err
+	}
+	memories := make([]Memory, 0, len(all))
+	for _, m := range all {
+		if f.matches(m) {
+			memories = append(memories, m)
+		}
+	}
+	return memories, nil
+}
+
+func findMemory(memories []Memory, memoryID string) (Memory, bool) {
+	for _, m := range memories {
+		if m.Id == memoryID {
+			return m, true
+		}
+	}
+	return Memory{}, false
+}
+
+// Get returns one memory of userID.
+func (a *MemoryAdmin) Get(userID, memoryID string) (*Memory, error) {
+	memories, err := a.List(userID, AdminFilter{})
+	if err != nil {
+		return nil, err
+	}
+	m, ok := findMemory(memories, memoryID)
+	if !ok {
+		return nil, ErrMemoryNotFound
+	}
+	return &m, nil
+}
+
+// History returns the supersession history of a memory. A newer memory in the
+// same scope replaced an older, superseded one when it lists the older id or
+// one of its canonical keys in SupersedesCanonicalKeys, or when both share a
+// category and have similar summaries, which is how ingestion supersedes.
+func (a *MemoryAdmin) History(userID, memoryID string) (*MemoryHistory, error) {
+	memories, err := a.List(userID, AdminFilter{})
+	if err != nil {
+		return nil, err
+	}
+	target, ok := findMemory(memories, memoryID)
+	if !ok {
+		return nil, ErrMemoryNotFound
+	}
+	history := &MemoryHistory{Memory: target, Supersedes: []Memory{}, SupersededBy: []Memory{}}
+	for _, m := range memories {
+		if m.Id == target.Id || m.Namespace != target.Namespace {
+			continue
+		}
+		switch {
+		case m.Status == StatusSuperseded && m.CreatedAt.Before(target.CreatedAt) && replaces(target, m):
+			history.Supersedes = append(history.Supersedes, m)
+		case target.Status == StatusSuperseded && target.CreatedAt.Before(m.CreatedAt) && replaces(m, target):
+			history.SupersededBy = append(history.SupersededBy, m)
+		}
+	}
+	return history, nil
+}
+
+// replaces reports whether newer looks like the replacement of older.
+func replaces(newer, older Memory) bool {
+	for _, key := range newer.SupersedesCanonicalKeys {
+		if key == older.Id {
+			return true
+		}
+		for _, oldKey := range older.SupersedesCanonicalKeys {
+			if key == oldKey {
+				return true
+			}
+		}
+	}
+	return newer.Category == older.Category &&
+		isSimilarMemory(normalizeSummary(newer.Summary), normalizeSummary(older.Summary))
+}
+
+// update applies fn to a memory and writes it back in full, re-embedding it
+// for services that store vectors.
+func (a *MemoryAdmin) update(userID, memoryID string, fn func(*Memory)) (*Memory, error) {
+	u := a.mem.forUser(userID)
+	all, err := u.listAllMemories()
+	if err != nil {
+		return nil, err
+	}
+	m, ok := findMemory(all, memoryID)
+	if !ok {
+		return nil, ErrMemoryNotFound
+	}
+	fn(&m)
+	m.UpdatedAt = time.Now()
+
+	if u.memorydb.currentService == VectorServicePinecone {
+		_, err = u.memorydb.client.updateVector(m)
+	} else {
+		var embeddings []float32
+		embeddings, err = u.getEmbeddings(memoryEmbeddingText(m))
+		if err != nil {
+			return nil, err
+		}
+		_, err = u.memorydb.client.updateVector(m, embeddings)
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.syncGraph([]Memory{m}, nil)
+	if a.mem.cache != nil {
+		if err := a.mem.cache.InvalidateUserCache(context.Background(), userID); err != nil {
+			a.mem.logger.Warn("karma_memory: failed to invalidate cache after admin update", zap.Error(err))
+		}
+	}
+	return &m, nil
+}
+
+// Edit applies the non-nil fields of edit. Changing the lifespan or forget
+// score recomputes the expiry of unpinned memories.
+func (a *MemoryAdmin) Edit(userID, memoryID string, edit MemoryEdit) (*Memory, error) {
+	return a.update(userID, memoryID, func(m *Memory) {
+		if edit.Summary != nil {
+			m.Summary = *edit.Summary
+		}
+		if edit.RawText != nil {
+			m.RawText = *edit.RawText
+		}
+		if edit.Category != nil {
+			m.Category = *edit.Category
+		}
+		if edit.Importance != nil {
+			m.Importance = *edit.Importance
+		}
+		if edit.Mutability != nil {
+			m.Mutability = *edit.Mutability
+		}
+		if edit.Status != nil {
+			m.Status = *edit.Status
+		}
+		if edit.Lifespan != nil || edit.ForgetScore != nil {
+			if edit.Lifespan != nil {
+				m.Lifespan = *edit.Lifespan
+			}
+			if edit.ForgetScore != nil {
+				m.ForgetScore = *edit.ForgetScore
+			}
+			if m.Mutability != MutabilityImmutable {
+				m.ExpiresAt = computeExpiry(time.Now(), m.Lifespan, m.ForgetScore)
+			}
+		}
+	})
+}
+
+// Pin makes a memory immutable, never expiring and of top importance, so
+// garbage collection and consolidation leave it alone. Unpinning makes it
+// mutable again and restores an expiry from its lifespan.
+func (a *MemoryAdmin) Pin(userID, memoryID string, pinned bool) (*Memory, error) {
+	return a.update(userID, memoryID, func(m *Memory) {
+		if pinned {
+			m.Mutability = MutabilityImmutable
+			m.ExpiresAt = nil
+			m.Importance = 5
+			m.Status = StatusActive
+			return
+		}
+		m.Mutability = MutabilityMutable
+		m.ExpiresAt = computeExpiry(time.Now(), m.Lifespan, m.ForgetScore)
+	})
+}
+
+// Delete soft-deletes a memory; garbage collection removes it later.
+func (a *MemoryAdmin) Delete(userID, memoryID string) (*Memory, error) {
+	return a.update(userID, memoryID, func(m *Memory) {
+		m.Status = StatusDeleted
+	})
+}
+
+// WarmupCache loads userID's memories in scope into the cache.
+func (a *MemoryAdmin) WarmupCache(userID, scope string) error {
+	if !a.mem.IsCacheEnabled() {
+		return nil
+	}
+	u := a.mem.forUser(userID, scope)
+	return a.mem.cache.WarmupCache(context.Background(), userID, u.scope, u.memorydb.client)
+}
+
+// InvalidateCache drops every cached entry of userID.
+func (a *MemoryAdmin) InvalidateCache(userID string) error {
+	if a.mem.cache == nil {
+		return nil
+	}
+	return a.mem.cache.InvalidateUserCache(context.Background(), userID)
+}
+
+type ResponseHTTP struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data"`
+	Message string      `json:"message"`
+}
+
+// MemoryListResponse, MemoryResponse and MemoryHistoryResponse document the
+// admin responses; the handlers send ResponseHTTP with the same shape.
+type MemoryListResponse struct {
+	Success bool     `json:"success"`
+	Data    []Memory `json:"data"`
+	Message string   `json:"message"`
+}
+
+type MemoryResponse struct {
+	Success bool   `json:"success"`
+	Data    Memory `json:"data"`
+	Message string `json:"message"`
+}
+
+type MemoryHistoryResponse struct {
+	Success bool          `json:"success"`
+	Data    MemoryHistory `json:"data"`
+	Message string        `json:"message"`
+}
+
+func adminError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	if errors.Is(err, ErrMemoryNotFound) {
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(ResponseHTTP{Success: false, Message: err.Error()})
+}
+
+func adminOK(c *fiber.Ctx, data any, message string) error {
+	return c.JSON(ResponseHTTP{Success: true, Data: data, Message: message})
+}
+
+// ErrAdminAuthRequired is returned by Mount for a registry without an auth
+// handler, which would expose every user's memories.
+var ErrAdminAuthRequired = errors.New("memory admin routes require a registry with an auth handler")
+
+// Mount registers the admin routes under prefix, e.g. "/admin/memory", on
+// router through reg, so they appear in the generated docs. Every route
+// requires the registry's auth handler; registries built with nil auth are
+// rejected with ErrAdminAuthRequired and nothing is mounted.
+func (a *MemoryAdmin) Mount(reg *fiberspec.Registry, router fiber.Router, prefix string) error {
+	if !reg.HasAuth() {
+		return ErrAdminAuthRequired
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	user := prefix + "/users/:userId"
+	memory := user + "/memories/:memoryId"
+	errorResponses := []fiberspec.Response{
+		{StatusCode: fiber.StatusNotFound, Description: "Memory not found", Body: ResponseHTTP{}},
+		{StatusCode: fiber.StatusInternalServerError, Description: "Vector service error", Body: ResponseHTTP{}},
+	}
+	withErrors := func(ok fiberspec.Response) []fiberspec.Response {
+		return append([]fiberspec.Response{ok}, errorResponses...)
+	}
+
+	reg.Mount(router, fiberspec.Spec{
+		Method: fiber.MethodGet, Path: user + "/memories", Summary: "List a user's memories", Auth: true,
+		Description: "Lists memories across scopes, oldest first. Filter with the category, lifespan and status query parameters (comma separated) and scope.",
+		Responses:   []fiberspec.Response{{StatusCode: fiber.StatusOK, Description: "Memories", Body: MemoryListResponse{}}, errorResponses[1]},
+	}, func(c *fiber.Ctx) error {
+		memories, err := a.List(c.Params("userId"), AdminFilter{
+			Category: c.Query("category"),
+			Lifespan: c.Query("lifespan"),
+			Status:   c.Query("status"),
+			Scope:    c.Query("scope"),
+		})
+		if err != nil {
+			return adminError(c, err)
+		}
+		return adminOK(c, memories, fmt.Sprintf("%d memories", len(memories)))
+	})
+
+	reg.Mount(router, fiberspec.Spec{
+		Method: fiber.MethodGet, Path: memory, Summary: "Get a memory", Auth: true,
+		Responses: withErrors(fiberspec.Response{StatusCode: fiber.StatusOK, Description: "Memory", Body: MemoryResponse{}}),
+	}, func(c *fiber.Ctx) error {
+		m, err := a.Get(c.Params("userId"), c.Params("memoryId"))
+		if err != nil {
+			return adminError(c, err)
+		}
+		return adminOK(c, m, "")
+	})
+
+	reg.Mount(router, fiberspec.Spec{
+		Method: fiber.MethodGet, Path: memory + "/history", Summary: "Get a memory's supersession history", Auth: true,
+		Responses: withErrors(fiberspec.Response{StatusCode: fiber.StatusOK, Description: "History", Body: MemoryHistoryResponse{}}),
+	}, func(c *fiber.Ctx) error {
+		h, err := a.History(c.Params("userId"), c.Params("memoryId"))
+		if err != nil {
+			return adminError(c, err)
+		}
+		return adminOK(c, h, "")
+	})
+
+	reg.Mount(router, fiberspec.Spec{
+		Method: fiber.MethodPatch, Path: memory, Summary: "Edit a memory", Auth: true,
+		Description: "Updates the given fields and re-embeds the memory.",
+		Request:     MemoryEdit{},
+		Responses: append(withErrors(fiberspec.Response{StatusCode: fiber.StatusOK, Description: "Updated memory", Body: MemoryResponse{}}),
+			fiberspec.Response{StatusCode: fiber.StatusBadRequest, Description: "Invalid body", Body: ResponseHTTP{}}),
+	}, func(c *fiber.Ctx) error {
+		var edit MemoryEdit
+		if err := c.BodyParser(&edit); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(ResponseHTTP{Success: false, Message: "Invalid request body."})
+		}
+		m, err := a.Edit(c.Params("userId"), c.Params("memoryId"), edit)
+		if err != nil {
+			return adminError(c, err)
+		}
+		return adminOK(c, m, "Memory updated.")
+	})
+
+	reg.Mount(router, fiberspec.Spec{
+		Method: fiber.MethodPost, Path: memory + "/pin", Summary: "Pin a memory", Auth: true,
+		Description: "Makes the memory immutable and non-expiring so it is never collected or consolidated.",
+		Responses:   withErrors(fiberspec.Response{StatusCode: fiber.StatusOK, Description: "Pinned memory", Body: MemoryResponse{}}),
+	}, func(c *fiber.Ctx) error {
+		m, err := a.Pin(c.Params("userId"), c.Params("memoryId"), true)
+		if err != nil {
+			return adminError(c, err)
+		}
+		return adminOK(c, m, "Memory pinned.")
+	})
+
+	reg.Mount(router, fiberspec.Spec{
+		Method: fiber.MethodDelete, Path: memory + "/pin", Summary: "Unpin a memory", Auth: true,
+		Responses: withErrors(fiberspec.Response{StatusCode: fiber.StatusOK, Description: "Unpinned memory", Body: MemoryResponse{}}),
+	}, func(c *fiber.Ctx) error {
+		m, err := a.Pin(c.Params("userId"), c.Params("memoryId"), false)
+		if err != nil {
+			return adminError(c, err)
+		}
+		return adminOK(c, m, "Memory unpinned.")
+	})
+
+	reg.Mount(router, fiberspec.Spec{
+		Method: fiber.MethodDelete, Path: memory, Summary: "Soft-delete a memory", Auth: true,
+		Description: "Marks the memory deleted; it stops being retrieved and garbage collection removes it later.",
+		Responses:   withErrors(fiberspec.Response{StatusCode: fiber.StatusOK, Description: "Deleted memory", Body: MemoryResponse{}}),
+	}, func(c *fiber.Ctx) error {
+		m, err := a.Delete(c.Params("userId"), c.Params("memoryId"))
+		if err != nil {
+			return adminError(c, err)
+		}
+		return adminOK(c, m, "Memory deleted.")
+	})
+
+	reg.Mount(router, fiberspec.Spec{
+		Method: fiber.MethodPost, Path: user + "/cache/warmup", Summary: "Warm up a user's memory cache", Auth: true,
+		Description: "Loads the user's rules, facts, skills and context of the scope query parameter, or the default scope, into the cache.",
+		Responses:   []fiberspec.Response{{StatusCode: fiber.StatusOK, Description: "Cache warmed up", Body: ResponseHTTP{}}, errorResponses[1]},
+	}, func(c *fiber.Ctx) error {
+		if err := a.WarmupCache(c.Params("userId"), c.Query("scope", a.mem.scope)); err != nil {
+			return adminError(c, err)
+		}
+		return adminOK(c, nil, "Cache warmed up.")
+	})
+
+	reg.Mount(router, fiberspec.Spec{
+		Method: fiber.MethodDelete, Path: user + "/cache", Summary: "Invalidate a user's memory cache", Auth: true,
+		Responses: []fiberspec.Response{{StatusCode: fiber.StatusOK, Description: "Cache invalidated", Body: ResponseHTTP{}}, errorResponses[1]},
+	}, func(c *fiber.Ctx) error {
+		if err := a.InvalidateCache(c.Params("userId")); err != nil {
+			return adminError(c, err)
+		}
+		return adminOK(c, nil, "Cache invalidated.")
+	})
+
+	return nil
+}
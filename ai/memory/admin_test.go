@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MelloB1989/karma/apigen/fiberspec"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMemoryAdminRoutes(t *testing.T) {
+	mem := newLocalTestMemory(t, "support")
+	mem.UseUser("alice")
+	old := time.Now().Add(-48 * time.Hour)
+	if _, err := mem.ImportMemories([]Memory{
+		{Id: "p1", Category: CategoryPreference, Summary: "User likes Adidas shoes", Status: StatusSuperseded, CreatedAt: old},
+		{Id: "p2", Category: CategoryPreference, Summary: "User does not like Adidas shoes anymore", Lifespan: LifespanMidTerm},
+		{Id: "r1", Category: CategoryRule, Summary: "Answer in English"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	mem.UseUser("support")
+
+	var authCalls int
+	reg := fiberspec.New(func(c *fiber.Ctx) error {
+		authCalls++
+		return c.Next()
+	})
+	app := fiber.New()
+	if err := NewMemoryAdmin(mem).Mount(fiberspec.New(nil), app, "/admin/memory/"); !errors.Is(err, ErrAdminAuthRequired) || len(app.GetRoutes()) != 0 {
+		t.Fatalf("mount without auth = %v, %d routes", err, len(app.GetRoutes()))
+	}
+	if err := NewMemoryAdmin(mem).Mount(reg, app, "/admin/memory/"); err != nil {
+		t.Fatal(err)
+	}
+	if len(reg.Specs()) != 9 {
+		t.Fatalf("mounted %d specs", len(reg.Specs()))
+	}
+	if _, err := reg.Build("Memory Admin", "", []string{"http://localhost"}, t.TempDir(), "admin"); err != nil {
+		t.Fatalf("docs build failed: %v", err)
+	}
+
+	call := func(method, path, body string, out any) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+
+	var list MemoryListResponse
+	if code := call(http.MethodGet, "/admin/memory/users/alice/memories?category=preference&status=active", "", &list); code != 200 ||
+		len(list.Data) != 1 || list.Data[0].Id != "p2" {
+		t.Fatalf("list = %d %+v", code, list)
+	}
+
+	var history MemoryHistoryResponse
+	call(http.MethodGet, "/admin/memory/users/alice/memories/p2/history", "", &history)
+	if len(history.Data.Supersedes) != 1 || history.Data.Supersedes[0].Id != "p1" {
+		t.Fatalf("history = %+v", history.Data)
+	}
+
+	var edited MemoryResponse
+	if code := call(http.MethodPatch, "/admin/memory/users/alice/memories/p2", `{"summary":"User dislikes Adidas"}`, &edited); code != 200 ||
+		edited.Data.Summary != "User dislikes Adidas" || edited.Data.Lifespan != LifespanMidTerm {
+		t.Fatalf("edit = %d %+v", code, edited)
+	}
+
+	var pinned MemoryResponse
+	call(http.MethodPost, "/admin/memory/users/alice/memories/p2/pin", "", &pinned)
+	if pinned.Data.Mutability != MutabilityImmutable || pinned.Data.ExpiresAt != nil || pinned.Data.Importance != 5 {
+		t.Fatalf("pin = %+v", pinned.Data)
+	}
+
+	call(http.MethodDelete, "/admin/memory/users/alice/memories/r1", "", nil)
+	if code := call(http.MethodGet, "/admin/memory/users/alice/memories?status=deleted", "", &list); code != 200 || len(list.Data) != 1 || list.Data[0].Id != "r1" {
+		t.Fatalf("deleted list = %+v", list)
+	}
+
+	if code := call(http.MethodGet, "/admin/memory/users/alice/memories/nope", "", nil); code != 404 {
+		t.Fatalf("missing memory status = %d", code)
+	}
+	if code := call(http.MethodPost, "/admin/memory/users/alice/cache/warmup", "", nil); code != 200 {
+		t.Fatalf("warmup status = %d", code)
+	}
+	if code := call(http.MethodDelete, "/admin/memory/users/alice/cache", "", nil); code != 200 {
+		t.Fatalf("invalidate status = %d", code)
+	}
+	if authCalls != 9 {
+		t.Fatalf("auth ran %d times", authCalls)
+	}
+
+	if all, _ := mem.ExportUserMemories("support"); len(all) != 0 {
+		t.Fatalf("admin calls leaked into the admin's own user: %+v", all)
+	}
+}
@@ -672,6 +672,7 @@ func (c *inMemoryCache) InvalidateUserCache(ctx context.Context, userID string)
 		fmt.Sprintf("%s%s:", factsCachePrefix, userID),
 		fmt.Sprintf("%s%s:", skillsCachePrefix, userID),
 		fmt.Sprintf("%s%s:", contextCachePrefix, userID),
+		fmt.Sprintf("%s%s:", allMemoriesCachePrefix, userID),
 	}
 
 	for _, prefix := range prefixes {
@@ -1033,6 +1034,7 @@ func (c *redisCache) InvalidateUserCache(ctx context.Context, userID string) err
 		fmt.Sprintf("%s%s:*", factsCachePrefix, userID),
 		fmt.Sprintf("%s%s:*", skillsCachePrefix, userID),
 		fmt.Sprintf("%s%s:*", contextCachePrefix, userID),
+		fmt.Sprintf("%s%s:*", allMemoriesCachePrefix, userID),
 	}
 
 	prefixes := []string{
@@ -1040,20 +1042,25 @@ func (c *redisCache) InvalidateUserCache(ctx context.Context, userID string) err
 		fmt.Sprintf("%s%s:", factsCachePrefix, userID),
 		fmt.Sprintf("%s%s:", skillsCachePrefix, userID),
 		fmt.Sprintf("%s%s:", contextCachePrefix, userID),
+		fmt.Sprintf("%s%s:", allMemoriesCachePrefix, userID),
 	}
 
 	for _, prefix := range prefixes {
 		c.deleteLocalByPrefix(prefix)
 	}
 
+	var firstErr error
 	for _, pattern := range patterns {
 		if err := c.deleteByPattern(ctx, pattern); err != nil {
 			c.logger.Warn("karma_memory: failed to delete cache pattern", zap.String("pattern", pattern), zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
 		}
 	}
 
 	c.logger.Debug("karma_memory: invalidated all cache for user", zap.String("userID", userID))
-	return nil
+	return firstErr
 }
 
 func (c *redisCache) deleteByPattern(ctx context.Context, pattern string) error {
@@ -0,0 +1,376 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/ai/parser"
+	"github.com/MelloB1989/karma/utils"
+	"go.uber.org/zap"
+)
+
+// ConsolidationPolicy selects which memories MemoryConsolidator distills and
+// how they are grouped.
+type ConsolidationPolicy struct {
+	// MinAge skips memories younger than this, so recent episodes stay
+	// verbatim; defaults to 24 hours.
+	MinAge time.Duration
+	// SimilarityThreshold is the cosine similarity a memory needs with a
+	// cluster's centroid to join it; defaults to 0.8.
+	SimilarityThreshold float32
+	// MinClusterSize is the fewest related memories worth distilling;
+	// defaults to 3.
+	MinClusterSize int
+	// MaxClusterSize caps the memories sent to the LLM at once; defaults to 20.
+	MaxClusterSize int
+	// DryRun clusters and distills but writes nothing.
+	DryRun bool
+}
+
+// DefaultConsolidationPolicy distills clusters of three or more related
+// memories older than a day.
+func DefaultConsolidationPolicy() ConsolidationPolicy {
+	return ConsolidationPolicy{
+		MinAge:              24 * time.Hour,
+		SimilarityThreshold: 0.8,
+		MinClusterSize:      3,
+		MaxClusterSize:      20,
+	}
+}
+
+// ConsolidationReport lists what a run created and superseded, or would have
+// in a dry run.
+type ConsolidationReport struct {
+	UserID     string        `json:"user_id"`
+	DryRun     bool          `json:"dry_run"`
+	Scanned    int           `json:"scanned"`
+	Candidates int           `json:"candidates"`
+	Clusters   int           `json:"clusters"`
+	Created    []Memory      `json:"created"`
+	Superseded []string      `json:"superseded"`
+	Errors     []string      `json:"errors,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// MemoryConsolidator clusters a user's active episodic and short-term
+// memories by embedding, has the memory LLM distill each cluster into long
+// term facts or preferences, and supersedes the sources. Runs are scheduled
+// as for MemoryGC.
+type MemoryConsolidator struct {
+	mem    *KarmaMemory
+	policy ConsolidationPolicy
+	// Users lists the users a global run covers. When nil, scheduled runs
+	// only cover the memory's current user.
+	Users func(ctx context.Context) ([]string, error)
+	// OnReport, when set, receives every report.
+	OnReport func(*ConsolidationReport)
+
+	sched userScheduler[*ConsolidationReport]
+}
+
+func NewMemoryConsolidator(mem *KarmaMemory, policy ConsolidationPolicy) *MemoryConsolidator {
+	def := DefaultConsolidationPolicy()
+	if policy.MinAge <= 0 {
+		policy.MinAge = def.MinAge
+	}
+	if policy.SimilarityThreshold <= 0 {
+		policy.SimilarityThreshold = def.SimilarityThreshold
+	}
+	if policy.MinClusterSize < 2 {
+		policy.MinClusterSize = def.MinClusterSize
+	}
+	if policy.MaxClusterSize < policy.MinClusterSize {
+		policy.MaxClusterSize = max(def.MaxClusterSize, policy.MinClusterSize)
+	}
+	c := &MemoryConsolidator{mem: mem, policy: policy}
+	c.sched = userScheduler[*ConsolidationReport]{mem: mem, job: "memory consolidation", runUser: c.runUser}
+	return c
+}
+
+// consolidatable reports whether m is an active episodic or short-term
+// memory old enough to distill. Rules are left alone, since their wording
+// and expiry matter.
+func (c *MemoryConsolidator) consolidatable(m Memory, now time.Time) bool {
+	if m.Status != StatusActive || m.Category == CategoryRule || m.Mutability == MutabilityImmutable {
+		return false
+	}
+	if m.Category != CategoryEpisodic && m.Lifespan != LifespanShortTerm {
+		return false
+	}
+	if m.ExpiresAt != nil && !now.Before(*m.ExpiresAt) {
+		return false
+	}
+	return now.Sub(m.CreatedAt) >= c.policy.MinAge
+}
+
+type memoryCluster struct {
+	members  []Memory
+	centroid []float32
+}
+
+// cluster greedily assigns each memory, oldest first, to the first cluster
+// whose centroid is similar enough, or starts a new one.
+func (c *MemoryConsolidator) cluster(memories []Memory, vectors [][]float32) []*memoryCluster {
+	var clusters []*memoryCluster
+	for i, mem := range memories {
+		var target *memoryCluster
+		for _, cl := range clusters {
+			if len(cl.members) < c.policy.MaxClusterSize && cosineSimilarity(vectors[i], cl.centroid) >= c.policy.SimilarityThreshold {
+				target = cl
+				break
+			}
+		}
+		if target == nil {
+			clusters = append(clusters, &memoryCluster{members: []Memory{mem}, centroid: append([]float32(nil), vectors[i]...)})
+			continue
+		}
+		n := float32(len(target.members))
+		for d := range target.centroid {
+			target.centroid[d] = (target.centroid[d]*n + vectors[i][d]) / (n + 1)
+		}
+		target.members = append(target.members, mem)
+	}
+	return clusters
+}
+
+// distill asks the memory LLM for the durable facts and preferences behind
+// a cluster.
+func (c *MemoryConsolidator) distill(cl *memoryCluster) ([]m, error) {
+	var b strings.Builder
+	for _, mem := range cl.members {
+		fmt.Fprintf(&b, "- [%s] (%s, %s) %s\n", mem.CreatedAt.Format(time.DateOnly), mem.Category, mem.Lifespan, mem.Summary)
+	}
+	prompt := fmt.Sprintf(`Consolidate these related episodic and short-term memories into long-term memories.
+Memories:
+%s
+Distill only what stays true beyond the individual episodes, as "fact" or "preference" memories (use "skill", "context" or "entity" only when clearly better).
+Use operation "create" and lifespan "long_term" or "lifelong". Merge repeated information into a single memory.
+Return a JSON object with a "memories" array. If nothing durable can be concluded, return: {"memories": []}`, b.String())
+
+	var wrapper memoriesWrapper
+	p := parser.NewParser(parser.WithAIClient(c.mem.memoryAI), parser.WithDebug(false))
+	if _, _, err := p.Parse(prompt, "", &wrapper); err != nil {
+		return nil, err
+	}
+	return wrapper.Memories, nil
+}
+
+// RunUser consolidates one user's memories, scope by scope.
+func (c *MemoryConsolidator) RunUser(userID string) (*ConsolidationReport, error) {
+	return c.sched.runOne(userID)
+}
+
+func (c *MemoryConsolidator) runUser(userID string) (*ConsolidationReport, error) {
+	k := c.mem
+	now := time.Now()
+	report := &ConsolidationReport{UserID: userID, DryRun: c.policy.DryRun, Created: []Memory{}, Superseded: []string{}, StartedAt: now}
+	fail := func(err error) {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	u := k.forUser(userID)
+	err := func() error {
+		memories, err := u.listAllMemories()
+		if err != nil {
+			return err
+		}
+		report.Scanned = len(memories)
+
+		byScope := map[string][]Memory{}
+		var scopes []string
+		for _, mem := range memories {
+			if !c.consolidatable(mem, now) {
+				continue
+			}
+			if _, ok := byScope[mem.Namespace]; !ok {
+				scopes = append(scopes, mem.Namespace)
+			}
+			byScope[mem.Namespace] = append(byScope[mem.Namespace], mem)
+			report.Candidates++
+		}
+		sort.Strings(scopes)
+
+		for _, scope := range scopes {
+			candidates := byScope[scope]
+			if len(candidates) < c.policy.MinClusterSize {
+				continue
+			}
+			kept, vectors := c.vectorsOf(u, candidates, fail)
+
+			for _, cl := range c.cluster(kept, vectors) {
+				if len(cl.members) < c.policy.MinClusterSize {
+					continue
+				}
+				report.Clusters++
+				created, err := c.consolidateCluster(u, scope, cl, now)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				if len(created) == 0 {
+					continue
+				}
+				report.Created = append(report.Created, created...)
+				for _, mem := range cl.members {
+					report.Superseded = append(report.Superseded, mem.Id)
+				}
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		fail(err)
+	}
+
+	if !c.policy.DryRun && len(report.Created) > 0 && k.cache != nil {
+		if err := k.cache.InvalidateUserCache(context.Background(), userID); err != nil {
+			fail(fmt.Errorf("invalidate cache: %w", err))
+		}
+	}
+	report.Duration = time.Since(now)
+
+	k.logger.Info("karma_memory: memory consolidation run",
+		zap.String("userID", userID),
+		zap.Bool("dryRun", report.DryRun),
+		zap.Int("candidates", report.Candidates),
+		zap.Int("clusters", report.Clusters),
+		zap.Int("created", len(report.Created)),
+		zap.Int("superseded", len(report.Superseded)),
+		zap.Int("errors", len(report.Errors)))
+	if c.OnReport != nil {
+		c.OnReport(report)
+	}
+
+	if err != nil {
+		return report, fmt.Errorf("memory consolidation for user %s failed: %w", userID, err)
+	}
+	return report, nil
+}
+
+// vectorsOf returns the stored vectors of memories, embedding only those the
+// vector service returns none for. Memories that cannot be embedded are left
+// out and reported through fail.
+func (c *MemoryConsolidator) vectorsOf(u *KarmaMemory, memories []Memory, fail func(error)) ([]Memory, [][]float32) {
+	ids := make([]string, len(memories))
+	for i, mem := range memories {
+		ids[i] = mem.Id
+	}
+	stored := map[string][]float32{}
+	records, err := u.memorydb.client.fetchVectors(ids, true)
+	if err != nil {
+		fail(fmt.Errorf("fetch vectors: %w", err))
+	}
+	for _, r := range records {
+		if len(r.vector) > 0 {
+			stored[r.memories.Id] = r.vector
+		}
+	}
+
+	kept := make([]Memory, 0, len(memories))
+	vectors := make([][]float32, 0, len(memories))
+	for _, mem := range memories {
+		vec, ok := stored[mem.Id]
+		if !ok {
+			if vec, err = u.getEmbeddings(memoryEmbeddingText(mem)); err != nil {
+				fail(fmt.Errorf("embed %s: %w", mem.Id, err))
+				continue
+			}
+		}
+		kept = append(kept, mem)
+		vectors = append(vectors, vec)
+	}
+	return kept, vectors
+}
+
+// consolidateCluster distills cl into new memories in scope, stores them and
+// supersedes the sources. The source ids are appended to each new memory's
+// SupersedesCanonicalKeys so the lineage survives compaction.
+func (c *MemoryConsolidator) consolidateCluster(k *KarmaMemory, scope string, cl *memoryCluster, now time.Time) ([]Memory, error) {
+	extracted, err := c.distill(cl)
+	if err != nil {
+		return nil, fmt.Errorf("distill cluster in %s: %w", scope, err)
+	}
+
+	sourceIds := make([]string, len(cl.members))
+	importance := 0
+	for i, mem := range cl.members {
+		sourceIds[i] = mem.Id
+		importance = max(importance, mem.Importance)
+	}
+
+	created := make([]Memory, 0, len(extracted))
+	records := make([]v, 0, len(extracted))
+	for _, e := range extracted {
+		if e.Summary == "" || e.Operation == "delete" || e.Category == CategoryRule || e.Category == CategoryEpisodic {
+			continue
+		}
+		lifespan := e.Lifespan
+		if lifespan != LifespanLifelong {
+			lifespan = LifespanLongTerm
+		}
+		mem := Memory{
+			Id:                      utils.GenerateID(7),
+			SubjectKey:              k.userID,
+			Namespace:               scope,
+			Category:                e.Category,
+			Summary:                 e.Summary,
+			RawText:                 e.RawText,
+			Importance:              max(e.Importance, importance),
+			Mutability:              e.Mutability,
+			Lifespan:                lifespan,
+			ForgetScore:             e.ForgetScore,
+			Status:                  StatusActive,
+			SupersedesCanonicalKeys: append(append([]string{}, e.SupersedesCanonicalKeys...), sourceIds...),
+			Metadata:                e.Metadata,
+			EntityRelationships:     e.EntityRelationships,
+			CreatedAt:               now,
+			UpdatedAt:               now,
+			ExpiresAt:               computeExpiry(now, lifespan, e.ForgetScore),
+		}
+		if mem.Mutability == "" {
+			mem.Mutability = MutabilityMutable
+		}
+		record := v{memories: mem}
+		if !c.policy.DryRun && k.memorydb.currentService != VectorServicePinecone {
+			vec, err := k.getEmbeddings(memoryEmbeddingText(mem))
+			if err != nil {
+				return nil, fmt.Errorf("embed consolidated memory: %w", err)
+			}
+			record.vector = vec
+		}
+		created = append(created, mem)
+		records = append(records, record)
+	}
+	if len(created) == 0 || c.policy.DryRun {
+		return created, nil
+	}
+
+	if err := k.memorydb.client.upsertVectors(records); err != nil {
+		return nil, fmt.Errorf("store consolidated memories: %w", err)
+	}
+	k.syncGraph(created, nil)
+	for _, id := range sourceIds {
+		if err := k.markMemoryAsSuperseded(id); err != nil {
+			k.logger.Warn("karma_memory: failed to supersede consolidated memory",
+				zap.String("memoryId", id),
+				zap.Error(err))
+		}
+	}
+	return created, nil
+}
+
+// Run consolidates each of userIDs, or every user from Users when none are
+// given. Reports are returned for all users attempted.
+func (c *MemoryConsolidator) Run(ctx context.Context, userIDs ...string) ([]*ConsolidationReport, error) {
+	return c.sched.run(ctx, c.Users, userIDs)
+}
+
+// Start runs the consolidator every interval until ctx is done, over userIDs
+// or, when none are given, over Users.
+func (c *MemoryConsolidator) Start(ctx context.Context, interval time.Duration, userIDs ...string) {
+	c.sched.start(ctx, interval, func() func(context.Context) ([]string, error) { return c.Users }, userIDs)
+}
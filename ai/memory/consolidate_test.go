@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MelloB1989/karma/ai"
+)
+
+func TestMemoryConsolidator(t *testing.T) {
+	t.Setenv("KARMA_MEMORY_LOCAL_VECTOR_PATH", filepath.Join(t.TempDir(), "vectors.json"))
+	t.Setenv("OPENAI_KEY", "sk-test")
+	distilled := `{"memories":[{"operation":"create","category":"preference","summary":"Alice goes to the gym in the morning","lifespan":"short_term","importance":3,"supersedes_canonical_keys":["habit.gym"]}]}`
+	var chatCalls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			chatCalls++
+			content, _ := json.Marshal(distilled)
+			fmt.Fprintf(w, `{"id":"1","object":"chat.completion","model":"gpt-4o-mini","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":%s}}]}`, content)
+			return
+		}
+		var body struct {
+			Input any `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		embedding := "[0,1]"
+		if strings.Contains(strings.ToLower(fmt.Sprint(body.Input)), "gym") {
+			embedding = "[1,0]"
+		}
+		fmt.Fprintf(w, `{"object":"list","model":"text-embedding-3-small","data":[{"object":"embedding","index":0,"embedding":%s}],"usage":{"prompt_tokens":1,"total_tokens":1}}`, embedding)
+	}))
+	defer srv.Close()
+	t.Setenv("OPENAI_BASE_URL", srv.URL)
+
+	mem := NewKarmaMemory(ai.NewKarmaAI(ai.GPT4oMini, ai.OpenAI), "alice", "project_a")
+	if err := mem.UseService(VectorServiceLocal); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-72 * time.Hour)
+	if _, err := mem.ImportMemories([]Memory{
+		{Id: "e1", Category: CategoryEpisodic, Summary: "Went to the gym at 7am", Importance: 2, CreatedAt: old},
+		{Id: "e2", Category: CategoryEpisodic, Summary: "Morning gym session again", Importance: 4, CreatedAt: old},
+		{Id: "e3", Category: CategoryEpisodic, Summary: "Skipped breakfast, hit the gym", Importance: 2, CreatedAt: old},
+		{Id: "e4", Category: CategoryEpisodic, Summary: "Watched a movie", CreatedAt: old},
+		{Id: "e5", Category: CategoryEpisodic, Summary: "Gym before work today"},
+		{Id: "f1", Category: CategoryFact, Summary: "Alice has a gym membership", Lifespan: LifespanLongTerm, CreatedAt: old},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dry := DefaultConsolidationPolicy()
+	dry.DryRun = true
+	report, err := NewMemoryConsolidator(mem, dry).RunUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Candidates != 4 || report.Clusters != 1 || len(report.Created) != 1 || len(report.Superseded) != 3 {
+		t.Fatalf("dry run report = %+v", report)
+	}
+	if all, _ := mem.ExportUserMemories("alice"); len(all) != 6 {
+		t.Fatalf("dry run wrote memories: %d", len(all))
+	}
+
+	report, err = NewMemoryConsolidator(mem, DefaultConsolidationPolicy()).RunUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chatCalls != 2 || len(report.Created) != 1 {
+		t.Fatalf("report = %+v, chat calls = %d", report, chatCalls)
+	}
+	created := report.Created[0]
+	if created.Lifespan != LifespanLongTerm || created.Importance != 4 || created.Namespace != "project_a" {
+		t.Fatalf("created = %+v", created)
+	}
+	if keys := strings.Join(created.SupersedesCanonicalKeys, ","); keys != "habit.gym,e1,e2,e3" {
+		t.Fatalf("supersedes = %s", keys)
+	}
+
+	all, _ := mem.ExportUserMemories("alice")
+	status := map[string]MemoryStatus{}
+	for _, m := range all {
+		status[m.Id] = m.Status
+	}
+	if len(all) != 7 || status["e1"] != StatusSuperseded || status["e3"] != StatusSuperseded ||
+		status["e4"] != StatusActive || status["e5"] != StatusActive || status[created.Id] != StatusActive {
+		t.Fatalf("statuses = %v", status)
+	}
+}
+
+func TestConsolidatorReusesStoredVectors(t *testing.T) {
+	mem := newLocalTestMemory(t, "alice")
+	if err := mem.memorydb.client.upsertVectors([]v{
+		{memories: Memory{Id: "e1", Namespace: "project_a", Category: CategoryEpisodic, Summary: "Alice ran 5k"}, vector: []float32{1, 0}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c := NewMemoryConsolidator(mem, ConsolidationPolicy{})
+	var errs []error
+	kept, vectors := c.vectorsOf(mem, []Memory{{Id: "e1", Summary: "Alice ran 5k"}, {Id: "missing", Summary: "Alice swam"}}, func(err error) { errs = append(errs, err) })
+	if len(errs) != 0 || len(kept) != 2 {
+		t.Fatalf("kept = %d, errors = %v", len(kept), errs)
+	}
+	if vectors[0][0] != 1 || vectors[0][1] != 0 {
+		t.Fatalf("stored vector not reused: %v", vectors[0])
+	}
+	if vectors[1][0] != 0.6 {
+		t.Fatalf("memories without a stored vector should be embedded: %v", vectors[1])
+	}
+}
@@ -278,6 +278,20 @@ Use:
     - category: "preference"
     - supersedes_canonical_keys: ["brand.adidas"]
 
+--------------------
+ENTITY RELATIONSHIPS
+--------------------
+
+When a memory mentions people, organizations, places, projects or other named entities, list them in
+"entity_relationships", each with:
+- "entity_type": "person | organization | place | project | product | other"
+- "relation_type": how the entity relates to the user, in snake_case (e.g. "lead_developer", "mother", "employer", "lives_in")
+- "entity_value": the entity's name as the user refers to it (e.g. "Karthik", "Bleu")
+  - Examples:
+    - "Karthik is my lead developer." -> [{"entity_type": "person", "relation_type": "lead_developer", "entity_value": "Karthik"}]
+    - "Jane, my mom, works at Acme." -> [{"entity_type": "person", "relation_type": "mother", "entity_value": "Jane"}, {"entity_type": "organization", "relation_type": "mother_employer", "entity_value": "Acme"}]
+Use an empty list when no entity is mentioned.
+
 --------------------
 IMPORTANCE
 --------------------
@@ -317,6 +331,7 @@ Always output exactly this structure:
 
       "mutability": "immutable | mutable",
       "supersedes_canonical_keys": ["optional", "list", "can", "be", "empty"],
+      "entity_relationships": [{"entity_type": "person", "relation_type": "lead_developer", "entity_value": "Karthik"}],
 
       "metadata": {
         "tags": ["optional", "tags"],
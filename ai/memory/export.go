@@ -0,0 +1,337 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/utils"
+	"go.uber.org/zap"
+)
+
+// ExportFormat is the encoding used by WriteUserMemories.
+type ExportFormat string
+
+const (
+	ExportJSON   ExportFormat = "json"
+	ExportNDJSON ExportFormat = "ndjson"
+)
+
+// memoryBatchSize bounds upserts and deletes per vector service call.
+const memoryBatchSize = 100
+
+// PurgeReport describes what PurgeUser erased.
+type PurgeReport struct {
+	UserID string `json:"user_id"`
+	// Scopes are the scopes the user had memories in.
+	Scopes  []string `json:"scopes"`
+	Found   int      `json:"found"`
+	Deleted int      `json:"deleted"`
+	// Remaining is what a listing after deletion still returned; anything but
+	// zero means the purge must be retried.
+	Remaining int `json:"remaining"`
+	// DroppedJobs are the user's turns removed from the ingest queue.
+	DroppedJobs  int       `json:"dropped_jobs"`
+	CacheCleared bool      `json:"cache_cleared"`
+	Errors       []string  `json:"errors,omitempty"`
+	PurgedAt     time.Time `json:"purged_at"`
+}
+
+// forUser returns a view of k bound to userID and, when given, scope (else
+// k's scope). It shares k's LLMs, cache, entity graph and vector connection
+// but has its own vector client, so background jobs and admin calls never
+// switch the user that k's chat traffic reads and writes. The view has no
+// message history or ingest queue.
+func (k *KarmaMemory) forUser(userID string, scope ...string) *KarmaMemory {
+	sc := k.scope
+	if len(scope) > 0 && scope[0] != "" {
+		sc = scope[0]
+	}
+	return &KarmaMemory{
+		messagesHistory: models.AIChatHistory{Messages: make([]models.AIMessage, 0)},
+		kai:             k.kai,
+		memoryAI:        k.memoryAI,
+		embeddingAI:     k.embeddingAI,
+		retrievalAI:     k.retrievalAI,
+		memorydb:        k.memorydb.forUser(userID, sc),
+		cache:           k.cache,
+		userID:          userID,
+		scope:           sc,
+		logger:          k.logger,
+		retrievalMode:   k.retrievalMode,
+		hybrid:          k.hybrid,
+		graph:           k.graph,
+		cacheEnabled:    k.cacheEnabled,
+	}
+}
+
+func (k *KarmaMemory) listAllMemories() ([]Memory, error) {
+	all := true
+	metadatas, err := k.memorydb.client.queryVectorByMetadata(filters{IncludeAllScopes: &all})
+	if err != nil {
+		return nil, err
+	}
+	memories := make([]Memory, 0, len(metadatas))
+	for _, m := range metadatas {
+		memories = append(memories, metadataToMemory(m, ""))
+	}
+	sort.SliceStable(memories, func(i, j int) bool {
+		if !memories[i].CreatedAt.Equal(memories[j].CreatedAt) {
+			return memories[i].CreatedAt.Before(memories[j].CreatedAt)
+		}
+		return memories[i].Id < memories[j].Id
+	})
+	return memories, nil
+}
+
+// ExportUserMemories returns every memory stored for userID, across all
+// scopes and statuses, oldest first.
+func (k *KarmaMemory) ExportUserMemories(userID string) ([]Memory, error) {
+	memories, err := k.forUser(userID).listAllMemories()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export memories: %w", err)
+	}
+	return memories, nil
+}
+
+// WriteUserMemories exports userID's memories to w as a JSON array or as
+// newline-delimited JSON.
+func (k *KarmaMemory) WriteUserMemories(w io.Writer, userID string, format ExportFormat) error {
+	memories, err := k.ExportUserMemories(userID)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case ExportJSON, "":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(memories)
+	case ExportNDJSON:
+		enc := json.NewEncoder(w)
+		for _, m := range memories {
+			if err := enc.Encode(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// ReadMemories decodes an export written by WriteUserMemories, in either
+// format.
+func ReadMemories(r io.Reader) ([]Memory, error) {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return []Memory{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(br)
+	if first == '[' {
+		var memories []Memory
+		if err := dec.Decode(&memories); err != nil {
+			return nil, fmt.Errorf("invalid memory export: %w", err)
+		}
+		return memories, nil
+	}
+	memories := make([]Memory, 0)
+	for {
+		var m Memory
+		if err := dec.Decode(&m); err == io.EOF {
+			return memories, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid memory export: %w", err)
+		}
+		memories = append(memories, m)
+	}
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if len(bytes.TrimSpace(b)) > 0 {
+			return b[0], nil
+		}
+		br.ReadByte()
+	}
+}
+
+// ImportMemories stores memories for the current user, re-embedding each one
+// with the embedding LLM, so exports move between users, services and
+// embedding models. Ids, scopes and statuses are kept; missing ones default
+// to a new id, the current scope and active.
+func (k *KarmaMemory) ImportMemories(memories []Memory) (int, error) {
+	now := time.Now()
+	batch := make([]v, 0, memoryBatchSize)
+	imported := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := k.memorydb.client.upsertVectors(batch); err != nil {
+			return fmt.Errorf("failed to import memories: %w", err)
+		}
+		imported += len(batch)
+		memories := make([]Memory, len(batch))
+		for i, record := range batch {
+			memories[i] = record.memories
+		}
+		k.syncGraph(memories, nil)
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, m := range memories {
+		if m.Id == "" {
+			m.Id = utils.GenerateID(7)
+		}
+		if m.Namespace == "" {
+			m.Namespace = k.scope
+		}
+		if m.Status == "" {
+			m.Status = StatusActive
+		}
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = now
+		}
+		m.UpdatedAt = now
+
+		record := v{memories: m}
+		if k.memorydb.currentService != VectorServicePinecone {
+			embeddings, err := k.getEmbeddings(memoryEmbeddingText(m))
+			if err != nil {
+				return imported, fmt.Errorf("failed to embed memory %s: %w", m.Id, err)
+			}
+			record.vector = embeddings
+		}
+		batch = append(batch, record)
+		if len(batch) == memoryBatchSize {
+			if err := flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return imported, err
+	}
+
+	if k.cache != nil {
+		if err := k.cache.InvalidateUserCache(context.Background(), k.userID); err != nil {
+			k.logger.Warn("karma_memory: failed to invalidate cache after import", zap.Error(err))
+		}
+	}
+	return imported, nil
+}
+
+// userPurger is implemented by services that store each user separately and
+// can delete all of a user's memories in one call.
+type userPurger interface {
+	purgeUser() error
+}
+
+// PurgeUser hard-deletes every memory of userID in all scopes and clears the
+// user from the cache. Their turns waiting in the ingest queue attached with
+// UseIngestQueue are dropped first; call IngestQueue.DropUser for queues fed
+// by other memories. If userID is the current user, the in-session message
+// history is cleared too. The report is returned even on error.
+func (k *KarmaMemory) PurgeUser(userID string) (*PurgeReport, error) {
+	report := &PurgeReport{UserID: userID, Scopes: []string{}, PurgedAt: time.Now()}
+	fail := func(err error) {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	if k.ingestQueue != nil {
+		n, err := k.ingestQueue.DropUser(context.Background(), userID)
+		if err != nil {
+			fail(fmt.Errorf("failed to drop queued turns: %w", err))
+		}
+		report.DroppedJobs = n
+	}
+
+	u := k.forUser(userID)
+	err := func() error {
+		memories, err := u.listAllMemories()
+		if err != nil {
+			return err
+		}
+		report.Found = len(memories)
+
+		scopes := map[string]bool{}
+		ids := make([]string, 0, len(memories))
+		for _, m := range memories {
+			if m.Id != "" {
+				ids = append(ids, m.Id)
+			}
+			if !scopes[m.Namespace] {
+				scopes[m.Namespace] = true
+				report.Scopes = append(report.Scopes, m.Namespace)
+			}
+		}
+		sort.Strings(report.Scopes)
+
+		for start := 0; start < len(ids); start += memoryBatchSize {
+			end := min(start+memoryBatchSize, len(ids))
+			n, err := u.memorydb.client.deleteVectors(ids[start:end])
+			if err != nil {
+				fail(err)
+				continue
+			}
+			report.Deleted += n
+			u.syncGraph(nil, ids[start:end])
+		}
+		return nil
+	}()
+	if err != nil {
+		fail(err)
+	}
+
+	// Services keeping each user apart can drop everything at once, including
+	// anything the listing missed.
+	if p, ok := u.memorydb.client.(userPurger); ok {
+		if err := p.purgeUser(); err != nil {
+			fail(fmt.Errorf("failed to drop user namespace: %w", err))
+		}
+	}
+	if remaining, err := u.listAllMemories(); err != nil {
+		fail(fmt.Errorf("failed to verify purge: %w", err))
+	} else {
+		report.Remaining = len(remaining)
+	}
+
+	if k.cache != nil {
+		if err := k.cache.InvalidateUserCache(context.Background(), userID); err != nil {
+			fail(fmt.Errorf("failed to clear cache: %w", err))
+		} else {
+			report.CacheCleared = true
+		}
+	} else {
+		report.CacheCleared = true
+	}
+	if userID == k.userID {
+		k.ClearHistory()
+		k.currentMemoryContext = ""
+	}
+
+	k.logger.Info("karma_memory: purged user",
+		zap.String("userID", userID),
+		zap.Int("found", report.Found),
+		zap.Int("deleted", report.Deleted),
+		zap.Int("remaining", report.Remaining))
+
+	if len(report.Errors) > 0 || report.Remaining > 0 {
+		return report, fmt.Errorf("purge of user %s incomplete: %d memories remain, %d errors", userID, report.Remaining, len(report.Errors))
+	}
+	return report, nil
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/MelloB1989/karma/ai"
+)
+
+func newLocalTestMemory(t *testing.T, userID string) *KarmaMemory {
+	t.Helper()
+	t.Setenv("KARMA_MEMORY_LOCAL_VECTOR_PATH", filepath.Join(t.TempDir(), "vectors.json"))
+	t.Setenv("OPENAI_KEY", "sk-test")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","model":"text-embedding-3-small","data":[{"object":"embedding","index":0,"embedding":[0.6,0.8]}],"usage":{"prompt_tokens":1,"total_tokens":1}}`)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("OPENAI_BASE_URL", srv.URL)
+
+	mem := NewKarmaMemory(ai.NewKarmaAI(ai.GPT4oMini, ai.OpenAI), userID, "project_a")
+	if err := mem.UseService(VectorServiceLocal); err != nil {
+		t.Fatal(err)
+	}
+	return mem
+}
+
+func TestExportImportPurge(t *testing.T) {
+	mem := newLocalTestMemory(t, "alice")
+
+	imported, err := mem.ImportMemories([]Memory{
+		{Id: "m1", Category: CategoryFact, Summary: "Alice uses Go"},
+		{Id: "m2", Namespace: "project_b", Category: CategoryRule, Summary: "Answer in English", Status: StatusSuperseded},
+	})
+	if err != nil || imported != 2 {
+		t.Fatalf("import = %d, %v", imported, err)
+	}
+	mem.UseUser("bob")
+	mem.ImportMemories([]Memory{{Id: "b1", Category: CategoryFact, Summary: "Bob uses Rust"}})
+	mem.UseUser("alice")
+
+	exported, err := mem.ExportUserMemories("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 2 || exported[0].Namespace != "project_a" || exported[1].Status != StatusSuperseded {
+		t.Fatalf("exported = %+v", exported)
+	}
+
+	for _, format := range []ExportFormat{ExportJSON, ExportNDJSON} {
+		var buf bytes.Buffer
+		if err := mem.WriteUserMemories(&buf, "alice", format); err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadMemories(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read) != 2 || read[0].Summary != "Alice uses Go" {
+			t.Fatalf("%s round trip = %+v", format, read)
+		}
+	}
+
+	report, err := mem.PurgeUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Found != 2 || report.Deleted != 2 || report.Remaining != 0 || !report.CacheCleared || len(report.Scopes) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if left, _ := mem.ExportUserMemories("alice"); len(left) != 0 {
+		t.Fatalf("alice still has %d memories", len(left))
+	}
+	if bob, _ := mem.ExportUserMemories("bob"); len(bob) != 1 {
+		t.Fatalf("purge touched another user: %+v", bob)
+	}
+	if mem.memorydb.userId != "alice" || mem.userID != "alice" {
+		t.Fatalf("exporting another user switched the memory to %q", mem.memorydb.userId)
+	}
+}
@@ -0,0 +1,222 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// GCPolicy selects what MemoryGC removes or decays. Zero values disable
+// each step.
+type GCPolicy struct {
+	// DeleteExpired hard-deletes memories whose ExpiresAt has passed.
+	DeleteExpired bool
+	// CompactSupersededAfter hard-deletes superseded and soft-deleted
+	// memories last updated longer ago than this.
+	CompactSupersededAfter time.Duration
+	// DecayEvery lowers Importance by one for every full period since the
+	// memory was last updated. Lifelong and immutable memories never decay.
+	DecayEvery time.Duration
+	// DecayFloor is the lowest Importance decay reaches; defaults to 1.
+	DecayFloor int
+	// DryRun reports what would change without touching the store.
+	DryRun bool
+}
+
+// DefaultGCPolicy deletes expired memories, compacts superseded ones after
+// 30 days and decays importance monthly.
+func DefaultGCPolicy() GCPolicy {
+	return GCPolicy{
+		DeleteExpired:          true,
+		CompactSupersededAfter: 30 * 24 * time.Hour,
+		DecayEvery:             30 * 24 * time.Hour,
+		DecayFloor:             1,
+	}
+}
+
+// GCReport lists the memory ids a run acted on, or would have in a dry run.
+type GCReport struct {
+	UserID    string        `json:"user_id"`
+	DryRun    bool          `json:"dry_run"`
+	Scanned   int           `json:"scanned"`
+	Expired   []string      `json:"expired"`
+	Compacted []string      `json:"compacted"`
+	Decayed   []string      `json:"decayed"`
+	Deleted   int           `json:"deleted"`
+	Updated   int           `json:"updated"`
+	Errors    []string      `json:"errors,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// GCMetrics are cumulative counters across every run of a MemoryGC.
+type GCMetrics struct {
+	Runs      int64     `json:"runs"`
+	Scanned   int64     `json:"scanned"`
+	Deleted   int64     `json:"deleted"`
+	Decayed   int64     `json:"decayed"`
+	Errors    int64     `json:"errors"`
+	LastRunAt time.Time `json:"last_run_at"`
+}
+
+// MemoryGC applies a GCPolicy to the vectors behind a KarmaMemory. Runs are
+// serialised, and each works on its own view of the user.
+type MemoryGC struct {
+	mem    *KarmaMemory
+	policy GCPolicy
+	// Users lists the users a global run covers. When nil, scheduled runs
+	// only cover the memory's current user.
+	Users func(ctx context.Context) ([]string, error)
+	// OnReport, when set, receives every report, e.g. to log or export it.
+	OnReport func(*GCReport)
+
+	sched   userScheduler[*GCReport]
+	mu      sync.Mutex
+	metrics GCMetrics
+}
+
+func NewMemoryGC(mem *KarmaMemory, policy GCPolicy) *MemoryGC {
+	if policy.DecayFloor < 1 {
+		policy.DecayFloor = 1
+	}
+	g := &MemoryGC{mem: mem, policy: policy}
+	g.sched = userScheduler[*GCReport]{mem: mem, job: "memory gc", runUser: g.runUser}
+	return g
+}
+
+// Metrics returns a snapshot of the cumulative counters.
+func (g *MemoryGC) Metrics() GCMetrics {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.metrics
+}
+
+// RunUser collects one user's memories across all scopes.
+func (g *MemoryGC) RunUser(userID string) (*GCReport, error) {
+	return g.sched.runOne(userID)
+}
+
+func (g *MemoryGC) runUser(userID string) (*GCReport, error) {
+	now := time.Now()
+	report := &GCReport{UserID: userID, DryRun: g.policy.DryRun, Expired: []string{}, Compacted: []string{}, Decayed: []string{}, StartedAt: now}
+	u := g.mem.forUser(userID)
+	err := func() error {
+		memories, err := u.listAllMemories()
+		if err != nil {
+			return err
+		}
+		report.Scanned = len(memories)
+
+		var toDelete []string
+		var toUpdate []Memory
+		for _, m := range memories {
+			switch {
+			case g.policy.DeleteExpired && m.ExpiresAt != nil && !now.Before(*m.ExpiresAt):
+				report.Expired = append(report.Expired, m.Id)
+				toDelete = append(toDelete, m.Id)
+			case g.policy.CompactSupersededAfter > 0 && (m.Status == StatusSuperseded || m.Status == StatusDeleted) &&
+				now.Sub(m.UpdatedAt) > g.policy.CompactSupersededAfter:
+				report.Compacted = append(report.Compacted, m.Id)
+				toDelete = append(toDelete, m.Id)
+			default:
+				if decayed, ok := g.decay(m, now); ok {
+					report.Decayed = append(report.Decayed, m.Id)
+					toUpdate = append(toUpdate, decayed)
+				}
+			}
+		}
+		if g.policy.DryRun {
+			return nil
+		}
+
+		for start := 0; start < len(toDelete); start += memoryBatchSize {
+			end := min(start+memoryBatchSize, len(toDelete))
+			n, err := u.memorydb.client.deleteVectors(toDelete[start:end])
+			if err != nil {
+				report.Errors = append(report.Errors, err.Error())
+				continue
+			}
+			report.Deleted += n
+			u.syncGraph(nil, toDelete[start:end])
+		}
+		for _, m := range toUpdate {
+			if err := u.updateMemory(m); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("decay %s: %v", m.Id, err))
+				continue
+			}
+			report.Updated++
+		}
+		return nil
+	}()
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	if !g.policy.DryRun && (report.Deleted > 0 || report.Updated > 0) && g.mem.cache != nil {
+		if err := g.mem.cache.InvalidateUserCache(context.Background(), userID); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("invalidate cache: %v", err))
+		}
+	}
+	report.Duration = time.Since(now)
+	g.record(report)
+
+	if err != nil {
+		return report, fmt.Errorf("memory gc for user %s failed: %w", userID, err)
+	}
+	return report, nil
+}
+
+// decay returns m with its importance lowered for the whole DecayEvery
+// periods since its last update. UpdatedAt advances by the periods applied,
+// so the remainder carries over to the next run.
+func (g *MemoryGC) decay(m Memory, now time.Time) (Memory, bool) {
+	if g.policy.DecayEvery <= 0 || m.Lifespan == LifespanLifelong || m.Mutability == MutabilityImmutable ||
+		m.Status != StatusActive || m.Importance <= g.policy.DecayFloor || m.UpdatedAt.IsZero() {
+		return m, false
+	}
+	periods := int(now.Sub(m.UpdatedAt) / g.policy.DecayEvery)
+	if periods <= 0 {
+		return m, false
+	}
+	m.Importance = max(m.Importance-periods, g.policy.DecayFloor)
+	m.UpdatedAt = m.UpdatedAt.Add(time.Duration(periods) * g.policy.DecayEvery)
+	return m, true
+}
+
+// Run collects each of userIDs, or every user from Users when none are
+// given. Reports are returned for all users attempted.
+func (g *MemoryGC) Run(ctx context.Context, userIDs ...string) ([]*GCReport, error) {
+	return g.sched.run(ctx, g.Users, userIDs)
+}
+
+// Start runs the collector every interval until ctx is done, over userIDs
+// or, when none are given, over Users.
+func (g *MemoryGC) Start(ctx context.Context, interval time.Duration, userIDs ...string) {
+	g.sched.start(ctx, interval, func() func(context.Context) ([]string, error) { return g.Users }, userIDs)
+}
+
+func (g *MemoryGC) record(report *GCReport) {
+	g.mu.Lock()
+	g.metrics.Runs++
+	g.metrics.Scanned += int64(report.Scanned)
+	g.metrics.Deleted += int64(report.Deleted)
+	g.metrics.Decayed += int64(report.Updated)
+	g.metrics.Errors += int64(len(report.Errors))
+	g.metrics.LastRunAt = report.StartedAt
+	g.mu.Unlock()
+
+	g.mem.logger.Info("karma_memory: memory gc run",
+		zap.String("userID", report.UserID),
+		zap.Bool("dryRun", report.DryRun),
+		zap.Int("scanned", report.Scanned),
+		zap.Int("expired", len(report.Expired)),
+		zap.Int("compacted", len(report.Compacted)),
+		zap.Int("decayed", len(report.Decayed)),
+		zap.Int("errors", len(report.Errors)))
+	if g.OnReport != nil {
+		g.OnReport(report)
+	}
+}
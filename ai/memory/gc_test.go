@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryGC(t *testing.T) {
+	mem := newLocalTestMemory(t, "alice")
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	old := now.Add(-45 * 24 * time.Hour)
+	err := mem.memorydb.client.upsertVectors([]v{
+		{memories: Memory{Id: "expired", Namespace: "project_a", Status: StatusActive, Importance: 5, ExpiresAt: &past, UpdatedAt: now}, vector: []float32{1, 0}},
+		{memories: Memory{Id: "superseded", Namespace: "project_b", Status: StatusSuperseded, Importance: 5, UpdatedAt: old}, vector: []float32{1, 0}},
+		{memories: Memory{Id: "fresh_superseded", Namespace: "project_a", Status: StatusSuperseded, Importance: 5, UpdatedAt: now}, vector: []float32{1, 0}},
+		{memories: Memory{Id: "stale", Namespace: "project_a", Summary: "likes tea", Status: StatusActive, Importance: 5, Lifespan: LifespanLongTerm, UpdatedAt: old}, vector: []float32{1, 0}},
+		{memories: Memory{Id: "lifelong", Namespace: "project_a", Status: StatusActive, Importance: 5, Lifespan: LifespanLifelong, UpdatedAt: old}, vector: []float32{1, 0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := DefaultGCPolicy()
+	policy.DecayEvery = 10 * 24 * time.Hour
+	policy.DecayFloor = 2
+	policy.DryRun = true
+	gc := NewMemoryGC(mem, policy)
+
+	dry, err := gc.RunUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dry.Expired) != 1 || len(dry.Compacted) != 1 || len(dry.Decayed) != 1 || dry.Deleted != 0 {
+		t.Fatalf("dry run = %+v", dry)
+	}
+	if all, _ := mem.ExportUserMemories("alice"); len(all) != 5 {
+		t.Fatalf("dry run changed the store: %d memories", len(all))
+	}
+
+	policy.DryRun = false
+	gc = NewMemoryGC(mem, policy)
+	reports, err := gc.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Deleted != 2 || reports[0].Updated != 1 {
+		t.Fatalf("reports = %+v", reports[0])
+	}
+
+	left, _ := mem.ExportUserMemories("alice")
+	if len(left) != 3 {
+		t.Fatalf("left = %+v", left)
+	}
+	for _, m := range left {
+		if m.Id == "stale" && (m.Importance != 2 || m.Summary != "likes tea" || !m.UpdatedAt.After(old)) {
+			t.Fatalf("decayed memory = %+v", m)
+		}
+		if m.Id == "lifelong" && m.Importance != 5 {
+			t.Fatalf("lifelong memory decayed: %+v", m)
+		}
+	}
+	if m := gc.Metrics(); m.Runs != 1 || m.Deleted != 2 || m.Decayed != 1 {
+		t.Fatalf("metrics = %+v", m)
+	}
+}
@@ -0,0 +1,691 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrEntityGraphDisabled is returned by the entity graph helpers until
+// UseEntityGraph is called.
+var ErrEntityGraphDisabled = errors.New("entity graph is not enabled")
+
+// SelfEntity is the graph node standing for the user. Every extracted
+// relationship links it to an entity, e.g. self -lead_developer-> Karthik.
+const SelfEntity = "@self"
+
+// RelationMentionedWith links entities that appear in the same memory.
+const RelationMentionedWith = "mentioned_with"
+
+// GraphRelationship is one EntityRelationship of a stored memory.
+type GraphRelationship struct {
+	MemoryID string `json:"memory_id"`
+	Scope    string `json:"scope"`
+	EntityRelationship
+}
+
+// EntityGraphStore keeps the relationships behind each user's entity graph.
+// The vector service stays the source of truth; a store can always be
+// rebuilt from it with RebuildEntityGraph.
+type EntityGraphStore interface {
+	// Index replaces the relationships of each memory. Memories that are not
+	// active, or have no relationships, are dropped from the graph.
+	Index(ctx context.Context, userID string, memories []Memory) error
+	// Replace drops everything stored for userID and indexes memories.
+	Replace(ctx context.Context, userID string, memories []Memory) error
+	Remove(ctx context.Context, userID string, memoryIDs []string) error
+	Relationships(ctx context.Context, userID string) ([]GraphRelationship, error)
+}
+
+// graphRelationships returns the relationships m contributes to the graph.
+func graphRelationships(m Memory) []GraphRelationship {
+	if m.Status != StatusActive || m.Id == "" {
+		return nil
+	}
+	rels := make([]GraphRelationship, 0, len(m.EntityRelationships))
+	for _, r := range m.EntityRelationships {
+		if entityKey(r.EntityValue) == "" {
+			continue
+		}
+		rels = append(rels, GraphRelationship{MemoryID: m.Id, Scope: m.Namespace, EntityRelationship: r})
+	}
+	return rels
+}
+
+// inMemoryGraphMaxUsers bounds an in-memory store; the least recently used
+// graphs are evicted and rebuilt on their next use.
+const inMemoryGraphMaxUsers = 10000
+
+type inMemoryGraphUser struct {
+	byMemory map[string][]GraphRelationship
+	used     time.Time
+}
+
+type inMemoryGraphStore struct {
+	mu       sync.RWMutex
+	users    map[string]*inMemoryGraphUser
+	maxUsers int
+}
+
+// NewInMemoryGraphStore keeps entity graphs in process, for up to 10000 users
+// at a time. They are rebuilt from the vector service the first time each
+// user is queried after a restart or an eviction.
+func NewInMemoryGraphStore() EntityGraphStore {
+	return &inMemoryGraphStore{users: map[string]*inMemoryGraphUser{}, maxUsers: inMemoryGraphMaxUsers}
+}
+
+// sharedGraphStore is the default store, shared by every KarmaMemory in the
+// process so writes through one are seen by the others.
+var sharedGraphStore = sync.OnceValue(NewInMemoryGraphStore)
+
+// has reports whether the store holds userID's graph, so an evicted graph is
+// rebuilt rather than read as empty.
+func (s *inMemoryGraphStore) has(userID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.users[userID]
+	return ok
+}
+
+func (s *inMemoryGraphStore) Index(ctx context.Context, userID string, memories []Memory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index(userID, memories)
+	return nil
+}
+
+func (s *inMemoryGraphStore) index(userID string, memories []Memory) {
+	u := s.users[userID]
+	if u == nil {
+		s.evict()
+		u = &inMemoryGraphUser{byMemory: map[string][]GraphRelationship{}}
+		s.users[userID] = u
+	}
+	u.used = time.Now()
+	byMemory := u.byMemory
+	for _, m := range memories {
+		if rels := graphRelationships(m); len(rels) > 0 {
+			byMemory[m.Id] = rels
+		} else {
+			delete(byMemory, m.Id)
+		}
+	}
+}
+
+// evict makes room for one more user by dropping the least recently used.
+func (s *inMemoryGraphStore) evict() {
+	if len(s.users) < s.maxUsers {
+		return
+	}
+	var oldest string
+	var oldestUsed time.Time
+	for id, u := range s.users {
+		if oldest == "" || u.used.Before(oldestUsed) {
+			oldest, oldestUsed = id, u.used
+		}
+	}
+	delete(s.users, oldest)
+}
+
+func (s *inMemoryGraphStore) Replace(ctx context.Context, userID string, memories []Memory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, userID)
+	s.index(userID, memories)
+	return nil
+}
+
+func (s *inMemoryGraphStore) Remove(ctx context.Context, userID string, memoryIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.users[userID]; u != nil {
+		for _, id := range memoryIDs {
+			delete(u.byMemory, id)
+		}
+	}
+	return nil
+}
+
+func (s *inMemoryGraphStore) Relationships(ctx context.Context, userID string) ([]GraphRelationship, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rels []GraphRelationship
+	if u := s.users[userID]; u != nil {
+		u.used = time.Now()
+		for _, rs := range u.byMemory {
+			rels = append(rels, rs...)
+		}
+	}
+	sort.SliceStable(rels, func(i, j int) bool { return rels[i].MemoryID < rels[j].MemoryID })
+	return rels, nil
+}
+
+// GraphConfig enables the entity graph. Zero values use the defaults.
+type GraphConfig struct {
+	// Store defaults to an in-memory store shared by the whole process; use
+	// NewPgGraphStore to share graphs between processes.
+	Store EntityGraphStore
+	// Refresh is how long a loaded graph is trusted before it is rebuilt from
+	// the vector service, which picks up memories written without the graph,
+	// e.g. by another process; defaults to 10 minutes.
+	Refresh time.Duration
+	// Depth is how many hops RetrievalModeGraph follows from the entities
+	// matched in the prompt; defaults to 1.
+	Depth int
+	// MaxExpanded caps the memories added through the graph; defaults to 5.
+	MaxExpanded int
+}
+
+func (c GraphConfig) withDefaults() GraphConfig {
+	if c.Store == nil {
+		c.Store = sharedGraphStore()
+	}
+	if c.Refresh <= 0 {
+		c.Refresh = 10 * time.Minute
+	}
+	if c.Depth <= 0 {
+		c.Depth = 1
+	}
+	if c.MaxExpanded <= 0 {
+		c.MaxExpanded = 5
+	}
+	return c
+}
+
+// entityGraphState tracks when this memory last rebuilt each user's graph.
+type entityGraphState struct {
+	cfg    GraphConfig
+	mu     sync.Mutex
+	loaded map[string]time.Time
+}
+
+// UseEntityGraph maintains a per-user entity graph from the relationships
+// extracted during ingestion, used by RetrievalModeGraph and the
+// EntityNeighbors and EntityPaths helpers.
+func (k *KarmaMemory) UseEntityGraph(cfg GraphConfig) {
+	k.graph = &entityGraphState{cfg: cfg.withDefaults(), loaded: map[string]time.Time{}}
+}
+
+// fresh reports whether userID's graph was rebuilt within Refresh and is
+// still held by the store. Callers hold g.mu.
+func (g *entityGraphState) fresh(userID string) bool {
+	at, ok := g.loaded[userID]
+	if !ok || time.Since(at) >= g.cfg.Refresh {
+		return false
+	}
+	if s, ok := g.cfg.Store.(interface{ has(string) bool }); ok && !s.has(userID) {
+		return false
+	}
+	return true
+}
+
+// markLoaded records a rebuild of userID's graph and forgets expired ones.
+// Callers hold g.mu.
+func (g *entityGraphState) markLoaded(userID string) {
+	now := time.Now()
+	for id, at := range g.loaded {
+		if now.Sub(at) >= g.cfg.Refresh {
+			delete(g.loaded, id)
+		}
+	}
+	g.loaded[userID] = now
+}
+
+// ensureGraphLoaded rebuilds the current vector user's graph from the vector
+// service unless this memory did so within Refresh.
+func (k *KarmaMemory) ensureGraphLoaded(ctx context.Context) error {
+	g := k.graph
+	userID := k.memorydb.userId
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.fresh(userID) {
+		return nil
+	}
+	memories, err := k.listAllMemories()
+	if err != nil {
+		return err
+	}
+	if err := g.cfg.Store.Replace(ctx, userID, memories); err != nil {
+		return err
+	}
+	g.markLoaded(userID)
+	return nil
+}
+
+// syncGraph mirrors a write to the vector service into the entity graph of
+// the current vector user. Failures are logged; RebuildEntityGraph repairs
+// the graph.
+func (k *KarmaMemory) syncGraph(indexed []Memory, removed []string) {
+	if k.graph == nil || (len(indexed) == 0 && len(removed) == 0) {
+		return
+	}
+	ctx := context.Background()
+	userID := k.memorydb.userId
+	err := k.ensureGraphLoaded(ctx)
+	if err == nil && len(indexed) > 0 {
+		err = k.graph.cfg.Store.Index(ctx, userID, indexed)
+	}
+	if err == nil && len(removed) > 0 {
+		err = k.graph.cfg.Store.Remove(ctx, userID, removed)
+	}
+	if err != nil {
+		k.logger.Warn("karma_memory: failed to update entity graph", zap.String("userID", userID), zap.Error(err))
+	}
+}
+
+// RebuildEntityGraph replaces userID's entity graph with the relationships
+// of their active memories in the vector service.
+func (k *KarmaMemory) RebuildEntityGraph(userID string) error {
+	if k.graph == nil {
+		return ErrEntityGraphDisabled
+	}
+	memories, err := k.forUser(userID).listAllMemories()
+	if err != nil {
+		return err
+	}
+	k.graph.mu.Lock()
+	defer k.graph.mu.Unlock()
+	if err := k.graph.cfg.Store.Replace(context.Background(), userID, memories); err != nil {
+		return err
+	}
+	k.graph.markLoaded(userID)
+	return nil
+}
+
+// EntityGraph returns userID's entity graph across scopes, or limited to the
+// given ones.
+func (k *KarmaMemory) EntityGraph(userID string, scopes ...string) (*EntityGraph, error) {
+	if k.graph == nil {
+		return nil, ErrEntityGraphDisabled
+	}
+	ctx := context.Background()
+	if err := k.forUser(userID).ensureGraphLoaded(ctx); err != nil {
+		return nil, err
+	}
+	rels, err := k.graph.cfg.Store.Relationships(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return buildEntityGraph(rels, scopes...), nil
+}
+
+// EntityNeighbors returns the entities within depth hops of entity in the
+// current user's scope. entity may be a name or a relation, e.g. "lead".
+func (k *KarmaMemory) EntityNeighbors(entity string, depth int) ([]EntityNeighbor, error) {
+	g, err := k.EntityGraph(k.userID, k.scope)
+	if err != nil {
+		return nil, err
+	}
+	return g.Neighbors(entity, depth), nil
+}
+
+// EntityPaths returns the paths of at most maxDepth edges between two
+// entities in the current user's scope, shortest first.
+func (k *KarmaMemory) EntityPaths(from, to string, maxDepth int) ([][]EntityEdge, error) {
+	g, err := k.EntityGraph(k.userID, k.scope)
+	if err != nil {
+		return nil, err
+	}
+	return g.Paths(from, to, maxDepth), nil
+}
+
+// EntityNode is an entity and the memories that mention it.
+type EntityNode struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+	// Relations are how the entity relates to the user, e.g. lead_developer.
+	Relations []string `json:"relations,omitempty"`
+	MemoryIDs []string `json:"memory_ids"`
+}
+
+// EntityEdge is a relationship taken from one memory.
+type EntityEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+	MemoryID string `json:"memory_id"`
+}
+
+// EntityNeighbor is a node reached Hops edges away from the start.
+type EntityNeighbor struct {
+	EntityNode
+	Hops int `json:"hops"`
+}
+
+// EntityGraph is an undirected view over a user's relationships. Traversals
+// never pass through SelfEntity, which is linked to every entity.
+type EntityGraph struct {
+	nodes map[string]*EntityNode
+	edges map[string][]EntityEdge
+}
+
+// entityKey normalises an entity name into its node key.
+func entityKey(value string) string {
+	return strings.Join(strings.Fields(strings.ToLower(value)), " ")
+}
+
+func buildEntityGraph(rels []GraphRelationship, scopes ...string) *EntityGraph {
+	g := &EntityGraph{
+		nodes: map[string]*EntityNode{SelfEntity: {Key: SelfEntity, Value: SelfEntity}},
+		edges: map[string][]EntityEdge{},
+	}
+	byMemory := map[string][]string{}
+	for _, r := range rels {
+		if len(scopes) > 0 && !slices.Contains(scopes, r.Scope) {
+			continue
+		}
+		key := entityKey(r.EntityValue)
+		if key == "" {
+			continue
+		}
+		n := g.nodes[key]
+		if n == nil {
+			n = &EntityNode{Key: key, Value: strings.TrimSpace(r.EntityValue)}
+			g.nodes[key] = n
+		}
+		if n.Type == "" {
+			n.Type = r.EntityType
+		}
+		if r.RelationType != "" && !slices.Contains(n.Relations, r.RelationType) {
+			n.Relations = append(n.Relations, r.RelationType)
+		}
+		if !slices.Contains(n.MemoryIDs, r.MemoryID) {
+			n.MemoryIDs = append(n.MemoryIDs, r.MemoryID)
+		}
+		if r.RelationType != "" {
+			g.link(EntityEdge{From: SelfEntity, To: key, Relation: r.RelationType, MemoryID: r.MemoryID})
+		}
+		for _, other := range byMemory[r.MemoryID] {
+			if other != key {
+				g.link(EntityEdge{From: other, To: key, Relation: RelationMentionedWith, MemoryID: r.MemoryID})
+			}
+		}
+		if !slices.Contains(byMemory[r.MemoryID], key) {
+			byMemory[r.MemoryID] = append(byMemory[r.MemoryID], key)
+		}
+	}
+	return g
+}
+
+func (g *EntityGraph) link(e EntityEdge) {
+	for _, existing := range g.edges[e.From] {
+		if existing.To == e.To && existing.Relation == e.Relation {
+			return
+		}
+	}
+	g.edges[e.From] = append(g.edges[e.From], e)
+	g.edges[e.To] = append(g.edges[e.To], EntityEdge{From: e.To, To: e.From, Relation: e.Relation, MemoryID: e.MemoryID})
+}
+
+// Nodes returns every entity, SelfEntity excluded, sorted by key.
+func (g *EntityGraph) Nodes() []EntityNode {
+	nodes := make([]EntityNode, 0, len(g.nodes))
+	for key, n := range g.nodes {
+		if key != SelfEntity {
+			nodes = append(nodes, *n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Key < nodes[j].Key })
+	return nodes
+}
+
+// Node returns the entity named value.
+func (g *EntityGraph) Node(value string) (EntityNode, bool) {
+	if n, ok := g.nodes[entityKey(value)]; ok {
+		return *n, true
+	}
+	return EntityNode{}, false
+}
+
+// Edges returns the relationships of the entity named value.
+func (g *EntityGraph) Edges(value string) []EntityEdge {
+	return append([]EntityEdge(nil), g.edges[entityKey(value)]...)
+}
+
+// Match returns the keys of entities text refers to, by their full name or
+// by a word of their relation to the user, so "my lead" matches an entity
+// related as lead_developer.
+func (g *EntityGraph) Match(text string) []string {
+	stopWords := loadStopWords()
+	words := map[string]bool{}
+	for _, w := range tokenize(text, stopWords) {
+		words[w] = true
+	}
+	var keys []string
+	for key, n := range g.nodes {
+		if key == SelfEntity {
+			continue
+		}
+		if containsAllWords(words, tokenize(n.Value, nil)) || relationMatches(words, n.Relations, stopWords) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsAllWords(words map[string]bool, tokens []string) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	for _, t := range tokens {
+		if !words[t] {
+			return false
+		}
+	}
+	return true
+}
+
+func relationMatches(words map[string]bool, relations []string, stopWords map[string]bool) bool {
+	for _, r := range relations {
+		for _, t := range tokenize(r, stopWords) {
+			if words[t] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolve finds the node for an entity name, falling back to Match.
+func (g *EntityGraph) resolve(entity string) []string {
+	if entity == SelfEntity {
+		return []string{SelfEntity}
+	}
+	if _, ok := g.nodes[entityKey(entity)]; ok {
+		return []string{entityKey(entity)}
+	}
+	return g.Match(entity)
+}
+
+// Neighbors returns the entities within depth hops of entity, nearest first.
+// The matched entities themselves are returned at hop 0.
+func (g *EntityGraph) Neighbors(entity string, depth int) []EntityNeighbor {
+	return g.expand(g.resolve(entity), depth)
+}
+
+func (g *EntityGraph) expand(seeds []string, depth int) []EntityNeighbor {
+	hops := map[string]int{}
+	frontier := make([]string, 0, len(seeds))
+	for _, s := range seeds {
+		if _, ok := g.nodes[s]; ok {
+			if _, seen := hops[s]; !seen {
+				hops[s] = 0
+				frontier = append(frontier, s)
+			}
+		}
+	}
+	for hop := 1; hop <= depth && len(frontier) > 0; hop++ {
+		var next []string
+		for _, key := range frontier {
+			if key == SelfEntity && hop > 1 {
+				continue
+			}
+			for _, e := range g.edges[key] {
+				if _, seen := hops[e.To]; seen || e.To == SelfEntity {
+					continue
+				}
+				hops[e.To] = hop
+				next = append(next, e.To)
+			}
+		}
+		frontier = next
+	}
+
+	out := make([]EntityNeighbor, 0, len(hops))
+	for key, hop := range hops {
+		if key != SelfEntity {
+			out = append(out, EntityNeighbor{EntityNode: *g.nodes[key], Hops: hop})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Hops != out[j].Hops {
+			return out[i].Hops < out[j].Hops
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+// Paths returns the simple paths of at most maxDepth edges from one entity to
+// another, shortest first. Paths may start or end at SelfEntity but never
+// pass through it.
+func (g *EntityGraph) Paths(from, to string, maxDepth int) [][]EntityEdge {
+	if maxDepth <= 0 {
+		maxDepth = 3
+	}
+	targets := map[string]bool{}
+	for _, t := range g.resolve(to) {
+		targets[t] = true
+	}
+	var paths [][]EntityEdge
+	var walk func(key string, path []EntityEdge, visited map[string]bool)
+	walk = func(key string, path []EntityEdge, visited map[string]bool) {
+		if len(path) > 0 && targets[key] {
+			paths = append(paths, append([]EntityEdge(nil), path...))
+			return
+		}
+		if len(path) == maxDepth || (key == SelfEntity && len(path) > 0) {
+			return
+		}
+		for _, e := range g.edges[key] {
+			if visited[e.To] {
+				continue
+			}
+			visited[e.To] = true
+			walk(e.To, append(path, e), visited)
+			delete(visited, e.To)
+		}
+	}
+	for _, start := range g.resolve(from) {
+		walk(start, nil, map[string]bool{start: true})
+	}
+	sort.SliceStable(paths, func(i, j int) bool { return len(paths[i]) < len(paths[j]) })
+	return paths
+}
+
+// queryGraph adds the memories of entities related to the prompt to the
+// vector results: entities named or described in the prompt, or found in the
+// top results, and their neighbours up to Depth hops away.
+func (k *KarmaMemory) queryGraph(ctx context.Context, sq string, topK int, searchQuery filters) ([]Memory, error) {
+	base, err := k.queryVectorService(sq, topK, searchQuery)
+	if err != nil {
+		k.logger.Warn("karma_memory: graph vector query failed, using graph results only", zap.Error(err))
+	}
+	cfg := k.graph.cfg
+
+	g, graphErr := k.EntityGraph(k.userID, k.scope)
+	if graphErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("graph retrieval failed: %w", err)
+		}
+		k.logger.Warn("karma_memory: entity graph unavailable, using vector results only", zap.Error(graphErr))
+		return base, nil
+	}
+
+	seeds := g.Match(sq)
+	for _, m := range base {
+		for _, r := range m.EntityRelationships {
+			seeds = append(seeds, entityKey(r.EntityValue))
+		}
+	}
+	hops := map[string]int{}
+	for _, n := range g.expand(seeds, cfg.Depth) {
+		for _, id := range n.MemoryIDs {
+			if h, ok := hops[id]; !ok || n.Hops < h {
+				hops[id] = n.Hops
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for _, m := range base {
+		seen[memoryKey(m)] = true
+	}
+	if len(hops) == 0 {
+		return base, nil
+	}
+
+	ids := make([]string, 0, len(hops))
+	for id := range hops {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	records, err := k.memorydb.client.fetchVectors(ids, false)
+	if err != nil {
+		k.logger.Warn("karma_memory: graph memory lookup failed", zap.Error(err))
+		return base, nil
+	}
+	now := time.Now()
+	var related []Memory
+	for _, rec := range records {
+		m := rec.memories
+		if seen[memoryKey(m)] || !searchQuery.matchesMemory(m, k.scope) {
+			continue
+		}
+		if m.ExpiresAt != nil && !now.Before(*m.ExpiresAt) {
+			continue
+		}
+		related = append(related, m)
+	}
+	sort.SliceStable(related, func(i, j int) bool {
+		if hops[related[i].Id] != hops[related[j].Id] {
+			return hops[related[i].Id] < hops[related[j].Id]
+		}
+		return related[i].Importance > related[j].Importance
+	})
+	if len(related) > cfg.MaxExpanded {
+		related = related[:cfg.MaxExpanded]
+	}
+
+	k.logger.Debug("karma_memory: graph retrieval",
+		zap.Int("vector", len(base)),
+		zap.Int("seeds", len(seeds)),
+		zap.Int("expanded", len(related)))
+	return append(base, related...), nil
+}
+
+// matchesMemory applies a retrieval's scope, status, category and lifespan
+// filters to a memory fetched by id.
+func (f filters) matchesMemory(m Memory, scope string) bool {
+	if (f.IncludeAllScopes == nil || !*f.IncludeAllScopes) && m.Namespace != scope {
+		return false
+	}
+	if f.Status != nil && *f.Status != "" && m.Status != *f.Status {
+		return false
+	}
+	if f.Category != nil && *f.Category != "" && !matchesAny(string(m.Category), *f.Category) {
+		return false
+	}
+	if f.Lifespan != nil && *f.Lifespan != "" && !matchesAny(string(m.Lifespan), *f.Lifespan) {
+		return false
+	}
+	return true
+}
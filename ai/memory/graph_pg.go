@@ -0,0 +1,197 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/MelloB1989/karma/v2/orm"
+	"github.com/lib/pq"
+)
+
+// PgGraphConfig configures the Postgres entity graph store. Zero values use
+// the defaults noted on each field.
+type PgGraphConfig struct {
+	// Table defaults to karma_memory_entities.
+	Table string
+	// ORMOptions select the database, e.g. orm.WithDB to share your pool. By
+	// default DATABASE_URL is used.
+	ORMOptions []orm.Options
+	// SkipMigrate leaves schema creation to your own migrations; see
+	// PgGraphSchema.
+	SkipMigrate bool
+}
+
+func (c PgGraphConfig) withDefaults() (PgGraphConfig, error) {
+	if c.Table == "" {
+		c.Table = "karma_memory_entities"
+	}
+	if !pgIdentifier.MatchString(c.Table) {
+		return c, fmt.Errorf("invalid entity graph table name %q", c.Table)
+	}
+	return c, nil
+}
+
+// PgGraphSchema returns the statements the Postgres entity graph store
+// migrates with, for teams that run migrations themselves.
+func PgGraphSchema(cfg PgGraphConfig) (string, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %[1]s (
+	user_id       TEXT NOT NULL,
+	memory_id     TEXT NOT NULL,
+	scope         TEXT NOT NULL DEFAULT '',
+	entity_type   TEXT NOT NULL DEFAULT '',
+	relation_type TEXT NOT NULL DEFAULT '',
+	entity_value  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS %[1]s_memory ON %[1]s (user_id, memory_id);
+`, cfg.Table), nil
+}
+
+type pgGraphRow struct {
+	MemoryID     string `json:"memory_id"`
+	Scope        string `json:"scope"`
+	EntityType   string `json:"entity_type"`
+	RelationType string `json:"relation_type"`
+	EntityValue  string `json:"entity_value"`
+}
+
+type pgGraphStore struct {
+	table string
+	db    *orm.ORM
+}
+
+// NewPgGraphStore keeps entity graphs in a Postgres table, creating it unless
+// cfg.SkipMigrate is set. Graphs survive restarts and are shared between
+// processes.
+func NewPgGraphStore(cfg PgGraphConfig) (EntityGraphStore, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	store := &pgGraphStore{table: cfg.Table, db: orm.Load(tableEntity(cfg.Table), cfg.ORMOptions...)}
+	if cfg.SkipMigrate {
+		// Transactions start on a resolved pool, which the migration would
+		// otherwise have opened.
+		if _, err := store.db.ExecuteRaw("SELECT 1"); err != nil {
+			return nil, fmt.Errorf("entity graph connection failed: %w", err)
+		}
+		return store, nil
+	}
+	schema, err := PgGraphSchema(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := store.db.ExecuteRaw(schema); err != nil {
+		return nil, fmt.Errorf("entity graph migration failed: %w", err)
+	}
+	return store, nil
+}
+
+// inTx runs fn in a transaction bound to ctx, so cancelling ctx aborts it.
+func (s *pgGraphStore) inTx(ctx context.Context, readOnly bool, fn func(tx *orm.ORM) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
+	if err != nil {
+		return err
+	}
+	if err := fn(tx.ORM()); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *pgGraphStore) Index(ctx context.Context, userID string, memories []Memory) error {
+	ids := make([]string, 0, len(memories))
+	for _, m := range memories {
+		ids = append(ids, m.Id)
+	}
+	return s.inTx(ctx, false, func(tx *orm.ORM) error {
+		if err := s.remove(tx, userID, ids); err != nil {
+			return err
+		}
+		return s.insert(tx, userID, memories)
+	})
+}
+
+func (s *pgGraphStore) Replace(ctx context.Context, userID string, memories []Memory) error {
+	return s.inTx(ctx, false, func(tx *orm.ORM) error {
+		if _, err := tx.ExecuteRaw(fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, s.table), userID); err != nil {
+			return fmt.Errorf("entity graph reset failed: %w", err)
+		}
+		return s.insert(tx, userID, memories)
+	})
+}
+
+func (s *pgGraphStore) insert(tx *orm.ORM, userID string, memories []Memory) error {
+	var rows []string
+	var args []any
+	for _, m := range memories {
+		for _, r := range graphRelationships(m) {
+			n := len(args)
+			rows = append(rows, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
+			args = append(args, userID, r.MemoryID, r.Scope, r.EntityType, r.RelationType, r.EntityValue)
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, memory_id, scope, entity_type, relation_type, entity_value)
+VALUES %s`, s.table, strings.Join(rows, ",\n"))
+	if _, err := tx.ExecuteRaw(query, args...); err != nil {
+		return fmt.Errorf("entity graph insert failed: %w", err)
+	}
+	return nil
+}
+
+func (s *pgGraphStore) Remove(ctx context.Context, userID string, memoryIDs []string) error {
+	if len(memoryIDs) == 0 {
+		return nil
+	}
+	return s.inTx(ctx, false, func(tx *orm.ORM) error {
+		return s.remove(tx, userID, memoryIDs)
+	})
+}
+
+func (s *pgGraphStore) remove(tx *orm.ORM, userID string, memoryIDs []string) error {
+	if len(memoryIDs) == 0 {
+		return nil
+	}
+	_, err := tx.ExecuteRaw(fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 AND memory_id = ANY($2)`, s.table), userID, pq.Array(memoryIDs))
+	if err != nil {
+		return fmt.Errorf("entity graph delete failed: %w", err)
+	}
+	return nil
+}
+
+func (s *pgGraphStore) Relationships(ctx context.Context, userID string) ([]GraphRelationship, error) {
+	var rows []pgGraphRow
+	query := fmt.Sprintf(`SELECT memory_id, scope, entity_type, relation_type, entity_value FROM %s WHERE user_id = $1 ORDER BY memory_id`, s.table)
+	err := s.inTx(ctx, true, func(tx *orm.ORM) error {
+		return tx.QueryRaw(query, userID).Scan(&rows)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("entity graph query failed: %w", err)
+	}
+	rels := make([]GraphRelationship, 0, len(rows))
+	for _, r := range rows {
+		rels = append(rels, GraphRelationship{
+			MemoryID: r.MemoryID,
+			Scope:    r.Scope,
+			EntityRelationship: EntityRelationship{
+				EntityType:   r.EntityType,
+				RelationType: r.RelationType,
+				EntityValue:  r.EntityValue,
+			},
+		})
+	}
+	return rels, nil
+}
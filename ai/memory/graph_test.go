@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MelloB1989/karma/v2/orm"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestEntityGraphRetrieval(t *testing.T) {
+	mem := newLocalTestMemory(t, "alice")
+	mem.DisableCache()
+	mem.UseEntityGraph(GraphConfig{})
+	if _, err := mem.ImportMemories([]Memory{
+		{Id: "k1", Category: CategoryEntity, Summary: "Karthik is Alice's lead developer",
+			EntityRelationships: []EntityRelationship{{EntityType: "person", RelationType: "lead_developer", EntityValue: "Karthik"}}},
+		{Id: "k2", Category: CategoryPreference, Summary: "Karthik wants code reviews before Friday releases",
+			EntityRelationships: []EntityRelationship{{EntityType: "person", RelationType: "colleague", EntityValue: "karthik"}, {EntityType: "project", RelationType: "works_on", EntityValue: "Orion"}}},
+		{Id: "o1", Category: CategoryContext, Summary: "Orion ships every Friday",
+			EntityRelationships: []EntityRelationship{{EntityType: "project", RelationType: "works_on", EntityValue: "Orion"}}},
+		{Id: "p1", Category: CategoryEntity, Summary: "Priya is Alice's lead", Status: StatusSuperseded,
+			EntityRelationships: []EntityRelationship{{EntityType: "person", RelationType: "lead", EntityValue: "Priya"}}},
+		{Id: "t1", Category: CategoryPreference, Summary: "Alice likes tea"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	neighbors, err := mem.EntityNeighbors("my lead", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(neighbors) != 2 || neighbors[0].Key != "karthik" || neighbors[0].Hops != 0 || len(neighbors[0].MemoryIDs) != 2 ||
+		neighbors[1].Key != "orion" || neighbors[1].Hops != 1 {
+		t.Fatalf("neighbors = %+v", neighbors)
+	}
+
+	paths, err := mem.EntityPaths(SelfEntity, "Orion", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 || len(paths[0]) != 1 || paths[0][0].Relation != "works_on" ||
+		len(paths[1]) != 2 || paths[1][0].To != "karthik" || paths[1][1].Relation != RelationMentionedWith {
+		t.Fatalf("paths = %+v", paths)
+	}
+
+	mem.UseRetrievalMode(RetrievalModeGraph)
+	activeStatus := StatusActive
+	got, err := mem.queryRelevantMemories(context.Background(), "When does my lead want reviews?", 1, filters{Status: &activeStatus})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	for _, m := range got {
+		ids[m.Id] = true
+	}
+	if !ids["k1"] || !ids["k2"] || !ids["o1"] || ids["p1"] || len(got) > 4 {
+		t.Fatalf("graph retrieval = %v", ids)
+	}
+	if _, err := mem.GetContext("When does my lead want reviews?"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mem.markMemoryAsSuperseded("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if neighbors, _ := mem.EntityNeighbors("lead", 1); len(neighbors) != 0 {
+		t.Fatalf("superseded relation still matched: %+v", neighbors)
+	}
+
+	mem.UseEntityGraph(GraphConfig{})
+	g, err := mem.EntityGraph("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := g.Node("Karthik"); !ok || len(n.MemoryIDs) != 1 || n.Relations[0] != "colleague" {
+		t.Fatalf("rebuilt node = %+v", n)
+	}
+	if _, err := mem.PurgeUser("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if g, _ := mem.EntityGraph("alice"); len(g.Nodes()) != 0 {
+		t.Fatalf("purged graph = %+v", g.Nodes())
+	}
+}
+
+func TestPgGraphStore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS karma_memory_entities`).WillReturnResult(sqlmock.NewResult(0, 0))
+	store, err := NewPgGraphStore(PgGraphConfig{ORMOptions: []orm.Options{orm.WithDB(sqlx.NewDb(db, "sqlmock"))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM karma_memory_entities WHERE user_id = \$1 AND memory_id = ANY\(\$2\)`).
+		WithArgs("u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO karma_memory_entities \(user_id, memory_id, scope, entity_type, relation_type, entity_value\)`).
+		WithArgs("u1", "k1", "proj", "person", "lead_developer", "Karthik").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err = store.Index(ctx, "u1", []Memory{
+		{Id: "k1", Namespace: "proj", Status: StatusActive, EntityRelationships: []EntityRelationship{{EntityType: "person", RelationType: "lead_developer", EntityValue: "Karthik"}}},
+		{Id: "p1", Namespace: "proj", Status: StatusSuperseded, EntityRelationships: []EntityRelationship{{EntityValue: "Priya"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT memory_id, scope, entity_type, relation_type, entity_value FROM karma_memory_entities WHERE user_id = \$1`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"memory_id", "scope", "entity_type", "relation_type", "entity_value"}).
+			AddRow("k1", "proj", "person", "lead_developer", "Karthik"))
+	mock.ExpectCommit()
+	rels, err := store.Relationships(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rels) != 1 || rels[0].MemoryID != "k1" || rels[0].EntityValue != "Karthik" {
+		t.Fatalf("relationships = %+v", rels)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM karma_memory_entities WHERE user_id = \$1`).
+		WithArgs("u2").
+		WillReturnError(context.DeadlineExceeded)
+	mock.ExpectRollback()
+	if err := store.Replace(ctx, "u2", []Memory{{Id: "x", EntityRelationships: []EntityRelationship{{EntityValue: "X"}}}}); err == nil {
+		t.Fatal("a failed reset should abort the replace")
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := store.Remove(cancelled, "u1", []string{"k1"}); err == nil {
+		t.Fatal("a cancelled context should not reach the database")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PgGraphSchema(PgGraphConfig{Table: "entities; DROP TABLE users"}); err == nil {
+		t.Error("unsafe table names should be rejected")
+	}
+}
@@ -0,0 +1,373 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/MelloB1989/karma/ai"
+	"github.com/MelloB1989/karma/ai/parser"
+	"go.uber.org/zap"
+)
+
+// HybridConfig tunes RetrievalModeHybrid. Zero values use the defaults.
+type HybridConfig struct {
+	// CandidateMultiplier sets how many candidates each ranker contributes,
+	// as a multiple of topK; defaults to 4.
+	CandidateMultiplier int
+	// RRFK is the reciprocal rank fusion constant; defaults to 60.
+	RRFK float64
+	// K1 is the BM25 term saturation parameter; defaults to 1.2.
+	K1 float64
+	// B is the BM25 length normalisation, from 0 to 1; nil uses 0.75 and 0
+	// turns normalisation off.
+	B *float64
+	// Reranker, when set, reorders the fused candidates before they are cut
+	// to topK. A failing reranker falls back to the fused order.
+	Reranker Reranker
+}
+
+func (c HybridConfig) withDefaults() HybridConfig {
+	if c.CandidateMultiplier <= 0 {
+		c.CandidateMultiplier = 4
+	}
+	if c.RRFK <= 0 {
+		c.RRFK = 60
+	}
+	if c.K1 <= 0 {
+		c.K1 = 1.2
+	}
+	if c.B == nil || *c.B < 0 || *c.B > 1 {
+		b := 0.75
+		c.B = &b
+	}
+	return c
+}
+
+// Reranker reorders candidate memories by relevance to query, most relevant
+// first. It may drop candidates but must not add any.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, memories []Memory) ([]Memory, error)
+}
+
+// queryRelevantMemories returns the prompt-relevant memories for the current
+// retrieval mode.
+func (k *KarmaMemory) queryRelevantMemories(ctx context.Context, sq string, topK int, searchQuery filters) ([]Memory, error) {
+	switch k.retrievalMode {
+	case RetrievalModeHybrid:
+		return k.queryHybrid(ctx, sq, topK, searchQuery)
+	case RetrievalModeGraph:
+		if k.graph != nil {
+			return k.queryGraph(ctx, sq, topK, searchQuery)
+		}
+	}
+	return k.queryVectorService(sq, topK, searchQuery)
+}
+
+// queryHybrid fuses vector similarity with BM25 over Summary and RawText
+// using reciprocal rank fusion, then applies the optional reranker.
+func (k *KarmaMemory) queryHybrid(ctx context.Context, sq string, topK int, searchQuery filters) ([]Memory, error) {
+	cfg := k.hybrid.withDefaults()
+	candidates := topK * cfg.CandidateMultiplier
+
+	var wg sync.WaitGroup
+	var vectorRanked, corpus []Memory
+	var vectorErr, corpusErr error
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		vectorRanked, vectorErr = k.queryVectorService(sq, candidates, searchQuery)
+	}()
+	go func() {
+		defer wg.Done()
+		var metadatas []map[string]any
+		metadatas, corpusErr = k.memorydb.client.queryVectorByMetadata(searchQuery)
+		now := time.Now()
+		for _, m := range metadatas {
+			mem := metadataToMemory(m, "")
+			if mem.ExpiresAt != nil && !now.Before(*mem.ExpiresAt) {
+				continue
+			}
+			corpus = append(corpus, mem)
+		}
+	}()
+	wg.Wait()
+
+	if vectorErr != nil && corpusErr != nil {
+		return nil, fmt.Errorf("hybrid retrieval failed: %w", vectorErr)
+	}
+	if vectorErr != nil {
+		k.logger.Warn("karma_memory: hybrid vector query failed, using keyword results only", zap.Error(vectorErr))
+	}
+	if corpusErr != nil {
+		k.logger.Warn("karma_memory: hybrid keyword corpus query failed, using vector results only", zap.Error(corpusErr))
+	}
+
+	keywordRanked := bm25Rank(sq, corpus, cfg.K1, *cfg.B)
+	if len(keywordRanked) > candidates {
+		keywordRanked = keywordRanked[:candidates]
+	}
+	fused := reciprocalRankFusion(cfg.RRFK, vectorRanked, keywordRanked)
+	if len(fused) > candidates {
+		fused = fused[:candidates]
+	}
+
+	if cfg.Reranker != nil && len(fused) > 1 {
+		reranked, err := cfg.Reranker.Rerank(ctx, sq, fused)
+		if err != nil {
+			k.logger.Warn("karma_memory: rerank failed, using fused order", zap.Error(err))
+		} else {
+			fused = reranked
+		}
+	}
+	if len(fused) > topK {
+		fused = fused[:topK]
+	}
+
+	k.logger.Debug("karma_memory: hybrid retrieval",
+		zap.Int("vector", len(vectorRanked)),
+		zap.Int("keyword", len(keywordRanked)),
+		zap.Int("corpus", len(corpus)),
+		zap.Int("returned", len(fused)))
+	return fused, nil
+}
+
+// memoryKey identifies a memory across result lists.
+func memoryKey(m Memory) string {
+	if m.Id != "" {
+		return m.Id
+	}
+	return normalizeSummary(m.Summary)
+}
+
+// tokenize lowercases s and splits it into words, dropping stop words and
+// single characters.
+func tokenize(s string, stopWords map[string]bool) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, w := range words {
+		if len(w) > 1 && !stopWords[w] {
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+// bm25Rank scores docs against query with Okapi BM25 over Summary and
+// RawText, returning the docs that match at least one term, best first.
+func bm25Rank(query string, docs []Memory, k1, b float64) []Memory {
+	stopWords := loadStopWords()
+	terms := tokenize(query, stopWords)
+	if len(terms) == 0 || len(docs) == 0 {
+		return []Memory{}
+	}
+
+	freqs := make([]map[string]int, len(docs))
+	lengths := make([]int, len(docs))
+	docFreq := map[string]int{}
+	total := 0
+	for i, d := range docs {
+		tokens := tokenize(d.Summary+" "+d.RawText, stopWords)
+		freqs[i] = map[string]int{}
+		for _, t := range tokens {
+			if freqs[i][t] == 0 {
+				docFreq[t]++
+			}
+			freqs[i][t]++
+		}
+		lengths[i] = len(tokens)
+		total += len(tokens)
+	}
+	avgLen := float64(total) / float64(len(docs))
+	if avgLen == 0 {
+		return []Memory{}
+	}
+
+	n := float64(len(docs))
+	type scored struct {
+		memory Memory
+		score  float64
+	}
+	results := make([]scored, 0)
+	for i, d := range docs {
+		var score float64
+		for _, t := range terms {
+			tf := float64(freqs[i][t])
+			if tf == 0 {
+				continue
+			}
+			df := float64(docFreq[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(lengths[i])/avgLen))
+		}
+		if score > 0 {
+			results = append(results, scored{memory: d, score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].score > results[j].score })
+
+	ranked := make([]Memory, len(results))
+	for i, r := range results {
+		ranked[i] = r.memory
+	}
+	return ranked
+}
+
+// reciprocalRankFusion merges ranked lists, scoring each memory by the sum of
+// 1/(k+rank) over the lists it appears in.
+func reciprocalRankFusion(k float64, lists ...[]Memory) []Memory {
+	scores := map[string]float64{}
+	memories := map[string]Memory{}
+	order := make([]string, 0)
+	for _, list := range lists {
+		seen := map[string]bool{}
+		for rank, m := range list {
+			key := memoryKey(m)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if _, ok := memories[key]; !ok {
+				memories[key] = m
+				order = append(order, key)
+			}
+			scores[key] += 1 / (k + float64(rank+1))
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	fused := make([]Memory, len(order))
+	for i, key := range order {
+		fused[i] = memories[key]
+	}
+	return fused
+}
+
+// LLMReranker asks a chat model to order the candidates.
+type LLMReranker struct {
+	AI *ai.KarmaAI
+}
+
+// NewLLMReranker returns a reranker backed by model. It defaults to
+// GPT-4o-Mini on OpenAI.
+func NewLLMReranker(model ...*ai.KarmaAI) *LLMReranker {
+	if len(model) > 0 && model[0] != nil {
+		return &LLMReranker{AI: model[0]}
+	}
+	return &LLMReranker{AI: ai.NewKarmaAI(ai.GPT4oMini, ai.OpenAI, ai.WithTemperature(0))}
+}
+
+func (r *LLMReranker) Rerank(ctx context.Context, query string, memories []Memory) ([]Memory, error) {
+	var b strings.Builder
+	for i, m := range memories {
+		fmt.Fprintf(&b, "[%d] (%s) %s\n", i, m.Category, m.Summary)
+	}
+	var out struct {
+		Order []int `json:"order" description:"indices of the relevant memories, most relevant first"`
+	}
+	p := parser.NewParser(parser.WithAIClient(r.AI))
+	prompt := fmt.Sprintf("Rank the memories below by how useful they are for answering the user's message. Leave out memories that are irrelevant.\n\nUser message: %s\n\nMemories:\n%s", query, b.String())
+	if _, _, err := p.Parse(prompt, "", &out); err != nil {
+		return nil, fmt.Errorf("llm rerank failed: %w", err)
+	}
+	return pickByIndex(memories, out.Order), nil
+}
+
+// CrossEncoderReranker calls a Cohere or Jina compatible /rerank endpoint,
+// such as a self-hosted cross-encoder.
+type CrossEncoderReranker struct {
+	URL    string
+	APIKey string
+	Model  string
+	// MinScore drops candidates scoring below it.
+	MinScore   float64
+	HTTPClient *http.Client
+}
+
+func (r *CrossEncoderReranker) Rerank(ctx context.Context, query string, memories []Memory) ([]Memory, error) {
+	documents := make([]string, len(memories))
+	for i, m := range memories {
+		documents[i] = m.Summary
+		if m.RawText != "" {
+			documents[i] = m.Summary + "\n" + m.RawText
+		}
+	}
+	payload, err := json.Marshal(map[string]any{
+		"model":     r.Model,
+		"query":     query,
+		"documents": documents,
+		"top_n":     len(documents),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.APIKey)
+	}
+	client := r.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rerank request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("rerank failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var out struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("invalid rerank response: %w", err)
+	}
+	sort.SliceStable(out.Results, func(i, j int) bool { return out.Results[i].RelevanceScore > out.Results[j].RelevanceScore })
+	order := make([]int, 0, len(out.Results))
+	for _, res := range out.Results {
+		if res.RelevanceScore >= r.MinScore {
+			order = append(order, res.Index)
+		}
+	}
+	return pickByIndex(memories, order), nil
+}
+
+// pickByIndex returns memories in the given order, skipping invalid and
+// repeated indices.
+func pickByIndex(memories []Memory, order []int) []Memory {
+	picked := make([]Memory, 0, len(order))
+	seen := map[int]bool{}
+	for _, i := range order {
+		if i < 0 || i >= len(memories) || seen[i] {
+			continue
+		}
+		seen[i] = true
+		picked = append(picked, memories[i])
+	}
+	return picked
+}
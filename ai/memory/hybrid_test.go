@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBM25AndFusion(t *testing.T) {
+	docs := []Memory{
+		{Id: "a", Summary: "User prefers dark mode in every editor"},
+		{Id: "b", Summary: "User deploys with Kubernetes", RawText: "our kubernetes cluster runs on GKE"},
+		{Id: "c", Summary: "User likes coffee"},
+	}
+	ranked := bm25Rank("how is the kubernetes cluster set up?", docs, 1.2, 0.75)
+	if len(ranked) != 1 || ranked[0].Id != "b" {
+		t.Fatalf("bm25 = %+v", ranked)
+	}
+	if got := bm25Rank("the and", docs, 1.2, 0.75); len(got) != 0 {
+		t.Fatalf("stop-word query matched %+v", got)
+	}
+
+	fused := reciprocalRankFusion(60, []Memory{docs[0], docs[2], docs[1]}, []Memory{docs[1], docs[2]})
+	if len(fused) != 3 || fused[0].Id != "b" || fused[2].Id != "a" {
+		t.Fatalf("fused = %v, %v, %v", fused[0].Id, fused[1].Id, fused[2].Id)
+	}
+}
+
+func TestHybridConfigKeepsZeroB(t *testing.T) {
+	if b := *(HybridConfig{}).withDefaults().B; b != 0.75 {
+		t.Fatalf("default B = %v", b)
+	}
+	zero := 0.0
+	if b := *(HybridConfig{B: &zero}).withDefaults().B; b != 0 {
+		t.Fatalf("explicit B = %v, want 0", b)
+	}
+}
+
+func TestCrossEncoderReranker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string   `json:"query"`
+			Documents []string `json:"documents"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("Authorization") != "Bearer key" || len(body.Documents) != 3 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"results":[{"index":0,"relevance_score":0.1},{"index":2,"relevance_score":0.9},{"index":1,"relevance_score":0.5}]}`))
+	}))
+	defer srv.Close()
+
+	r := &CrossEncoderReranker{URL: srv.URL, APIKey: "key", MinScore: 0.2}
+	got, err := r.Rerank(context.Background(), "q", []Memory{{Id: "a"}, {Id: "b"}, {Id: "c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Id != "c" || got[1].Id != "b" {
+		t.Fatalf("reranked = %+v", got)
+	}
+}
+
+type reverseReranker struct{ calls int }
+
+func (r *reverseReranker) Rerank(ctx context.Context, query string, memories []Memory) ([]Memory, error) {
+	r.calls++
+	out := make([]Memory, 0, len(memories))
+	for i := len(memories) - 1; i >= 0; i-- {
+		out = append(out, memories[i])
+	}
+	return out, nil
+}
+
+func TestHybridRetrieval(t *testing.T) {
+	mem := newLocalTestMemory(t, "alice")
+	mem.DisableCache()
+	if _, err := mem.ImportMemories([]Memory{
+		{Id: "m1", Category: CategoryFact, Summary: "Alice uses Go"},
+		{Id: "m2", Category: CategoryContext, Summary: "Alice is migrating the billing service to Postgres"},
+		{Id: "m3", Category: CategoryPreference, Summary: "Alice likes short answers"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	mem.UseRetrievalMode(RetrievalModeHybrid)
+
+	activeStatus := StatusActive
+	f := filters{Status: &activeStatus}
+	got, err := mem.queryRelevantMemories(context.Background(), "what database does billing use?", 1, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Id != "m2" {
+		t.Fatalf("hybrid = %+v", got)
+	}
+
+	rr := &reverseReranker{}
+	mem.UseHybridConfig(HybridConfig{Reranker: rr})
+	got, err = mem.queryRelevantMemories(context.Background(), "billing postgres", 3, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.calls != 1 || len(got) != 3 || got[2].Id != "m2" {
+		t.Fatalf("reranked hybrid = %+v", got)
+	}
+
+	if _, err := mem.GetContext("billing"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/MelloB1989/karma/ai/parser"
@@ -12,45 +13,68 @@ import (
 )
 
 type m struct {
-	Operation               string           `json:"operation"`
-	Id                      *string          `json:"id"`
-	Category                MemoryCategory   `json:"category"`
-	Summary                 string           `json:"summary"`
-	RawText                 string           `json:"raw_text"`
-	Importance              int              `json:"importance"`
-	Mutability              MemoryMutability `json:"mutability"`
-	Lifespan                MemoryLifespan   `json:"lifespan"`
-	ForgetScore             float64          `json:"forget_score"`
-	Status                  MemoryStatus     `json:"status"`
-	SupersedesCanonicalKeys []string         `json:"supersedes_canonical_keys"`
-	Metadata                json.RawMessage  `json:"metadata"`
-	SupersedesMemoryId      *string          `json:"supersedes_memory_id,omitempty"`
+	Operation               string               `json:"operation"`
+	Id                      *string              `json:"id"`
+	Category                MemoryCategory       `json:"category"`
+	Summary                 string               `json:"summary"`
+	RawText                 string               `json:"raw_text"`
+	Importance              int                  `json:"importance"`
+	Mutability              MemoryMutability     `json:"mutability"`
+	Lifespan                MemoryLifespan       `json:"lifespan"`
+	ForgetScore             float64              `json:"forget_score"`
+	Status                  MemoryStatus         `json:"status"`
+	SupersedesCanonicalKeys []string             `json:"supersedes_canonical_keys"`
+	Metadata                json.RawMessage      `json:"metadata"`
+	SupersedesMemoryId      *string              `json:"supersedes_memory_id,omitempty"`
+	EntityRelationships     []EntityRelationship `json:"entity_relationships"`
 }
 
 type memoriesWrapper struct {
 	Memories []m `json:"memories"`
 }
 
-func (k *KarmaMemory) ingest(convo struct {
-	UserMessage          string
-	AIResponse           string
-	CurrentMemoryContext string
-}) error {
-	p := parser.NewParser(parser.WithAIClient(k.memoryAI), parser.WithDebug(false))
+// ingestTurn is one user message and assistant reply to extract memories from.
+type ingestTurn struct {
+	UserMessage          string `json:"user_message"`
+	AIResponse           string `json:"ai_response"`
+	CurrentMemoryContext string `json:"current_memory_context"`
+}
 
-	prompt := fmt.Sprintf(`Extract memories from this conversation.
+func ingestPrompt(turns []ingestTurn) string {
+	if len(turns) == 1 {
+		return fmt.Sprintf(`Extract memories from this conversation.
 CurrentMemoryContext: %s
 UserMessage: %s
 AIResponse: %s
 Return a JSON object with a "memories" array containing all extracted memories.
-If no memories should be stored, return: {"memories": []}`, convo.CurrentMemoryContext, convo.UserMessage, convo.AIResponse)
+If no memories should be stored, return: {"memories": []}`, turns[0].CurrentMemoryContext, turns[0].UserMessage, turns[0].AIResponse)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Extract memories from these conversation turns, oldest first.\nCurrentMemoryContext: %s\n", turns[len(turns)-1].CurrentMemoryContext)
+	for i, t := range turns {
+		fmt.Fprintf(&b, "Turn %d:\nUserMessage: %s\nAIResponse: %s\n", i+1, t.UserMessage, t.AIResponse)
+	}
+	b.WriteString(`Later turns override earlier ones. Return a JSON object with a "memories" array containing all extracted memories.
+If no memories should be stored, return: {"memories": []}`)
+	return b.String()
+}
+
+// ingest extracts memories from one or more turns of the current user and
+// scope with a single memory LLM call, and stores them.
+func (k *KarmaMemory) ingest(turns ...ingestTurn) error {
+	if len(turns) == 0 {
+		return nil
+	}
+	p := parser.NewParser(parser.WithAIClient(k.memoryAI), parser.WithDebug(false))
 
 	var wrapper memoriesWrapper
-	if _, _, err := p.Parse(prompt, "", &wrapper); err != nil {
+	if _, _, err := p.Parse(ingestPrompt(turns), "", &wrapper); err != nil {
 		k.logger.Error("karma_memory: failed to parse memories", zap.Error(err))
 		return err
 	}
 
+	wrapper.Memories = k.dedupeExtracted(wrapper.Memories)
 	if len(wrapper.Memories) == 0 {
 		k.logger.Debug("karma_memory: no memories extracted from conversation")
 		return nil
@@ -61,9 +85,12 @@ If no memories should be stored, return: {"memories": []}`, convo.CurrentMemoryC
 	var vc []v
 	var vd []string
 	var memoriesIdsToSupersede []string
-	var newMemories []Memory
+	// created are stored with vc; updated were written in place.
+	var created, updated []Memory
 
 	categoriesToInvalidate := make(map[MemoryCategory]bool)
+	var embedded, embedFailures int
+	var embedErr, storeErr error
 
 	for _, memory := range wrapper.Memories {
 		now := time.Now()
@@ -109,6 +136,7 @@ If no memories should be stored, return: {"memories": []}`, convo.CurrentMemoryC
 			Status:                  memory.Status,
 			SupersedesCanonicalKeys: memory.SupersedesCanonicalKeys,
 			Metadata:                memory.Metadata,
+			EntityRelationships:     memory.EntityRelationships,
 			Id:                      memoryId,
 			CreatedAt:               now,
 			UpdatedAt:               now,
@@ -137,29 +165,34 @@ If no memories should be stored, return: {"memories": []}`, convo.CurrentMemoryC
 		}
 
 		switch k.memorydb.currentService {
-		case VectorServiceUpstash:
+		case VectorServiceUpstash, VectorServiceLocal, VectorServicePgvector, VectorServiceQdrant:
 			embeddings, err := k.getEmbeddings(embeddingText)
 			if err != nil {
 				k.logger.Error("karma_memory: failed to generate embeddings",
 					zap.String("memoryID", mem.Id),
 					zap.Error(err))
+				embedFailures++
+				embedErr = err
 				continue
 			}
+			embedded++
 			if memory.Operation == "create" {
 				vc = append(vc, v{memories: *mem, vector: embeddings})
-				newMemories = append(newMemories, *mem)
+				created = append(created, *mem)
 			} else if memory.Operation == "update" {
-				k.memorydb.client.updateVector(*mem, embeddings)
-				newMemories = append(newMemories, *mem)
+				if k.updateStored(*mem, embeddings) {
+					updated = append(updated, *mem)
+				}
 			}
 
 		case VectorServicePinecone:
 			if memory.Operation == "create" {
 				vc = append(vc, v{memories: *mem})
-				newMemories = append(newMemories, *mem)
+				created = append(created, *mem)
 			} else if memory.Operation == "update" {
-				k.memorydb.client.updateVector(*mem)
-				newMemories = append(newMemories, *mem)
+				if k.updateStored(*mem) {
+					updated = append(updated, *mem)
+				}
 			}
 		}
 	}
@@ -172,22 +205,29 @@ If no memories should be stored, return: {"memories": []}`, convo.CurrentMemoryC
 		}
 	}
 
+	stored := updated
 	if len(vc) > 0 {
 		if err := k.memorydb.client.upsertVectors(vc); err != nil {
 			k.logger.Error("karma_memory: failed to upsert vectors", zap.Error(err))
+			storeErr = fmt.Errorf("failed to upsert memories: %w", err)
 		} else {
 			k.logger.Info("karma_memory: upserted memories", zap.Int("count", len(vc)))
+			stored = append(stored, created...)
 		}
 	}
 
+	var deleted []string
 	if len(vd) > 0 {
 		if count, err := k.memorydb.client.deleteVectors(vd); err != nil {
 			k.logger.Error("karma_memory: failed to delete vectors", zap.Error(err))
 		} else {
 			k.logger.Info("karma_memory: deleted memories", zap.Int("count", count))
+			deleted = vd
 		}
 	}
 
+	k.syncGraph(stored, deleted)
+
 	if k.IsCacheEnabled() {
 		ctx := context.Background()
 
@@ -202,11 +242,17 @@ If no memories should be stored, return: {"memories": []}`, convo.CurrentMemoryC
 			}
 		}
 
-		if len(newMemories) > 0 {
-			k.cacheNewMemories(ctx, newMemories)
+		if len(stored) > 0 {
+			k.cacheNewMemories(ctx, stored)
 		}
 	}
 
+	if storeErr != nil {
+		return storeErr
+	}
+	if embedFailures > 0 && embedded == 0 {
+		return fmt.Errorf("failed to embed %d memories: %w", embedFailures, embedErr)
+	}
 	return nil
 }
 
@@ -245,3 +291,55 @@ func (k *KarmaMemory) cacheNewMemories(ctx context.Context, memories []Memory) {
 		}
 	}
 }
+
+// dedupeExtracted drops repeated memories in one extraction, and new memories
+// that already exist verbatim, before anything is embedded.
+func (k *KarmaMemory) dedupeExtracted(memories []m) []m {
+	active := StatusActive
+	existing := map[MemoryCategory]map[string]bool{}
+	seen := map[string]bool{}
+	out := make([]m, 0, len(memories))
+	for _, mem := range memories {
+		summary := normalizeSummary(mem.Summary)
+		key := mem.Operation + "|" + string(mem.Category) + "|" + summary
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if mem.Operation == "create" && summary != "" {
+			known, ok := existing[mem.Category]
+			if !ok {
+				known = map[string]bool{}
+				category := string(mem.Category)
+				if metadatas, err := k.memorydb.client.queryVectorByMetadata(filters{Category: &category, Status: &active}); err == nil {
+					for _, md := range metadatas {
+						if s, ok := md["summary"].(string); ok {
+							known[normalizeSummary(s)] = true
+						}
+					}
+				}
+				existing[mem.Category] = known
+			}
+			if known[summary] {
+				k.logger.Debug("karma_memory: skipping duplicate memory", zap.String("summary", mem.Summary))
+				continue
+			}
+		}
+		out = append(out, mem)
+	}
+	return out
+}
+
+// updateStored writes an updated memory in place and reports whether it was
+// stored.
+func (k *KarmaMemory) updateStored(m Memory, embeddings ...[]float32) bool {
+	ok, err := k.memorydb.client.updateVector(m, embeddings...)
+	if err != nil || !ok {
+		k.logger.Warn("karma_memory: failed to update memory",
+			zap.String("memoryID", m.Id),
+			zap.Error(err))
+		return false
+	}
+	return true
+}
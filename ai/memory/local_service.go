@@ -0,0 +1,407 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MelloB1989/karma/config"
+	"github.com/upstash/vector-go"
+	"go.uber.org/zap"
+)
+
+// localVectorRecord is one stored memory. Metadata is kept in its JSON decoded
+// form so results look the same as those returned by the hosted services.
+type localVectorRecord struct {
+	Vector   []float32      `json:"vector"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+// localVectorStore holds every user namespace of one in-process index. Stores
+// are shared per file path, so clients switching users see the same data the
+// way they would with a hosted index.
+//
+// The file holds a JSON snapshot of every namespace followed by a journal of
+// the changes made since, one JSON value per write. Writes append to the
+// journal, and the file is rewritten as a fresh snapshot once the journal
+// outgrows the store.
+type localVectorStore struct {
+	mu         sync.RWMutex
+	path       string
+	namespaces map[string]map[string]localVectorRecord
+	// journaled counts the entries appended since the last snapshot.
+	journaled int
+}
+
+// localJournalEntry is one change appended after the snapshot: a put of
+// Record, or a delete when Record is nil.
+type localJournalEntry struct {
+	User   string             `json:"user"`
+	ID     string             `json:"id"`
+	Record *localVectorRecord `json:"record,omitempty"`
+}
+
+// localJournalMin is the journal length below which the file is never
+// compacted, however small the store.
+const localJournalMin = 1000
+
+var (
+	localStoresMu sync.Mutex
+	localStores   = map[string]*localVectorStore{}
+)
+
+func openLocalVectorStore(path string) (*localVectorStore, error) {
+	localStoresMu.Lock()
+	defer localStoresMu.Unlock()
+	if s, ok := localStores[path]; ok {
+		return s, nil
+	}
+	s := &localVectorStore{path: path, namespaces: map[string]map[string]localVectorRecord{}}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read local vector store: %w", err)
+		}
+		if len(data) > 0 {
+			if err := s.load(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	localStores[path] = s
+	return s, nil
+}
+
+// load reads the snapshot and replays the journal after it. A journal entry
+// cut short by a crash ends the replay; the next write compacts the file.
+func (s *localVectorStore) load(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&s.namespaces); err != nil {
+		return fmt.Errorf("failed to decode local vector store: %w", err)
+	}
+	if s.namespaces == nil {
+		s.namespaces = map[string]map[string]localVectorRecord{}
+	}
+	for {
+		var e localJournalEntry
+		if err := dec.Decode(&e); err != nil {
+			if err != io.EOF {
+				s.journaled = math.MaxInt / 2
+			}
+			return nil
+		}
+		s.apply(e)
+		s.journaled++
+	}
+}
+
+func (s *localVectorStore) apply(e localJournalEntry) {
+	ns := s.namespaces[e.User]
+	if e.Record == nil {
+		delete(ns, e.ID)
+		return
+	}
+	if ns == nil {
+		ns = map[string]localVectorRecord{}
+		s.namespaces[e.User] = ns
+	}
+	ns[e.ID] = *e.Record
+}
+
+// size counts the records across all namespaces.
+func (s *localVectorStore) size() int {
+	n := 0
+	for _, ns := range s.namespaces {
+		n += len(ns)
+	}
+	return n
+}
+
+// persist records changes, already applied in memory, in the store's file,
+// if any. Callers hold s.mu.
+func (s *localVectorStore) persist(changes ...localJournalEntry) error {
+	if s.path == "" || len(changes) == 0 {
+		return nil
+	}
+	if s.journaled+len(changes) > max(localJournalMin, s.size()) {
+		return s.snapshot()
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if os.IsNotExist(err) {
+		return s.snapshot()
+	}
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range changes {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	s.journaled += len(changes)
+	return f.Close()
+}
+
+// snapshot rewrites the file as a snapshot of the whole store, dropping the
+// journal. Callers hold s.mu.
+func (s *localVectorStore) snapshot() error {
+	data, err := json.Marshal(s.namespaces)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.journaled = 0
+	return nil
+}
+
+type localVectorClient struct {
+	userId string
+	scope  string
+	store  *localVectorStore
+	logger *zap.Logger
+}
+
+// newLocalClient returns an in-process vector service using brute-force cosine
+// search. Set KARMA_MEMORY_LOCAL_VECTOR_PATH to persist it to a JSON file.
+func newLocalClient(userId, scope string, logger *zap.Logger) vectorService {
+	store, err := openLocalVectorStore(config.GetEnvRaw("KARMA_MEMORY_LOCAL_VECTOR_PATH"))
+	if err != nil {
+		logger.Error("[KARMA_MEMORY] failed to open local vector store, using an empty one", zap.Error(err))
+		store = &localVectorStore{namespaces: map[string]map[string]localVectorRecord{}}
+	}
+	return &localVectorClient{
+		userId: userId,
+		scope:  scope,
+		store:  store,
+		logger: logger,
+	}
+}
+
+func (d *localVectorClient) upsertVectors(vectors []v) error {
+	d.store.mu.Lock()
+	defer d.store.mu.Unlock()
+	ns := d.store.namespaces[d.userId]
+	if ns == nil {
+		ns = map[string]localVectorRecord{}
+		d.store.namespaces[d.userId] = ns
+	}
+	changes := make([]localJournalEntry, 0, len(vectors))
+	for _, v := range vectors {
+		m, err := memoryMetadata(v.memories)
+		if err != nil {
+			d.logger.Error("Failed to map memory metadata", zap.Error(err))
+			continue
+		}
+		rec := localVectorRecord{Vector: append([]float32(nil), v.vector...), Metadata: m}
+		ns[v.memories.Id] = rec
+		changes = append(changes, localJournalEntry{User: d.userId, ID: v.memories.Id, Record: &rec})
+	}
+	return d.store.persist(changes...)
+}
+
+func (d *localVectorClient) queryVector(q []float32, topK int, fs ...filters) ([]vector.VectorScore, error) {
+	var f *filters
+	if len(fs) > 0 {
+		f = &fs[0]
+	}
+	if len(q) == 0 {
+		return nil, fmt.Errorf("no query vector provided")
+	}
+
+	d.store.mu.RLock()
+	defer d.store.mu.RUnlock()
+	scores := make([]vector.VectorScore, 0)
+	for id, rec := range d.store.namespaces[d.userId] {
+		if !d.matchesFilter(rec.Metadata, f) {
+			continue
+		}
+		scores = append(scores, vector.VectorScore{
+			Id:       id,
+			Score:    cosineSimilarity(q, rec.Vector),
+			Metadata: rec.Metadata,
+		})
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+	if topK > 0 && len(scores) > topK {
+		scores = scores[:topK]
+	}
+	return scores, nil
+}
+
+func (d *localVectorClient) fetchVectors(ids []string, withVectors bool) ([]v, error) {
+	d.store.mu.RLock()
+	defer d.store.mu.RUnlock()
+	records := make([]v, 0, len(ids))
+	for _, id := range ids {
+		rec, ok := d.store.namespaces[d.userId][id]
+		if !ok {
+			continue
+		}
+		record := v{memories: metadataToMemory(rec.Metadata, id)}
+		if withVectors {
+			record.vector = slices.Clone(rec.Vector)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (d *localVectorClient) queryVectorByMetadata(f filters) ([]map[string]any, error) {
+	d.store.mu.RLock()
+	defer d.store.mu.RUnlock()
+	metadatas := make([]map[string]any, 0)
+	for _, rec := range d.store.namespaces[d.userId] {
+		if d.matchesFilter(rec.Metadata, &f) {
+			metadatas = append(metadatas, rec.Metadata)
+		}
+	}
+	return metadatas, nil
+}
+
+// matchesFilter applies the same filters as the Upstash and Pinecone services.
+func (d *localVectorClient) matchesFilter(metadata map[string]any, f *filters) bool {
+	if f == nil || f.IncludeAllScopes == nil || !*f.IncludeAllScopes {
+		if ns, ok := metadata["namespace"].(string); !ok || ns != d.scope {
+			return false
+		}
+	}
+	if f == nil {
+		return true
+	}
+	if f.Category != nil && *f.Category != "" && !matchesAny(metadata["category"], *f.Category) {
+		return false
+	}
+	if f.Lifespan != nil && *f.Lifespan != "" && !matchesAny(metadata["lifespan"], *f.Lifespan) {
+		return false
+	}
+	if f.Importance != nil && *f.Importance != 0 {
+		if imp, ok := metadata["importance"].(float64); !ok || int(imp) != *f.Importance {
+			return false
+		}
+	}
+	if f.Status != nil && *f.Status != "" {
+		if status, ok := metadata["status"].(string); !ok || status != string(*f.Status) {
+			return false
+		}
+	}
+	if f.Expiry != nil {
+		exp, ok := metadata["expires_at"].(string)
+		if !ok {
+			return false
+		}
+		t, err := time.Parse(time.RFC3339, exp)
+		if err != nil || t.After(*f.Expiry) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAny reports whether value equals one of the comma separated options.
+func matchesAny(value any, options string) bool {
+	s, ok := value.(string)
+	if !ok {
+		return false
+	}
+	for _, o := range strings.Split(options, ",") {
+		if strings.TrimSpace(o) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// updateVector replaces the stored memory. Without a vector the existing one
+// is kept.
+func (d *localVectorClient) updateVector(memory Memory, v ...[]float32) (bool, error) {
+	m, err := memoryMetadata(memory)
+	if err != nil {
+		d.logger.Error("Failed to map memory metadata", zap.Error(err))
+		return false, err
+	}
+
+	d.store.mu.Lock()
+	defer d.store.mu.Unlock()
+	ns := d.store.namespaces[d.userId]
+	rec, ok := ns[memory.Id]
+	if !ok {
+		return false, fmt.Errorf("memory %s not found", memory.Id)
+	}
+	if len(v) > 0 && len(v[0]) > 0 {
+		rec = localVectorRecord{Vector: append([]float32(nil), v[0]...), Metadata: m}
+	} else {
+		rec.Metadata = m
+	}
+	ns[memory.Id] = rec
+	return true, d.store.persist(localJournalEntry{User: d.userId, ID: memory.Id, Record: &rec})
+}
+
+func (d *localVectorClient) deleteVectors(vectorsIds []string) (count int, err error) {
+	d.store.mu.Lock()
+	defer d.store.mu.Unlock()
+	ns := d.store.namespaces[d.userId]
+	var changes []localJournalEntry
+	for _, id := range vectorsIds {
+		if _, ok := ns[id]; ok {
+			delete(ns, id)
+			count++
+			changes = append(changes, localJournalEntry{User: d.userId, ID: id})
+		}
+	}
+	return count, d.store.persist(changes...)
+}
+
+func (d *localVectorClient) shiftScope(scope string) string {
+	d.scope = scope
+	return d.scope
+}
+
+func (d *localVectorClient) shiftUser(userId string) string {
+	d.userId = userId
+	return d.userId
+}
+
+func (d *localVectorClient) forUser(userId, scope string) vectorService {
+	return &localVectorClient{userId: userId, scope: scope, store: d.store, logger: d.logger}
+}
+
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(na) * math.Sqrt(nb)))
+}
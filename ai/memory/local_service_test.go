@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestLocalVectorService(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	t.Setenv("KARMA_MEMORY_LOCAL_VECTOR_PATH", path)
+	logger := zap.NewNop()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	expiry := now.Add(time.Hour)
+	client := newLocalClient("user_1", "proj", logger)
+	err := client.upsertVectors([]v{
+		{memories: Memory{Id: "m1", Namespace: "proj", Category: CategoryFact, Summary: "uses postgres", Lifespan: LifespanLongTerm, Status: StatusActive, Importance: 3, CreatedAt: now, ExpiresAt: &expiry}, vector: []float32{1, 0, 0}},
+		{memories: Memory{Id: "m2", Namespace: "proj", Category: CategoryRule, Summary: "write tests first", Lifespan: LifespanLifelong, Status: StatusActive}, vector: []float32{0, 1, 0}},
+		{memories: Memory{Id: "m3", Namespace: "other", Category: CategoryFact, Summary: "likes tea", Status: StatusActive}, vector: []float32{1, 0, 0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scores, err := client.queryVector([]float32{0.9, 0.1, 0}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 || scores[0].Id != "m1" {
+		t.Fatalf("scores = %+v", scores)
+	}
+	mem := metadataToMemory(scores[0].Metadata, scores[0].Id)
+	if mem.Summary != "uses postgres" || mem.Importance != 3 || !mem.CreatedAt.Equal(now) || mem.ExpiresAt == nil {
+		t.Fatalf("memory = %+v", mem)
+	}
+
+	category := "rule, skill"
+	rules, _ := client.queryVectorByMetadata(filters{Category: &category})
+	if len(rules) != 1 || rules[0]["summary"] != "write tests first" {
+		t.Fatalf("rules = %v", rules)
+	}
+	all := true
+	facts, _ := client.queryVectorByMetadata(filters{Category: ptrStr("fact"), IncludeAllScopes: &all})
+	if len(facts) != 2 {
+		t.Fatalf("all-scope facts = %v", facts)
+	}
+	later := now.Add(2 * time.Hour)
+	if expiring, _ := client.queryVectorByMetadata(filters{Expiry: &later}); len(expiring) != 1 {
+		t.Fatalf("expiring = %v", expiring)
+	}
+
+	recs, err := client.fetchVectors([]string{"m1"}, false)
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("fetch = %v, %v", recs, err)
+	}
+	superseded := recs[0].memories
+	superseded.Status = StatusSuperseded
+	if _, err := client.updateVector(superseded); err != nil {
+		t.Fatal(err)
+	}
+	active := StatusActive
+	scores, _ = client.queryVector([]float32{1, 0, 0}, 5, filters{Status: &active})
+	if len(scores) != 1 || scores[0].Id != "m2" {
+		t.Fatalf("active scores = %+v", scores)
+	}
+
+	if n, _ := client.deleteVectors([]string{"m2", "missing"}); n != 1 {
+		t.Fatalf("deleted %d", n)
+	}
+
+	// A fresh store must load what was persisted
+	localStoresMu.Lock()
+	delete(localStores, path)
+	localStoresMu.Unlock()
+	reopened := newLocalClient("user_1", "proj", logger)
+	reloaded, _ := reopened.queryVectorByMetadata(filters{Category: ptrStr("fact")})
+	if len(reloaded) != 1 || reloaded[0]["status"] != string(StatusSuperseded) || reloaded[0]["summary"] != "uses postgres" {
+		t.Fatalf("reloaded = %v", reloaded)
+	}
+	reopened.shiftUser("user_2")
+	if others, _ := reopened.queryVectorByMetadata(filters{}); len(others) != 0 {
+		t.Fatal("users should not share memories")
+	}
+}
+
+func TestLocalVectorStoreJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.json")
+	t.Setenv("KARMA_MEMORY_LOCAL_VECTOR_PATH", path)
+	client := newLocalClient("user_1", "proj", zap.NewNop())
+	for i := range 3 {
+		id := fmt.Sprintf("m%d", i)
+		if err := client.upsertVectors([]v{{memories: Memory{Id: id, Namespace: "proj", Summary: id}, vector: []float32{1, 0}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.deleteVectors([]string{"m0"}); err != nil {
+		t.Fatal(err)
+	}
+	store := client.(*localVectorClient).store
+	if store.journaled != 3 {
+		t.Fatalf("journaled = %d; writes after the first snapshot should append", store.journaled)
+	}
+
+	// A write cut short by a crash drops only itself.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"user":"user_1","id":"m9","rec`)
+	f.Close()
+
+	localStoresMu.Lock()
+	delete(localStores, path)
+	localStoresMu.Unlock()
+	reopened := newLocalClient("user_1", "proj", zap.NewNop())
+	got, _ := reopened.fetchVectors([]string{"m0", "m1", "m2", "m9"}, false)
+	if len(got) != 2 || got[0].memories.Id != "m1" || got[1].memories.Id != "m2" {
+		t.Fatalf("replayed = %+v", got)
+	}
+
+	// The next write compacts the torn journal into a snapshot.
+	if _, err := reopened.deleteVectors([]string{"m1"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	var snapshot map[string]map[string]localVectorRecord
+	if err := json.Unmarshal(data, &snapshot); err != nil || len(snapshot["user_1"]) != 1 {
+		t.Fatalf("compacted file = %s (%v)", data, err)
+	}
+}
@@ -3,6 +3,7 @@ package memory
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/MelloB1989/karma/ai"
 	"github.com/MelloB1989/karma/models"
@@ -25,6 +26,19 @@ const (
 	// Best for: Fast retrieval, predictable behavior, lower cost.
 	// Tradeoff: Less intelligent filtering, may retrieve less relevant memories.
 	RetrievalModeAuto RetrievalMode = "auto"
+
+	// RetrievalModeHybrid runs the auto strategy, but ranks relevant memories by fusing
+	// vector similarity with BM25 keyword scores over summaries and raw text.
+	// Best for: Prompts with names, identifiers or rare terms that embeddings blur.
+	// Tradeoff: Loads the scope's matching memories to score keywords; reranking adds a call.
+	RetrievalModeHybrid RetrievalMode = "hybrid"
+
+	// RetrievalModeGraph runs the auto strategy, then follows the entity graph
+	// from entities named or described in the prompt ("my lead") and in the top
+	// results, adding the memories of related entities. Requires UseEntityGraph.
+	// Best for: People, teams and projects referred to by role or relation.
+	// Tradeoff: Loads the scope's matching memories to expand; bounded by GraphConfig.
+	RetrievalModeGraph RetrievalMode = "graph"
 )
 
 type KarmaMemory struct {
@@ -39,6 +53,9 @@ type KarmaMemory struct {
 	scope                string
 	logger               *zap.Logger
 	retrievalMode        RetrievalMode
+	hybrid               HybridConfig
+	graph                *entityGraphState
+	ingestQueue          *IngestQueue
 	currentMemoryContext string
 	cacheEnabled         bool
 }
@@ -122,6 +139,19 @@ func (k *KarmaMemory) UseService(service VectorServices) error {
 	return k.memorydb.switchService(k.userID, k.scope, service)
 }
 
+// UsePgvector stores memories in a pgvector table, migrating it unless
+// cfg.SkipMigrate is set. UseService(VectorServicePgvector) does the same with
+// the default config.
+func (k *KarmaMemory) UsePgvector(cfg PgvectorConfig) error {
+	return k.memorydb.usePgvector(k.userID, k.scope, cfg)
+}
+
+// UseQdrant stores memories in a Qdrant collection, created with its payload
+// indexes on first write. It fails when the server does not answer.
+func (k *KarmaMemory) UseQdrant(cfg QdrantConfig) error {
+	return k.memorydb.useQdrant(k.userID, k.scope, cfg)
+}
+
 func (k *KarmaMemory) UseLogger(logger *zap.Logger) {
 	k.logger = logger
 }
@@ -130,6 +160,17 @@ func (k *KarmaMemory) UseRetrievalMode(mode RetrievalMode) {
 	k.retrievalMode = mode
 }
 
+// UseIngestQueue hands conversation turns to q instead of ingesting each one
+// in its own goroutine. Pass nil to go back to per-turn ingestion.
+func (k *KarmaMemory) UseIngestQueue(q *IngestQueue) {
+	k.ingestQueue = q
+}
+
+// UseHybridConfig tunes RetrievalModeHybrid, including its optional reranker.
+func (k *KarmaMemory) UseHybridConfig(cfg HybridConfig) {
+	k.hybrid = cfg
+}
+
 func (k *KarmaMemory) EnableCache(cfg CacheConfig) {
 	k.cache = NewCache(k.logger, cfg)
 	k.cacheEnabled = k.cache.IsEnabled()
@@ -197,6 +238,10 @@ func (k *KarmaMemory) NumberOfMessages() int {
 	return len(k.messagesHistory.Messages)
 }
 
+// ingestEnqueueTimeout bounds how long a turn waits for room in a full
+// ingest queue.
+var ingestEnqueueTimeout = 2 * time.Second
+
 // Advanced implementations require custom logic to manage message history, in such cases below function can be used to update the message history
 func (k *KarmaMemory) UpdateMessageHistory(messages []models.AIMessage) {
 	k.messagesHistory.Messages = messages
@@ -224,13 +269,29 @@ func (k *KarmaMemory) UpdateMessageHistory(messages []models.AIMessage) {
 		k.GetContext(lastUserMsg)
 	}
 
+	if lastUserMsg != "" && lastAIMsg != "" && k.ingestQueue != nil {
+		// A queue set to BlockWhenFull must not stall the chat path behind a
+		// backlog, so the wait is bounded and the turn dropped past it.
+		ctx, cancel := context.WithTimeout(context.Background(), ingestEnqueueTimeout)
+		defer cancel()
+		if err := k.ingestQueue.Enqueue(ctx, IngestJob{
+			UserID:        k.userID,
+			Scope:         k.scope,
+			UserMessage:   lastUserMsg,
+			AIResponse:    lastAIMsg,
+			MemoryContext: k.currentMemoryContext,
+		}); err != nil {
+			k.logger.Warn("karma_memory: failed to queue memory ingestion, turn dropped",
+				zap.String("userID", k.userID),
+				zap.String("scope", k.scope),
+				zap.Error(err))
+		}
+		return
+	}
+
 	if lastUserMsg != "" && lastAIMsg != "" {
 		go func() {
-			if err := k.ingest(struct {
-				UserMessage          string
-				AIResponse           string
-				CurrentMemoryContext string
-			}{
+			if err := k.ingest(ingestTurn{
 				UserMessage:          lastUserMsg,
 				AIResponse:           lastAIMsg,
 				CurrentMemoryContext: k.currentMemoryContext,
@@ -271,7 +332,7 @@ func (k *KarmaMemory) GetContext(userPrompt string) (string, error) {
 			searchQuery.SearchQuery = sq
 		}
 
-	case RetrievalModeAuto:
+	case RetrievalModeAuto, RetrievalModeHybrid, RetrievalModeGraph:
 		maxTokens = 800
 		topK = 5
 
@@ -575,7 +636,7 @@ func (k *KarmaMemory) getContextAuto(ctx context.Context, sq string, searchQuery
 		vectorWg.Add(1)
 		go func() {
 			defer vectorWg.Done()
-			relevant, err := k.queryVectorService(sq, topK, searchQuery)
+			relevant, err := k.queryRelevantMemories(ctx, sq, topK, searchQuery)
 			if err != nil {
 				k.logger.Warn("karma_memory: relevant memories query failed", zap.Error(err))
 				return
@@ -706,7 +767,7 @@ func (k *KarmaMemory) getContextAuto(ctx context.Context, sq string, searchQuery
 
 		go func() {
 			defer wg.Done()
-			relevant, err := k.queryVectorService(sq, topK, searchQuery)
+			relevant, err := k.queryRelevantMemories(ctx, sq, topK, searchQuery)
 			if err != nil {
 				k.logger.Warn("karma_memory: relevant memories query failed", zap.Error(err))
 				return
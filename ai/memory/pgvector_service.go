@@ -0,0 +1,393 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MelloB1989/karma/v2/orm"
+	"github.com/lib/pq"
+	"github.com/upstash/vector-go"
+	"go.uber.org/zap"
+)
+
+// PgvectorIndex is the approximate nearest neighbour index built on the
+// embedding column.
+type PgvectorIndex string
+
+const (
+	PgvectorIndexHNSW    PgvectorIndex = "hnsw"
+	PgvectorIndexIVFFlat PgvectorIndex = "ivfflat"
+	// PgvectorIndexNone keeps exact (sequential) search, fine for small tables.
+	PgvectorIndexNone PgvectorIndex = "none"
+)
+
+// PgvectorConfig configures the pgvector service. Zero values use the
+// defaults noted on each field.
+type PgvectorConfig struct {
+	// Table defaults to karma_memories.
+	Table string
+	// Dimensions must match the embedding model; defaults to 1536, the size
+	// of text-embedding-3-small.
+	Dimensions int
+	// Index defaults to PgvectorIndexHNSW.
+	Index PgvectorIndex
+	// HNSWM and HNSWEfConstruction default to pgvector's 16 and 64.
+	HNSWM              int
+	HNSWEfConstruction int
+	// IVFFlatLists defaults to 100.
+	IVFFlatLists int
+	// ORMOptions select the database, e.g. orm.WithDB to share your pool or
+	// orm.WithDatabasePrefix. By default DATABASE_URL is used.
+	ORMOptions []orm.Options
+	// SkipMigrate leaves schema creation to your own migrations; see
+	// PgvectorSchema.
+	SkipMigrate bool
+}
+
+var pgIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// tableEntity returns a row entity naming table in its karma_table tag, so an
+// ORM loaded from it targets the configured table rather than a fixed one.
+func tableEntity(table string) any {
+	t := reflect.StructOf([]reflect.StructField{{
+		Name: "TableName",
+		Type: reflect.TypeOf(struct{}{}),
+		Tag:  reflect.StructTag(fmt.Sprintf(`karma_table:%q`, table)),
+	}})
+	return reflect.New(t).Interface()
+}
+
+func (c PgvectorConfig) withDefaults() (PgvectorConfig, error) {
+	if c.Table == "" {
+		c.Table = "karma_memories"
+	}
+	if !pgIdentifier.MatchString(c.Table) {
+		return c, fmt.Errorf("invalid pgvector table name %q", c.Table)
+	}
+	if c.Dimensions <= 0 {
+		c.Dimensions = 1536
+	}
+	if c.Index == "" {
+		c.Index = PgvectorIndexHNSW
+	}
+	if c.HNSWM <= 0 {
+		c.HNSWM = 16
+	}
+	if c.HNSWEfConstruction <= 0 {
+		c.HNSWEfConstruction = 64
+	}
+	if c.IVFFlatLists <= 0 {
+		c.IVFFlatLists = 100
+	}
+	return c, nil
+}
+
+// PgvectorSchema returns the statements the pgvector service migrates with,
+// for teams that run migrations themselves.
+func PgvectorSchema(cfg PgvectorConfig) (string, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	schema := fmt.Sprintf(`
+CREATE EXTENSION IF NOT EXISTS vector;
+CREATE TABLE IF NOT EXISTS %[1]s (
+	user_id    TEXT NOT NULL,
+	id         TEXT NOT NULL,
+	namespace  TEXT NOT NULL DEFAULT '',
+	category   TEXT NOT NULL DEFAULT '',
+	lifespan   TEXT NOT NULL DEFAULT '',
+	status     TEXT NOT NULL DEFAULT '',
+	importance INT NOT NULL DEFAULT 0,
+	expires_at TIMESTAMPTZ,
+	metadata   JSONB NOT NULL,
+	embedding  vector(%[2]d),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (user_id, id)
+);
+CREATE INDEX IF NOT EXISTS %[1]s_filter ON %[1]s (user_id, namespace, category, status);
+`, cfg.Table, cfg.Dimensions)
+
+	switch cfg.Index {
+	case PgvectorIndexHNSW:
+		schema += fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_embedding ON %[1]s USING hnsw (embedding vector_cosine_ops) WITH (m = %[2]d, ef_construction = %[3]d);\n",
+			cfg.Table, cfg.HNSWM, cfg.HNSWEfConstruction)
+	case PgvectorIndexIVFFlat:
+		schema += fmt.Sprintf("CREATE INDEX IF NOT EXISTS %[1]s_embedding ON %[1]s USING ivfflat (embedding vector_cosine_ops) WITH (lists = %[2]d);\n",
+			cfg.Table, cfg.IVFFlatLists)
+	case PgvectorIndexNone:
+	default:
+		return "", fmt.Errorf("unknown pgvector index %q", cfg.Index)
+	}
+	return schema, nil
+}
+
+type pgvectorRow struct {
+	Id       string         `json:"id"`
+	Metadata map[string]any `json:"metadata" db:"metadata"`
+	Score    float64        `json:"score"`
+	// Embedding is the vector's text form, e.g. "[0.6,0.8]".
+	Embedding string `json:"embedding"`
+}
+
+type pgvectorClient struct {
+	userId string
+	scope  string
+	table  string
+	db     *orm.ORM
+	logger *zap.Logger
+}
+
+// newPgvectorClient opens the table, creating it and its indexes unless
+// cfg.SkipMigrate is set.
+func newPgvectorClient(userId, scope string, logger *zap.Logger, cfg PgvectorConfig) (*pgvectorClient, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	client := &pgvectorClient{
+		userId: userId,
+		scope:  scope,
+		table:  cfg.Table,
+		db:     orm.Load(tableEntity(cfg.Table), cfg.ORMOptions...),
+		logger: logger,
+	}
+	if !cfg.SkipMigrate {
+		schema, err := PgvectorSchema(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := client.db.ExecuteRaw(schema); err != nil {
+			return nil, fmt.Errorf("pgvector migration failed: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// pgvectorLiteral formats v in pgvector's text form; empty vectors are NULL.
+func pgvectorLiteral(v []float32) any {
+	if len(v) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(x), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// pgvectorValues returns the namespace through metadata column values.
+func pgvectorValues(m Memory) ([]any, error) {
+	md, err := memoryMetadata(m)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(md)
+	if err != nil {
+		return nil, err
+	}
+	return []any{m.Namespace, string(m.Category), string(m.Lifespan), string(m.Status), m.Importance, m.ExpiresAt, string(data)}, nil
+}
+
+func (d *pgvectorClient) upsertVectors(vectors []v) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+	rows := make([]string, 0, len(vectors))
+	args := make([]any, 0, len(vectors)*10)
+	for _, v := range vectors {
+		values, err := pgvectorValues(v.memories)
+		if err != nil {
+			d.logger.Error("Failed to map memory metadata", zap.Error(err))
+			continue
+		}
+		n := len(args)
+		rows = append(rows, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d::jsonb, $%d::vector, now())",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10))
+		args = append(args, d.userId, v.memories.Id)
+		args = append(args, values...)
+		args = append(args, pgvectorLiteral(v.vector))
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, id, namespace, category, lifespan, status, importance, expires_at, metadata, embedding, updated_at)
+VALUES %s
+ON CONFLICT (user_id, id) DO UPDATE SET
+	namespace = EXCLUDED.namespace,
+	category = EXCLUDED.category,
+	lifespan = EXCLUDED.lifespan,
+	status = EXCLUDED.status,
+	importance = EXCLUDED.importance,
+	expires_at = EXCLUDED.expires_at,
+	metadata = EXCLUDED.metadata,
+	embedding = EXCLUDED.embedding,
+	updated_at = now()`, d.table, strings.Join(rows, ",\n"))
+	if _, err := d.db.ExecuteRaw(query, args...); err != nil {
+		return fmt.Errorf("pgvector upsert failed: %w", err)
+	}
+	return nil
+}
+
+// buildWhere turns filters into SQL conditions, appending their arguments.
+func (d *pgvectorClient) buildWhere(f *filters, args []any) (string, []any) {
+	args = append(args, d.userId)
+	parts := []string{fmt.Sprintf("user_id = $%d", len(args))}
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		parts = append(parts, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f == nil || f.IncludeAllScopes == nil || !*f.IncludeAllScopes {
+		add("namespace = $%d", d.scope)
+	}
+	if f != nil {
+		if f.Category != nil && *f.Category != "" {
+			add("category = ANY($%d)", pq.Array(splitList(*f.Category)))
+		}
+		if f.Lifespan != nil && *f.Lifespan != "" {
+			add("lifespan = ANY($%d)", pq.Array(splitList(*f.Lifespan)))
+		}
+		if f.Importance != nil && *f.Importance != 0 {
+			add("importance = $%d", *f.Importance)
+		}
+		if f.Expiry != nil {
+			add("expires_at <= $%d", *f.Expiry)
+		}
+		if f.Status != nil && *f.Status != "" {
+			add("status = $%d", string(*f.Status))
+		}
+	}
+	return strings.Join(parts, " AND "), args
+}
+
+func splitList(s string) []string {
+	items := strings.Split(s, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+func (d *pgvectorClient) queryVector(q []float32, topK int, fs ...filters) ([]vector.VectorScore, error) {
+	var f *filters
+	if len(fs) > 0 {
+		f = &fs[0]
+	}
+	if len(q) == 0 {
+		return nil, fmt.Errorf("no query vector provided")
+	}
+
+	where, args := d.buildWhere(f, []any{pgvectorLiteral(q)})
+	args = append(args, topK)
+	query := fmt.Sprintf(`SELECT id, metadata, 1 - (embedding <=> $1::vector) AS score FROM %s
+WHERE %s AND embedding IS NOT NULL
+ORDER BY embedding <=> $1::vector
+LIMIT $%d`, d.table, where, len(args))
+
+	var rows []pgvectorRow
+	if err := d.db.QueryRaw(query, args...).Scan(&rows); err != nil {
+		return nil, fmt.Errorf("pgvector query failed: %w", err)
+	}
+	scores := make([]vector.VectorScore, 0, len(rows))
+	for _, r := range rows {
+		scores = append(scores, vector.VectorScore{Id: r.Id, Score: float32(r.Score), Metadata: r.Metadata})
+	}
+	return scores, nil
+}
+
+func (d *pgvectorClient) fetchVectors(ids []string, withVectors bool) ([]v, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	columns := "id, metadata"
+	if withVectors {
+		columns += ", embedding::text AS embedding"
+	}
+	var rows []pgvectorRow
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE user_id = $1 AND id = ANY($2)`, columns, d.table)
+	if err := d.db.QueryRaw(query, d.userId, pq.Array(ids)).Scan(&rows); err != nil {
+		return nil, fmt.Errorf("pgvector fetch failed: %w", err)
+	}
+	records := make([]v, 0, len(rows))
+	for _, r := range rows {
+		record := v{memories: metadataToMemory(r.Metadata, r.Id)}
+		if r.Embedding != "" {
+			if err := json.Unmarshal([]byte(r.Embedding), &record.vector); err != nil {
+				return nil, fmt.Errorf("pgvector embedding of %s: %w", r.Id, err)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (d *pgvectorClient) queryVectorByMetadata(f filters) ([]map[string]any, error) {
+	where, args := d.buildWhere(&f, nil)
+	var rows []pgvectorRow
+	if err := d.db.QueryRaw(fmt.Sprintf(`SELECT id, metadata FROM %s WHERE %s`, d.table, where), args...).Scan(&rows); err != nil {
+		return nil, fmt.Errorf("pgvector metadata query failed: %w", err)
+	}
+	metadatas := make([]map[string]any, 0, len(rows))
+	for _, r := range rows {
+		metadatas = append(metadatas, r.Metadata)
+	}
+	return metadatas, nil
+}
+
+// updateVector replaces the stored memory, keeping the embedding when no
+// vector is given.
+func (d *pgvectorClient) updateVector(memory Memory, v ...[]float32) (bool, error) {
+	values, err := pgvectorValues(memory)
+	if err != nil {
+		return false, err
+	}
+	embedding := "embedding"
+	args := append([]any{d.userId, memory.Id}, values...)
+	if len(v) > 0 && len(v[0]) > 0 {
+		embedding = "$10::vector"
+		args = append(args, pgvectorLiteral(v[0]))
+	}
+	query := fmt.Sprintf(`UPDATE %s SET namespace = $3, category = $4, lifespan = $5, status = $6, importance = $7,
+	expires_at = $8, metadata = $9::jsonb, embedding = %s, updated_at = now()
+WHERE user_id = $1 AND id = $2`, d.table, embedding)
+
+	res, err := d.db.ExecuteRaw(query, args...)
+	if err != nil {
+		return false, fmt.Errorf("pgvector update failed: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (d *pgvectorClient) deleteVectors(vectorsIds []string) (count int, err error) {
+	res, err := d.db.ExecuteRaw(fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 AND id = ANY($2)`, d.table), d.userId, pq.Array(vectorsIds))
+	if err != nil {
+		return 0, fmt.Errorf("pgvector delete failed: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (d *pgvectorClient) shiftScope(scope string) string {
+	d.scope = scope
+	return d.scope
+}
+
+func (d *pgvectorClient) shiftUser(userId string) string {
+	d.userId = userId
+	return d.userId
+}
+
+func (d *pgvectorClient) forUser(userId, scope string) vectorService {
+	return &pgvectorClient{userId: userId, scope: scope, table: d.table, db: d.db, logger: d.logger}
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MelloB1989/karma/v2/orm"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+func TestPgvectorSchema(t *testing.T) {
+	schema, err := PgvectorSchema(PgvectorConfig{Dimensions: 768, Index: PgvectorIndexIVFFlat, IVFFlatLists: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"embedding  vector(768)", "USING ivfflat (embedding vector_cosine_ops) WITH (lists = 50)", "PRIMARY KEY (user_id, id)"} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("schema is missing %q", want)
+		}
+	}
+	if _, err := PgvectorSchema(PgvectorConfig{Table: "memories; DROP TABLE users"}); err == nil {
+		t.Error("unsafe table names should be rejected")
+	}
+}
+
+func TestPgvectorClient(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`CREATE EXTENSION IF NOT EXISTS vector;.*USING hnsw`).WillReturnResult(sqlmock.NewResult(0, 0))
+	client, err := newPgvectorClient("u1", "proj", zap.NewNop(), PgvectorConfig{ORMOptions: []orm.Options{orm.WithDB(sqlx.NewDb(db, "sqlmock"))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec(`INSERT INTO karma_memories .* ON CONFLICT \(user_id, id\) DO UPDATE`).
+		WithArgs("u1", "m1", "proj", "fact", "long_term", "active", 2, nil, sqlmock.AnyArg(), "[0.5,0.25]").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	err = client.upsertVectors([]v{{
+		memories: Memory{Id: "m1", Namespace: "proj", Category: CategoryFact, Lifespan: LifespanLongTerm, Status: StatusActive, Importance: 2, Summary: "uses go"},
+		vector:   []float32{0.5, 0.25},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	category, status := "fact, rule", StatusActive
+	mock.ExpectQuery(`SELECT id, metadata, 1 - \(embedding <=> \$1::vector\) AS score FROM karma_memories\s+WHERE user_id = \$2 AND namespace = \$3 AND category = ANY\(\$4\) AND status = \$5 AND embedding IS NOT NULL\s+ORDER BY embedding <=> \$1::vector\s+LIMIT \$6`).
+		WithArgs("[1,0]", "u1", "proj", sqlmock.AnyArg(), "active", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "metadata", "score"}).
+			AddRow("m1", []byte(`{"summary":"uses go","category":"fact","importance":2}`), 0.9))
+	scores, err := client.queryVector([]float32{1, 0}, 5, filters{Category: &category, Status: &status})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 1 || scores[0].Id != "m1" || scores[0].Score < 0.89 || metadataToMemory(scores[0].Metadata, "m1").Summary != "uses go" {
+		t.Fatalf("scores = %+v", scores)
+	}
+
+	// Without a vector the memory is still replaced in full, so the cleared
+	// lifespan and expiry are written rather than skipped.
+	mock.ExpectExec(`UPDATE karma_memories SET namespace = \$3, .* embedding = embedding, updated_at = now\(\)`).
+		WithArgs("u1", "m1", "proj", "fact", "", "superseded", 2, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if ok, err := client.updateVector(Memory{Id: "m1", Namespace: "proj", Category: CategoryFact, Status: StatusSuperseded, Importance: 2, Summary: "uses go"}); err != nil || !ok {
+		t.Fatalf("update = %v, %v", ok, err)
+	}
+
+	mock.ExpectExec(`DELETE FROM karma_memories WHERE user_id = \$1 AND id = ANY\(\$2\)`).
+		WithArgs("u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	if n, err := client.deleteVectors([]string{"m1", "m2"}); err != nil || n != 2 {
+		t.Fatalf("delete = %d, %v", n, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -18,11 +18,35 @@ type pineconeVectorClient struct {
 	userId string
 	scope  string
 	client *pinecone.Client
-	idx    *pinecone.IndexConnection
+	idx    pineconeIndex
 	logger *zap.Logger
 	ctx    context.Context
 }
 
+// pineconeIndex is the part of a Pinecone index connection the service uses.
+type pineconeIndex interface {
+	UpsertRecords(ctx context.Context, records []*pinecone.IntegratedRecord) error
+	UpsertVectors(ctx context.Context, in []*pinecone.Vector) (uint32, error)
+	QueryByVectorValues(ctx context.Context, in *pinecone.QueryByVectorValuesRequest) (*pinecone.QueryVectorsResponse, error)
+	FetchVectors(ctx context.Context, ids []string) (*pinecone.FetchVectorsResponse, error)
+	ListVectors(ctx context.Context, in *pinecone.ListVectorsRequest) (*pinecone.ListVectorsResponse, error)
+	UpdateVector(ctx context.Context, in *pinecone.UpdateVectorRequest) error
+	DeleteVectorsById(ctx context.Context, ids []string) error
+	DeleteAllVectorsInNamespace(ctx context.Context) error
+	SearchRecords(ctx context.Context, in *pinecone.SearchRecordsRequest) (*pinecone.SearchRecordsResponse, error)
+	// WithNamespace returns the index scoped to namespace, sharing the connection.
+	WithNamespace(namespace string) pineconeIndex
+}
+
+// pineconeConn adapts *pinecone.IndexConnection to pineconeIndex.
+type pineconeConn struct {
+	*pinecone.IndexConnection
+}
+
+func (c pineconeConn) WithNamespace(namespace string) pineconeIndex {
+	return pineconeConn{c.IndexConnection.WithNamespace(namespace)}
+}
+
 func newPineconeClient(userId, scope string, logger *zap.Logger) vectorService {
 	apiKey := config.GetEnvRaw("KARMA_MEMORY_PINECONE_API_KEY")
 	indexHost := config.GetEnvRaw("KARMA_MEMORY_PINECONE_INDEX_HOST")
@@ -56,7 +80,7 @@ func newPineconeClient(userId, scope string, logger *zap.Logger) vectorService {
 		scope:  scope,
 		logger: logger,
 		client: pc,
-		idx:    idxConnection,
+		idx:    pineconeConn{idxConnection},
 		ctx:    ctx,
 	}
 
@@ -304,6 +328,33 @@ func (d *pineconeVectorClient) queryVector(v []float32, topK int, fs ...filters)
 	return scores, nil
 }
 
+func (d *pineconeVectorClient) fetchVectors(ids []string, withVectors bool) ([]v, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	res, err := d.idx.FetchVectors(d.ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("pinecone fetch vectors failed: %w", err)
+	}
+	records := make([]v, 0, len(res.Vectors))
+	for _, id := range ids {
+		vec, ok := res.Vectors[id]
+		if !ok || vec == nil {
+			continue
+		}
+		metadata := map[string]any{}
+		if vec.Metadata != nil {
+			metadata = vec.Metadata.AsMap()
+		}
+		record := v{memories: metadataToMemory(metadata, id)}
+		if withVectors && vec.Values != nil {
+			record.vector = *vec.Values
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
 func (d *pineconeVectorClient) queryVectorByMetadata(f filters) ([]map[string]any, error) {
 	var metadataFilter map[string]any
 
@@ -392,20 +443,26 @@ func (d *pineconeVectorClient) queryVectorByMetadata(f filters) ([]map[string]an
 			},
 		})
 	}
-	if len(filterConditions) == 0 {
+	// An all-scopes query without other filters lists the whole namespace
+	listAll := len(filterConditions) == 0 && f.IncludeAllScopes != nil && *f.IncludeAllScopes
+	if len(filterConditions) == 0 && !listAll {
 		return nil, fmt.Errorf("no metadata filters provided")
 	}
 	if len(filterConditions) > 1 {
 		metadataFilter = map[string]any{
 			"$and": filterConditions,
 		}
-	} else {
+	} else if len(filterConditions) == 1 {
 		metadataFilter = filterConditions[0].(map[string]any)
 	}
 
-	filterStruct, err := structpb.NewStruct(d.sanitizeFilterForProto(metadataFilter))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create filter struct: %w", err)
+	var filterStruct *structpb.Struct
+	if !listAll {
+		var err error
+		filterStruct, err = structpb.NewStruct(d.sanitizeFilterForProto(metadataFilter))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create filter struct: %w", err)
+		}
 	}
 
 	limit := uint32(100)
@@ -434,22 +491,21 @@ func (d *pineconeVectorClient) queryVectorByMetadata(f filters) ([]map[string]an
 			}
 		}
 
+		// A skipped page would hide memories from export and purge, so fail.
 		fetchRes, err := d.idx.FetchVectors(d.ctx, vectorIds)
 		if err != nil {
-			d.logger.Warn("Failed to fetch vectors", zap.Error(err))
-			if listRes.NextPaginationToken == nil {
-				break
-			}
-			paginationToken = listRes.NextPaginationToken
-			continue
+			return nil, fmt.Errorf("pinecone fetch vectors failed: %w", err)
 		}
 
-		for _, vec := range fetchRes.Vectors {
-			if d.matchesPineconeFilter(vec.Metadata, filterStruct) {
+		for id, vec := range fetchRes.Vectors {
+			if listAll || d.matchesPineconeFilter(vec.Metadata, filterStruct) {
 				metadataMap := make(map[string]any)
 				if vec.Metadata != nil {
 					metadataMap = vec.Metadata.AsMap()
 				}
+				if _, ok := metadataMap["id"]; !ok {
+					metadataMap["id"] = id
+				}
 
 				allMemories = append(allMemories, metadataMap)
 			}
@@ -678,10 +734,20 @@ func (d *pineconeVectorClient) shiftUser(userId string) string {
 		return d.userId
 	}
 
-	d.idx = idxConnection
+	d.idx = pineconeConn{idxConnection}
 	return d.userId
 }
 
+// purgeUser drops the user's namespace, which holds all of their vectors.
+func (d *pineconeVectorClient) purgeUser() error {
+	return d.idx.DeleteAllVectorsInNamespace(d.ctx)
+}
+
+// forUser shares the gRPC connection, scoped to userId's namespace.
+func (d *pineconeVectorClient) forUser(userId, scope string) vectorService {
+	return &pineconeVectorClient{userId: userId, scope: scope, client: d.client, idx: d.idx.WithNamespace(userId), logger: d.logger, ctx: d.ctx}
+}
+
 // queryByText queries using Pinecone's integrated inference (for indexes with integrated embeddings)
 func (d *pineconeVectorClient) queryByText(query string, topK int, fs ...filters) ([]vector.VectorScore, error) {
 	var f *filters
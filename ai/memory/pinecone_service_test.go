@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pinecone-io/go-pinecone/v4/pinecone"
+)
+
+// fakePineconeIndex lists ids but fails every fetch.
+type fakePineconeIndex struct {
+	pineconeIndex
+	ids              []string
+	namespaceDropped bool
+}
+
+func (f *fakePineconeIndex) ListVectors(ctx context.Context, in *pinecone.ListVectorsRequest) (*pinecone.ListVectorsResponse, error) {
+	res := &pinecone.ListVectorsResponse{}
+	for i := range f.ids {
+		res.VectorIds = append(res.VectorIds, &f.ids[i])
+	}
+	return res, nil
+}
+
+func (f *fakePineconeIndex) FetchVectors(ctx context.Context, ids []string) (*pinecone.FetchVectorsResponse, error) {
+	return nil, errors.New("unavailable")
+}
+
+func (f *fakePineconeIndex) DeleteAllVectorsInNamespace(ctx context.Context) error {
+	f.namespaceDropped = true
+	f.ids = nil
+	return nil
+}
+
+func (f *fakePineconeIndex) WithNamespace(namespace string) pineconeIndex {
+	return f
+}
+
+func TestPurgeUserFailsWhenPineconeFetchFails(t *testing.T) {
+	mem := newLocalTestMemory(t, "alice")
+	idx := &fakePineconeIndex{ids: []string{"m1", "m2"}}
+	mem.memorydb.client = &pineconeVectorClient{userId: "alice", scope: "project_a", idx: idx, logger: mem.logger, ctx: context.Background()}
+	mem.memorydb.currentService = VectorServicePinecone
+
+	report, err := mem.PurgeUser("alice")
+	if err == nil {
+		t.Fatalf("purge reported success: %+v", report)
+	}
+	if len(report.Errors) == 0 || !strings.Contains(report.Errors[0], "pinecone fetch vectors failed") {
+		t.Fatalf("errors = %v", report.Errors)
+	}
+	if !idx.namespaceDropped {
+		t.Fatal("the user's namespace was not dropped")
+	}
+}
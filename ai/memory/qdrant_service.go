@@ -0,0 +1,403 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MelloB1989/karma/config"
+	"github.com/google/uuid"
+	"github.com/upstash/vector-go"
+	"go.uber.org/zap"
+)
+
+// QdrantConfig configures the Qdrant service. Empty fields fall back to the
+// KARMA_MEMORY_QDRANT_URL, KARMA_MEMORY_QDRANT_API_KEY and
+// KARMA_MEMORY_QDRANT_COLLECTION environment variables, then to a local
+// instance and the karma_memories collection.
+type QdrantConfig struct {
+	URL        string
+	APIKey     string
+	Collection string
+	// BatchSize caps points per upsert request; defaults to 256.
+	BatchSize int
+}
+
+func (c QdrantConfig) withDefaults() QdrantConfig {
+	if c.URL == "" {
+		c.URL = config.GetEnvRaw("KARMA_MEMORY_QDRANT_URL")
+	}
+	if c.URL == "" {
+		c.URL = "http://localhost:6333"
+	}
+	c.URL = strings.TrimSuffix(c.URL, "/")
+	if c.APIKey == "" {
+		c.APIKey = config.GetEnvRaw("KARMA_MEMORY_QDRANT_API_KEY")
+	}
+	if c.Collection == "" {
+		c.Collection = config.GetEnvRaw("KARMA_MEMORY_QDRANT_COLLECTION")
+	}
+	if c.Collection == "" {
+		c.Collection = "karma_memories"
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 256
+	}
+	return c
+}
+
+// qdrantIndexes are the payload fields filtered on; Qdrant only filters
+// efficiently on indexed fields.
+var qdrantIndexes = map[string]string{
+	"user_id":         "keyword",
+	"namespace":       "keyword",
+	"category":        "keyword",
+	"lifespan":        "keyword",
+	"status":          "keyword",
+	"importance":      "integer",
+	"expires_at_unix": "integer",
+}
+
+// qdrantVectorClient keeps every user in one collection, isolated by user_id
+// and namespace payload filters.
+type qdrantVectorClient struct {
+	userId string
+	scope  string
+	cfg    QdrantConfig
+	http   *http.Client
+	logger *zap.Logger
+	// collection is shared by the clients forUser derives from this one.
+	collection *qdrantCollection
+}
+
+// qdrantCollection records whether the collection and its indexes exist.
+type qdrantCollection struct {
+	mu      sync.Mutex
+	ensured bool
+}
+
+// newQdrantClient checks that the server answers; the collection itself may
+// not exist until the first write.
+func newQdrantClient(userId, scope string, logger *zap.Logger, cfg QdrantConfig) (*qdrantVectorClient, error) {
+	cfg = cfg.withDefaults()
+	if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid qdrant url %q", cfg.URL)
+	}
+	d := &qdrantVectorClient{
+		userId:     userId,
+		scope:      scope,
+		cfg:        cfg,
+		http:       &http.Client{Timeout: 30 * time.Second},
+		logger:     logger,
+		collection: &qdrantCollection{},
+	}
+	if err := d.ensureCollection(0); err != nil && !errors.Is(err, errQdrantNoCollection) {
+		return nil, fmt.Errorf("qdrant unreachable: %w", err)
+	}
+	return d, nil
+}
+
+// call sends a JSON request and decodes the "result" field of the response
+// into out, when non-nil.
+func (d *qdrantVectorClient) call(method, path string, body, out any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, d.cfg.URL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.cfg.APIKey != "" {
+		req.Header.Set("api-key", d.cfg.APIKey)
+	}
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("qdrant %s %s failed with status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		var envelope struct {
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return resp.StatusCode, err
+		}
+		if err := json.Unmarshal(envelope.Result, out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *qdrantVectorClient) collectionPath(suffix string) string {
+	return "/collections/" + d.cfg.Collection + suffix
+}
+
+// errQdrantNoCollection means nothing has been written yet.
+var errQdrantNoCollection = errors.New("qdrant collection does not exist")
+
+// ensureCollection creates the collection, sized to the first vector written,
+// and its payload indexes.
+func (d *qdrantVectorClient) ensureCollection(size int) error {
+	d.collection.mu.Lock()
+	defer d.collection.mu.Unlock()
+	if d.collection.ensured {
+		return nil
+	}
+	status, err := d.call(http.MethodGet, d.collectionPath(""), nil, nil)
+	if status == http.StatusNotFound {
+		if size == 0 {
+			return errQdrantNoCollection
+		}
+		body := map[string]any{"vectors": map[string]any{"size": size, "distance": "Cosine"}}
+		if _, err := d.call(http.MethodPut, d.collectionPath(""), body, nil); err != nil {
+			return err
+		}
+		for field, schema := range qdrantIndexes {
+			if _, err := d.call(http.MethodPut, d.collectionPath("/index?wait=true"), map[string]any{"field_name": field, "field_schema": schema}, nil); err != nil {
+				return err
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+	d.collection.ensured = true
+	return nil
+}
+
+// pointID maps a memory id to the UUID Qdrant requires, stable per user.
+func (d *qdrantVectorClient) pointID(id string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(d.userId+"/"+id)).String()
+}
+
+func (d *qdrantVectorClient) payload(m Memory) (map[string]any, error) {
+	p, err := memoryMetadata(m)
+	if err != nil {
+		return nil, err
+	}
+	p["user_id"] = d.userId
+	if m.ExpiresAt != nil {
+		p["expires_at_unix"] = m.ExpiresAt.Unix()
+	}
+	return p, nil
+}
+
+func (d *qdrantVectorClient) upsertVectors(vectors []v) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+	if err := d.ensureCollection(len(vectors[0].vector)); err != nil {
+		return fmt.Errorf("qdrant collection setup failed: %w", err)
+	}
+	points := make([]map[string]any, 0, len(vectors))
+	for _, v := range vectors {
+		p, err := d.payload(v.memories)
+		if err != nil {
+			d.logger.Error("Failed to map memory metadata", zap.Error(err))
+			continue
+		}
+		points = append(points, map[string]any{"id": d.pointID(v.memories.Id), "vector": v.vector, "payload": p})
+	}
+	for start := 0; start < len(points); start += d.cfg.BatchSize {
+		end := min(start+d.cfg.BatchSize, len(points))
+		if _, err := d.call(http.MethodPut, d.collectionPath("/points?wait=true"), map[string]any{"points": points[start:end]}, nil); err != nil {
+			return fmt.Errorf("qdrant upsert failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func matchCondition(key string, value any) map[string]any {
+	return map[string]any{"key": key, "match": map[string]any{"value": value}}
+}
+
+func (d *qdrantVectorClient) buildFilter(f *filters) map[string]any {
+	must := []map[string]any{matchCondition("user_id", d.userId)}
+	if f == nil || f.IncludeAllScopes == nil || !*f.IncludeAllScopes {
+		must = append(must, matchCondition("namespace", d.scope))
+	}
+	if f != nil {
+		if f.Category != nil && *f.Category != "" {
+			must = append(must, map[string]any{"key": "category", "match": map[string]any{"any": splitList(*f.Category)}})
+		}
+		if f.Lifespan != nil && *f.Lifespan != "" {
+			must = append(must, map[string]any{"key": "lifespan", "match": map[string]any{"any": splitList(*f.Lifespan)}})
+		}
+		if f.Importance != nil && *f.Importance != 0 {
+			must = append(must, matchCondition("importance", *f.Importance))
+		}
+		if f.Expiry != nil {
+			must = append(must, map[string]any{"key": "expires_at_unix", "range": map[string]any{"lte": f.Expiry.Unix()}})
+		}
+		if f.Status != nil && *f.Status != "" {
+			must = append(must, matchCondition("status", string(*f.Status)))
+		}
+	}
+	return map[string]any{"must": must}
+}
+
+type qdrantPoint struct {
+	Id      any            `json:"id"`
+	Score   float32        `json:"score"`
+	Payload map[string]any `json:"payload"`
+}
+
+func (p qdrantPoint) memoryId() string {
+	if id, ok := p.Payload["id"].(string); ok {
+		return id
+	}
+	return fmt.Sprint(p.Id)
+}
+
+func (d *qdrantVectorClient) queryVector(q []float32, topK int, fs ...filters) ([]vector.VectorScore, error) {
+	var f *filters
+	if len(fs) > 0 {
+		f = &fs[0]
+	}
+	if len(q) == 0 {
+		return nil, fmt.Errorf("no query vector provided")
+	}
+	if err := d.ensureCollection(len(q)); err != nil {
+		return nil, fmt.Errorf("qdrant collection setup failed: %w", err)
+	}
+
+	var points []qdrantPoint
+	body := map[string]any{"vector": q, "limit": topK, "filter": d.buildFilter(f), "with_payload": true}
+	if _, err := d.call(http.MethodPost, d.collectionPath("/points/search"), body, &points); err != nil {
+		return nil, fmt.Errorf("qdrant vector query failed: %w", err)
+	}
+	scores := make([]vector.VectorScore, 0, len(points))
+	for _, p := range points {
+		scores = append(scores, vector.VectorScore{Id: p.memoryId(), Score: p.Score, Metadata: p.Payload})
+	}
+	return scores, nil
+}
+
+func (d *qdrantVectorClient) fetchVectors(ids []string, withVectors bool) ([]v, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := d.ensureCollection(0); errors.Is(err, errQdrantNoCollection) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("qdrant collection setup failed: %w", err)
+	}
+	pointIds := make([]string, len(ids))
+	for i, id := range ids {
+		pointIds[i] = d.pointID(id)
+	}
+	var points []struct {
+		qdrantPoint
+		Vector []float32 `json:"vector"`
+	}
+	body := map[string]any{"ids": pointIds, "with_payload": true, "with_vector": withVectors}
+	if _, err := d.call(http.MethodPost, d.collectionPath("/points"), body, &points); err != nil {
+		return nil, fmt.Errorf("qdrant fetch failed: %w", err)
+	}
+	records := make([]v, 0, len(points))
+	for _, p := range points {
+		records = append(records, v{memories: metadataToMemory(p.Payload, p.memoryId()), vector: p.Vector})
+	}
+	return records, nil
+}
+
+func (d *qdrantVectorClient) queryVectorByMetadata(f filters) ([]map[string]any, error) {
+	if err := d.ensureCollection(0); errors.Is(err, errQdrantNoCollection) {
+		return []map[string]any{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("qdrant collection setup failed: %w", err)
+	}
+	metadatas := make([]map[string]any, 0)
+	var offset any
+	for {
+		body := map[string]any{"filter": d.buildFilter(&f), "limit": 256, "with_payload": true, "with_vector": false}
+		if offset != nil {
+			body["offset"] = offset
+		}
+		var page struct {
+			Points         []qdrantPoint `json:"points"`
+			NextPageOffset any           `json:"next_page_offset"`
+		}
+		if _, err := d.call(http.MethodPost, d.collectionPath("/points/scroll"), body, &page); err != nil {
+			return nil, fmt.Errorf("qdrant scroll failed: %w", err)
+		}
+		for _, p := range page.Points {
+			metadatas = append(metadatas, p.Payload)
+		}
+		if page.NextPageOffset == nil {
+			return metadatas, nil
+		}
+		offset = page.NextPageOffset
+	}
+}
+
+// updateVector overwrites the point. Without a vector only its payload is
+// replaced.
+func (d *qdrantVectorClient) updateVector(memory Memory, vs ...[]float32) (bool, error) {
+	if len(vs) > 0 && len(vs[0]) > 0 {
+		if err := d.upsertVectors([]v{{memories: memory, vector: vs[0]}}); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	p, err := d.payload(memory)
+	if err != nil {
+		return false, err
+	}
+	body := map[string]any{"payload": p, "points": []string{d.pointID(memory.Id)}}
+	if _, err := d.call(http.MethodPut, d.collectionPath("/points/payload?wait=true"), body, nil); err != nil {
+		return false, fmt.Errorf("qdrant payload update failed: %w", err)
+	}
+	return true, nil
+}
+
+// deleteVectors deletes the points of vectorsIds that exist, counting them
+// first since Qdrant's delete does not report how many it removed.
+func (d *qdrantVectorClient) deleteVectors(vectorsIds []string) (count int, err error) {
+	existing, err := d.fetchVectors(vectorsIds, false)
+	if err != nil || len(existing) == 0 {
+		return 0, err
+	}
+	ids := make([]string, len(existing))
+	for i, e := range existing {
+		ids[i] = d.pointID(e.memories.Id)
+	}
+	if _, err := d.call(http.MethodPost, d.collectionPath("/points/delete?wait=true"), map[string]any{"points": ids}, nil); err != nil {
+		return 0, fmt.Errorf("qdrant delete failed: %w", err)
+	}
+	return len(ids), nil
+}
+
+func (d *qdrantVectorClient) shiftScope(scope string) string {
+	d.scope = scope
+	return d.scope
+}
+
+func (d *qdrantVectorClient) shiftUser(userId string) string {
+	d.userId = userId
+	return d.userId
+}
+
+func (d *qdrantVectorClient) forUser(userId, scope string) vectorService {
+	return &qdrantVectorClient{userId: userId, scope: scope, cfg: d.cfg, http: d.http, logger: d.logger, collection: d.collection}
+}
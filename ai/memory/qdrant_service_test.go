@@ -0,0 +1,215 @@
+package memory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeQdrant is a minimal in-memory stand-in for the Qdrant REST endpoints
+// the service uses.
+type fakeQdrant struct {
+	mu      sync.Mutex
+	created bool
+	indexes []string
+	points  map[string]fakeQdrantPoint
+}
+
+type fakeQdrantPoint struct {
+	Vector  []float32      `json:"vector"`
+	Payload map[string]any `json:"payload"`
+}
+
+func (q *fakeQdrant) matches(payload map[string]any, filter map[string]any) bool {
+	must, _ := filter["must"].([]any)
+	for _, c := range must {
+		cond := c.(map[string]any)
+		value := payload[cond["key"].(string)]
+		if m, ok := cond["match"].(map[string]any); ok {
+			if want, ok := m["value"]; ok && want != value {
+				return false
+			}
+			if options, ok := m["any"].([]any); ok {
+				found := false
+				for _, a := range options {
+					found = found || a == value
+				}
+				if !found {
+					return false
+				}
+			}
+		}
+		if r, ok := cond["range"].(map[string]any); ok {
+			n, ok := value.(float64)
+			if !ok || n > r["lte"].(float64) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (q *fakeQdrant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	reply := func(result any) { json.NewEncoder(w).Encode(map[string]any{"result": result, "status": "ok"}) }
+	path := strings.TrimPrefix(r.URL.Path, "/collections/mem")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		if !q.created {
+			http.Error(w, `{"status":{"error":"Not found"}}`, http.StatusNotFound)
+			return
+		}
+		reply(map[string]any{})
+	case path == "" && r.Method == http.MethodPut:
+		q.created = true
+		reply(true)
+	case path == "/index":
+		q.indexes = append(q.indexes, body["field_name"].(string))
+		reply(map[string]any{})
+	case path == "/points" && r.Method == http.MethodPut:
+		for _, p := range body["points"].([]any) {
+			raw, _ := json.Marshal(p)
+			var point struct {
+				Id string `json:"id"`
+				fakeQdrantPoint
+			}
+			json.Unmarshal(raw, &point)
+			q.points[point.Id] = point.fakeQdrantPoint
+		}
+		reply(map[string]any{})
+	case path == "/points" && r.Method == http.MethodPost:
+		out := []map[string]any{}
+		for _, id := range body["ids"].([]any) {
+			if p, ok := q.points[id.(string)]; ok {
+				out = append(out, map[string]any{"id": id, "payload": p.Payload})
+			}
+		}
+		reply(out)
+	case path == "/points/search":
+		var query []float32
+		raw, _ := json.Marshal(body["vector"])
+		json.Unmarshal(raw, &query)
+		var out []map[string]any
+		for id, p := range q.points {
+			if q.matches(p.Payload, body["filter"].(map[string]any)) {
+				out = append(out, map[string]any{"id": id, "score": cosineSimilarity(query, p.Vector), "payload": p.Payload})
+			}
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i]["score"].(float32) > out[j]["score"].(float32) })
+		reply(out)
+	case path == "/points/scroll":
+		var out []map[string]any
+		for id, p := range q.points {
+			if q.matches(p.Payload, body["filter"].(map[string]any)) {
+				out = append(out, map[string]any{"id": id, "payload": p.Payload})
+			}
+		}
+		reply(map[string]any{"points": out, "next_page_offset": nil})
+	case path == "/points/payload" && r.Method == http.MethodPut:
+		for _, id := range body["points"].([]any) {
+			p := q.points[id.(string)]
+			p.Payload = body["payload"].(map[string]any)
+			q.points[id.(string)] = p
+		}
+		reply(map[string]any{})
+	case path == "/points/delete":
+		for _, id := range body["points"].([]any) {
+			delete(q.points, id.(string))
+		}
+		reply(map[string]any{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestQdrantVectorService(t *testing.T) {
+	fake := &fakeQdrant{points: map[string]fakeQdrantPoint{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	client, err := newQdrantClient("u1", "proj", zap.NewNop(), QdrantConfig{URL: srv.URL, Collection: "mem", BatchSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metas, err := client.queryVectorByMetadata(filters{}); err != nil || len(metas) != 0 {
+		t.Fatalf("empty collection = %v, %v", metas, err)
+	}
+
+	expiry := time.Now().Add(time.Hour)
+	err = client.upsertVectors([]v{
+		{memories: Memory{Id: "m1", Namespace: "proj", Category: CategoryFact, Summary: "uses qdrant", Status: StatusActive, ExpiresAt: &expiry}, vector: []float32{1, 0}},
+		{memories: Memory{Id: "m2", Namespace: "proj", Category: CategoryRule, Summary: "be brief", Status: StatusActive}, vector: []float32{0, 1}},
+		{memories: Memory{Id: "m3", Namespace: "other", Category: CategoryFact, Summary: "other scope", Status: StatusActive}, vector: []float32{1, 0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fake.created || len(fake.indexes) != len(qdrantIndexes) || len(fake.points) != 3 {
+		t.Fatalf("setup: created=%v indexes=%v points=%d", fake.created, fake.indexes, len(fake.points))
+	}
+
+	scores, err := client.queryVector([]float32{1, 0.1}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 || scores[0].Id != "m1" || metadataToMemory(scores[0].Metadata, scores[0].Id).Summary != "uses qdrant" {
+		t.Fatalf("scores = %+v", scores)
+	}
+
+	later := expiry.Add(time.Minute)
+	if expiring, _ := client.queryVectorByMetadata(filters{Expiry: &later}); len(expiring) != 1 {
+		t.Fatalf("expiring = %v", expiring)
+	}
+	rules, _ := client.queryVectorByMetadata(filters{Category: ptrStr("rule,skill")})
+	if len(rules) != 1 || rules[0]["summary"] != "be brief" {
+		t.Fatalf("rules = %v", rules)
+	}
+
+	recs, err := client.fetchVectors([]string{"m1"}, false)
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("fetch = %v, %v", recs, err)
+	}
+	superseded := recs[0].memories
+	superseded.Status, superseded.ExpiresAt = StatusSuperseded, nil
+	if _, err := client.updateVector(superseded); err != nil {
+		t.Fatal(err)
+	}
+	if expiring, _ := client.queryVectorByMetadata(filters{Expiry: &later}); len(expiring) != 0 {
+		t.Fatalf("cleared expiry still set: %v", expiring)
+	}
+	active := StatusActive
+	if facts, _ := client.queryVectorByMetadata(filters{Category: ptrStr("fact"), Status: &active}); len(facts) != 0 {
+		t.Fatalf("superseded memory still active: %v", facts)
+	}
+
+	client.shiftUser("u2")
+	if others, _ := client.queryVectorByMetadata(filters{}); len(others) != 0 {
+		t.Fatalf("users should be isolated, got %v", others)
+	}
+	client.shiftUser("u1")
+	if n, err := client.deleteVectors([]string{"m1", "m2", "missing"}); err != nil || n != 2 || len(fake.points) != 1 {
+		t.Fatalf("delete = %d, %v, left %d", n, err, len(fake.points))
+	}
+}
+
+func TestQdrantUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	if _, err := newQdrantClient("u1", "proj", zap.NewNop(), QdrantConfig{URL: srv.URL}); err == nil {
+		t.Fatal("expected an error for a server that does not answer")
+	}
+	if _, err := newQdrantClient("u1", "proj", zap.NewNop(), QdrantConfig{URL: "localhost:6333"}); err == nil {
+		t.Fatal("expected an error for a url without a scheme")
+	}
+}
@@ -0,0 +1,604 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MelloB1989/karma/utils"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// ErrIngestQueueFull is returned by Enqueue when the queue is at capacity and
+// BlockWhenFull is off.
+var ErrIngestQueueFull = errors.New("memory ingest queue is full")
+
+// ErrIngestQueueClosed is returned by Enqueue after Close.
+var ErrIngestQueueClosed = errors.New("memory ingest queue is closed")
+
+// IngestJob is one conversation turn waiting to be turned into memories.
+type IngestJob struct {
+	UserID        string    `json:"user_id"`
+	Scope         string    `json:"scope"`
+	UserMessage   string    `json:"user_message"`
+	AIResponse    string    `json:"ai_response"`
+	MemoryContext string    `json:"memory_context"`
+	EnqueuedAt    time.Time `json:"enqueued_at"`
+
+	// receipt identifies a popped job to its backend's Ack.
+	receipt string
+}
+
+// IngestBackend stores queued jobs. Pop returns up to max jobs, waiting at
+// most wait for the first one and returning an empty slice on timeout. Popped
+// jobs stay with the backend until they are acknowledged with Ack, so
+// Requeue can put back those of a worker that died mid-batch. Drop removes
+// a user's queued jobs.
+type IngestBackend interface {
+	Push(ctx context.Context, job IngestJob) error
+	Pop(ctx context.Context, max int, wait time.Duration) ([]IngestJob, error)
+	Ack(ctx context.Context, jobs []IngestJob) error
+	Requeue(ctx context.Context) (int, error)
+	Drop(ctx context.Context, userID string) (int, error)
+	Len(ctx context.Context) (int, error)
+}
+
+type memoryIngestBackend struct {
+	mu       sync.Mutex
+	jobs     []IngestJob
+	capacity int
+	ready    chan struct{}
+}
+
+// NewMemoryIngestBackend returns an in-process backend holding up to
+// capacity jobs. Queued jobs are lost if the process dies before a flush.
+func NewMemoryIngestBackend(capacity int) IngestBackend {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &memoryIngestBackend{capacity: capacity, ready: make(chan struct{}, 1)}
+}
+
+func (b *memoryIngestBackend) signal() {
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (b *memoryIngestBackend) Push(ctx context.Context, job IngestJob) error {
+	b.mu.Lock()
+	if len(b.jobs) >= b.capacity {
+		b.mu.Unlock()
+		return ErrIngestQueueFull
+	}
+	b.jobs = append(b.jobs, job)
+	b.mu.Unlock()
+	b.signal()
+	return nil
+}
+
+func (b *memoryIngestBackend) Pop(ctx context.Context, max int, wait time.Duration) ([]IngestJob, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		b.mu.Lock()
+		if len(b.jobs) > 0 {
+			n := min(max, len(b.jobs))
+			jobs := append([]IngestJob(nil), b.jobs[:n]...)
+			b.jobs = b.jobs[n:]
+			left := len(b.jobs)
+			b.mu.Unlock()
+			if left > 0 {
+				b.signal()
+			}
+			return jobs, nil
+		}
+		b.mu.Unlock()
+		select {
+		case <-b.ready:
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Ack is a no-op: in-process jobs die with the process either way.
+func (b *memoryIngestBackend) Ack(ctx context.Context, jobs []IngestJob) error {
+	return nil
+}
+
+func (b *memoryIngestBackend) Requeue(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+func (b *memoryIngestBackend) Drop(ctx context.Context, userID string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kept := b.jobs[:0]
+	for _, job := range b.jobs {
+		if job.UserID != userID {
+			kept = append(kept, job)
+		}
+	}
+	dropped := len(b.jobs) - len(kept)
+	clear(b.jobs[len(kept):])
+	b.jobs = kept
+	return dropped, nil
+}
+
+func (b *memoryIngestBackend) Len(ctx context.Context) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.jobs), nil
+}
+
+// pushIfRoom pushes ARGV[1] onto KEYS[1] unless it holds ARGV[2] jobs.
+var pushIfRoom = redis.NewScript(`
+if redis.call('LLEN', KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('LPUSH', KEYS[1], ARGV[1])
+return 1
+`)
+
+// moveMany moves up to ARGV[1] jobs from KEYS[1] to KEYS[2].
+var moveMany = redis.NewScript(`
+local moved = {}
+for i = 1, tonumber(ARGV[1]) do
+	local job = redis.call('LMOVE', KEYS[1], KEYS[2], 'RIGHT', 'LEFT')
+	if not job then
+		break
+	end
+	moved[#moved + 1] = job
+end
+return moved
+`)
+
+// dropUser removes the jobs of user ARGV[1] from KEYS[1].
+var dropUser = redis.NewScript(`
+local dropped = 0
+for _, raw in ipairs(redis.call('LRANGE', KEYS[1], 0, -1)) do
+	local ok, job = pcall(cjson.decode, raw)
+	if ok and type(job) == 'table' and job.user_id == ARGV[1] then
+		dropped = dropped + redis.call('LREM', KEYS[1], 0, raw)
+	end
+end
+return dropped
+`)
+
+type redisIngestBackend struct {
+	client     *redis.Client
+	key        string
+	processing string
+	capacity   int
+}
+
+// NewRedisIngestBackend returns a backend on a Redis list, so queued turns
+// survive restarts and several processes can share one queue. Popped jobs
+// move to the list key+":processing" until acknowledged. A nil client
+// connects with utils.RedisConnect; key defaults to
+// "karma_memory:ingest_queue" and capacity to 10000. Needs Redis 6.2 or
+// newer.
+func NewRedisIngestBackend(client *redis.Client, key string, capacity int) IngestBackend {
+	if client == nil {
+		client = utils.RedisConnect()
+	}
+	if key == "" {
+		key = "karma_memory:ingest_queue"
+	}
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return &redisIngestBackend{client: client, key: key, processing: key + ":processing", capacity: capacity}
+}
+
+func (b *redisIngestBackend) Push(ctx context.Context, job IngestJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	pushed, err := pushIfRoom.Run(ctx, b.client, []string{b.key}, data, b.capacity).Int()
+	if err != nil {
+		return err
+	}
+	if pushed == 0 {
+		return ErrIngestQueueFull
+	}
+	return nil
+}
+
+func (b *redisIngestBackend) Pop(ctx context.Context, max int, wait time.Duration) ([]IngestJob, error) {
+	first, err := b.client.BLMove(ctx, b.key, b.processing, "RIGHT", "LEFT", wait).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw := []string{first}
+	if max > 1 {
+		// The first job is already in the processing list, so it is handed
+		// out even if taking the rest fails.
+		var rest []string
+		rest, err = moveMany.Run(ctx, b.client, []string{b.key, b.processing}, max-1).StringSlice()
+		if err == redis.Nil {
+			err = nil
+		}
+		raw = append(raw, rest...)
+	}
+	jobs := make([]IngestJob, 0, len(raw))
+	for _, r := range raw {
+		var job IngestJob
+		if err := json.Unmarshal([]byte(r), &job); err != nil {
+			b.client.LRem(ctx, b.processing, 1, r)
+			continue
+		}
+		job.receipt = r
+		jobs = append(jobs, job)
+	}
+	return jobs, err
+}
+
+func (b *redisIngestBackend) Ack(ctx context.Context, jobs []IngestJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	pipe := b.client.Pipeline()
+	for _, job := range jobs {
+		if job.receipt != "" {
+			pipe.LRem(ctx, b.processing, 1, job.receipt)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Requeue moves every unacknowledged job back onto the queue, oldest first.
+func (b *redisIngestBackend) Requeue(ctx context.Context) (int, error) {
+	n := 0
+	for {
+		_, err := b.client.LMove(ctx, b.processing, b.key, "LEFT", "RIGHT").Result()
+		if err == redis.Nil {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+func (b *redisIngestBackend) Drop(ctx context.Context, userID string) (int, error) {
+	return dropUser.Run(ctx, b.client, []string{b.key}, userID).Int()
+}
+
+func (b *redisIngestBackend) Len(ctx context.Context) (int, error) {
+	n, err := b.client.LLen(ctx, b.key).Result()
+	return int(n), err
+}
+
+// IngestQueueConfig tunes an IngestQueue. Zero values use the defaults.
+type IngestQueueConfig struct {
+	// Backend defaults to an in-memory backend of 1000 jobs.
+	Backend IngestBackend
+	// BatchSize is the most jobs taken per batch; defaults to 20.
+	BatchSize int
+	// FlushInterval is the longest a worker waits for jobs; defaults to 2s.
+	FlushInterval time.Duration
+	// Workers is the number of batches processed at once; defaults to 2.
+	Workers int
+	// MaxRetries is how often a failed user batch is retried; defaults to 3,
+	// negative disables retries.
+	MaxRetries int
+	// RetryBackoff is the first retry delay, doubled per attempt; defaults
+	// to 1s.
+	RetryBackoff time.Duration
+	// BlockWhenFull makes Enqueue wait for room instead of returning
+	// ErrIngestQueueFull.
+	BlockWhenFull bool
+	// OnError, when set, receives the jobs dropped after their last retry.
+	OnError func(jobs []IngestJob, err error)
+	// RequeueOnStart puts back jobs that were popped but never acknowledged,
+	// e.g. by a worker that crashed. Only set it where one process consumes
+	// the backend, or another's in-flight jobs are ingested twice.
+	RequeueOnStart bool
+}
+
+func (c IngestQueueConfig) withDefaults() IngestQueueConfig {
+	if c.Backend == nil {
+		c.Backend = NewMemoryIngestBackend(1000)
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 20
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = 2 * time.Second
+	}
+	if c.Workers <= 0 {
+		c.Workers = 2
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = time.Second
+	}
+	return c
+}
+
+// IngestQueueStats are cumulative counters of an IngestQueue.
+type IngestQueueStats struct {
+	Enqueued  int64 `json:"enqueued"`
+	Rejected  int64 `json:"rejected"`
+	Batches   int64 `json:"batches"`
+	Processed int64 `json:"processed"`
+	Deduped   int64 `json:"deduped"`
+	Retried   int64 `json:"retried"`
+	Failed    int64 `json:"failed"`
+	// Dropped counts jobs discarded because their user was purged.
+	Dropped  int64 `json:"dropped"`
+	InFlight int64 `json:"in_flight"`
+}
+
+// IngestQueue batches conversation turns across users and ingests them in
+// the background, one memory LLM call per user and scope in a batch. Attach
+// it to chat memories with UseIngestQueue; it ingests through its own
+// KarmaMemory's LLMs and vector service.
+type IngestQueue struct {
+	mem *KarmaMemory
+	cfg IngestQueueConfig
+
+	// purgedMu guards purged, when each recently purged user was dropped.
+	// Jobs of theirs enqueued before then, popped before the drop, are
+	// skipped.
+	purgedMu sync.Mutex
+	purged   map[string]time.Time
+	// popMu lets Flush see popped jobs as in flight, never as neither queued
+	// nor in flight.
+	popMu  sync.RWMutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	closed atomic.Bool
+
+	enqueued, rejected, batches, processed, deduped, retried, failed, dropped, inFlight atomic.Int64
+}
+
+// purgeTombstoneTTL is how long DropUser guards against a purged user's
+// in-flight jobs; batches finish well within it.
+const purgeTombstoneTTL = time.Hour
+
+// NewIngestQueue starts the queue's workers.
+func NewIngestQueue(mem *KarmaMemory, cfg IngestQueueConfig) *IngestQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &IngestQueue{mem: mem, cfg: cfg.withDefaults(), cancel: cancel, purged: map[string]time.Time{}}
+	if q.cfg.RequeueOnStart {
+		if n, err := q.cfg.Backend.Requeue(ctx); err != nil {
+			mem.logger.Warn("karma_memory: failed to requeue unacknowledged ingest jobs", zap.Error(err))
+		} else if n > 0 {
+			mem.logger.Info("karma_memory: requeued unacknowledged ingest jobs", zap.Int("jobs", n))
+		}
+	}
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+	return q
+}
+
+// Enqueue adds a job, waiting for room when BlockWhenFull is set.
+func (q *IngestQueue) Enqueue(ctx context.Context, job IngestJob) error {
+	if q.closed.Load() {
+		return ErrIngestQueueClosed
+	}
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now()
+	}
+	for {
+		err := q.cfg.Backend.Push(ctx, job)
+		if err == nil {
+			q.enqueued.Add(1)
+			return nil
+		}
+		if !errors.Is(err, ErrIngestQueueFull) || !q.cfg.BlockWhenFull {
+			q.rejected.Add(1)
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			q.rejected.Add(1)
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Stats returns a snapshot of the counters.
+func (q *IngestQueue) Stats() IngestQueueStats {
+	return IngestQueueStats{
+		Enqueued:  q.enqueued.Load(),
+		Rejected:  q.rejected.Load(),
+		Batches:   q.batches.Load(),
+		Processed: q.processed.Load(),
+		Deduped:   q.deduped.Load(),
+		Retried:   q.retried.Load(),
+		Failed:    q.failed.Load(),
+		Dropped:   q.dropped.Load(),
+		InFlight:  q.inFlight.Load(),
+	}
+}
+
+// DropUser removes userID's queued jobs and skips those of theirs already
+// popped, so a purge is not undone by turns ingested after it. PurgeUser
+// calls it for the queue attached with UseIngestQueue.
+func (q *IngestQueue) DropUser(ctx context.Context, userID string) (int, error) {
+	now := time.Now()
+	q.purgedMu.Lock()
+	for id, at := range q.purged {
+		if now.Sub(at) > purgeTombstoneTTL {
+			delete(q.purged, id)
+		}
+	}
+	q.purged[userID] = now
+	q.purgedMu.Unlock()
+
+	n, err := q.cfg.Backend.Drop(ctx, userID)
+	q.dropped.Add(int64(n))
+	return n, err
+}
+
+// purgedSince drops the jobs enqueued before their user was purged.
+func (q *IngestQueue) purgedSince(jobs []IngestJob) []IngestJob {
+	q.purgedMu.Lock()
+	defer q.purgedMu.Unlock()
+	if len(q.purged) == 0 {
+		return jobs
+	}
+	kept := make([]IngestJob, 0, len(jobs))
+	for _, job := range jobs {
+		if at, ok := q.purged[job.UserID]; ok && !job.EnqueuedAt.After(at) {
+			q.dropped.Add(1)
+			continue
+		}
+		kept = append(kept, job)
+	}
+	return kept
+}
+
+// Flush waits until every queued job has been processed, or ctx is done.
+func (q *IngestQueue) Flush(ctx context.Context) error {
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		q.popMu.Lock()
+		n, err := q.cfg.Backend.Len(ctx)
+		inFlight := q.inFlight.Load()
+		q.popMu.Unlock()
+		if err != nil {
+			return err
+		}
+		if n == 0 && inFlight == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops accepting jobs, flushes the queue and stops the workers. Call
+// it on shutdown with a deadline.
+func (q *IngestQueue) Close(ctx context.Context) error {
+	q.closed.Store(true)
+	err := q.Flush(ctx)
+	q.cancel()
+	q.wg.Wait()
+	return err
+}
+
+func (q *IngestQueue) work(ctx context.Context) {
+	defer q.wg.Done()
+	for ctx.Err() == nil {
+		q.popMu.RLock()
+		jobs, err := q.cfg.Backend.Pop(ctx, q.cfg.BatchSize, q.cfg.FlushInterval)
+		q.inFlight.Add(int64(len(jobs)))
+		q.popMu.RUnlock()
+		if len(jobs) > 0 {
+			q.processBatch(jobs)
+			// Acknowledge even without ctx, so a shutdown mid-batch does not
+			// leave finished jobs to be requeued.
+			if err := q.cfg.Backend.Ack(context.WithoutCancel(ctx), jobs); err != nil {
+				q.mem.logger.Warn("karma_memory: ingest queue ack failed", zap.Error(err))
+			}
+			q.inFlight.Add(-int64(len(jobs)))
+		}
+		if err != nil && ctx.Err() == nil {
+			q.mem.logger.Warn("karma_memory: ingest queue pop failed", zap.Error(err))
+			time.Sleep(q.cfg.FlushInterval)
+		}
+	}
+}
+
+type ingestGroup struct {
+	userID, scope string
+	jobs          []IngestJob
+}
+
+// processBatch groups jobs by user and scope, in arrival order, drops
+// repeated turns and ingests each group with retries.
+func (q *IngestQueue) processBatch(jobs []IngestJob) {
+	q.batches.Add(1)
+	jobs = q.purgedSince(jobs)
+	var groups []*ingestGroup
+	index := map[string]*ingestGroup{}
+	seen := map[string]bool{}
+	for _, job := range jobs {
+		turnKey := job.UserID + "\x00" + job.Scope + "\x00" + job.UserMessage + "\x00" + job.AIResponse
+		if seen[turnKey] {
+			q.deduped.Add(1)
+			continue
+		}
+		seen[turnKey] = true
+		key := job.UserID + "\x00" + job.Scope
+		g, ok := index[key]
+		if !ok {
+			g = &ingestGroup{userID: job.UserID, scope: job.Scope}
+			index[key] = g
+			groups = append(groups, g)
+		}
+		g.jobs = append(g.jobs, job)
+	}
+
+	for _, g := range groups {
+		var err error
+		for attempt := 0; attempt <= q.cfg.MaxRetries; attempt++ {
+			if attempt > 0 {
+				q.retried.Add(1)
+				time.Sleep(q.cfg.RetryBackoff << (attempt - 1))
+			}
+			// The user may have been purged while earlier groups ran.
+			if g.jobs = q.purgedSince(g.jobs); len(g.jobs) == 0 {
+				err = nil
+				break
+			}
+			if err = q.ingestGroup(g); err == nil {
+				break
+			}
+			q.mem.logger.Warn("karma_memory: queued ingestion failed",
+				zap.String("userID", g.userID),
+				zap.String("scope", g.scope),
+				zap.Int("attempt", attempt+1),
+				zap.Error(err))
+		}
+		if err != nil {
+			q.failed.Add(int64(len(g.jobs)))
+			if q.cfg.OnError != nil {
+				q.cfg.OnError(g.jobs, err)
+			}
+			continue
+		}
+		q.processed.Add(int64(len(g.jobs)))
+	}
+}
+
+func (q *IngestQueue) ingestGroup(g *ingestGroup) error {
+	turns := make([]ingestTurn, len(g.jobs))
+	for i, job := range g.jobs {
+		turns[i] = ingestTurn{UserMessage: job.UserMessage, AIResponse: job.AIResponse, CurrentMemoryContext: job.MemoryContext}
+	}
+	if err := q.mem.forUser(g.userID, g.scope).ingest(turns...); err != nil {
+		return fmt.Errorf("ingest %d turns: %w", len(turns), err)
+	}
+	return nil
+}
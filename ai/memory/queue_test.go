@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MelloB1989/karma/ai"
+	"github.com/MelloB1989/karma/models"
+)
+
+func TestIngestQueue(t *testing.T) {
+	t.Setenv("KARMA_MEMORY_LOCAL_VECTOR_PATH", filepath.Join(t.TempDir(), "vectors.json"))
+	t.Setenv("OPENAI_KEY", "sk-test")
+	var chatCalls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := io.ReadAll(r.Body)
+		if strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			chatCalls.Add(1)
+			memories := `{"memories":[{"operation":"create","category":"fact","summary":"User drinks tea"},{"operation":"create","category":"fact","summary":"User drinks tea."}]}`
+			if strings.Contains(string(body), "Bob") {
+				memories = `{"memories":[{"operation":"create","category":"fact","summary":"Bob lives in Pune"}]}`
+			}
+			content, _ := json.Marshal(memories)
+			fmt.Fprintf(w, `{"id":"1","object":"chat.completion","model":"gpt-4o-mini","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":%s}}]}`, content)
+			return
+		}
+		if chatCalls.Load() == 1 {
+			http.Error(w, `{"error":{"message":"rate limited"}}`, http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"object":"list","model":"text-embedding-3-small","data":[{"object":"embedding","index":0,"embedding":[0.6,0.8]}],"usage":{"prompt_tokens":1,"total_tokens":1}}`)
+	}))
+	defer srv.Close()
+	t.Setenv("OPENAI_BASE_URL", srv.URL)
+
+	worker := NewKarmaMemory(ai.NewKarmaAI(ai.GPT4oMini, ai.OpenAI), "ingest-worker")
+	if err := worker.UseService(VectorServiceLocal); err != nil {
+		t.Fatal(err)
+	}
+	var dropped atomic.Int32
+	q := NewIngestQueue(worker, IngestQueueConfig{
+		BatchSize:     10,
+		FlushInterval: 50 * time.Millisecond,
+		Workers:       1,
+		RetryBackoff:  time.Millisecond,
+		OnError:       func(jobs []IngestJob, err error) { dropped.Add(int32(len(jobs))) },
+	})
+
+	chat := NewKarmaMemory(ai.NewKarmaAI(ai.GPT4oMini, ai.OpenAI), "alice", "project_a")
+	chat.UseService(VectorServiceLocal)
+	chat.UseIngestQueue(q)
+	chat.currentMemoryContext = "none"
+	turn := []models.AIMessage{{Role: models.User, Message: "I drink tea every morning"}, {Role: models.Assistant, Message: "Noted!"}}
+	chat.UpdateMessageHistory(turn)
+	chat.UpdateMessageHistory(turn)
+	ctx := context.Background()
+	if err := q.Enqueue(ctx, IngestJob{UserID: "bob", Scope: "default", UserMessage: "Bob here, I moved to Pune", AIResponse: "Nice!"}); err != nil {
+		t.Fatal(err)
+	}
+
+	flushCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := q.Close(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Enqueue(ctx, IngestJob{UserID: "alice"}); !errors.Is(err, ErrIngestQueueClosed) {
+		t.Fatalf("enqueue after close = %v", err)
+	}
+
+	stats := q.Stats()
+	if chatCalls.Load() != 3 {
+		t.Fatalf("chat calls = %d, want one per user plus a retry", chatCalls.Load())
+	}
+	if stats.Enqueued != 3 || stats.Deduped != 1 || stats.Processed != 2 || stats.Retried != 1 || stats.Failed != 0 || dropped.Load() != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if worker.userID != "ingest-worker" {
+		t.Fatalf("worker user not restored: %s", worker.userID)
+	}
+
+	alice, _ := worker.ExportUserMemories("alice")
+	if len(alice) != 1 || alice[0].Summary != "User drinks tea" || alice[0].Namespace != "project_a" || alice[0].SubjectKey != "alice" {
+		t.Fatalf("alice = %+v", alice)
+	}
+	if bob, _ := worker.ExportUserMemories("bob"); len(bob) != 1 || bob[0].Summary != "Bob lives in Pune" {
+		t.Fatalf("bob = %+v", bob)
+	}
+
+	full := NewMemoryIngestBackend(1)
+	full.Push(ctx, IngestJob{})
+	if err := full.Push(ctx, IngestJob{}); !errors.Is(err, ErrIngestQueueFull) {
+		t.Fatalf("push past capacity = %v", err)
+	}
+}
+
+func TestIngestQueueDropUser(t *testing.T) {
+	mem := newLocalTestMemory(t, "alice")
+	backend := NewMemoryIngestBackend(10)
+	q := &IngestQueue{mem: mem, cfg: IngestQueueConfig{Backend: backend}.withDefaults(), purged: map[string]time.Time{}}
+	mem.UseIngestQueue(q)
+
+	ctx := context.Background()
+	backend.Push(ctx, IngestJob{UserID: "alice", UserMessage: "I drink tea", EnqueuedAt: time.Now()})
+	backend.Push(ctx, IngestJob{UserID: "bob", UserMessage: "I drink coffee", EnqueuedAt: time.Now()})
+	inFlight := IngestJob{UserID: "alice", UserMessage: "I moved to Pune", EnqueuedAt: time.Now()}
+
+	report, err := mem.PurgeUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.DroppedJobs != 1 {
+		t.Fatalf("dropped jobs = %d", report.DroppedJobs)
+	}
+	if n, _ := backend.Len(ctx); n != 1 {
+		t.Fatalf("queued after purge = %d", n)
+	}
+
+	q.processBatch([]IngestJob{inFlight})
+	if stats := q.Stats(); stats.Dropped != 2 || stats.Processed != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if left, _ := mem.ExportUserMemories("alice"); len(left) != 0 {
+		t.Fatalf("purged user got memories back: %+v", left)
+	}
+}
+
+func TestUpdateMessageHistoryDoesNotBlockOnFullQueue(t *testing.T) {
+	mem := newLocalTestMemory(t, "alice")
+	backend := NewMemoryIngestBackend(1)
+	backend.Push(context.Background(), IngestJob{UserID: "bob", UserMessage: "queued"})
+	q := &IngestQueue{mem: mem, cfg: IngestQueueConfig{Backend: backend, BlockWhenFull: true}.withDefaults(), purged: map[string]time.Time{}}
+	mem.UseIngestQueue(q)
+	mem.currentMemoryContext = "known"
+
+	old := ingestEnqueueTimeout
+	ingestEnqueueTimeout = 100 * time.Millisecond
+	defer func() { ingestEnqueueTimeout = old }()
+
+	done := make(chan struct{})
+	go func() {
+		mem.UpdateMessageHistory([]models.AIMessage{
+			{Role: models.User, Message: "I drink tea"},
+			{Role: models.Assistant, Message: "Noted"},
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("UpdateMessageHistory blocked on a full queue")
+	}
+	if stats := q.Stats(); stats.Rejected != 1 || stats.Enqueued != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// userScheduler runs a per-user maintenance job for MemoryGC and
+// MemoryConsolidator: one user at a time, over an explicit list or a Users
+// function, and on a ticker.
+type userScheduler[R any] struct {
+	mem *KarmaMemory
+	// job names the job in errors and logs, e.g. "memory gc".
+	job     string
+	runUser func(userID string) (R, error)
+
+	runMu sync.Mutex
+}
+
+// runOne runs the job for userID once no other run is in progress.
+func (s *userScheduler[R]) runOne(userID string) (R, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	return s.runUser(userID)
+}
+
+// run runs the job for each of userIDs, or every user from users when none
+// are given, or else the memory's current user. Reports are returned for all
+// users attempted.
+func (s *userScheduler[R]) run(ctx context.Context, users func(ctx context.Context) ([]string, error), userIDs []string) ([]R, error) {
+	if len(userIDs) == 0 {
+		if users == nil {
+			userIDs = []string{s.mem.userID}
+		} else {
+			listed, err := users(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list users for %s: %w", s.job, err)
+			}
+			userIDs = listed
+		}
+	}
+	reports := make([]R, 0, len(userIDs))
+	var firstErr error
+	for _, userID := range userIDs {
+		if err := ctx.Err(); err != nil {
+			return reports, err
+		}
+		report, err := s.runOne(userID)
+		reports = append(reports, report)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return reports, firstErr
+}
+
+// start calls run every interval until ctx is done. users is read on each
+// tick, so it may be set after start.
+func (s *userScheduler[R]) start(ctx context.Context, interval time.Duration, users func() func(ctx context.Context) ([]string, error), userIDs []string) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.run(ctx, users(), userIDs); err != nil {
+					s.mem.logger.Warn("karma_memory: "+s.job+" run failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
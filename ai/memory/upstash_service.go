@@ -134,10 +134,29 @@ func (d *upstashVectorClient) queryVector(v []float32, topK int, fs ...filters)
 	return scores, nil
 }
 
+func (d *upstashVectorClient) fetchVectors(ids []string, withVectors bool) ([]v, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	vectors, err := d.ns.Fetch(vector.Fetch{Ids: ids, IncludeMetadata: true, IncludeVectors: withVectors})
+	if err != nil {
+		return nil, fmt.Errorf("upstash fetch failed: %w", err)
+	}
+	records := make([]v, 0, len(vectors))
+	for _, vec := range vectors {
+		if vec.Id == "" || vec.Metadata == nil {
+			continue
+		}
+		records = append(records, v{memories: metadataToMemory(vec.Metadata, vec.Id), vector: vec.Vector})
+	}
+	return records, nil
+}
+
 func (d *upstashVectorClient) queryVectorByMetadata(f filters) ([]map[string]any, error) {
 	filter := d.buildMetadataFilter(&f, true)
 
-	if filter == "" {
+	// An all-scopes query without other filters lists the whole namespace
+	if filter == "" && (f.IncludeAllScopes == nil || !*f.IncludeAllScopes) {
 		return nil, fmt.Errorf("no metadata filters provided")
 	}
 
@@ -149,16 +168,23 @@ func (d *upstashVectorClient) queryVectorByMetadata(f filters) ([]map[string]any
 		IncludeData:     false,
 	}
 
-	rangeVectors, err := d.ns.Range(r)
-	if err != nil {
-		return nil, fmt.Errorf("upstash range query failed: %w", err)
-	}
-
 	metadatas := make([]map[string]any, 0)
-	for _, vec := range rangeVectors.Vectors {
-		if d.matchesFilter(vec.Metadata, &f) {
-			metadatas = append(metadatas, vec.Metadata)
+	for {
+		rangeVectors, err := d.ns.Range(r)
+		if err != nil {
+			return nil, fmt.Errorf("upstash range query failed: %w", err)
 		}
+
+		for _, vec := range rangeVectors.Vectors {
+			if d.matchesFilter(vec.Metadata, &f) {
+				metadatas = append(metadatas, vec.Metadata)
+			}
+		}
+
+		if rangeVectors.NextCursor == "" || len(rangeVectors.Vectors) == 0 {
+			break
+		}
+		r.Cursor = rangeVectors.NextCursor
 	}
 
 	return metadatas, nil
@@ -228,17 +254,17 @@ func (d *upstashVectorClient) matchesFilter(metadata map[string]interface{}, f *
 	return true
 }
 
+// updateVector overwrites the vector and metadata. Without a vector only the
+// metadata is replaced.
 func (d *upstashVectorClient) updateVector(memory Memory, v ...[]float32) (bool, error) {
-	if len(v) == 0 {
-		d.logger.Warn("no vector provided to updateVector")
-		return false, fmt.Errorf("no vector provided")
-	}
-
 	m, err := memory.ToMap()
 	if err != nil {
 		d.logger.Error("Failed to map memory metadata", zap.Error(err))
 	}
 
+	if len(v) == 0 || len(v[0]) == 0 {
+		return d.ns.Update(vector.Update{Id: memory.Id, Metadata: m})
+	}
 	vs := vector.Update{
 		Id:       memory.Id,
 		Vector:   v[0],
@@ -261,3 +287,7 @@ func (d *upstashVectorClient) shiftUser(userId string) string {
 	d.ns = d.idx.Namespace(d.userId)
 	return d.userId
 }
+
+func (d *upstashVectorClient) forUser(userId, scope string) vectorService {
+	return &upstashVectorClient{userId: userId, scope: scope, ns: d.idx.Namespace(userId), idx: d.idx, logger: d.logger}
+}
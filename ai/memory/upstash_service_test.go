@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestUpstashUpdateWithoutVectorKeepsVector(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"result":{"updated":1}}`))
+	}))
+	defer srv.Close()
+	t.Setenv("KARMA_MEMORY_UPSTASH_VECTOR_REST_URL", srv.URL)
+	t.Setenv("KARMA_MEMORY_UPSTASH_VECTOR_REST_TOKEN", "test")
+
+	client := newUpstashClient("u1", "proj", zap.NewNop())
+	ok, err := client.updateVector(Memory{Id: "m1", Status: StatusSuperseded, Importance: 2})
+	if err != nil || !ok {
+		t.Fatalf("update = %v, %v", ok, err)
+	}
+	if _, sent := got["vector"]; sent {
+		t.Fatalf("metadata-only update sent a vector: %v", got)
+	}
+	meta, _ := got["metadata"].(map[string]any)
+	if _, mode := got["metadataUpdateMode"]; mode || meta["status"] != "superseded" || meta["importance"] != float64(2) {
+		t.Fatalf("update body = %v", got)
+	}
+	if summary, ok := meta["summary"]; !ok || summary != "" {
+		t.Fatalf("metadata should be replaced in full: %v", meta)
+	}
+}
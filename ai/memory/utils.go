@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/MelloB1989/karma/ai/parser"
+	"github.com/MelloB1989/karma/tokenizer"
 	"github.com/upstash/vector-go"
 	"go.uber.org/zap"
 )
@@ -256,12 +257,12 @@ func (k *KarmaMemory) formatContext(memories []Memory, maxTokens int) string {
 		rulesStr := strings.Join(rules, "; ")
 		sb.WriteString(rulesStr)
 		sb.WriteString("\n")
-		currentTokens += len(rulesStr) / 5
+		currentTokens += k.countTokens(rulesStr)
 	}
 
 	// Add other memories if token budget allows
 	for _, summary := range otherMemories {
-		entryTokens := len(summary) / 5
+		entryTokens := k.countTokens(summary)
 		if currentTokens+entryTokens > maxTokens {
 			break
 		}
@@ -274,6 +275,15 @@ func (k *KarmaMemory) formatContext(memories []Memory, maxTokens int) string {
 	return sb.String()
 }
 
+// countTokens estimates tokens for the model that will receive the context.
+func (k *KarmaMemory) countTokens(text string) int {
+	model := ""
+	if k.kai != nil {
+		model = k.kai.Model.GetModelString()
+	}
+	return tokenizer.CountTokens(model, text)
+}
+
 func (k *KarmaMemory) formatContextForIngest(memories []Memory) string {
 	if len(memories) == 0 {
 		return ""
@@ -377,6 +387,33 @@ func (m *Memory) ToMap() (map[string]any, error) {
 	return out, nil
 }
 
+// memoryMetadata round-trips the memory through JSON, so times become RFC3339
+// strings and numbers float64, matching what metadataToMemory expects. The
+// self-hosted services store memories in this form.
+func memoryMetadata(memory Memory) (map[string]any, error) {
+	m, err := memory.ToMap()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	if rels, ok := out["entity_relationships"]; ok && rels != nil {
+		relBytes, _ := json.Marshal(rels)
+		out["entity_relationships_json"] = string(relBytes)
+	}
+	if md, ok := out["metadata"]; ok && md != nil {
+		mdBytes, _ := json.Marshal(md)
+		out["metadata"] = string(mdBytes)
+	}
+	return out, nil
+}
+
 func ptrStr(s string) *string {
 	return &s
 }
@@ -484,23 +521,41 @@ func extractKeyWords(s string) []string {
 	return keyWords
 }
 
+// markMemoryAsSuperseded rewrites the stored memory with its status flipped;
+// updates replace the whole memory, so it is read first.
 func (k *KarmaMemory) markMemoryAsSuperseded(memoryId string) error {
-	supersededMem := Memory{
-		Id:        memoryId,
-		Status:    StatusSuperseded,
-		UpdatedAt: time.Now(),
+	records, err := k.memorydb.client.fetchVectors([]string{memoryId}, false)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("memory %s not found", memoryId)
+	}
+	mem := records[0].memories
+	mem.Status = StatusSuperseded
+	mem.UpdatedAt = time.Now()
+	if _, err = k.memorydb.client.updateVector(mem); err == nil {
+		k.syncGraph(nil, []string{memoryId})
 	}
+	return err
+}
 
-	switch k.memorydb.currentService {
-	case VectorServiceUpstash:
-		_, err := k.memorydb.client.updateVector(supersededMem)
-		return err
-	case VectorServicePinecone:
-		_, err := k.memorydb.client.updateVector(supersededMem)
-		return err
+// updateMemory writes back the metadata of a complete memory, keeping its
+// vector.
+func (k *KarmaMemory) updateMemory(mem Memory) error {
+	_, err := k.memorydb.client.updateVector(mem)
+	if err == nil {
+		k.syncGraph([]Memory{mem}, nil)
 	}
+	return err
+}
 
-	return nil
+// memoryEmbeddingText is the text a memory is embedded from.
+func memoryEmbeddingText(mem Memory) string {
+	if mem.RawText != "" {
+		return mem.RawText + " " + mem.Summary
+	}
+	return mem.Summary
 }
 
 func intPtr(i int) *int {
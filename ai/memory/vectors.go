@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/MelloB1989/karma/config"
 	"github.com/upstash/vector-go"
 	"go.uber.org/zap"
 )
@@ -76,6 +77,12 @@ type VectorServices string
 const (
 	VectorServiceUpstash  VectorServices = "upstash"
 	VectorServicePinecone VectorServices = "pinecone"
+	// VectorServiceLocal runs in-process, for tests and air-gapped setups.
+	VectorServiceLocal VectorServices = "local"
+	// VectorServicePgvector stores memories in Postgres; see UsePgvector.
+	VectorServicePgvector VectorServices = "pgvector"
+	// VectorServiceQdrant uses a self-hosted Qdrant; see UseQdrant.
+	VectorServiceQdrant VectorServices = "qdrant"
 )
 
 type filters struct {
@@ -97,40 +104,76 @@ type vectorService interface {
 	upsertVectors(vectors []v) error
 	queryVector(vectors []float32, topK int, fs ...filters) ([]vector.VectorScore, error)
 	queryVectorByMetadata(filters filters) ([]map[string]any, error)
+	// fetchVectors returns the records of those ids the client's user has,
+	// in any scope, with their vectors when withVectors is set.
+	fetchVectors(ids []string, withVectors bool) ([]v, error)
+	// updateVector replaces the stored memory. Without a vector it keeps the
+	// stored one, so memory must still be complete: empty fields are cleared.
 	updateVector(memory Memory, v ...[]float32) (bool, error)
 	deleteVectors(vectorsIds []string) (count int, err error)
 	shiftScope(scope string) string
 	shiftUser(userId string) string
+	// forUser returns a client for userId and scope sharing this one's
+	// connection, leaving this one untouched.
+	forUser(userId, scope string) vectorService
 }
 
 type vectorClient struct {
 	currentService VectorServices
 	client         vectorService
-	logger         *zap.Logger
+	// userId is the user the client currently reads and writes.
+	userId string
+	logger *zap.Logger
 }
 
 func newVectorClient(userId, scope string, logger *zap.Logger) *vectorClient {
 	client := &vectorClient{
 		logger: logger,
 	}
-	if err := client.switchService(userId, scope, VectorServiceUpstash); err != nil {
+	service := defaultVectorService()
+	if service == VectorServiceLocal && config.GetEnvRaw("KARMA_MEMORY_LOCAL_VECTOR_PATH") == "" {
+		logger.Warn("[KARMA_MEMORY] no vector service configured, keeping memories in process only; they are lost on restart. Set KARMA_MEMORY_LOCAL_VECTOR_PATH or configure a hosted service")
+	}
+	if err := client.switchService(userId, scope, service); err != nil {
 		client.logger.Error("[KARMA_MEMORY] failed to switch service", zap.Error(err))
 		return nil
 	}
 	return client // Default service, use the useService method to set a different service
 }
 
+// defaultVectorService picks the first hosted service with credentials set,
+// falling back to the in-process one, which newVectorClient warns about
+// unless it persists to a file.
+func defaultVectorService() VectorServices {
+	switch {
+	case config.GetEnvRaw("KARMA_MEMORY_UPSTASH_VECTOR_REST_URL") != "" && config.GetEnvRaw("KARMA_MEMORY_UPSTASH_VECTOR_REST_TOKEN") != "":
+		return VectorServiceUpstash
+	case config.GetEnvRaw("KARMA_MEMORY_PINECONE_API_KEY") != "" && config.GetEnvRaw("KARMA_MEMORY_PINECONE_INDEX_HOST") != "":
+		return VectorServicePinecone
+	case config.GetEnvRaw("KARMA_MEMORY_QDRANT_URL") != "":
+		return VectorServiceQdrant
+	}
+	return VectorServiceLocal
+}
+
 func (d *vectorClient) switchService(userId, scope string, service VectorServices) error {
 	switch service {
 	case VectorServiceUpstash:
 		d.client = newUpstashClient(userId, scope, d.logger)
 	case VectorServicePinecone:
 		d.client = newPineconeClient(userId, scope, d.logger)
+	case VectorServiceLocal:
+		d.client = newLocalClient(userId, scope, d.logger)
+	case VectorServicePgvector:
+		return d.usePgvector(userId, scope, PgvectorConfig{})
+	case VectorServiceQdrant:
+		return d.useQdrant(userId, scope, QdrantConfig{})
 	default:
 		d.logger.Error("[KARMA_MEMORY] invalid service")
 		return fmt.Errorf("invalid service")
 	}
 	d.currentService = service
+	d.userId = userId
 	return nil
 }
 
@@ -138,6 +181,37 @@ func (d *vectorClient) setScope(scope string) string {
 	return d.client.shiftScope(scope)
 }
 
+// forUser returns a vector client bound to userId and scope, for serving
+// another user without switching this one.
+func (d *vectorClient) forUser(userId, scope string) *vectorClient {
+	return &vectorClient{currentService: d.currentService, client: d.client.forUser(userId, scope), userId: userId, logger: d.logger}
+}
+
 func (d *vectorClient) setUser(userId string) string {
-	return d.client.shiftUser(userId)
+	d.userId = d.client.shiftUser(userId)
+	return d.userId
+}
+
+func (d *vectorClient) usePgvector(userId, scope string, cfg PgvectorConfig) error {
+	client, err := newPgvectorClient(userId, scope, d.logger, cfg)
+	if err != nil {
+		d.logger.Error("[KARMA_MEMORY] failed to open pgvector store", zap.Error(err))
+		return err
+	}
+	d.client = client
+	d.currentService = VectorServicePgvector
+	d.userId = userId
+	return nil
+}
+
+func (d *vectorClient) useQdrant(userId, scope string, cfg QdrantConfig) error {
+	client, err := newQdrantClient(userId, scope, d.logger, cfg)
+	if err != nil {
+		d.logger.Error("[KARMA_MEMORY] failed to open qdrant store", zap.Error(err))
+		return err
+	}
+	d.client = client
+	d.currentService = VectorServiceQdrant
+	d.userId = userId
+	return nil
 }
@@ -0,0 +1,324 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MelloB1989/karma/apis/aws/bedrock"
+)
+
+// RemoteModel is a model a provider reports as available.
+type RemoteModel struct {
+	ID          string    `json:"id"`
+	Provider    Provider  `json:"provider"`
+	DisplayName string    `json:"display_name,omitempty"`
+	OwnedBy     string    `json:"owned_by,omitempty"`
+	Created     time.Time `json:"created,omitempty"`
+	// Deprecated is set when the provider flags the model for retirement.
+	Deprecated bool `json:"deprecated,omitempty"`
+}
+
+// openAICompatibleModelsAPI is the base URL whose /models endpoint lists each
+// provider's models.
+var openAICompatibleModelsAPI = map[Provider]string{
+	OpenAI:      "https://api.openai.com/v1",
+	XAI:         XAI_API,
+	Groq:        GROQ_API,
+	FireworksAI: FIREWORKS_API,
+	OpenRouter:  OPENROUTER_API,
+	TogetherAI:  TOGETHER_API,
+	NvidiaNIM:   NVIDIA_NIM_API,
+}
+
+const (
+	anthropicModelsAPI = "https://api.anthropic.com/v1/models"
+	googleModelsAPI    = "https://generativelanguage.googleapis.com/v1beta/models"
+)
+
+var modelCache struct {
+	sync.RWMutex
+	dir string
+	ttl time.Duration
+}
+
+// EnableModelCache caches ListModels results as JSON files in dir for ttl, so
+// repeated discovery across restarts skips the network. An empty dir disables
+// the cache.
+func EnableModelCache(dir string, ttl time.Duration) {
+	modelCache.Lock()
+	defer modelCache.Unlock()
+	modelCache.dir = dir
+	modelCache.ttl = ttl
+}
+
+// ListModels returns the models provider currently serves and registers each
+// one in ProviderModelMapping, so BaseModel(id) resolves for that provider.
+// Existing mappings are left untouched. Options supply credentials the same
+// way they do for NewKarmaAI: WithCustomProvider, WithCredentialResolver,
+// WithTenant and WithBedrockRegion are honoured.
+func ListModels(ctx context.Context, provider Provider, opts ...Option) ([]RemoteModel, error) {
+	kai := NewKarmaAI("", provider, opts...)
+	if err := kai.resolveCredentials(); err != nil {
+		return nil, err
+	}
+	cacheKey := kai.modelCacheKey()
+	if cached, ok := readModelCache(cacheKey); ok {
+		RegisterModels(provider, cached)
+		return cached, nil
+	}
+
+	list, err := kai.listModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list %s models: %s", provider, kai.redact(err.Error()))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	RegisterModels(provider, list)
+	writeModelCache(cacheKey, list)
+	return list, nil
+}
+
+// RegisterModels adds discovered models to ProviderModelMapping without
+// overriding existing entries.
+func RegisterModels(provider Provider, list []RemoteModel) {
+	providerModelMappingMu.Lock()
+	defer providerModelMappingMu.Unlock()
+	if ProviderModelMapping[provider] == nil {
+		ProviderModelMapping[provider] = make(map[BaseModel]string, len(list))
+	}
+	for _, m := range list {
+		if _, ok := ProviderModelMapping[provider][BaseModel(m.ID)]; !ok {
+			ProviderModelMapping[provider][BaseModel(m.ID)] = m.ID
+		}
+	}
+}
+
+func (kai *KarmaAI) listModels(ctx context.Context) ([]RemoteModel, error) {
+	provider := kai.Model.GetModelProvider()
+	switch provider {
+	case Anthropic:
+		return listAnthropicModels(ctx, kai.baseURL(anthropicModelsAPI), kai.apiKey(providerKeyEnv[Anthropic]))
+	case Google:
+		return listGoogleModels(ctx, kai.apiKey(providerKeyEnv[Google]))
+	case Bedrock:
+		found, err := bedrock.ListFoundationModels(ctx, bedrock.ClientOptions{Region: kai.bedrockRegion()})
+		if err != nil {
+			return nil, err
+		}
+		list := make([]RemoteModel, len(found))
+		for i, m := range found {
+			list[i] = RemoteModel{ID: m.ID, Provider: Bedrock, DisplayName: m.Name, OwnedBy: m.Provider, Deprecated: m.Legacy}
+		}
+		return list, nil
+	case Codex:
+		found, err := ListCodexModels(ctx)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]RemoteModel, len(found))
+		for i, m := range found {
+			list[i] = RemoteModel{ID: m.ID, Provider: Codex, DisplayName: m.DisplayName}
+		}
+		return list, nil
+	}
+
+	if base, ok := openAICompatibleModelsAPI[provider]; ok {
+		return listOpenAICompatibleModels(ctx, provider, kai.baseURL(base), kai.apiKey(providerKeyEnv[provider]))
+	}
+	if base, key, ok := kai.resolveOpenAICompatibleEndpoint(); ok {
+		return listOpenAICompatibleModels(ctx, provider, base, key)
+	}
+	return nil, fmt.Errorf("model listing is not supported for provider %q", provider)
+}
+
+// modelsHTTPClient bounds model listing calls that come without a deadline.
+var modelsHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// modelsMaxBody caps a model listing response.
+const modelsMaxBody = 16 << 20
+
+func getModelsJSON(ctx context.Context, endpoint string, headers map[string]string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	for k, val := range headers {
+		if val != "" {
+			req.Header.Set(k, val)
+		}
+	}
+	resp, err := modelsHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, modelsMaxBody))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+func listOpenAICompatibleModels(ctx context.Context, provider Provider, baseURL, apiKey string) ([]RemoteModel, error) {
+	var resp struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Name    string `json:"name"`
+			OwnedBy string `json:"owned_by"`
+			Created int64  `json:"created"`
+		} `json:"data"`
+	}
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["Authorization"] = "Bearer " + apiKey
+	}
+	if err := getModelsJSON(ctx, strings.TrimSuffix(baseURL, "/")+"/models", headers, &resp); err != nil {
+		return nil, err
+	}
+	list := make([]RemoteModel, 0, len(resp.Data))
+	for _, m := range resp.Data {
+		rm := RemoteModel{ID: m.ID, Provider: provider, DisplayName: m.Name, OwnedBy: m.OwnedBy}
+		if m.Created > 0 {
+			rm.Created = time.Unix(m.Created, 0).UTC()
+		}
+		list = append(list, rm)
+	}
+	return list, nil
+}
+
+func listAnthropicModels(ctx context.Context, endpoint, apiKey string) ([]RemoteModel, error) {
+	if !strings.HasSuffix(endpoint, "/models") {
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/models"
+	}
+	headers := map[string]string{"x-api-key": apiKey, "anthropic-version": "2023-06-01"}
+	var list []RemoteModel
+	after := ""
+	for {
+		q := url.Values{"limit": {"1000"}}
+		if after != "" {
+			q.Set("after_id", after)
+		}
+		var resp struct {
+			Data []struct {
+				ID          string    `json:"id"`
+				DisplayName string    `json:"display_name"`
+				CreatedAt   time.Time `json:"created_at"`
+			} `json:"data"`
+			HasMore bool   `json:"has_more"`
+			LastID  string `json:"last_id"`
+		}
+		if err := getModelsJSON(ctx, endpoint+"?"+q.Encode(), headers, &resp); err != nil {
+			return nil, err
+		}
+		for _, m := range resp.Data {
+			list = append(list, RemoteModel{ID: m.ID, Provider: Anthropic, DisplayName: m.DisplayName, Created: m.CreatedAt})
+		}
+		if !resp.HasMore || resp.LastID == "" {
+			return list, nil
+		}
+		after = resp.LastID
+	}
+}
+
+func listGoogleModels(ctx context.Context, apiKey string) ([]RemoteModel, error) {
+	var list []RemoteModel
+	token := ""
+	for {
+		q := url.Values{"pageSize": {"1000"}}
+		if token != "" {
+			q.Set("pageToken", token)
+		}
+		var resp struct {
+			Models []struct {
+				Name        string `json:"name"`
+				DisplayName string `json:"displayName"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := getModelsJSON(ctx, googleModelsAPI+"?"+q.Encode(), map[string]string{"x-goog-api-key": apiKey}, &resp); err != nil {
+			return nil, err
+		}
+		for _, m := range resp.Models {
+			list = append(list, RemoteModel{ID: strings.TrimPrefix(m.Name, "models/"), Provider: Google, DisplayName: m.DisplayName})
+		}
+		if resp.NextPageToken == "" {
+			return list, nil
+		}
+		token = resp.NextPageToken
+	}
+}
+
+type modelCacheFile struct {
+	FetchedAt time.Time     `json:"fetched_at"`
+	Models    []RemoteModel `json:"models"`
+}
+
+// modelCacheKey names the cache file for kai's provider and endpoint: tenants
+// and endpoints serving different models get separate files. Credentials are
+// hashed, never written.
+func (kai *KarmaAI) modelCacheKey() string {
+	provider := kai.Model.GetModelProvider()
+	creds := kai.credentials()
+	h := sha256.New()
+	for _, part := range []string{creds.BaseURL, kai.CustomProviderBaseURL, kai.apiKey(providerKeyEnv[provider]), kai.CustomProviderAPIKey, kai.bedrockRegion(), creds.ProjectID} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	name := strings.NewReplacer("/", "_", string(os.PathSeparator), "_").Replace(string(provider))
+	return name + "-" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func modelCachePath(key string) (string, time.Duration) {
+	modelCache.RLock()
+	defer modelCache.RUnlock()
+	if modelCache.dir == "" {
+		return "", 0
+	}
+	return filepath.Join(modelCache.dir, key+".models.json"), modelCache.ttl
+}
+
+func readModelCache(key string) ([]RemoteModel, bool) {
+	path, ttl := modelCachePath(key)
+	if path == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var f modelCacheFile
+	if json.Unmarshal(data, &f) != nil || (ttl > 0 && time.Since(f.FetchedAt) > ttl) {
+		return nil, false
+	}
+	return f.Models, true
+}
+
+// writeModelCache is best effort; a read-only filesystem only loses caching.
+func writeModelCache(key string, list []RemoteModel) {
+	path, _ := modelCachePath(key)
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(modelCacheFile{FetchedAt: time.Now(), Models: list})
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(path), 0755) == nil {
+		os.WriteFile(path, data, 0644)
+	}
+}
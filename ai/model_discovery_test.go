@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListModelsRegistersAndCaches(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"zeta-2","created":1700000000,"owned_by":"acme"},{"id":"alpha-1"}]}`)
+	}))
+	defer srv.Close()
+
+	EnableModelCache(t.TempDir(), time.Hour)
+	defer EnableModelCache("", 0)
+
+	provider := Provider("acme-discovery")
+	list, err := ListModels(context.Background(), provider, WithCustomProvider(srv.URL+"/v1", "sk-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "alpha-1" || list[1].OwnedBy != "acme" || list[1].Created.Year() != 2023 {
+		t.Fatalf("list = %+v", list)
+	}
+
+	providerModelMappingMu.RLock()
+	got := ProviderModelMapping[provider][BaseModel("zeta-2")]
+	providerModelMappingMu.RUnlock()
+	if got != "zeta-2" {
+		t.Fatalf("zeta-2 not registered, got %q", got)
+	}
+
+	if _, err := ListModels(context.Background(), provider, WithCustomProvider(srv.URL+"/v1", "sk-test")); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("cached listing hit the server %d times", calls)
+	}
+
+	// Another credential or endpoint must not be served this listing.
+	if _, err := ListModels(context.Background(), provider, WithCustomProvider(srv.URL+"/v1", "sk-other")); err == nil {
+		t.Fatal("a different key should not reuse the cached listing")
+	}
+	if calls != 2 {
+		t.Fatalf("calls = %d, want a fresh listing for the other key", calls)
+	}
+}
@@ -39,12 +39,15 @@ func (e *RateLimitError) Unwrap() error {
 	return ErrRateLimited
 }
 
-// RateLimitConfig configures requests-per-minute limiting for a KarmaAI instance.
+// RateLimitConfig configures requests-per-minute and tokens-per-minute
+// limiting for a KarmaAI instance. Both limits share the same Behavior.
 type RateLimitConfig struct {
 	RequestsPerMinute int               `json:"requests_per_minute"`
+	TokensPerMinute   int               `json:"tokens_per_minute"`
 	Behavior          RateLimitBehavior `json:"behavior"`
 
-	limiter *rateLimiter
+	limiter      *rateLimiter
+	tokenLimiter *tokenRateLimiter
 }
 
 type globalRateLimitKey struct {
@@ -61,15 +64,42 @@ var globalRateLimits = struct {
 // WithRateLimit applies an instance-local requests-per-minute limit.
 func WithRateLimit(requestsPerMinute int, behavior RateLimitBehavior) Option {
 	return func(kai *KarmaAI) {
-		kai.RateLimit = newRateLimitConfig(requestsPerMinute, behavior)
+		kai.RateLimit = carryTokenLimit(newRateLimitConfig(requestsPerMinute, behavior), kai.RateLimit)
 	}
 }
 
+// WithTokenRateLimit applies an instance-local tokens-per-minute limit. Prompt
+// tokens are estimated with the tokenizer package before each request.
+func WithTokenRateLimit(tokensPerMinute int, behavior RateLimitBehavior) Option {
+	return func(kai *KarmaAI) {
+		kai.RateLimit = withTokenLimit(kai.RateLimit, tokensPerMinute, behavior)
+	}
+}
+
+// SetGlobalTokenRateLimit applies a tokens-per-minute limit shared by all KarmaAI instances for a provider.
+func SetGlobalTokenRateLimit(provider Provider, tokensPerMinute int, behavior RateLimitBehavior) {
+	globalRateLimits.Lock()
+	defer globalRateLimits.Unlock()
+	key := globalRateLimitKey{provider: provider}
+	cfg := withTokenLimit(globalRateLimits.limits[key], tokensPerMinute, behavior)
+	if cfg == nil {
+		delete(globalRateLimits.limits, key)
+		return
+	}
+	globalRateLimits.limits[key] = cfg
+}
+
 // SetGlobalRateLimit applies a requests-per-minute limit shared by all KarmaAI instances for a provider.
 func SetGlobalRateLimit(provider Provider, requestsPerMinute int, behavior RateLimitBehavior) {
 	globalRateLimits.Lock()
 	defer globalRateLimits.Unlock()
-	globalRateLimits.limits[globalRateLimitKey{provider: provider}] = newRateLimitConfig(requestsPerMinute, behavior)
+	key := globalRateLimitKey{provider: provider}
+	cfg := carryTokenLimit(newRateLimitConfig(requestsPerMinute, behavior), globalRateLimits.limits[key])
+	if cfg == nil {
+		delete(globalRateLimits.limits, key)
+		return
+	}
+	globalRateLimits.limits[key] = cfg
 }
 
 // ClearGlobalRateLimit removes the shared rate limit for a provider.
@@ -100,6 +130,46 @@ func newRateLimitConfig(requestsPerMinute int, behavior RateLimitBehavior) *Rate
 	}
 }
 
+// carryTokenLimit keeps a previously configured tokens-per-minute limit when
+// the requests-per-minute limit is replaced.
+func carryTokenLimit(cfg, prev *RateLimitConfig) *RateLimitConfig {
+	if prev == nil || prev.tokenLimiter == nil {
+		return cfg
+	}
+	if cfg == nil {
+		return &RateLimitConfig{
+			TokensPerMinute: prev.TokensPerMinute,
+			Behavior:        prev.Behavior,
+			tokenLimiter:    prev.tokenLimiter,
+		}
+	}
+	cfg.TokensPerMinute = prev.TokensPerMinute
+	cfg.tokenLimiter = prev.tokenLimiter
+	return cfg
+}
+
+func withTokenLimit(cfg *RateLimitConfig, tokensPerMinute int, behavior RateLimitBehavior) *RateLimitConfig {
+	if tokensPerMinute <= 0 {
+		if cfg != nil {
+			cfg.TokensPerMinute = 0
+			cfg.tokenLimiter = nil
+		}
+		return cfg
+	}
+	if cfg == nil {
+		cfg = &RateLimitConfig{}
+	}
+	if behavior != "" {
+		cfg.Behavior = behavior
+	}
+	if cfg.Behavior == "" {
+		cfg.Behavior = RateLimitBehaviorWait
+	}
+	cfg.TokensPerMinute = tokensPerMinute
+	cfg.tokenLimiter = newTokenRateLimiter(tokensPerMinute)
+	return cfg
+}
+
 func getGlobalRateLimit(provider Provider) *RateLimitConfig {
 	globalRateLimits.RLock()
 	defer globalRateLimits.RUnlock()
@@ -169,6 +239,71 @@ func (kai *KarmaAI) enforceRateLimit() error {
 	}
 }
 
+// enforceTokenRateLimit reserves tokens against the instance and global
+// tokens-per-minute limits, waiting or failing according to Behavior.
+func (kai *KarmaAI) enforceTokenRateLimit(tokens int) error {
+	provider := kai.Model.GetModelProvider()
+	model := kai.Model.GetModelString()
+	limits := []rateLimitEntry{
+		{config: kai.RateLimit, scope: "instance_tokens"},
+		{config: getGlobalRateLimit(provider), scope: "global_tokens"},
+	}
+	activeLimits := limits[:0]
+	for _, limit := range limits {
+		if limit.config != nil && limit.config.tokenLimiter != nil {
+			activeLimits = append(activeLimits, limit)
+		}
+	}
+	if len(activeLimits) == 0 {
+		return nil
+	}
+
+	for {
+		for _, limit := range activeLimits {
+			limit.config.tokenLimiter.mu.Lock()
+		}
+
+		now := time.Now()
+		var blocked *rateLimitEntry
+		var waitFor time.Duration
+		for i := range activeLimits {
+			limit := &activeLimits[i]
+			limiter := limit.config.tokenLimiter
+			limiter.purgeLocked(now)
+			retryAfter := limiter.retryAfterLocked(now, tokens)
+			if retryAfter > 0 && (blocked == nil || retryAfter > waitFor || limit.config.Behavior == RateLimitBehaviorError) {
+				blocked = limit
+				waitFor = retryAfter
+			}
+		}
+
+		if blocked == nil {
+			for _, limit := range activeLimits {
+				limit.config.tokenLimiter.recordLocked(now, tokens)
+			}
+			for i := len(activeLimits) - 1; i >= 0; i-- {
+				activeLimits[i].config.tokenLimiter.mu.Unlock()
+			}
+			return nil
+		}
+
+		behavior := blocked.config.Behavior
+		scope := blocked.scope
+		for i := len(activeLimits) - 1; i >= 0; i-- {
+			activeLimits[i].config.tokenLimiter.mu.Unlock()
+		}
+		if behavior == RateLimitBehaviorError {
+			return &RateLimitError{
+				Provider:   provider,
+				Model:      model,
+				Scope:      scope,
+				RetryAfter: waitFor,
+			}
+		}
+		time.Sleep(waitFor)
+	}
+}
+
 type rateLimitEntry struct {
 	config *RateLimitConfig
 	scope  string
@@ -217,3 +352,64 @@ func (rl *rateLimiter) retryAfterLocked(now time.Time) time.Duration {
 func (rl *rateLimiter) recordLocked(now time.Time) {
 	rl.requests = append(rl.requests, now)
 }
+
+type tokenUsage struct {
+	at     time.Time
+	tokens int
+}
+
+type tokenRateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	used   int
+	usages []tokenUsage
+}
+
+func newTokenRateLimiter(limit int) *tokenRateLimiter {
+	return &tokenRateLimiter{
+		limit:  limit,
+		window: time.Minute,
+	}
+}
+
+func (tl *tokenRateLimiter) purgeLocked(now time.Time) {
+	cutoff := now.Add(-tl.window)
+	writeIndex := 0
+	for _, usage := range tl.usages {
+		if usage.at.After(cutoff) {
+			tl.usages[writeIndex] = usage
+			writeIndex++
+		} else {
+			tl.used -= usage.tokens
+		}
+	}
+	tl.usages = tl.usages[:writeIndex]
+}
+
+// retryAfterLocked returns how long until tokens fit in the window. A single
+// request larger than the whole limit is let through once the window is empty
+// so it can never block forever.
+func (tl *tokenRateLimiter) retryAfterLocked(now time.Time, tokens int) time.Duration {
+	if tl.used+tokens <= tl.limit || len(tl.usages) == 0 {
+		return 0
+	}
+
+	freed := 0
+	for _, usage := range tl.usages {
+		freed += usage.tokens
+		if tl.used-freed+tokens <= tl.limit || freed == tl.used {
+			retryAfter := usage.at.Add(tl.window).Sub(now)
+			if retryAfter < 0 {
+				return 0
+			}
+			return retryAfter
+		}
+	}
+	return 0
+}
+
+func (tl *tokenRateLimiter) recordLocked(now time.Time, tokens int) {
+	tl.usages = append(tl.usages, tokenUsage{at: now, tokens: tokens})
+	tl.used += tokens
+}
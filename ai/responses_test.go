@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/MelloB1989/karma/internal/codex"
+	"github.com/MelloB1989/karma/models"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+func TestResponsesChainSendsOnlyNewMessages(t *testing.T) {
+	kai := NewKarmaAI(GPT4oMini, OpenAI, WithResponsesAPI(ResponsesOptions{Chain: true}))
+	history := &models.AIChatHistory{Messages: []models.AIMessage{
+		{Role: models.User, Message: "hi"},
+		{Role: models.Assistant, Message: "hello", Metadata: map[string]any{ResponseIDMetadataKey: "resp_1"}},
+		{Role: models.User, Message: "again"},
+	}}
+	id, from := kai.responsesChain(history)
+	if id != "resp_1" || from != 2 {
+		t.Fatalf("responsesChain = (%q, %d), want (resp_1, 2)", id, from)
+	}
+
+	// Without chaining the whole history is always sent.
+	kai.Responses.Chain = false
+	if id, from := kai.responsesChain(history); id != "" || from != 0 {
+		t.Fatalf("unchained responsesChain = (%q, %d), want empty", id, from)
+	}
+}
+
+func TestResponsesChainFromExplicitID(t *testing.T) {
+	kai := NewKarmaAI(GPT4oMini, OpenAI, WithResponsesAPI(ResponsesOptions{PreviousResponseID: "resp_9"}))
+	history := &models.AIChatHistory{Messages: []models.AIMessage{
+		{Role: models.User, Message: "hi"},
+		{Role: models.Assistant, Message: "hello"},
+		{Role: models.User, Message: "again"},
+	}}
+	if id, from := kai.responsesChain(history); id != "resp_9" || from != 2 {
+		t.Fatalf("responsesChain = (%q, %d), want (resp_9, 2)", id, from)
+	}
+}
+
+func TestResponsesRequestBuiltinTools(t *testing.T) {
+	kai := NewKarmaAI(GPT4oMini, OpenAI,
+		WithResponsesWebSearch(ResponsesWebSearch{AllowedDomains: []string{"go.dev"}}),
+		WithResponsesFileSearch("vs_1"),
+		WithResponsesCodeInterpreter(),
+		WithMaxTokens(256),
+	)
+	req := kai.responsesRequest("", nil, nil, "")
+	if req.Store {
+		t.Error("store should be off unless chaining")
+	}
+	if req.MaxOutputTokens != 256 {
+		t.Errorf("MaxOutputTokens = %d, want 256", req.MaxOutputTokens)
+	}
+	var types []string
+	for _, tool := range req.Tools {
+		types = append(types, tool.Type)
+	}
+	if len(types) != 3 || types[0] != "web_search" || types[1] != "file_search" || types[2] != "code_interpreter" {
+		t.Fatalf("tool types = %v", types)
+	}
+	if req.Tools[1].VectorStoreIDs[0] != "vs_1" {
+		t.Errorf("file_search vector stores = %v", req.Tools[1].VectorStoreIDs)
+	}
+}
+
+func TestResponsesRequestSamplingAndFormat(t *testing.T) {
+	kai := NewKarmaAI(GPT4oMini, OpenAI, WithTemperature(0.2), WithTopP(0.5), WithResponseType("application/json"))
+	req := kai.responsesRequest("", nil, nil, "")
+	if req.Temperature == nil || *req.Temperature != float64(float32(0.2)) || req.TopP == nil || *req.TopP != 0.5 {
+		t.Fatalf("sampling = %v, %v", req.Temperature, req.TopP)
+	}
+	if req.Text == nil || req.Text.Format.Type != "json_object" {
+		t.Fatalf("text format = %+v", req.Text)
+	}
+
+	kai.ResponseType = `{"type": "object", "properties": {"answer": {"type": "string"}}}`
+	req = kai.responsesRequest("", nil, nil, "")
+	if req.Text == nil || req.Text.Format.Type != "json_schema" || req.Text.Format.Schema["type"] != "object" {
+		t.Fatalf("structured output format = %+v", req.Text)
+	}
+
+	effort := shared.ReasoningEffortLow
+	kai.ReasoningEffort = &effort
+	if req := kai.responsesRequest("", nil, nil, ""); req.Temperature != nil || req.TopP != nil {
+		t.Error("reasoning requests must not carry sampling parameters")
+	}
+}
+
+func TestAppendResponsesTurnKeepsToolCalls(t *testing.T) {
+	history := &models.AIChatHistory{}
+	appendResponsesTurn(history, &codex.Result{
+		Text:       "checking",
+		ResponseID: "resp_2",
+		ToolCalls:  []codex.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Pune"}`}},
+	}, map[string]string{"get_weather": "get.weather"})
+	if len(history.Messages) != 1 {
+		t.Fatalf("history = %+v", history.Messages)
+	}
+	msg := history.Messages[0]
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].ID != "call_1" || msg.ToolCalls[0].Function.Name != "get.weather" ||
+		msg.Metadata[ResponseIDMetadataKey] != "resp_2" {
+		t.Fatalf("assistant turn = %+v", msg)
+	}
+}
@@ -0,0 +1,381 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/tokenizer"
+)
+
+// Capability is a feature a routed model must support for a request.
+type Capability string
+
+const (
+	CapabilityVision      Capability = "vision"
+	CapabilityTools       Capability = "tools"
+	CapabilityLongContext Capability = "long_context"
+	CapabilityReasoning   Capability = "reasoning"
+)
+
+// Routing analytics properties, set on a per-request copy of the routed
+// instance.
+const (
+	RouterRoute  AIProperty = "$kai_router_route"
+	RouterReason AIProperty = "$kai_router_reason"
+)
+
+// Route is a named model the Router can send requests to.
+type Route struct {
+	Name string `json:"name"`
+	// Description tells the classifier what the route is for, e.g. "simple
+	// questions and small talk".
+	Description string      `json:"description"`
+	Model       ModelConfig `json:"model"`
+	// Options are applied after RouterOptions.Options.
+	Options []Option `json:"-"`
+	// Capabilities lists what the model supports. A route is skipped for
+	// requests that need a capability it does not list.
+	Capabilities []Capability `json:"capabilities,omitempty"`
+}
+
+// RoutingRule sends matching requests to Route. Zero fields match anything;
+// set fields must all match.
+type RoutingRule struct {
+	Route string `json:"route"`
+	// MinPromptTokens and MaxPromptTokens bound the estimated prompt size.
+	MinPromptTokens int `json:"min_prompt_tokens,omitempty"`
+	MaxPromptTokens int `json:"max_prompt_tokens,omitempty"`
+	// HasImages and HasTools match requests with images attached or tools
+	// in play.
+	HasImages bool `json:"has_images,omitempty"`
+	HasTools  bool `json:"has_tools,omitempty"`
+	// Requires matches requests that need all of these capabilities.
+	Requires []Capability `json:"requires,omitempty"`
+}
+
+// ClassifierOptions configures the model that picks a route when no rule
+// matches.
+type ClassifierOptions struct {
+	// Model classifies requests. The zero value uses GPT5Nano on OpenAI.
+	Model   ModelConfig `json:"model"`
+	Options []Option    `json:"-"`
+}
+
+// RouterOptions configures NewRouter.
+type RouterOptions struct {
+	Routes []Route `json:"routes"`
+	// Rules are checked in order; the first match wins.
+	Rules []RoutingRule `json:"rules,omitempty"`
+	// Classifier, when set, picks among Routes for requests no rule matches.
+	Classifier *ClassifierOptions `json:"classifier,omitempty"`
+	// Default is the route used when neither rules nor the classifier
+	// decide. Empty means the first route.
+	Default string `json:"default,omitempty"`
+	// Options are applied to every routed instance, e.g. ConfigureAnalytics,
+	// WithSystemMessage or WithTenant.
+	Options []Option `json:"-"`
+}
+
+// RoutingDecision records why a request went to a route.
+type RoutingDecision struct {
+	Route string      `json:"route"`
+	Model ModelConfig `json:"model"`
+	// Reason is "rule:<index>", "classifier", "default" or "capabilities".
+	Reason string `json:"reason"`
+	// Requires are the capabilities the request needed.
+	Requires     []Capability `json:"requires,omitempty"`
+	PromptTokens int          `json:"prompt_tokens"`
+}
+
+// Router picks a model per request with rules and an optional classifier,
+// and exposes the same completion methods as KarmaAI so call sites only
+// change the constructor. Routed instances are built once and reused, so
+// routes keep their MCP connections and analytics clients.
+//
+//	r, _ := ai.NewRouter(ai.RouterOptions{
+//		Routes: []ai.Route{
+//			{Name: "simple", Description: "small talk and short factual questions", Model: ai.ModelConfig{BaseModel: ai.GPT5Nano, Provider: ai.OpenAI}},
+//			{Name: "coding", Description: "writing or debugging code", Model: ai.ModelConfig{BaseModel: ai.Claude4_5Sonnet, Provider: ai.Anthropic}, Capabilities: []ai.Capability{ai.CapabilityTools, ai.CapabilityVision}},
+//		},
+//		Classifier: &ai.ClassifierOptions{},
+//	})
+//	res, err := r.ChatCompletion(history)
+type Router struct {
+	opts       RouterOptions
+	routes     map[string]*KarmaAI
+	classifier *KarmaAI
+
+	mu   sync.Mutex
+	last *RoutingDecision
+}
+
+// NewRouter validates opts and builds an instance per route.
+func NewRouter(opts RouterOptions) (*Router, error) {
+	if len(opts.Routes) == 0 {
+		return nil, errors.New("router needs at least one route")
+	}
+	r := &Router{opts: opts, routes: make(map[string]*KarmaAI, len(opts.Routes))}
+	for _, route := range opts.Routes {
+		if route.Name == "" {
+			return nil, errors.New("router route has no name")
+		}
+		if _, dup := r.routes[route.Name]; dup {
+			return nil, fmt.Errorf("router route %q is defined twice", route.Name)
+		}
+		kai := NewKarmaAI(route.Model.BaseModel, route.Model.Provider, append(append([]Option{}, opts.Options...), route.Options...)...)
+		kai.Model.CustomModelString = route.Model.CustomModelString
+		r.routes[route.Name] = kai
+	}
+	if r.opts.Default == "" {
+		r.opts.Default = opts.Routes[0].Name
+	}
+	if _, ok := r.routes[r.opts.Default]; !ok {
+		return nil, fmt.Errorf("router default route %q is not defined", r.opts.Default)
+	}
+	for i, rule := range opts.Rules {
+		if _, ok := r.routes[rule.Route]; !ok {
+			return nil, fmt.Errorf("router rule %d targets unknown route %q", i, rule.Route)
+		}
+	}
+	if c := opts.Classifier; c != nil {
+		model := c.Model
+		if model.BaseModel == "" {
+			model = ModelConfig{BaseModel: GPT5Nano, Provider: OpenAI}
+		}
+		r.classifier = NewKarmaAI(model.BaseModel, model.Provider, append([]Option{WithMaxTokens(20), WithTemperature(0)}, c.Options...)...)
+		r.classifier.Model.CustomModelString = model.CustomModelString
+	}
+	return r, nil
+}
+
+// Instance returns the KarmaAI behind a route, e.g. to add tools to it.
+func (r *Router) Instance(route string) (*KarmaAI, bool) {
+	kai, ok := r.routes[route]
+	return kai, ok
+}
+
+// Route decides where history would go without sending it. requires adds
+// capabilities to those inferred from the request.
+func (r *Router) Route(history models.AIChatHistory, requires ...Capability) *RoutingDecision {
+	d := &RoutingDecision{
+		Requires:     r.requirements(history, requires),
+		PromptTokens: tokenizer.CountMessageTokens(r.routes[r.opts.Default].Model.GetModelString(), history),
+	}
+	hasImages, hasTools := hasImages(history), r.hasTools(history)
+
+	for i, rule := range r.opts.Rules {
+		if rule.matches(d, hasImages, hasTools) && r.capable(rule.Route, d.Requires) {
+			return r.decide(d, rule.Route, fmt.Sprintf("rule:%d", i))
+		}
+	}
+	if r.classifier != nil {
+		if name, err := r.classify(history); err != nil {
+			log.Printf("karma: router classifier failed: %v", err)
+		} else if r.capable(name, d.Requires) {
+			return r.decide(d, name, "classifier")
+		}
+	}
+	if r.capable(r.opts.Default, d.Requires) {
+		return r.decide(d, r.opts.Default, "default")
+	}
+	for _, route := range r.opts.Routes {
+		if r.capable(route.Name, d.Requires) {
+			return r.decide(d, route.Name, "capabilities")
+		}
+	}
+	// Nothing declares the capabilities; trust the default.
+	return r.decide(d, r.opts.Default, "default")
+}
+
+func (r *Router) decide(d *RoutingDecision, route, reason string) *RoutingDecision {
+	d.Route, d.Reason = route, reason
+	d.Model = r.routes[route].Model
+	return d
+}
+
+func (rule RoutingRule) matches(d *RoutingDecision, images, tools bool) bool {
+	if rule.MinPromptTokens > 0 && d.PromptTokens < rule.MinPromptTokens {
+		return false
+	}
+	if rule.MaxPromptTokens > 0 && d.PromptTokens > rule.MaxPromptTokens {
+		return false
+	}
+	if (rule.HasImages && !images) || (rule.HasTools && !tools) {
+		return false
+	}
+	for _, c := range rule.Requires {
+		if !hasCapability(d.Requires, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Router) capable(route string, requires []Capability) bool {
+	for _, def := range r.opts.Routes {
+		if def.Name != route {
+			continue
+		}
+		for _, c := range requires {
+			if !hasCapability(def.Capabilities, c) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (r *Router) requirements(history models.AIChatHistory, extra []Capability) []Capability {
+	var out []Capability
+	if hasImages(history) {
+		out = append(out, CapabilityVision)
+	}
+	if r.hasTools(history) {
+		out = append(out, CapabilityTools)
+	}
+	for _, c := range extra {
+		if !hasCapability(out, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// hasTools reports tool calls in the history or tools enabled through the
+// shared options.
+func (r *Router) hasTools(history models.AIChatHistory) bool {
+	for _, m := range history.Messages {
+		if len(m.ToolCalls) > 0 || m.ToolCallId != "" {
+			return true
+		}
+	}
+	kai := r.routes[r.opts.Default]
+	return kai.ToolsEnabled && (len(kai.GoFunctionTools) > 0 || len(kai.MCPTools) > 0)
+}
+
+func hasImages(history models.AIChatHistory) bool {
+	for _, m := range history.Messages {
+		if len(m.Images) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func hasCapability(caps []Capability, c Capability) bool {
+	for _, have := range caps {
+		if have == c {
+			return true
+		}
+	}
+	return false
+}
+
+const classifierPrompt = `Pick the best route for the user's latest request.
+Routes:
+%s
+Reply with the route name only.`
+
+// classifierMaxChars bounds the request text sent to the classifier.
+const classifierMaxChars = 2000
+
+func (r *Router) classify(history models.AIChatHistory) (string, error) {
+	var routes strings.Builder
+	for _, route := range r.opts.Routes {
+		fmt.Fprintf(&routes, "- %s: %s\n", route.Name, route.Description)
+	}
+	var last string
+	for i := len(history.Messages) - 1; i >= 0; i-- {
+		if history.Messages[i].Role == models.User {
+			last = history.Messages[i].Message
+			break
+		}
+	}
+	last = truncateRunes(last, classifierMaxChars)
+	res, err := r.classifier.ChatCompletion(models.AIChatHistory{
+		SystemMsg: fmt.Sprintf(classifierPrompt, routes.String()),
+		Messages:  []models.AIMessage{{Role: models.User, Message: last}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return r.parseRoute(res.AIResponse)
+}
+
+// parseRoute finds the route named in a classifier reply, tolerating
+// quotes, punctuation and case.
+func (r *Router) parseRoute(reply string) (string, error) {
+	answer := strings.ToLower(strings.Trim(strings.TrimSpace(reply), "\"'`.*"))
+	for _, route := range r.opts.Routes {
+		if strings.ToLower(route.Name) == answer {
+			return route.Name, nil
+		}
+	}
+	for _, route := range r.opts.Routes {
+		if strings.Contains(answer, strings.ToLower(route.Name)) {
+			return route.Name, nil
+		}
+	}
+	return "", fmt.Errorf("classifier picked unknown route %q", reply)
+}
+
+// LastDecision returns the decision for the most recently routed request.
+// With concurrent requests that may not be the caller's; each request's
+// analytics carry its own route under RouterRoute.
+func (r *Router) LastDecision() *RoutingDecision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// dispatch routes history and returns a copy of the routed instance whose
+// analytics are tagged with the decision, so concurrent requests never see
+// each other's tags.
+func (r *Router) dispatch(history models.AIChatHistory) *KarmaAI {
+	d := r.Route(history)
+	r.mu.Lock()
+	r.last = d
+	kai := r.routes[d.Route]
+	if kai.ToolsEnabled && len(kai.MCPServers) > 0 {
+		// Build the MCP manager on the route, not on each copy.
+		kai.getOrBuildMultiMCP()
+	}
+	r.mu.Unlock()
+	return kai.withAnalyticProperties(map[AIProperty]any{
+		RouterRoute:  d.Route,
+		RouterReason: d.Reason,
+	})
+}
+
+func (r *Router) ChatCompletion(messages models.AIChatHistory) (*models.AIChatResponse, error) {
+	return r.dispatch(messages).ChatCompletion(messages)
+}
+
+func (r *Router) ChatCompletionStream(messages models.AIChatHistory, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+	return r.dispatch(messages).ChatCompletionStream(messages, callback)
+}
+
+func (r *Router) ChatCompletionManaged(history *models.AIChatHistory) (*models.AIChatResponse, error) {
+	if history == nil {
+		return nil, errors.New("history is nil")
+	}
+	return r.dispatch(*history).ChatCompletionManaged(history)
+}
+
+func (r *Router) ChatCompletionStreamManaged(history *models.AIChatHistory, callback func(chunk models.StreamedResponse) error) (*models.AIChatResponse, error) {
+	if history == nil {
+		return nil, errors.New("history is nil")
+	}
+	return r.dispatch(*history).ChatCompletionStreamManaged(history, callback)
+}
+
+func (r *Router) GenerateFromSinglePrompt(prompt string) (*models.AIChatResponse, error) {
+	return r.dispatch(models.AIChatHistory{
+		Messages: []models.AIMessage{{Role: models.User, Message: prompt}},
+	}).GenerateFromSinglePrompt(prompt)
+}
@@ -0,0 +1,161 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+func testRouter(t *testing.T, rules ...RoutingRule) *Router {
+	t.Helper()
+	r, err := NewRouter(RouterOptions{
+		Routes: []Route{
+			{Name: "simple", Model: ModelConfig{BaseModel: GPT5Nano, Provider: OpenAI}},
+			{Name: "coding", Model: ModelConfig{BaseModel: Claude4_5Sonnet, Provider: Anthropic}, Capabilities: []Capability{CapabilityVision, CapabilityTools}},
+			{Name: "long", Model: ModelConfig{BaseModel: Gemini25Flash, Provider: Google}, Capabilities: []Capability{CapabilityLongContext}},
+		},
+		Rules: rules,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func userSays(text string, images ...string) models.AIChatHistory {
+	return models.AIChatHistory{Messages: []models.AIMessage{{Role: models.User, Message: text, Images: images}}}
+}
+
+func TestRouterRules(t *testing.T) {
+	r := testRouter(t,
+		RoutingRule{Route: "long", MinPromptTokens: 500},
+		RoutingRule{Route: "coding", HasImages: true},
+	)
+	if d := r.Route(userSays("hi")); d.Route != "simple" || d.Reason != "default" {
+		t.Fatalf("short prompt = %+v", d)
+	}
+	if d := r.Route(userSays(strings.Repeat("lorem ipsum ", 400))); d.Route != "long" || d.Reason != "rule:0" {
+		t.Fatalf("long prompt = %+v", d)
+	}
+	d := r.Route(userSays("what is this?", "https://example.com/cat.png"))
+	if d.Route != "coding" || d.Reason != "rule:1" || d.Model.Provider != Anthropic {
+		t.Fatalf("image prompt = %+v", d)
+	}
+}
+
+func TestRouterCapabilities(t *testing.T) {
+	r := testRouter(t, RoutingRule{Route: "long", MinPromptTokens: 1})
+	// The rule matches but "long" has no vision, so the first capable route wins.
+	d := r.Route(userSays("describe", "https://example.com/cat.png"))
+	if d.Route != "coding" || d.Reason != "capabilities" {
+		t.Fatalf("decision = %+v", d)
+	}
+	if d := r.Route(userSays("hi"), CapabilityReasoning); d.Route != "simple" || d.Reason != "default" {
+		t.Fatalf("unsatisfiable requirement = %+v", d)
+	}
+}
+
+func TestRouterParseRoute(t *testing.T) {
+	r := testRouter(t)
+	for reply, want := range map[string]string{"coding": "coding", " \"Long\".": "long", "Route: simple": "simple"} {
+		if got, err := r.parseRoute(reply); err != nil || got != want {
+			t.Errorf("parseRoute(%q) = %q, %v", reply, got, err)
+		}
+	}
+	if _, err := r.parseRoute("none"); err == nil {
+		t.Error("unknown route should fail")
+	}
+}
+
+func TestNewRouterValidates(t *testing.T) {
+	if _, err := NewRouter(RouterOptions{}); err == nil {
+		t.Error("no routes should fail")
+	}
+	_, err := NewRouter(RouterOptions{
+		Routes: []Route{{Name: "a", Model: ModelConfig{BaseModel: GPT5Nano, Provider: OpenAI}}},
+		Rules:  []RoutingRule{{Route: "b"}},
+	})
+	if err == nil {
+		t.Error("rule with unknown route should fail")
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	r := testRouter(t)
+	if _, err := r.ChatCompletionManaged(nil); err == nil {
+		t.Fatal("a nil history should be an error")
+	}
+	if _, err := r.ChatCompletionStreamManaged(nil, nil); err == nil {
+		t.Fatal("a nil history should be an error")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			kai := r.dispatch(userSays("hi"))
+			if kai.Analytics.properties[string(RouterRoute)] != "simple" {
+				t.Errorf("properties = %v", kai.Analytics.properties)
+			}
+		}()
+	}
+	wg.Wait()
+	if route, _ := r.Instance("simple"); len(route.Analytics.properties) != 0 {
+		t.Fatalf("dispatch tagged the shared instance: %v", route.Analytics.properties)
+	}
+	if d := r.LastDecision(); d == nil || d.Route != "simple" {
+		t.Fatalf("last decision = %+v", d)
+	}
+}
+
+func TestRouterClassifyTruncatesByRune(t *testing.T) {
+	var sent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, m := range body.Messages {
+			if m.Role == "user" {
+				sent = m.Content
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"gpt-5-nano","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"coding"}}]}`)
+	}))
+	defer srv.Close()
+	t.Setenv("OPENAI_BASE_URL", srv.URL)
+	t.Setenv("OPENAI_KEY", "sk-test")
+
+	r, err := NewRouter(RouterOptions{
+		Routes: []Route{
+			{Name: "simple", Model: ModelConfig{BaseModel: GPT5Nano, Provider: OpenAI}},
+			{Name: "coding", Model: ModelConfig{BaseModel: GPT5Nano, Provider: OpenAI}},
+		},
+		Classifier: &ClassifierOptions{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, err := r.classify(userSays("a" + strings.Repeat("é", classifierMaxChars)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route != "coding" {
+		t.Fatalf("route = %q", route)
+	}
+	if !utf8.ValidString(sent) || strings.ContainsRune(sent, utf8.RuneError) || len(sent) > classifierMaxChars+3 {
+		t.Fatalf("classifier got %d bytes, valid = %v", len(sent), utf8.ValidString(sent))
+	}
+}
@@ -0,0 +1,330 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/utils"
+)
+
+// HistorySummary is a generated title and rolling summary of a conversation.
+type HistorySummary struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
+
+// AutoSummaryOptions configures WithAutoSummary.
+type AutoSummaryOptions struct {
+	// Model writes titles and summaries. The zero value uses GPT5Nano on
+	// OpenAI.
+	Model ModelConfig `json:"model"`
+	// Options are applied to the summarizer, e.g. WithCustomProvider.
+	// Credential resolver and tenant are inherited from the main instance.
+	Options []Option `json:"-"`
+	// DisableTitle skips generating a title after the first exchange.
+	DisableTitle bool `json:"disable_title"`
+	// SummaryEvery refreshes the summary every N user turns. Zero disables
+	// summaries.
+	SummaryEvery int `json:"summary_every"`
+	// OnUpdate is called from the background goroutine once a title or
+	// summary is ready, e.g. to persist it to a ChatStore.
+	OnUpdate func(history *models.AIChatHistory, s HistorySummary) `json:"-"`
+}
+
+// WithAutoSummary makes ChatCompletionManaged and ChatCompletionStreamManaged
+// fill in AIChatHistory.Title after the first exchange and refresh
+// AIChatHistory.Description with a rolling summary every SummaryEvery turns.
+//
+// Generation runs in the background with a separate, cheap model and never
+// delays or fails the main response. Results are passed to OnUpdate as soon as
+// they are ready and written to the history at the start of the next managed
+// call on it, so the history is never modified concurrently. Results are
+// matched to histories by ChatId; one is assigned to a history without it.
+func WithAutoSummary(opts AutoSummaryOptions) Option {
+	return func(kai *KarmaAI) {
+		kai.AutoSummary = &opts
+		kai.summaries = &summaryState{
+			pending: map[string]pendingSummary{},
+			running: map[string]bool{},
+		}
+	}
+}
+
+// summaryMaxPending bounds the results kept for chats that are not called
+// again; the oldest are dropped first.
+const summaryMaxPending = 1000
+
+// summaryState tracks background generation per chat.
+type summaryState struct {
+	mu      sync.Mutex
+	pending map[string]pendingSummary
+	running map[string]bool
+}
+
+type pendingSummary struct {
+	HistorySummary
+	at time.Time
+}
+
+// merge records result for key, dropping the oldest result when full. The
+// caller holds mu.
+func (s *summaryState) merge(key string, result HistorySummary) {
+	p, ok := s.pending[key]
+	if !ok && len(s.pending) >= summaryMaxPending {
+		oldest, at := "", time.Time{}
+		for k, v := range s.pending {
+			if oldest == "" || v.at.Before(at) {
+				oldest, at = k, v.at
+			}
+		}
+		delete(s.pending, oldest)
+	}
+	if result.Title != "" {
+		p.Title = result.Title
+	}
+	if result.Summary != "" {
+		p.Summary = result.Summary
+	}
+	p.at = time.Now()
+	s.pending[key] = p
+}
+
+// summaryKey identifies a history across calls by its ChatId, assigning one
+// when it has none so results find their way back to it.
+func summaryKey(h *models.AIChatHistory) string {
+	if h.ChatId == "" {
+		h.ChatId = utils.GenerateID(16)
+	}
+	return h.ChatId
+}
+
+// applyAutoSummary writes results finished since the last call to history.
+func (kai *KarmaAI) applyAutoSummary(history *models.AIChatHistory) {
+	if kai.summaries == nil || history.ChatId == "" {
+		return
+	}
+	s := kai.summaries
+	s.mu.Lock()
+	result, ok := s.pending[history.ChatId]
+	delete(s.pending, history.ChatId)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	if result.Title != "" && history.Title == "" {
+		history.Title = result.Title
+	}
+	if result.Summary != "" {
+		history.Description = result.Summary
+	}
+}
+
+// scheduleAutoSummary starts background generation when the exchange that
+// just finished calls for a title or a summary refresh.
+func (kai *KarmaAI) scheduleAutoSummary(history *models.AIChatHistory, response *models.AIChatResponse) {
+	if kai.AutoSummary == nil || kai.summaries == nil || response == nil {
+		return
+	}
+	opts := kai.AutoSummary
+
+	// Copy what the goroutine needs so the caller keeps sole ownership of
+	// history. Handlers that take the history by value do not append the
+	// reply, so add it here.
+	snapshot := *history
+	snapshot.Messages = append([]models.AIMessage(nil), history.Messages...)
+	if n := len(snapshot.Messages); n == 0 || snapshot.Messages[n-1].Role != models.Assistant {
+		snapshot.Messages = append(snapshot.Messages, models.AIMessage{Role: models.Assistant, Message: response.AIResponse})
+	}
+
+	turns := countTurns(snapshot.Messages)
+	wantTitle := !opts.DisableTitle && snapshot.Title == "" && turns >= 1
+	wantSummary := opts.SummaryEvery > 0 && turns > 0 && turns%opts.SummaryEvery == 0
+	if !wantTitle && !wantSummary {
+		return
+	}
+
+	s := kai.summaries
+	key := summaryKey(history)
+	snapshot.ChatId = key
+	s.mu.Lock()
+	if p, ok := s.pending[key]; ok && p.Title != "" {
+		wantTitle = false
+	}
+	if s.running[key] || (!wantTitle && !wantSummary) {
+		s.mu.Unlock()
+		return
+	}
+	s.running[key] = true
+	s.mu.Unlock()
+
+	summarizer := kai.summarizer()
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, key)
+			s.mu.Unlock()
+		}()
+		result, err := summarizer.summarize(&snapshot, wantTitle, wantSummary)
+		if err != nil {
+			log.Printf("karma: auto summary failed: %v", err)
+			return
+		}
+		s.mu.Lock()
+		s.merge(key, *result)
+		s.mu.Unlock()
+
+		if opts.OnUpdate != nil {
+			if result.Title != "" {
+				snapshot.Title = result.Title
+			}
+			if result.Summary != "" {
+				snapshot.Description = result.Summary
+			}
+			opts.OnUpdate(&snapshot, *result)
+		}
+	}()
+}
+
+func (kai *KarmaAI) summarizer() *KarmaAI {
+	var opts AutoSummaryOptions
+	if kai.AutoSummary != nil {
+		opts = *kai.AutoSummary
+	}
+	model := opts.Model
+	if model.BaseModel == "" {
+		model = ModelConfig{BaseModel: GPT5Nano, Provider: OpenAI}
+	}
+	options := append([]Option{
+		WithMaxTokens(400),
+		WithCredentialResolver(kai.CredentialResolver),
+		WithTenant(kai.TenantID),
+	}, opts.Options...)
+	s := NewKarmaAI(model.BaseModel, model.Provider, options...)
+	s.Model.CustomModelString = model.CustomModelString
+	return s
+}
+
+// SummarizeHistory generates a title and summary for history and stores them
+// in Title and Description. It is the standalone form of WithAutoSummary, for
+// back-filling existing chats. model and opts select the summarizer as in
+// AutoSummaryOptions.
+func SummarizeHistory(history *models.AIChatHistory, model ModelConfig, opts ...Option) (*HistorySummary, error) {
+	if history == nil {
+		return nil, errors.New("history is nil")
+	}
+	kai := &KarmaAI{AutoSummary: &AutoSummaryOptions{Model: model, Options: opts}}
+	result, err := kai.summarizer().summarize(history, true, true)
+	if err != nil {
+		return nil, err
+	}
+	history.Title = result.Title
+	history.Description = result.Summary
+	return result, nil
+}
+
+const summaryPrompt = `You maintain the title and summary of a chat between a user and an assistant.
+Reply with exactly these lines and nothing else:
+TITLE: <a title of at most 6 words, no quotes>
+SUMMARY: <a summary of at most 3 sentences covering the topics, decisions and open questions>`
+
+// Only the tail of long chats is sent; the previous summary covers the rest.
+const (
+	summaryMaxMessages = 20
+	summaryMaxChars    = 1500
+)
+
+func (kai *KarmaAI) summarize(history *models.AIChatHistory, wantTitle, wantSummary bool) (*HistorySummary, error) {
+	var b strings.Builder
+	if history.Description != "" {
+		fmt.Fprintf(&b, "Summary so far: %s\n\n", history.Description)
+	}
+	b.WriteString("Conversation:\n")
+	msgs := history.Messages
+	if len(msgs) > summaryMaxMessages {
+		msgs = msgs[len(msgs)-summaryMaxMessages:]
+	}
+	for _, m := range msgs {
+		if m.Role != models.User && m.Role != models.Assistant {
+			continue
+		}
+		text := strings.TrimSpace(m.Message)
+		if text == "" {
+			continue
+		}
+		text = truncateRunes(text, summaryMaxChars)
+		fmt.Fprintf(&b, "%s: %s\n", m.Role, text)
+	}
+
+	res, err := kai.ChatCompletion(models.AIChatHistory{
+		SystemMsg: summaryPrompt,
+		Messages: []models.AIMessage{{
+			Role:      models.User,
+			Message:   b.String(),
+			Timestamp: time.Now(),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := parseSummary(res.AIResponse)
+	if !wantTitle {
+		out.Title = ""
+	}
+	if !wantSummary {
+		out.Summary = ""
+	}
+	if out.Title == "" && out.Summary == "" {
+		return nil, fmt.Errorf("summarizer returned no title or summary: %q", res.AIResponse)
+	}
+	return &out, nil
+}
+
+func parseSummary(text string) HistorySummary {
+	var out HistorySummary
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.Trim(line, "*"))
+		if v, ok := cutPrefixFold(line, "TITLE:"); ok {
+			out.Title = strings.Trim(v, " \t\"'*")
+		} else if v, ok := cutPrefixFold(line, "SUMMARY:"); ok {
+			out.Summary = strings.Trim(v, " \t*")
+		}
+	}
+	return out
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return "", false
+}
+
+// truncateRunes cuts s to at most n bytes without splitting a rune, marking
+// the cut with an ellipsis.
+func truncateRunes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
+// countTurns counts user messages, i.e. completed exchanges once the reply is
+// in.
+func countTurns(msgs []models.AIMessage) int {
+	n := 0
+	for _, m := range msgs {
+		if m.Role == models.User {
+			n++
+		}
+	}
+	return n
+}
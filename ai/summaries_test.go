@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"fmt"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+func TestParseSummary(t *testing.T) {
+	got := parseSummary("**Title:** \"Planning a Kyoto trip\"\nsummary: The user wants a 5 day itinerary.\n")
+	if got.Title != "Planning a Kyoto trip" || got.Summary != "The user wants a 5 day itinerary." {
+		t.Fatalf("parseSummary = %+v", got)
+	}
+	if got := parseSummary("no structure here"); got != (HistorySummary{}) {
+		t.Fatalf("parseSummary(unstructured) = %+v", got)
+	}
+}
+
+func TestApplyAutoSummary(t *testing.T) {
+	kai := NewKarmaAI(GPT4o, OpenAI, WithAutoSummary(AutoSummaryOptions{SummaryEvery: 2}))
+	h := &models.AIChatHistory{ChatId: "c1", Title: "Kept", Description: "old"}
+	kai.summaries.merge("c1", HistorySummary{Title: "New", Summary: "fresh"})
+
+	kai.applyAutoSummary(h)
+	if h.Title != "Kept" || h.Description != "fresh" {
+		t.Fatalf("history = %+v", h)
+	}
+	if _, ok := kai.summaries.pending["c1"]; ok {
+		t.Fatal("applied results should be cleared")
+	}
+
+	plain := NewKarmaAI(GPT4o, OpenAI)
+	plain.applyAutoSummary(h)
+	plain.scheduleAutoSummary(h, &models.AIChatResponse{AIResponse: "hi"})
+}
+
+func TestScheduleAutoSummarySkipsOffTurns(t *testing.T) {
+	kai := NewKarmaAI(GPT4o, OpenAI, WithAutoSummary(AutoSummaryOptions{SummaryEvery: 3}))
+	h := &models.AIChatHistory{ChatId: "c1", Title: "Has title", Messages: []models.AIMessage{
+		{Role: models.User, Message: "one"},
+		{Role: models.Assistant, Message: "a"},
+		{Role: models.User, Message: "two"},
+	}}
+	kai.scheduleAutoSummary(h, &models.AIChatResponse{AIResponse: "b"})
+	if len(kai.summaries.running) != 0 {
+		t.Fatal("nothing is due after the second turn of a titled chat")
+	}
+	if len(h.Messages) != 3 {
+		t.Fatal("scheduling must not modify the history")
+	}
+}
+
+func TestCountTurns(t *testing.T) {
+	msgs := []models.AIMessage{{Role: models.User}, {Role: models.Assistant}, {Role: models.User}}
+	if n := countTurns(msgs); n != 2 {
+		t.Fatalf("countTurns = %d", n)
+	}
+}
+
+func TestSummaryStateIsBounded(t *testing.T) {
+	kai := NewKarmaAI(GPT4o, OpenAI, WithAutoSummary(AutoSummaryOptions{}))
+	s := kai.summaries
+	for i := 0; i <= summaryMaxPending; i++ {
+		s.merge(fmt.Sprintf("c%d", i), HistorySummary{Title: "t"})
+	}
+	if len(s.pending) != summaryMaxPending {
+		t.Fatalf("pending = %d, want %d", len(s.pending), summaryMaxPending)
+	}
+	if _, ok := s.pending["c0"]; ok {
+		t.Fatal("the oldest result should be dropped first")
+	}
+
+	h := &models.AIChatHistory{}
+	if key := summaryKey(h); key == "" || h.ChatId != key || summaryKey(h) != key {
+		t.Fatalf("summaryKey should assign a stable ChatId, got %q", key)
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	got := truncateRunes("héllo", 2)
+	if got != "h..." || !utf8.ValidString(got) {
+		t.Fatalf("truncateRunes = %q", got)
+	}
+	if got := truncateRunes("short", 10); got != "short" {
+		t.Fatalf("truncateRunes = %q", got)
+	}
+}
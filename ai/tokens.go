@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/tokenizer"
+)
+
+// ErrContextLengthExceeded is returned before a request is sent when the
+// estimated prompt plus MaxTokens does not fit in the configured ContextWindow.
+var ErrContextLengthExceeded = errors.New("karma ai context length exceeded")
+
+// ContextLengthError reports the estimated prompt size that failed the
+// pre-flight context check.
+type ContextLengthError struct {
+	Model         string
+	PromptTokens  int
+	MaxTokens     int
+	ContextWindow int
+}
+
+func (e *ContextLengthError) Error() string {
+	return fmt.Sprintf("%s: model=%s prompt_tokens=%d max_tokens=%d context_window=%d", ErrContextLengthExceeded, e.Model, e.PromptTokens, e.MaxTokens, e.ContextWindow)
+}
+
+func (e *ContextLengthError) Unwrap() error {
+	return ErrContextLengthExceeded
+}
+
+// WithContextWindow enables a pre-flight check that rejects requests whose
+// estimated prompt tokens plus MaxTokens exceed tokens.
+func WithContextWindow(tokens int) Option {
+	return func(kai *KarmaAI) {
+		kai.ContextWindow = tokens
+	}
+}
+
+// CountTokens estimates the prompt tokens of history as sent by this
+// instance, including the system message and context.
+func (kai *KarmaAI) CountTokens(history models.AIChatHistory) int {
+	if history.SystemMsg == "" {
+		history.SystemMsg = kai.SystemMessage
+	}
+	if history.Context == "" {
+		history.Context = kai.Context
+	}
+	return tokenizer.CountMessageTokens(kai.Model.GetModelString(), history)
+}
+
+// preflight runs the token-based checks that must happen before a request is
+// dispatched: the context window check and tokens-per-minute rate limiting.
+// Counting is skipped entirely when neither is configured.
+func (kai *KarmaAI) preflight(history models.AIChatHistory) error {
+	if kai.ContextWindow <= 0 && !kai.hasTokenRateLimit() {
+		return nil
+	}
+	promptTokens := kai.CountTokens(history)
+	if kai.ContextWindow > 0 && promptTokens+kai.MaxTokens > kai.ContextWindow {
+		return &ContextLengthError{
+			Model:         kai.Model.GetModelString(),
+			PromptTokens:  promptTokens,
+			MaxTokens:     kai.MaxTokens,
+			ContextWindow: kai.ContextWindow,
+		}
+	}
+	return kai.enforceTokenRateLimit(promptTokens + kai.MaxTokens)
+}
+
+func (kai *KarmaAI) hasTokenRateLimit() bool {
+	if kai.RateLimit != nil && kai.RateLimit.tokenLimiter != nil {
+		return true
+	}
+	global := getGlobalRateLimit(kai.Model.GetModelProvider())
+	return global != nil && global.tokenLimiter != nil
+}
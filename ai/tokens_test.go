@@ -0,0 +1,55 @@
+package ai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+func TestPreflightRejectsOversizedPrompt(t *testing.T) {
+	kai := NewKarmaAI(GPT4oMini, OpenAI, WithContextWindow(200), WithMaxTokens(100))
+	history := models.AIChatHistory{
+		Messages: []models.AIMessage{{Role: models.User, Message: strings.Repeat("lorem ipsum dolor ", 50)}},
+	}
+
+	err := kai.preflight(history)
+	if !errors.Is(err, ErrContextLengthExceeded) {
+		t.Fatalf("expected ErrContextLengthExceeded, got %v", err)
+	}
+	var cle *ContextLengthError
+	if !errors.As(err, &cle) || cle.PromptTokens == 0 {
+		t.Fatalf("expected ContextLengthError with prompt tokens, got %#v", err)
+	}
+
+	history.Messages[0].Message = "hello"
+	if err := kai.preflight(history); err != nil {
+		t.Fatalf("small prompt should pass: %v", err)
+	}
+}
+
+func TestTokenRateLimitErrorsWhenExceeded(t *testing.T) {
+	kai := NewKarmaAI(GPT4oMini, OpenAI, WithMaxTokens(100), WithTokenRateLimit(150, RateLimitBehaviorError))
+	history := models.AIChatHistory{
+		Messages: []models.AIMessage{{Role: models.User, Message: "hello"}},
+	}
+
+	if err := kai.preflight(history); err != nil {
+		t.Fatalf("first request should pass: %v", err)
+	}
+	err := kai.preflight(history)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestWithRateLimitKeepsTokenLimit(t *testing.T) {
+	kai := NewKarmaAI(GPT4oMini, OpenAI,
+		WithTokenRateLimit(1000, RateLimitBehaviorError),
+		WithRateLimit(10, RateLimitBehaviorError))
+
+	if kai.RateLimit.TokensPerMinute != 1000 || kai.RateLimit.tokenLimiter == nil {
+		t.Fatalf("token limit dropped: %+v", kai.RateLimit)
+	}
+}
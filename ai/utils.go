@@ -98,7 +98,10 @@ func (kai *KarmaAI) processMessagesForLlamaBedrockSystemPrompt(chat models.AICha
 }
 
 func (kai *KarmaAI) configureClaudeClientForMCP(cc *claude.ClaudeClient) {
+	kai.applyCredentialsToClaude(cc)
 	cc.RequestTimeout = kai.RequestTimeout
+	kai.applyCacheToClaude(cc)
+	kai.applyWebSearchToClaude(cc)
 	if len(kai.MCPServers) > 0 {
 		kai.configureMultiMCPForClaude(cc)
 	} else if len(kai.MCPTools) > 0 {
@@ -188,8 +191,14 @@ func (kai *KarmaAI) configureMultiMCPForClaude(cc *claude.ClaudeClient) {
 // createGeminiClient creates a Gemini client using SpecialConfig or environment variables
 // Each SpecialConfig field is optional and overrides its corresponding environment variable
 func (kai *KarmaAI) createGeminiClient() (*gemini.Gemini, error) {
-	// Check for API key first (uses Gemini API backend)
-	if apiKey, ok := kai.SpecialConfig[GoogleAPIKey].(string); ok && apiKey != "" {
+	// The tenant's credentials come first: its API key selects the Gemini API
+	// backend and its project Vertex AI, whatever the instance configures.
+	creds := kai.credentials()
+	apiKey := creds.APIKey
+	if apiKey == "" && creds.ProjectID == "" {
+		apiKey, _ = kai.SpecialConfig[GoogleAPIKey].(string)
+	}
+	if apiKey != "" {
 		return gemini.NewGeminiWithAPIKey(
 			kai.Model.GetModelString(),
 			kai.SystemMessage,
@@ -201,18 +210,12 @@ func (kai *KarmaAI) createGeminiClient() (*gemini.Gemini, error) {
 		)
 	}
 
-	// For Vertex AI, each field can be individually overridden
-	// Start with environment variables as defaults
-	projectID := config.GetEnvRaw("GOOGLE_PROJECT_ID")
-	location := config.GetEnvRaw("GOOGLE_LOCATION")
-
-	// Override with SpecialConfig if set
-	if configProjectID, ok := kai.SpecialConfig[GoogleProjectID].(string); ok && configProjectID != "" {
-		projectID = configProjectID
-	}
-	if configLocation, ok := kai.SpecialConfig[GoogleLocation].(string); ok && configLocation != "" {
-		location = configLocation
-	}
+	// For Vertex AI, each field can be individually overridden: the tenant's
+	// credentials, then SpecialConfig, then the environment.
+	configProjectID, _ := kai.SpecialConfig[GoogleProjectID].(string)
+	configLocation, _ := kai.SpecialConfig[GoogleLocation].(string)
+	projectID := firstNonEmpty(creds.ProjectID, configProjectID, config.GetEnvRaw("GOOGLE_PROJECT_ID"))
+	location := firstNonEmpty(creds.Location, configLocation, config.GetEnvRaw("GOOGLE_LOCATION"))
 
 	return gemini.NewGeminiWithVertexAI(
 		kai.Model.GetModelString(),
@@ -230,6 +233,8 @@ func (kai *KarmaAI) configureGeminiClient(g *gemini.Gemini) {
 	kai.configureGeminiClientForMCP(g)
 	g.RequestGate = kai.enforceRateLimit
 	g.RequestTimeout = kai.RequestTimeout
+	kai.applyCacheToGemini(g)
+	kai.applyWebSearchToGemini(g)
 	if kai.ResponseType != "" {
 		g.SetResponseType(kai.ResponseType)
 	}
@@ -394,8 +399,11 @@ func buildGeminiChatResponse(response *genai.GenerateContentResponse, startTime
 		res.Tokens = int(response.UsageMetadata.TotalTokenCount)
 		res.InputTokens = int(response.UsageMetadata.PromptTokenCount)
 		res.OutputTokens = int(response.UsageMetadata.CandidatesTokenCount)
+		res.CacheReadTokens = int(response.UsageMetadata.CachedContentTokenCount)
 	}
 
+	res.Citations = gemini.Citations(response)
+
 	// Add tool calls if present
 	functionCalls := response.FunctionCalls()
 	if len(functionCalls) > 0 {
@@ -0,0 +1,81 @@
+package variants
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/MelloB1989/karma/ai"
+)
+
+// VariantDiff compares the mapped variants of a provider with what it serves.
+type VariantDiff struct {
+	Provider ai.Provider
+	// Retired variants are mapped here but no longer listed by the provider.
+	Retired []ModelVariant
+	// Deprecated variants are still listed but flagged for retirement.
+	Deprecated []ModelVariant
+	// Unmapped are listed model IDs with no variant mapping yet.
+	Unmapped []string
+}
+
+// DiffVariants reports which variants mapped for provider are missing from
+// remote, the result of ai.ListModels. Bedrock inference profiles (e.g.
+// "us.meta.llama3-3-70b-instruct-v1:0") are matched by the foundation model
+// they route to, since only those are listed.
+func DiffVariants(provider ai.Provider, remote []ai.RemoteModel) VariantDiff {
+	diff := VariantDiff{Provider: provider}
+	served := make(map[string]ai.RemoteModel, len(remote))
+	for _, m := range remote {
+		served[m.ID] = m
+	}
+	mapped := ProviderModelMapping[provider]
+	mappedIDs := make(map[string]bool, len(mapped))
+	for variant := range mapped {
+		id := string(variant)
+		if _, ok := served[id]; !ok && provider == ai.Bedrock {
+			id = foundationModelID(id)
+		}
+		mappedIDs[id] = true
+		m, ok := served[id]
+		switch {
+		case !ok:
+			diff.Retired = append(diff.Retired, variant)
+		case m.Deprecated:
+			diff.Deprecated = append(diff.Deprecated, variant)
+		}
+	}
+	for id := range served {
+		if !mappedIDs[id] {
+			diff.Unmapped = append(diff.Unmapped, id)
+		}
+	}
+	sort.Slice(diff.Retired, func(i, j int) bool { return diff.Retired[i] < diff.Retired[j] })
+	sort.Slice(diff.Deprecated, func(i, j int) bool { return diff.Deprecated[i] < diff.Deprecated[j] })
+	sort.Strings(diff.Unmapped)
+	return diff
+}
+
+// inferenceProfilePrefixes are the geography prefixes of Bedrock
+// cross-region inference profile IDs.
+var inferenceProfilePrefixes = []string{"us.", "us-gov.", "eu.", "apac.", "ca.", "jp.", "au.", "global."}
+
+// foundationModelID strips an inference profile's geography prefix.
+func foundationModelID(id string) string {
+	for _, p := range inferenceProfilePrefixes {
+		if rest, ok := strings.CutPrefix(id, p); ok {
+			return rest
+		}
+	}
+	return id
+}
+
+// FindRetiredVariants lists provider's models and diffs them against the
+// mapping. Options are passed through to ai.ListModels.
+func FindRetiredVariants(ctx context.Context, provider ai.Provider, opts ...ai.Option) (VariantDiff, error) {
+	remote, err := ai.ListModels(ctx, provider, opts...)
+	if err != nil {
+		return VariantDiff{Provider: provider}, err
+	}
+	return DiffVariants(provider, remote), nil
+}
@@ -0,0 +1,45 @@
+package variants
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/MelloB1989/karma/ai"
+)
+
+func TestDiffVariants(t *testing.T) {
+	remote := []ai.RemoteModel{
+		{ID: string(Grok4)},
+		{ID: string(Grok3), Deprecated: true},
+		{ID: "grok-5"},
+	}
+	diff := DiffVariants(ai.XAI, remote)
+	if !slices.Contains(diff.Retired, Grok3Mini) || !slices.Contains(diff.Retired, Grok4_0709) || slices.Contains(diff.Retired, Grok4) {
+		t.Fatalf("retired = %v", diff.Retired)
+	}
+	if len(diff.Deprecated) != 1 || diff.Deprecated[0] != Grok3 {
+		t.Fatalf("deprecated = %v", diff.Deprecated)
+	}
+	if len(diff.Unmapped) != 1 || diff.Unmapped[0] != "grok-5" {
+		t.Fatalf("unmapped = %v", diff.Unmapped)
+	}
+}
+
+func TestDiffVariantsMatchesBedrockInferenceProfiles(t *testing.T) {
+	var remote []ai.RemoteModel
+	for variant := range ProviderModelMapping[ai.Bedrock] {
+		remote = append(remote, ai.RemoteModel{ID: foundationModelID(string(variant))})
+	}
+	diff := DiffVariants(ai.Bedrock, remote)
+	if len(diff.Retired) != 0 || len(diff.Unmapped) != 0 {
+		t.Fatalf("retired = %v, unmapped = %v", diff.Retired, diff.Unmapped)
+	}
+	if foundationModelID(string(Llama33_70B_US)) != "meta.llama3-3-70b-instruct-v1:0" {
+		t.Fatalf("foundationModelID(%s) = %s", Llama33_70B_US, foundationModelID(string(Llama33_70B_US)))
+	}
+
+	diff = DiffVariants(ai.Bedrock, nil)
+	if !slices.Contains(diff.Retired, BedrockClaude35SonnetAPAC) {
+		t.Fatalf("an unlisted profile should be retired: %v", diff.Retired)
+	}
+}
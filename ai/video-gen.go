@@ -0,0 +1,209 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/apis/aws/bedrock"
+	"github.com/MelloB1989/karma/config"
+)
+
+// Video Models
+
+type VideoModels string
+
+const (
+	NOVA_REEL     VideoModels = "amazon.nova-reel-v1:0"
+	NOVA_REEL_1_1 VideoModels = "amazon.nova-reel-v1:1"
+)
+
+// KarmaVideoGen runs asynchronous video generation. Jobs write their output to
+// S3; the package can start, poll and download them or do all three at once
+// with GenerateVideo.
+type KarmaVideoGen struct {
+	Model           VideoModels
+	UserPrePrompt   string
+	OutputS3URI     string // e.g. "s3://bucket/videos"; defaults to KARMA_VIDEO_S3_URI
+	OutputDirectory string // local directory for downloads
+	DurationSeconds int
+	FPS             int
+	Dimension       string // e.g. "1280x720"
+	Seed            *int64
+	PollInterval    time.Duration
+	// OnProgress is called with every status snapshot while waiting.
+	OnProgress    func(*bedrock.VideoJob)
+	BedrockAPIKey string
+	BedrockRegion string
+}
+
+type VideoGenOptions func(*KarmaVideoGen)
+
+func NewKarmaVideoGen(model VideoModels, opts ...VideoGenOptions) *KarmaVideoGen {
+	kv := &KarmaVideoGen{
+		Model:        model,
+		OutputS3URI:  config.GetEnvRaw("KARMA_VIDEO_S3_URI"),
+		PollInterval: 15 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(kv)
+	}
+	return kv
+}
+
+// WithVideoS3Output sets the S3 location jobs write to
+func WithVideoS3Output(uri string) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.OutputS3URI = uri
+	}
+}
+
+// WithVideoOutputDirectory sets where downloaded videos are saved
+func WithVideoOutputDirectory(dir string) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.OutputDirectory = dir
+	}
+}
+
+// WithVideoUserPrePrompt prefixes every prompt
+func WithVideoUserPrePrompt(prompt string) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.UserPrePrompt = prompt
+	}
+}
+
+// WithVideoDuration sets the clip length in seconds
+func WithVideoDuration(seconds int) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.DurationSeconds = seconds
+	}
+}
+
+// WithVideoResolution sets the output dimension, e.g. "1280x720"
+func WithVideoResolution(dimension string) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.Dimension = dimension
+	}
+}
+
+// WithVideoFPS sets the frame rate
+func WithVideoFPS(fps int) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.FPS = fps
+	}
+}
+
+// WithVideoSeed fixes the seed for reproducible results
+func WithVideoSeed(seed int64) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.Seed = &seed
+	}
+}
+
+// WithVideoPollInterval sets how often job status is checked
+func WithVideoPollInterval(interval time.Duration) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.PollInterval = interval
+	}
+}
+
+// WithVideoProgress registers a callback for job status updates
+func WithVideoProgress(fn func(*bedrock.VideoJob)) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.OnProgress = fn
+	}
+}
+
+// WithVideoBedrockAPIKey sets an Amazon Bedrock API key (bearer token)
+func WithVideoBedrockAPIKey(apiKey string) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.BedrockAPIKey = apiKey
+	}
+}
+
+// WithVideoBedrockRegion overrides the AWS region used for Bedrock requests
+func WithVideoBedrockRegion(region string) VideoGenOptions {
+	return func(k *KarmaVideoGen) {
+		k.BedrockRegion = region
+	}
+}
+
+func (kv *KarmaVideoGen) options() bedrock.ClientOptions {
+	return bedrock.ClientOptions{Region: kv.BedrockRegion, APIKey: kv.BedrockAPIKey}
+}
+
+func (kv *KarmaVideoGen) params(prompt string, image *ImageInput) (bedrock.VideoParams, error) {
+	p := bedrock.VideoParams{
+		Prompt:          strings.TrimSpace(kv.UserPrePrompt + " " + prompt),
+		DurationSeconds: kv.DurationSeconds,
+		FPS:             kv.FPS,
+		Dimension:       kv.Dimension,
+		Seed:            kv.Seed,
+	}
+	if image != nil {
+		data, mime, err := image.load()
+		if err != nil {
+			return p, err
+		}
+		p.Image = data
+		p.ImageType = "png"
+		if strings.Contains(mime, "jpeg") {
+			p.ImageType = "jpeg"
+		}
+	}
+	return p, nil
+}
+
+// StartVideo queues a text-to-video job and returns immediately.
+func (kv *KarmaVideoGen) StartVideo(ctx context.Context, prompt string) (*bedrock.VideoJob, error) {
+	return kv.start(ctx, prompt, nil)
+}
+
+// StartVideoFromImage queues a job whose first frame is conditioned on img.
+func (kv *KarmaVideoGen) StartVideoFromImage(ctx context.Context, prompt string, img ImageInput) (*bedrock.VideoJob, error) {
+	return kv.start(ctx, prompt, &img)
+}
+
+func (kv *KarmaVideoGen) start(ctx context.Context, prompt string, img *ImageInput) (*bedrock.VideoJob, error) {
+	if kv.OutputS3URI == "" {
+		return nil, errors.New("video generation needs an S3 output location (WithVideoS3Output or KARMA_VIDEO_S3_URI)")
+	}
+	p, err := kv.params(prompt, img)
+	if err != nil {
+		return nil, err
+	}
+	return bedrock.StartVideoJob(ctx, string(kv.Model), p, kv.OutputS3URI, kv.options())
+}
+
+// VideoStatus returns the current state of a job.
+func (kv *KarmaVideoGen) VideoStatus(ctx context.Context, arn string) (*bedrock.VideoJob, error) {
+	return bedrock.GetVideoJob(ctx, arn, kv.options())
+}
+
+// WaitVideo polls a job until it finishes, calling OnProgress on each update.
+func (kv *KarmaVideoGen) WaitVideo(ctx context.Context, arn string) (*bedrock.VideoJob, error) {
+	return bedrock.WaitVideoJob(ctx, arn, kv.PollInterval, kv.OnProgress, kv.options())
+}
+
+// DownloadVideo saves a completed job's video to OutputDirectory (./videos by
+// default) and returns the local path.
+func (kv *KarmaVideoGen) DownloadVideo(ctx context.Context, job *bedrock.VideoJob) (string, error) {
+	dir := kv.OutputDirectory
+	if dir == "" {
+		dir = "./videos"
+	}
+	return bedrock.DownloadVideo(ctx, job, dir, kv.options())
+}
+
+// GenerateVideo starts a job, waits for it and downloads the result.
+func (kv *KarmaVideoGen) GenerateVideo(ctx context.Context, prompt string) (string, error) {
+	job, err := kv.StartVideo(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	if job, err = kv.WaitVideo(ctx, job.ARN); err != nil {
+		return "", err
+	}
+	return kv.DownloadVideo(ctx, job)
+}
@@ -0,0 +1,393 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MelloB1989/karma/apis/claude"
+	"github.com/MelloB1989/karma/apis/gemini"
+	"github.com/MelloB1989/karma/config"
+	"github.com/MelloB1989/karma/internal/codex"
+	"github.com/MelloB1989/karma/internal/openai"
+	"github.com/MelloB1989/karma/models"
+)
+
+// WebSearchToolName is the name of the fallback search tool offered to models
+// on providers without native web search.
+const WebSearchToolName = "web_search"
+
+// WebSearchOptions configures web search. See WithWebSearch.
+type WebSearchOptions struct {
+	// MaxResults bounds results per search (Grok, fallback) or searches per
+	// request (Anthropic). Zero uses the provider default.
+	MaxResults int `json:"max_results,omitempty"`
+	// AllowedDomains and BlockedDomains restrict sources where the provider
+	// supports it. Anthropic accepts only one of the two.
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	BlockedDomains []string `json:"blocked_domains,omitempty"`
+	// Approximate user location, used to localise results. Country is a two
+	// letter ISO code.
+	Country  string `json:"country,omitempty"`
+	City     string `json:"city,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	// ContextSize is OpenAI's search_context_size: "low", "medium" or "high".
+	ContextSize string `json:"context_size,omitempty"`
+	// Backend serves the fallback tool. Nil uses Tavily with TAVILY_API_KEY.
+	Backend WebSearchBackend `json:"-"`
+	// ForceFallback uses Backend even where the provider searches natively.
+	ForceFallback bool `json:"force_fallback"`
+}
+
+// WebSearchBackend runs searches for the fallback web_search tool.
+type WebSearchBackend interface {
+	Search(ctx context.Context, query string, maxResults int) ([]models.Citation, error)
+}
+
+// WebSearchFunc adapts a function to WebSearchBackend.
+type WebSearchFunc func(ctx context.Context, query string, maxResults int) ([]models.Citation, error)
+
+func (f WebSearchFunc) Search(ctx context.Context, query string, maxResults int) ([]models.Citation, error) {
+	return f(ctx, query, maxResults)
+}
+
+// WithWebSearch lets the model search the web with whatever the provider
+// offers natively:
+//
+//   - OpenAI: the web_search tool of the Responses API, when WithResponsesAPI
+//     is set; Chat Completions gets the fallback tool below
+//   - Codex: the web_search tool
+//   - Anthropic: the web_search server tool
+//   - Google: grounding with Google Search
+//   - xAI: Live Search
+//
+// Other providers get a web_search GoFunctionTool backed by opts.Backend and
+// have tools enabled. Sources come back in AIChatResponse.Citations.
+func WithWebSearch(opts WebSearchOptions) Option {
+	return func(kai *KarmaAI) {
+		kai.WebSearch = &opts
+		kai.syncWebSearchTool()
+	}
+}
+
+// syncWebSearchTool registers the fallback tool when search is not native, and
+// drops it once it is, e.g. when WithResponsesAPI follows WithWebSearch.
+func (kai *KarmaAI) syncWebSearchTool() {
+	if kai.WebSearch == nil {
+		return
+	}
+	i := slices.IndexFunc(kai.GoFunctionTools, func(t GoFunctionTool) bool { return t.Name == WebSearchToolName })
+	switch native := kai.nativeWebSearch(); {
+	case native && i >= 0:
+		kai.GoFunctionTools = slices.Delete(slices.Clone(kai.GoFunctionTools), i, i+1)
+	case !native && i < 0:
+		kai.GoFunctionTools = append(kai.GoFunctionTools, webSearchTool(kai.WebSearch, nil))
+		kai.ToolsEnabled = true
+	}
+}
+
+// nativeWebSearch reports whether web search is configured and the provider
+// runs it itself.
+func (kai *KarmaAI) nativeWebSearch() bool {
+	if kai.WebSearch == nil || kai.WebSearch.ForceFallback {
+		return false
+	}
+	switch kai.Model.GetModelProvider() {
+	case OpenAI:
+		// Chat Completions has no search tool; only the Responses API does.
+		return kai.Responses != nil
+	case Codex, Google, XAI:
+		return true
+	case Anthropic:
+		// The server tool is not offered through Bedrock.
+		return !claude.UseBedrockTransport()
+	}
+	return false
+}
+
+func (o *WebSearchOptions) responsesTool() *ResponsesWebSearch {
+	ws := &ResponsesWebSearch{
+		SearchContextSize: o.ContextSize,
+		AllowedDomains:    o.AllowedDomains,
+	}
+	if loc := o.location(); len(loc) > 0 {
+		loc["type"] = "approximate"
+		ws.UserLocation = loc
+	}
+	return ws
+}
+
+func (o *WebSearchOptions) location() map[string]any {
+	loc := map[string]any{}
+	for k, v := range map[string]string{"country": o.Country, "city": o.City, "region": o.Region, "timezone": o.Timezone} {
+		if v != "" {
+			loc[k] = v
+		}
+	}
+	return loc
+}
+
+func (kai *KarmaAI) codexWebSearchTools() []codex.Tool {
+	if kai.Model.GetModelProvider() != Codex || !kai.nativeWebSearch() {
+		return nil
+	}
+	return []codex.Tool{{Type: "web_search"}}
+}
+
+func (kai *KarmaAI) applyWebSearchToClaude(cc *claude.ClaudeClient) {
+	if !kai.nativeWebSearch() {
+		return
+	}
+	o := kai.WebSearch
+	cc.WebSearch = &claude.WebSearch{
+		MaxUses:        o.MaxResults,
+		AllowedDomains: o.AllowedDomains,
+		City:           o.City,
+		Region:         o.Region,
+		Country:        o.Country,
+		Timezone:       o.Timezone,
+	}
+	if len(o.AllowedDomains) == 0 {
+		cc.WebSearch.BlockedDomains = o.BlockedDomains
+	}
+}
+
+func (kai *KarmaAI) applyWebSearchToGemini(g *gemini.Gemini) {
+	if !kai.nativeWebSearch() {
+		return
+	}
+	g.GoogleSearch = &gemini.GoogleSearch{ExcludeDomains: kai.WebSearch.BlockedDomains}
+}
+
+func (kai *KarmaAI) applyWebSearchToOpenAI(o *openai.OpenAI) {
+	if kai.Model.GetModelProvider() != XAI || !kai.nativeWebSearch() {
+		return
+	}
+	// Copy rather than write into Features, which outlives this request.
+	fields := make(map[string]any, len(o.ExtraFields)+1)
+	for k, v := range o.ExtraFields {
+		fields[k] = v
+	}
+	if _, ok := fields["search_parameters"]; !ok {
+		var sources []map[string]any
+		if kai.WebSearch.Country != "" || len(kai.WebSearch.AllowedDomains) > 0 || len(kai.WebSearch.BlockedDomains) > 0 {
+			web := map[string]any{"type": "web"}
+			if kai.WebSearch.Country != "" {
+				web["country"] = kai.WebSearch.Country
+			}
+			if len(kai.WebSearch.AllowedDomains) > 0 {
+				web["allowed_websites"] = kai.WebSearch.AllowedDomains
+			} else if len(kai.WebSearch.BlockedDomains) > 0 {
+				web["excluded_websites"] = kai.WebSearch.BlockedDomains
+			}
+			sources = append(sources, web)
+		}
+		fields["search_parameters"] = grokSearchParameters(true, kai.WebSearch.MaxResults, sources)
+	}
+	o.ExtraFields = fields
+}
+
+// grokSearchParameters builds xAI's search_parameters request field.
+func grokSearchParameters(returnCitations bool, maxResults int, sources []map[string]any) map[string]any {
+	params := map[string]any{
+		"mode":             "auto",
+		"return_citations": returnCitations,
+	}
+	if maxResults > 0 {
+		params["max_search_results"] = maxResults
+	}
+	if len(sources) > 0 {
+		params["sources"] = sources
+	}
+	return params
+}
+
+// grokCitations reads the citations xAI returns next to the choices.
+func grokCitations(raw string) []models.Citation {
+	if raw == "" {
+		return nil
+	}
+	var urls []string
+	if err := json.Unmarshal([]byte(raw), &urls); err != nil {
+		return nil
+	}
+	out := make([]models.Citation, 0, len(urls))
+	for _, u := range urls {
+		if u != "" {
+			out = append(out, models.Citation{URL: u})
+		}
+	}
+	return out
+}
+
+func codexCitations(cs []codex.Citation) []models.Citation {
+	if len(cs) == 0 {
+		return nil
+	}
+	out := make([]models.Citation, 0, len(cs))
+	for _, c := range cs {
+		out = append(out, models.Citation{URL: c.URL, Title: c.Title, StartIndex: c.StartIndex, EndIndex: c.EndIndex})
+	}
+	return out
+}
+
+// searchCitations collects what the fallback tool returned during one
+// request so it can be attached to that request's response.
+type searchCitations struct {
+	mu    sync.Mutex
+	found []models.Citation
+}
+
+func (s *searchCitations) add(cs []models.Citation) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.found = append(s.found, cs...)
+	s.mu.Unlock()
+}
+
+// attach puts the collected results on response unless the provider returned
+// citations of its own.
+func (s *searchCitations) attach(response *models.AIChatResponse) {
+	if s == nil || response == nil || len(response.Citations) > 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	response.Citations = slices.Clone(s.found)
+}
+
+// withSearchCollector returns a per-request copy of kai whose fallback search
+// tool records into a fresh collector, so concurrent requests on one instance
+// keep their citations apart. Without the fallback tool kai is returned as is.
+func (kai *KarmaAI) withSearchCollector() (*KarmaAI, *searchCitations) {
+	if kai.WebSearch == nil || kai.nativeWebSearch() {
+		return kai, nil
+	}
+	if len(kai.MCPServers) > 0 {
+		// Build the MCP manager on kai so the copies share it.
+		kai.getOrBuildMultiMCP()
+	}
+	found := &searchCitations{}
+	c := *kai
+	c.GoFunctionTools = slices.Clone(kai.GoFunctionTools)
+	for i, t := range c.GoFunctionTools {
+		if t.Name == WebSearchToolName {
+			c.GoFunctionTools[i] = webSearchTool(kai.WebSearch, found)
+		}
+	}
+	return &c, found
+}
+
+// webSearchTool is the fallback tool offered on providers without native
+// search. Results are recorded in found when it is non-nil.
+func webSearchTool(opts *WebSearchOptions, found *searchCitations) GoFunctionTool {
+	return NewGoFunctionTool(
+		WebSearchToolName,
+		"Search the web for current information. Returns a JSON list of results with title, url and snippet.",
+		NewFuncParams().
+			SetString("query", "The search query").
+			SetRequired("query"),
+		func(ctx context.Context, args FuncParams) (string, error) {
+			query := args.GetStringDefault("query", "")
+			if query == "" {
+				return "", errors.New("query is required")
+			}
+			backend := opts.Backend
+			if backend == nil {
+				backend = &TavilySearch{
+					IncludeDomains: opts.AllowedDomains,
+					ExcludeDomains: opts.BlockedDomains,
+				}
+			}
+			maxResults := opts.MaxResults
+			if maxResults <= 0 {
+				maxResults = 5
+			}
+			results, err := backend.Search(ctx, query, maxResults)
+			if err != nil {
+				return "", err
+			}
+			found.add(results)
+			out, err := json.Marshal(results)
+			if err != nil {
+				return "", err
+			}
+			return string(out), nil
+		},
+	)
+}
+
+const tavilySearchURL = "https://api.tavily.com/search"
+
+// TavilySearch is a WebSearchBackend for the Tavily search API. An empty
+// APIKey reads TAVILY_API_KEY.
+type TavilySearch struct {
+	APIKey         string
+	IncludeDomains []string
+	ExcludeDomains []string
+	HTTPClient     *http.Client
+}
+
+func (t *TavilySearch) Search(ctx context.Context, query string, maxResults int) ([]models.Citation, error) {
+	key := t.APIKey
+	if key == "" {
+		key = config.GetEnvRaw("TAVILY_API_KEY")
+	}
+	if key == "" {
+		return nil, errors.New("web search: no backend configured (set WebSearchOptions.Backend or TAVILY_API_KEY)")
+	}
+	body, err := json.Marshal(map[string]any{
+		"query":           query,
+		"max_results":     maxResults,
+		"include_domains": t.IncludeDomains,
+		"exclude_domains": t.ExcludeDomains,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tavilySearchURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := t.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("web search: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("web search: tavily returned %d: %s", resp.StatusCode, msg)
+	}
+	var parsed struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("web search: decode tavily response: %w", err)
+	}
+	out := make([]models.Citation, 0, len(parsed.Results))
+	for _, r := range parsed.Results {
+		out = append(out, models.Citation{URL: r.URL, Title: r.Title, Snippet: r.Content})
+	}
+	return out, nil
+}
@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/MelloB1989/karma/apis/claude"
+	"github.com/MelloB1989/karma/internal/openai"
+	"github.com/MelloB1989/karma/models"
+)
+
+func TestWebSearchFallsBackToToolWithoutNativeSearch(t *testing.T) {
+	backend := WebSearchFunc(func(ctx context.Context, query string, max int) ([]models.Citation, error) {
+		return []models.Citation{{URL: "https://example.com/" + query, Title: "Example"}}, nil
+	})
+	kai := NewKarmaAI(Llama33_70B, Groq, WithWebSearch(WebSearchOptions{Backend: backend}))
+	if !kai.ToolsEnabled || len(kai.GoFunctionTools) != 1 || kai.GoFunctionTools[0].Name != WebSearchToolName {
+		t.Fatalf("fallback tool not registered: enabled=%v tools=%d", kai.ToolsEnabled, len(kai.GoFunctionTools))
+	}
+
+	// Each request collects its own results onto its response.
+	var wg sync.WaitGroup
+	for _, q := range []string{"go", "rust"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, found := kai.withSearchCollector()
+			if req == kai || len(kai.GoFunctionTools) != 1 {
+				t.Error("requests should run on a copy")
+			}
+			if _, err := req.GoFunctionTools[0].Handler(context.Background(), FuncParams{"query": q}); err != nil {
+				t.Errorf("handler: %v", err)
+			}
+			res := &models.AIChatResponse{}
+			found.attach(res)
+			if len(res.Citations) != 1 || res.Citations[0].URL != "https://example.com/"+q {
+				t.Errorf("citations for %s = %+v", q, res.Citations)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The instance's own tool still works outside a request.
+	if out, err := kai.GoFunctionTools[0].Handler(context.Background(), FuncParams{"query": "go"}); err != nil || out == "" {
+		t.Fatalf("handler = %q, %v", out, err)
+	}
+}
+
+func TestWebSearchUsesNativeSearch(t *testing.T) {
+	chat := NewKarmaAI(GPT4oMini, OpenAI, WithWebSearch(WebSearchOptions{Country: "IN"}))
+	if chat.useResponsesAPI() || len(chat.GoFunctionTools) != 1 {
+		t.Fatal("OpenAI web search should stay on Chat Completions with the fallback tool unless the Responses API is chosen")
+	}
+
+	kai := NewKarmaAI(GPT4oMini, OpenAI, WithWebSearch(WebSearchOptions{Country: "IN"}), WithResponsesAPI(ResponsesOptions{}))
+	if len(kai.GoFunctionTools) != 0 {
+		t.Fatal("native providers should not get the fallback tool")
+	}
+	if !kai.useResponsesAPI() {
+		t.Fatal("WithResponsesAPI should select the Responses API")
+	}
+	req := kai.responsesRequest("", nil, nil, "")
+	if len(req.Tools) != 1 || req.Tools[0].Type != "web_search" || req.Tools[0].UserLocation["country"] != "IN" {
+		t.Fatalf("tools = %+v", req.Tools)
+	}
+
+	cc := claude.NewClaudeClient(1024, "claude-sonnet-4-5", 1, 1, 0, "")
+	NewKarmaAI(Claude4Sonnet, Anthropic, WithWebSearch(WebSearchOptions{MaxResults: 3})).applyWebSearchToClaude(cc)
+	if cc.WebSearch == nil || cc.WebSearch.MaxUses != 3 {
+		t.Fatalf("claude web search = %+v", cc.WebSearch)
+	}
+}
+
+func TestGrokLiveSearchMapping(t *testing.T) {
+	kai := NewKarmaAI(Grok4, XAI, WithWebSearch(WebSearchOptions{MaxResults: 7}))
+	o := openai.NewOpenAI("grok-4", "", 1, 100)
+	o.ExtraFields = kai.Features.optionalFields
+	kai.applyWebSearchToOpenAI(o)
+
+	params, ok := o.ExtraFields["search_parameters"].(map[string]any)
+	if !ok || params["max_search_results"] != 7 || params["return_citations"] != true {
+		t.Fatalf("search_parameters = %+v", o.ExtraFields["search_parameters"])
+	}
+	if len(kai.Features.optionalFields) != 0 {
+		t.Fatal("request fields must not leak into Features")
+	}
+
+	cs := grokCitations(`["https://x.ai/a","https://x.ai/b"]`)
+	if len(cs) != 2 || cs[1].URL != "https://x.ai/b" {
+		t.Fatalf("grokCitations = %+v", cs)
+	}
+}
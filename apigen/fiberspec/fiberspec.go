@@ -73,6 +73,12 @@ func (r *Registry) Mount(router fiber.Router, s Spec, handlers ...fiber.Handler)
 	router.Add(s.Method, s.Path, chain...)
 }
 
+// HasAuth reports whether the Registry was created with an auth handler, i.e.
+// whether Auth=true specs are actually protected when mounted.
+func (r *Registry) HasAuth() bool {
+	return r.auth != nil
+}
+
 // Specs returns every spec mounted so far.
 func (r *Registry) Specs() []Spec {
 	return r.specs
@@ -0,0 +1,204 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// ErrContentFiltered is returned when Bedrock's content filters block an
+// image request or all of its results.
+var ErrContentFiltered = errors.New("bedrock content filters blocked the request")
+
+// ImageParams configures GenerateImages. Zero values use the model defaults.
+type ImageParams struct {
+	Prompt         string
+	NegativePrompt string
+	Width          int
+	Height         int
+	// Seed makes results reproducible when set.
+	Seed           *int64
+	NumberOfImages int
+	// CfgScale is how closely the image follows the prompt.
+	CfgScale float64
+	// Quality is "standard" or "premium" (Titan and Nova Canvas).
+	Quality string
+	// AspectRatio, e.g. "16:9", is used by Stability models that do not take
+	// a width and height.
+	AspectRatio string
+}
+
+// GeneratedImage is one result of GenerateImages.
+type GeneratedImage struct {
+	Data []byte
+	// Seed is reported by Stability models.
+	Seed int64
+	// FinishReason is reported by Stability models, e.g. "SUCCESS" or
+	// "CONTENT_FILTERED".
+	FinishReason string
+}
+
+// GenerateImages runs a text-to-image model through InvokeModel. Amazon Titan
+// Image Generator, Nova Canvas and the Stability models are supported.
+func GenerateImages(ctx context.Context, modelID string, p ImageParams, opts ClientOptions) ([]GeneratedImage, error) {
+	body, err := imageRequestBody(modelID, p)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewRuntimeClient(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(modelID),
+		ContentType: aws.String("application/json"),
+		Accept:      aws.String("application/json"),
+		Body:        body,
+	})
+	if err != nil {
+		return nil, imageInvokeError(err)
+	}
+	return parseImageResponse(modelID, out.Body)
+}
+
+// imageInvokeError reports the ValidationException Bedrock raises for a
+// prompt its content filters block as ErrContentFiltered. Other validation
+// errors, such as an unsupported size, are returned as they are.
+func imageInvokeError(err error) error {
+	var ve *types.ValidationException
+	if errors.As(err, &ve) && strings.Contains(ve.ErrorMessage(), "content filters") {
+		return fmt.Errorf("%w: %v", ErrContentFiltered, err)
+	}
+	return fmt.Errorf("bedrock invoke model (image) failed: %w", err)
+}
+
+func imageRequestBody(modelID string, p ImageParams) ([]byte, error) {
+	if p.Prompt == "" {
+		return nil, errors.New("image prompt is empty")
+	}
+	switch {
+	case strings.Contains(modelID, "titan-image") || strings.Contains(modelID, "nova-canvas"):
+		text := map[string]any{"text": p.Prompt}
+		if p.NegativePrompt != "" {
+			text["negativeText"] = p.NegativePrompt
+		}
+		cfg := map[string]any{"numberOfImages": max(p.NumberOfImages, 1)}
+		if p.Width > 0 && p.Height > 0 {
+			cfg["width"], cfg["height"] = p.Width, p.Height
+		}
+		if p.Seed != nil {
+			cfg["seed"] = *p.Seed
+		}
+		if p.CfgScale > 0 {
+			cfg["cfgScale"] = p.CfgScale
+		}
+		if p.Quality != "" {
+			cfg["quality"] = p.Quality
+		}
+		return json.Marshal(map[string]any{
+			"taskType":              "TEXT_IMAGE",
+			"textToImageParams":     text,
+			"imageGenerationConfig": cfg,
+		})
+	case strings.Contains(modelID, "stable-diffusion-xl"):
+		prompts := []map[string]any{{"text": p.Prompt, "weight": 1}}
+		if p.NegativePrompt != "" {
+			prompts = append(prompts, map[string]any{"text": p.NegativePrompt, "weight": -1})
+		}
+		req := map[string]any{"text_prompts": prompts, "samples": max(p.NumberOfImages, 1)}
+		if p.Width > 0 && p.Height > 0 {
+			req["width"], req["height"] = p.Width, p.Height
+		}
+		if p.Seed != nil {
+			req["seed"] = *p.Seed
+		}
+		if p.CfgScale > 0 {
+			req["cfg_scale"] = p.CfgScale
+		}
+		return json.Marshal(req)
+	case strings.HasPrefix(modelID, "stability."):
+		req := map[string]any{"prompt": p.Prompt, "mode": "text-to-image", "output_format": "png"}
+		if p.NegativePrompt != "" {
+			req["negative_prompt"] = p.NegativePrompt
+		}
+		if p.Seed != nil {
+			req["seed"] = *p.Seed
+		}
+		if p.AspectRatio != "" {
+			req["aspect_ratio"] = p.AspectRatio
+		}
+		return json.Marshal(req)
+	}
+	return nil, fmt.Errorf("unsupported image model: %s", modelID)
+}
+
+func parseImageResponse(modelID string, body []byte) ([]GeneratedImage, error) {
+	var resp struct {
+		// Titan, Nova Canvas and current Stability models
+		Images        []string  `json:"images"`
+		Seeds         []int64   `json:"seeds"`
+		FinishReasons []*string `json:"finish_reasons"`
+		Error         string    `json:"error"`
+		// Stable Diffusion XL
+		Artifacts []struct {
+			Base64       string `json:"base64"`
+			Seed         int64  `json:"seed"`
+			FinishReason string `json:"finishReason"`
+		} `json:"artifacts"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s response: %w", modelID, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s: %s", modelID, resp.Error)
+	}
+
+	var out []GeneratedImage
+	add := func(b64 string, seed int64, reason string) error {
+		if reason == "CONTENT_FILTERED" || reason == "Filter reason: prompt" || reason == "Filter reason: output image" {
+			out = append(out, GeneratedImage{Seed: seed, FinishReason: reason})
+			return nil
+		}
+		data, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil {
+			return fmt.Errorf("failed to decode image: %w", err)
+		}
+		out = append(out, GeneratedImage{Data: data, Seed: seed, FinishReason: reason})
+		return nil
+	}
+	for i, img := range resp.Images {
+		var seed int64
+		var reason string
+		if i < len(resp.Seeds) {
+			seed = resp.Seeds[i]
+		}
+		if i < len(resp.FinishReasons) && resp.FinishReasons[i] != nil {
+			reason = *resp.FinishReasons[i]
+		}
+		if err := add(img, seed, reason); err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range resp.Artifacts {
+		if err := add(a.Base64, a.Seed, a.FinishReason); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, img := range out {
+		if len(img.Data) > 0 {
+			return out, nil
+		}
+	}
+	if len(out) > 0 {
+		return out, ErrContentFiltered
+	}
+	return nil, fmt.Errorf("%s returned no images", modelID)
+}
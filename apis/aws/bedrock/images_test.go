@@ -0,0 +1,106 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+func TestImageRequestBody(t *testing.T) {
+	seed := int64(42)
+	p := ImageParams{Prompt: "a fox", NegativePrompt: "blurry", Width: 512, Height: 768, Seed: &seed, NumberOfImages: 2}
+
+	var titan struct {
+		TaskType string `json:"taskType"`
+		Text     struct {
+			Text         string `json:"text"`
+			NegativeText string `json:"negativeText"`
+		} `json:"textToImageParams"`
+		Config map[string]float64 `json:"imageGenerationConfig"`
+	}
+	body, err := imageRequestBody("amazon.nova-canvas-v1:0", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, &titan); err != nil {
+		t.Fatal(err)
+	}
+	if titan.TaskType != "TEXT_IMAGE" || titan.Text.NegativeText != "blurry" || titan.Config["seed"] != 42 || titan.Config["width"] != 512 || titan.Config["numberOfImages"] != 2 {
+		t.Fatalf("nova canvas body = %s", body)
+	}
+
+	var sdxl struct {
+		Prompts []struct {
+			Text   string  `json:"text"`
+			Weight float64 `json:"weight"`
+		} `json:"text_prompts"`
+		Seed int64 `json:"seed"`
+	}
+	if body, err = imageRequestBody("stability.stable-diffusion-xl-v1", p); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, &sdxl); err != nil {
+		t.Fatal(err)
+	}
+	if len(sdxl.Prompts) != 2 || sdxl.Prompts[1].Weight != -1 || sdxl.Seed != 42 {
+		t.Fatalf("sdxl body = %s", body)
+	}
+
+	if _, err := imageRequestBody("acme.painter-v1", p); err == nil {
+		t.Error("unknown models should be rejected")
+	}
+}
+
+func TestParseImageResponse(t *testing.T) {
+	images, err := parseImageResponse("amazon.titan-image-generator-v2:0", []byte(`{"images":["aGVsbG8="]}`))
+	if err != nil || len(images) != 1 || string(images[0].Data) != "hello" {
+		t.Fatalf("titan = %+v, %v", images, err)
+	}
+
+	images, err = parseImageResponse("stability.stable-diffusion-xl-v1", []byte(`{"artifacts":[{"base64":"","seed":7,"finishReason":"CONTENT_FILTERED"}]}`))
+	if !errors.Is(err, ErrContentFiltered) || len(images) != 1 || images[0].Seed != 7 {
+		t.Fatalf("filtered sdxl = %+v, %v", images, err)
+	}
+
+	if _, err := parseImageResponse("amazon.nova-canvas-v1:0", []byte(`{"error":"bad size"}`)); err == nil {
+		t.Error("model errors should surface")
+	}
+}
+
+func TestImageInvokeError(t *testing.T) {
+	blocked := &types.ValidationException{Message: aws.String("This request has been blocked by our content filters.")}
+	if err := imageInvokeError(fmt.Errorf("operation error: %w", blocked)); !errors.Is(err, ErrContentFiltered) {
+		t.Fatalf("blocked prompt = %v", err)
+	}
+	badSize := &types.ValidationException{Message: aws.String("width must be a multiple of 64")}
+	if err := imageInvokeError(badSize); errors.Is(err, ErrContentFiltered) {
+		t.Fatalf("validation error = %v", err)
+	}
+	if err := imageInvokeError(errors.New("throttled: content filters busy")); errors.Is(err, ErrContentFiltered) {
+		t.Fatalf("untyped error = %v", err)
+	}
+}
+
+func TestVideoModelInput(t *testing.T) {
+	seed := int64(9)
+	input, err := videoModelInput(VideoParams{Prompt: "waves", Seed: &seed, Image: []byte{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := input["videoGenerationConfig"].(map[string]any)
+	if cfg["durationSeconds"] != 6 || cfg["dimension"] != "1280x720" || cfg["seed"] != seed {
+		t.Fatalf("config = %v", cfg)
+	}
+	if _, ok := input["textToVideoParams"].(map[string]any)["images"]; !ok {
+		t.Fatal("conditioning image missing")
+	}
+
+	bucket, key, err := splitS3URI("s3://media/videos/abc123/")
+	if err != nil || bucket != "media" || key != "videos/abc123" {
+		t.Fatalf("split = %q %q %v", bucket, key, err)
+	}
+}
@@ -2,11 +2,16 @@ package bedrock
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
 
 	c "github.com/MelloB1989/karma/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	b "github.com/aws/aws-sdk-go-v2/service/bedrock"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock/types"
 )
 
 type Models struct {
@@ -46,3 +51,46 @@ func GetModels() []string {
 	// log.Println(model_names)
 	return model_names
 }
+
+// FoundationModel is one model from ListFoundationModels.
+type FoundationModel struct {
+	ID       string
+	Name     string
+	Provider string
+	// Legacy marks models Bedrock has scheduled for retirement.
+	Legacy bool
+}
+
+// ListFoundationModels lists the foundation models available in the resolved
+// region. The control plane signs with AWS credentials; Bedrock API keys only
+// cover the runtime.
+func ListFoundationModels(ctx context.Context, opts ClientOptions) ([]FoundationModel, error) {
+	loadOpts := []func(*config.LoadOptions) error{config.WithRegion(ResolveRegion(opts.Region))}
+	cfg := c.DefaultConfig()
+	if cfg.AwsAccessKey != "" && cfg.AwsSecretKey != "" {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AwsAccessKey, cfg.AwsSecretKey, os.Getenv("AWS_SESSION_TOKEN")),
+		))
+	}
+	sdkConfig, err := config.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	out, err := b.NewFromConfig(sdkConfig).ListFoundationModels(ctx, &b.ListFoundationModelsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("bedrock list foundation models failed: %w", err)
+	}
+	models := make([]FoundationModel, 0, len(out.ModelSummaries))
+	for _, m := range out.ModelSummaries {
+		fm := FoundationModel{
+			ID:       aws.ToString(m.ModelId),
+			Name:     aws.ToString(m.ModelName),
+			Provider: aws.ToString(m.ProviderName),
+		}
+		if m.ModelLifecycle != nil && m.ModelLifecycle.Status == types.FoundationModelLifecycleStatusLegacy {
+			fm.Legacy = true
+		}
+		models = append(models, fm)
+	}
+	return models, nil
+}
@@ -0,0 +1,249 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	karmas3 "github.com/MelloB1989/karma/apis/aws/s3"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Nova Reel renders video asynchronously: StartAsyncInvoke queues the job,
+// GetAsyncInvoke reports its status and the finished MP4 lands in the S3
+// location given at start time.
+
+// VideoJobStatus mirrors the Bedrock async invoke states.
+type VideoJobStatus string
+
+const (
+	VideoJobInProgress VideoJobStatus = VideoJobStatus(types.AsyncInvokeStatusInProgress)
+	VideoJobCompleted  VideoJobStatus = VideoJobStatus(types.AsyncInvokeStatusCompleted)
+	VideoJobFailed     VideoJobStatus = VideoJobStatus(types.AsyncInvokeStatusFailed)
+)
+
+// VideoParams configures StartVideoJob. Zero values use the model defaults.
+type VideoParams struct {
+	Prompt string
+	// DurationSeconds defaults to 6, the only length Nova Reel renders in a
+	// single shot.
+	DurationSeconds int
+	// FPS defaults to 24.
+	FPS int
+	// Dimension defaults to "1280x720".
+	Dimension string
+	Seed      *int64
+	// Image optionally conditions the first frame. It must match Dimension.
+	Image     []byte
+	ImageType string // "png" or "jpeg"
+}
+
+// VideoJob is a snapshot of an async video generation job.
+type VideoJob struct {
+	ARN            string
+	Status         VideoJobStatus
+	OutputURI      string
+	FailureMessage string
+	SubmitTime     time.Time
+	EndTime        time.Time
+}
+
+// Done reports whether the job has stopped, successfully or not.
+func (j *VideoJob) Done() bool {
+	return j.Status == VideoJobCompleted || j.Status == VideoJobFailed
+}
+
+// Elapsed is the time since submission, or the total run time once done.
+func (j *VideoJob) Elapsed() time.Duration {
+	if j.SubmitTime.IsZero() {
+		return 0
+	}
+	if !j.EndTime.IsZero() {
+		return j.EndTime.Sub(j.SubmitTime)
+	}
+	return time.Since(j.SubmitTime)
+}
+
+func videoModelInput(p VideoParams) (map[string]any, error) {
+	if p.Prompt == "" {
+		return nil, errors.New("video prompt is empty")
+	}
+	text := map[string]any{"text": p.Prompt}
+	if len(p.Image) > 0 {
+		format := p.ImageType
+		if format == "" {
+			format = "png"
+		}
+		text["images"] = []map[string]any{{
+			"format": format,
+			"source": map[string]any{"bytes": base64.StdEncoding.EncodeToString(p.Image)},
+		}}
+	}
+	cfg := map[string]any{
+		"durationSeconds": 6,
+		"fps":             24,
+		"dimension":       "1280x720",
+	}
+	if p.DurationSeconds > 0 {
+		cfg["durationSeconds"] = p.DurationSeconds
+	}
+	if p.FPS > 0 {
+		cfg["fps"] = p.FPS
+	}
+	if p.Dimension != "" {
+		cfg["dimension"] = p.Dimension
+	}
+	if p.Seed != nil {
+		cfg["seed"] = *p.Seed
+	}
+	return map[string]any{
+		"taskType":              "TEXT_VIDEO",
+		"textToVideoParams":     text,
+		"videoGenerationConfig": cfg,
+	}, nil
+}
+
+// StartVideoJob queues a Nova Reel generation. outputS3URI is the bucket (and
+// optional prefix) the video is written to, e.g. "s3://my-bucket/videos".
+func StartVideoJob(ctx context.Context, modelID string, p VideoParams, outputS3URI string, opts ClientOptions) (*VideoJob, error) {
+	if !strings.HasPrefix(outputS3URI, "s3://") {
+		return nil, fmt.Errorf("video output location must be an s3:// URI, got %q", outputS3URI)
+	}
+	input, err := videoModelInput(p)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewRuntimeClient(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.StartAsyncInvoke(ctx, &bedrockruntime.StartAsyncInvokeInput{
+		ModelId:    aws.String(modelID),
+		ModelInput: document.NewLazyDocument(input),
+		OutputDataConfig: &types.AsyncInvokeOutputDataConfigMemberS3OutputDataConfig{
+			Value: types.AsyncInvokeS3OutputDataConfig{S3Uri: aws.String(outputS3URI)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bedrock start async invoke failed: %w", err)
+	}
+	return &VideoJob{
+		ARN:        aws.ToString(out.InvocationArn),
+		Status:     VideoJobInProgress,
+		SubmitTime: time.Now(),
+	}, nil
+}
+
+// GetVideoJob fetches the current state of a job.
+func GetVideoJob(ctx context.Context, arn string, opts ClientOptions) (*VideoJob, error) {
+	client, err := NewRuntimeClient(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.GetAsyncInvoke(ctx, &bedrockruntime.GetAsyncInvokeInput{InvocationArn: aws.String(arn)})
+	if err != nil {
+		return nil, fmt.Errorf("bedrock get async invoke failed: %w", err)
+	}
+	job := &VideoJob{
+		ARN:            aws.ToString(out.InvocationArn),
+		Status:         VideoJobStatus(out.Status),
+		FailureMessage: aws.ToString(out.FailureMessage),
+		SubmitTime:     aws.ToTime(out.SubmitTime),
+		EndTime:        aws.ToTime(out.EndTime),
+	}
+	if s3Out, ok := out.OutputDataConfig.(*types.AsyncInvokeOutputDataConfigMemberS3OutputDataConfig); ok {
+		job.OutputURI = aws.ToString(s3Out.Value.S3Uri)
+	}
+	return job, nil
+}
+
+// WaitVideoJob polls a job every interval until it completes, fails or ctx is
+// done. progress, when non-nil, receives every snapshot.
+func WaitVideoJob(ctx context.Context, arn string, interval time.Duration, progress func(*VideoJob), opts ClientOptions) (*VideoJob, error) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := GetVideoJob(ctx, arn, opts)
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(job)
+		}
+		switch job.Status {
+		case VideoJobCompleted:
+			return job, nil
+		case VideoJobFailed:
+			return job, fmt.Errorf("video job failed: %s", job.FailureMessage)
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// splitS3URI turns "s3://bucket/a/b" into ("bucket", "a/b").
+func splitS3URI(uri string) (bucket, key string, err error) {
+	rest, ok := strings.CutPrefix(uri, "s3://")
+	if !ok || rest == "" {
+		return "", "", fmt.Errorf("invalid S3 URI %q", uri)
+	}
+	bucket, key, _ = strings.Cut(rest, "/")
+	return bucket, strings.Trim(key, "/"), nil
+}
+
+// DownloadVideo copies a completed job's output.mp4 from S3 into destDir and
+// returns the local path. S3 access uses AWS credentials, not Bedrock API keys.
+func DownloadVideo(ctx context.Context, job *VideoJob, destDir string, opts ClientOptions) (string, error) {
+	if job == nil || job.Status != VideoJobCompleted {
+		return "", errors.New("video job has not completed")
+	}
+	bucket, prefix, err := splitS3URI(job.OutputURI)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimPrefix(prefix+"/output.mp4", "/")
+
+	client, err := karmas3.CreateS3Client(karmas3.S3ClientConfig{Region: ResolveRegion(opts.Region)})
+	if err != nil {
+		return "", err
+	}
+	obj, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return "", fmt.Errorf("failed to download s3://%s/%s: %w", bucket, key, err)
+	}
+	defer obj.Body.Close()
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	name := "output.mp4"
+	if i := strings.LastIndex(job.ARN, "/"); i >= 0 && i < len(job.ARN)-1 {
+		name = job.ARN[i+1:] + ".mp4"
+	}
+	path := filepath.Join(destDir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create video file: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, obj.Body); err != nil {
+		return "", fmt.Errorf("failed to save video: %w", err)
+	}
+	return path, nil
+}
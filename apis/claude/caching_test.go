@@ -104,7 +104,10 @@ func TestContextIsDeliveredOnTheLastUserMessage(t *testing.T) {
 			{Role: models.User, Message: "second"},
 		},
 	}
-	got := processMessages(h)
+	got, _, err := processMessages(h)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(got) != 3 {
 		t.Fatalf("got %d messages", len(got))
 	}
@@ -127,7 +130,7 @@ func TestContextDoesNotTouchTheSystemBlock(t *testing.T) {
 	blocks := (CachePolicy{}).systemBlocks(strings.Repeat("stable system prompt. ", 300), 0)
 	before := blocks[0].Text
 	h := models.AIChatHistory{Context: "volatile timestamp", Messages: []models.AIMessage{{Role: models.User, Message: "hi"}}}
-	_ = processMessages(h)
+	_, _, _ = processMessages(h)
 	after := (CachePolicy{}).systemBlocks(strings.Repeat("stable system prompt. ", 300), 0)[0].Text
 	if before != after {
 		t.Error("the system block changed between calls; the cached prefix would never hit")
@@ -136,7 +139,11 @@ func TestContextDoesNotTouchTheSystemBlock(t *testing.T) {
 
 func TestNoContextLeavesMessagesAlone(t *testing.T) {
 	h := models.AIChatHistory{Messages: []models.AIMessage{{Role: models.User, Message: "only"}}}
-	if got := blockText(processMessages(h)[0]); got != "only" {
+	got, _, err := processMessages(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := blockText(got[0]); got != "only" {
 		t.Errorf("message was altered with no context set: %q", got)
 	}
 }
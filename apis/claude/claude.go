@@ -58,6 +58,8 @@ type ClaudeClient struct {
 	// Cache controls prompt caching. The zero value caches when it is worth it;
 	// see CachePolicy.
 	Cache CachePolicy
+	// WebSearch, when set, adds the server-side web_search tool.
+	WebSearch *WebSearch
 }
 
 func (cc *ClaudeClient) isThinkingModel() bool {
@@ -155,6 +157,24 @@ func newClaudeClient(client *anthropic.Client, maxTokens int, model anthropic.Mo
 	}
 }
 
+// UseCredentials replaces the environment's API key and base URL with the
+// given ones; empty values keep the current setting. It has no effect on the
+// Bedrock transport, which signs requests with AWS credentials.
+func (cc *ClaudeClient) UseCredentials(apiKey, baseURL string) {
+	if UseBedrockTransport() || (apiKey == "" && baseURL == "") {
+		return
+	}
+	var opts []option.RequestOption
+	if apiKey != "" {
+		opts = append(opts, option.WithAPIKey(apiKey))
+	}
+	if baseURL != "" {
+		opts = append(opts, option.WithBaseURL(baseURL), option.WithRequestTimeout(5*time.Minute))
+	}
+	client := anthropic.NewClient(opts...)
+	cc.Client = &client
+}
+
 // SetMCPServer configures the MCP server and creates a tool manager
 func (cc *ClaudeClient) SetMCPServer(serverURL, authToken string) {
 	mcpClient := mcp.NewClient(serverURL, authToken)
@@ -235,7 +255,10 @@ func (cc *ClaudeClient) ClaudeSinglePrompt(prompt string) (*models.AIChatRespons
 
 // ClaudeChatCompletionWithTools handles chat completion with optional MCP tool support
 func (cc *ClaudeClient) ClaudeChatCompletion(messages models.AIChatHistory, enableTools bool, useMCPExecution bool) (*models.AIChatResponse, error) {
-	processedMessages := processMessages(messages)
+	processedMessages, historySystem, err := processMessages(messages)
+	if err != nil {
+		return nil, err
+	}
 	mgsParam := anthropic.MessageNewParams{
 		MaxTokens: int64(cc.MaxTokens),
 		Messages:  processedMessages,
@@ -246,14 +269,11 @@ func (cc *ClaudeClient) ClaudeChatCompletion(messages models.AIChatHistory, enab
 	// Tools are assembled BEFORE the system blocks, because they render in
 	// front of the system prompt and therefore count toward the prefix a
 	// breakpoint on it would cache.
-	if enableTools && cc.hasAnyTools() {
-		mgsParam.Tools = cc.getAllToolsAsAnthropic()
-	}
+	mgsParam.Tools = cc.requestTools(enableTools)
 	prefixChars := toolChars(mgsParam.Tools)
-	if cc.SystemPrompt != "" {
-		mgsParam.System = cc.systemBlocks(prefixChars)
-		prefixChars += len(cc.SystemPrompt)
-	}
+	system, systemChars := cc.requestSystem(prefixChars, historySystem)
+	mgsParam.System = system
+	prefixChars += systemChars
 
 	// Cache the conversation prefix too. Without this the history — by far the
 	// largest part of a long turn — is re-billed in full every request.
@@ -267,6 +287,8 @@ func (cc *ClaudeClient) ClaudeChatCompletion(messages models.AIChatHistory, enab
 		maxPasses = 10
 	}
 
+	var citations []models.Citation
+	var pausedText string
 	for round := 0; round <= maxPasses; round++ {
 		// The last permitted round is answered without tools. Running out of
 		// passes used to fail the whole turn — a caller got an error, not an
@@ -329,7 +351,14 @@ func (cc *ClaudeClient) ClaudeChatCompletion(messages models.AIChatHistory, enab
 			}
 		}
 
-		if !hasToolUse || !enableTools {
+		citations = append(citations, webCitations(message.Content)...)
+		// pause_turn means a long server-side search was cut short; sending the
+		// partial turn back lets the model resume it. The text it wrote so far
+		// is part of the answer.
+		if message.StopReason == anthropic.StopReasonPauseTurn {
+			pausedText += responseText
+		} else if !hasToolUse || !enableTools {
+			responseText = pausedText + responseText
 			if thinkingText != "" {
 				responseText = "<think>" + thinkingText + "</think>" + responseText
 			}
@@ -340,6 +369,7 @@ func (cc *ClaudeClient) ClaudeChatCompletion(messages models.AIChatHistory, enab
 				CacheReadTokens:  int(cacheStatsFrom(message.Usage).Read),
 				CacheWriteTokens: int(cacheStatsFrom(message.Usage).Written),
 				Tokens:           int(message.Usage.InputTokens) + int(message.Usage.OutputTokens),
+				Citations:        citations,
 			}, nil
 		}
 
@@ -360,7 +390,10 @@ func (cc *ClaudeClient) ClaudeStreamCompletion(messages models.AIChatHistory, ca
 
 // ClaudeStreamCompletionWithTools handles streaming completion with optional MCP tool support
 func (cc *ClaudeClient) ClaudeStreamCompletionWithTools(messages models.AIChatHistory, callback func(chunck models.StreamedResponse) error, enableTools bool, useMCPExecution bool) (*models.AIChatResponse, error) {
-	processedMessages := processMessages(messages)
+	processedMessages, historySystem, err := processMessages(messages)
+	if err != nil {
+		return nil, err
+	}
 	streamParams := anthropic.MessageNewParams{
 		MaxTokens: int64(cc.MaxTokens),
 		Messages:  processedMessages,
@@ -370,14 +403,11 @@ func (cc *ClaudeClient) ClaudeStreamCompletionWithTools(messages models.AIChatHi
 
 	// Tools first: they render ahead of the system prompt and count toward the
 	// prefix a breakpoint on it would cache.
-	if enableTools && cc.hasAnyTools() {
-		streamParams.Tools = cc.getAllToolsAsAnthropic()
-	}
+	streamParams.Tools = cc.requestTools(enableTools)
 	prefixChars := toolChars(streamParams.Tools)
-	if cc.SystemPrompt != "" {
-		streamParams.System = cc.systemBlocks(prefixChars)
-		prefixChars += len(cc.SystemPrompt)
-	}
+	system, systemChars := cc.requestSystem(prefixChars, historySystem)
+	streamParams.System = system
+	prefixChars += systemChars
 	cacheHistory(streamParams.Messages, historyBoundary(streamParams.Messages, strings.TrimSpace(messages.Context) != ""), prefixChars, cc.cachePolicy())
 
 	ctx, cancel := cc.requestContext()
@@ -388,6 +418,8 @@ func (cc *ClaudeClient) ClaudeStreamCompletionWithTools(messages models.AIChatHi
 		maxPasses = 10
 	}
 
+	var citations []models.Citation
+	var pausedText string
 	for round := 0; round <= maxPasses; round++ {
 		// The last permitted round is answered without tools. Running out of
 		// passes used to fail the whole turn — a caller got an error, not an
@@ -448,18 +480,22 @@ func (cc *ClaudeClient) ClaudeStreamCompletionWithTools(messages models.AIChatHi
 			return nil, stream.Err()
 		}
 
-		// Check for tool calls
-		if !enableTools || message.StopReason != "tool_use" {
-			if len(message.Content) > 0 {
-				var thinkingText, responseText string
-				for _, block := range message.Content {
-					switch b := block.AsAny().(type) {
-					case anthropic.ThinkingBlock:
-						thinkingText += b.Thinking
-					case anthropic.TextBlock:
-						responseText += b.Text
-					}
-				}
+		citations = append(citations, webCitations(message.Content)...)
+		var thinkingText, responseText string
+		for _, block := range message.Content {
+			switch b := block.AsAny().(type) {
+			case anthropic.ThinkingBlock:
+				thinkingText += b.Thinking
+			case anthropic.TextBlock:
+				responseText += b.Text
+			}
+		}
+		if message.StopReason == anthropic.StopReasonPauseTurn {
+			// Resumed below; keep what was already streamed for the response.
+			pausedText += responseText
+		} else if !enableTools || message.StopReason != "tool_use" {
+			if len(message.Content) > 0 || pausedText != "" {
+				responseText = pausedText + responseText
 				if thinkingText != "" {
 					responseText = "<think>" + thinkingText + "</think>" + responseText
 				}
@@ -470,6 +506,7 @@ func (cc *ClaudeClient) ClaudeStreamCompletionWithTools(messages models.AIChatHi
 					OutputTokens:     int(message.Usage.OutputTokens),
 					CacheReadTokens:  int(stats.Read),
 					CacheWriteTokens: int(stats.Written),
+					Citations:        citations,
 				}, nil
 			}
 			return nil, nil
@@ -504,7 +541,9 @@ func (cc *ClaudeClient) ClaudeStreamCompletionWithTools(messages models.AIChatHi
 
 		// Append assistant turn + tool results and continue loop
 		processedMessages = append(processedMessages, message.ToParam())
-		processedMessages = append(processedMessages, anthropic.NewUserMessage(toolResults...))
+		if len(toolResults) > 0 {
+			processedMessages = append(processedMessages, anthropic.NewUserMessage(toolResults...))
+		}
 		streamParams.Messages = processedMessages
 	}
 
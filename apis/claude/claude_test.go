@@ -0,0 +1,106 @@
+package claude
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+func TestPausedTurnKeepsItsText(t *testing.T) {
+	replies := []map[string]any{
+		{"stop_reason": "pause_turn", "content": []map[string]any{{"type": "text", "text": "Searching the docs. "}}},
+		{"stop_reason": "end_turn", "content": []map[string]any{{"type": "text", "text": "Go 1.24 added generic type aliases."}}},
+	}
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := replies[min(calls, len(replies)-1)]
+		calls++
+		reply["id"], reply["type"], reply["role"], reply["model"] = "msg_1", "message", "assistant", "claude-sonnet-4-5"
+		reply["usage"] = map[string]any{"input_tokens": 10, "output_tokens": 5}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reply)
+	}))
+	defer srv.Close()
+	t.Setenv("ANTHROPIC_BASE_URL", srv.URL)
+	t.Setenv("ANTHROPIC_API_KEY", "test")
+	t.Setenv("KARMA_ANTHROPIC_BEDROCK", "")
+
+	cc := NewClaudeClient(256, "claude-sonnet-4-5", 1, 1, 0, "")
+	res, err := cc.ClaudeChatCompletion(models.AIChatHistory{Messages: []models.AIMessage{{Role: models.User, Message: "What changed in Go 1.24?"}}}, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || res.AIResponse != "Searching the docs. Go 1.24 added generic type aliases." {
+		t.Fatalf("calls = %d, response = %q", calls, res.AIResponse)
+	}
+}
+
+func TestProcessMessagesReplaysToolsAndImages(t *testing.T) {
+	call := models.OpenAIToolCall{ID: "toolu_1", Type: "function"}
+	call.Function.Name, call.Function.Arguments = "lookup", `{"q":"go"}`
+	h := models.AIChatHistory{Messages: []models.AIMessage{
+		{Role: models.User, Message: "what is this?", Images: []string{"data:image/png;base64,iVBORw0K"}},
+		{Role: models.Assistant, ToolCalls: []models.OpenAIToolCall{call}},
+		{Role: models.Tool, Message: "a gopher", ToolCallId: "toolu_1"},
+	}}
+	got, _, err := processMessages(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d messages, want 3", len(got))
+	}
+	if img := got[0].Content[0].OfImage; img == nil || img.Source.OfBase64 == nil || img.Source.OfBase64.Data != "iVBORw0K" {
+		t.Errorf("image was not sent: %+v", got[0].Content[0])
+	}
+	if use := got[1].Content[0].OfToolUse; use == nil || use.ID != "toolu_1" || use.Name != "lookup" {
+		t.Errorf("tool call was not replayed: %+v", got[1].Content[0])
+	}
+	if res := got[2].Content[0].OfToolResult; res == nil || res.ToolUseID != "toolu_1" || res.Content[0].OfText.Text != "a gopher" {
+		t.Errorf("tool result was not replayed: %+v", got[2].Content[0])
+	}
+}
+
+func TestHistorySystemMessageReachesRequest(t *testing.T) {
+	var sent struct {
+		System []struct {
+			Text string `json:"text"`
+		} `json:"system"`
+		Messages []struct {
+			Content []map[string]any `json:"content"`
+		} `json:"messages"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5", "stop_reason": "end_turn",
+			"content": []map[string]any{{"type": "text", "text": "ok"}},
+			"usage":   map[string]any{"input_tokens": 10, "output_tokens": 1},
+		})
+	}))
+	defer srv.Close()
+	t.Setenv("ANTHROPIC_BASE_URL", srv.URL)
+	t.Setenv("ANTHROPIC_API_KEY", "test")
+	t.Setenv("KARMA_ANTHROPIC_BEDROCK", "")
+
+	cc := NewClaudeClient(256, "claude-sonnet-4-5", 1, 1, 0, "You are terse.")
+	_, err := cc.ClaudeChatCompletion(models.AIChatHistory{Messages: []models.AIMessage{
+		{Role: models.System, Message: "The user is in Berlin."},
+		{Role: models.User, Message: "what is this?", Images: []string{"/tmp/local.png"}},
+	}}, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.System) != 2 || sent.System[0].Text != "You are terse." || sent.System[1].Text != "The user is in Berlin." {
+		t.Fatalf("system = %+v", sent.System)
+	}
+	if len(sent.Messages) != 1 || len(sent.Messages[0].Content) != 1 || sent.Messages[0].Content[0]["type"] != "text" {
+		t.Fatalf("messages = %+v", sent.Messages)
+	}
+}
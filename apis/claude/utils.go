@@ -3,9 +3,12 @@ package claude
 import (
 	"context"
 	"fmt"
+	"log"
+	"slices"
 	"sort"
 	"strings"
 
+	"github.com/MelloB1989/karma/ai/convert"
 	mcp "github.com/MelloB1989/karma/ai/mcp_client"
 	"github.com/MelloB1989/karma/models"
 	"github.com/anthropics/anthropic-sdk-go"
@@ -235,7 +238,10 @@ func (cc *ClaudeClient) callMCPTool(ctx context.Context, toolName string, argume
 	return result.Content, nil
 }
 
-// processMessages converts chat history into Anthropic message params.
+// processMessages converts chat history into Anthropic message params with
+// ai/convert, so tool calls, tool results and images are replayed. System
+// text in the history is returned separately as system blocks, and images
+// Claude cannot take are skipped rather than failing the request.
 //
 // AIChatHistory.Context is delivered on the LAST user message rather than the
 // system prompt, and that placement is the whole point. Callers put per-turn
@@ -245,32 +251,64 @@ func (cc *ClaudeClient) callMCPTool(ctx context.Context, toolName string, argume
 //
 // It used to be dropped on the floor: the field was set by callers and read by
 // nothing, so every scrap of per-turn context silently never reached the model.
-func processMessages(messages models.AIChatHistory) []anthropic.MessageParam {
-	processedMessages := make([]anthropic.MessageParam, 0, len(messages.Messages))
-	lastUser := -1
+func processMessages(messages models.AIChatHistory) ([]anthropic.MessageParam, []anthropic.TextBlockParam, error) {
+	msgs := slices.Clone(messages.Messages)
+	for i := range msgs {
+		if len(msgs[i].Images) == 0 {
+			continue
+		}
+		images := make([]string, 0, len(msgs[i].Images))
+		for _, img := range msgs[i].Images {
+			if !convert.IsAnthropicImage(img) {
+				log.Printf("karma: skipping image Claude cannot read, want a data or http(s) URL: %.40q", img)
+				continue
+			}
+			images = append(images, img)
+		}
+		msgs[i].Images = images
+	}
 	if strings.TrimSpace(messages.Context) != "" {
-		for i, msg := range messages.Messages {
-			if msg.Role == models.User {
-				lastUser = i
+		for i := len(msgs) - 1; i >= 0; i-- {
+			if msgs[i].Role == models.User {
+				msgs[i].Message = messages.Context + "\n\n" + msgs[i].Message
+				break
 			}
 		}
 	}
-	for i, msg := range messages.Messages {
-		if i == lastUser {
-			msg.Message = messages.Context + "\n\n" + msg.Message
+	messages.Messages = msgs
+	wire, err := convert.ToAnthropic(messages)
+	if err != nil {
+		return nil, nil, err
+	}
+	var processedMessages []anthropic.MessageParam
+	if err := convert.Decode(wire.Messages, &processedMessages); err != nil {
+		return nil, nil, err
+	}
+	var system []anthropic.TextBlockParam
+	if len(wire.System) > 0 {
+		if err := convert.Decode(wire.System, &system); err != nil {
+			return nil, nil, err
 		}
-		var role anthropic.MessageParamRole
-		if msg.Role == models.User {
-			role = anthropic.MessageParamRoleUser
-		} else {
-			role = anthropic.MessageParamRoleAssistant
+	}
+	return processedMessages, system, nil
+}
+
+// requestSystem is the client's system prompt followed by the system text
+// carried in the history, which sits after the prompt's cache breakpoint so it
+// cannot invalidate it. It returns the blocks and the prefix size they add.
+func (cc *ClaudeClient) requestSystem(toolsChars int, history []anthropic.TextBlockParam) ([]anthropic.TextBlockParam, int) {
+	var blocks []anthropic.TextBlockParam
+	chars := 0
+	if cc.SystemPrompt != "" {
+		blocks = cc.systemBlocks(toolsChars)
+		chars += len(cc.SystemPrompt)
+	}
+	for _, b := range history {
+		if b.Text == cc.SystemPrompt {
+			continue
 		}
-		processedMessages = append(processedMessages, anthropic.MessageParam{
-			Role: role,
-			Content: []anthropic.ContentBlockParamUnion{{
-				OfText: &anthropic.TextBlockParam{Text: msg.Message},
-			}},
-		})
+		blocks = append(blocks, b)
+		chars += len(b.Text)
 	}
-	return processedMessages
+	return blocks, chars
 }
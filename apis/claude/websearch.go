@@ -0,0 +1,81 @@
+package claude
+
+import (
+	"github.com/MelloB1989/karma/models"
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// WebSearch enables Anthropic's server-side web_search tool. Searches run on
+// Anthropic's side, so the tool is sent whether or not local tools are enabled,
+// and the sources the answer cites come back as AIChatResponse.Citations.
+type WebSearch struct {
+	// MaxUses caps searches per request. Zero leaves it to the API.
+	MaxUses int
+	// AllowedDomains and BlockedDomains are mutually exclusive.
+	AllowedDomains []string
+	BlockedDomains []string
+	// Approximate user location, used to localise results.
+	City     string
+	Region   string
+	Country  string
+	Timezone string
+}
+
+func (w *WebSearch) toolParam() anthropic.ToolUnionParam {
+	p := &anthropic.WebSearchTool20250305Param{
+		AllowedDomains: w.AllowedDomains,
+		BlockedDomains: w.BlockedDomains,
+	}
+	if w.MaxUses > 0 {
+		p.MaxUses = anthropic.Int(int64(w.MaxUses))
+	}
+	loc := anthropic.WebSearchTool20250305UserLocationParam{}
+	if w.City != "" {
+		loc.City = anthropic.String(w.City)
+	}
+	if w.Region != "" {
+		loc.Region = anthropic.String(w.Region)
+	}
+	if w.Country != "" {
+		loc.Country = anthropic.String(w.Country)
+	}
+	if w.Timezone != "" {
+		loc.Timezone = anthropic.String(w.Timezone)
+	}
+	if w.City != "" || w.Region != "" || w.Country != "" || w.Timezone != "" {
+		p.UserLocation = loc
+	}
+	return anthropic.ToolUnionParam{OfWebSearchTool20250305: p}
+}
+
+// requestTools is the tool list for a request: local tools when enabled, then
+// server tools, which need no local execution.
+func (cc *ClaudeClient) requestTools(enableTools bool) []anthropic.ToolUnionParam {
+	var tools []anthropic.ToolUnionParam
+	if enableTools && cc.hasAnyTools() {
+		tools = cc.getAllToolsAsAnthropic()
+	}
+	if cc.WebSearch != nil {
+		tools = append(tools, cc.WebSearch.toolParam())
+	}
+	return tools
+}
+
+// webCitations collects the web search results cited by the text blocks of a
+// message.
+func webCitations(content []anthropic.ContentBlockUnion) []models.Citation {
+	var out []models.Citation
+	for _, block := range content {
+		text, ok := block.AsAny().(anthropic.TextBlock)
+		if !ok {
+			continue
+		}
+		for _, c := range text.Citations {
+			if c.Type != "web_search_result_location" || c.URL == "" {
+				continue
+			}
+			out = append(out, models.Citation{URL: c.URL, Title: c.Title, Snippet: c.CitedText})
+		}
+	}
+	return out
+}
@@ -0,0 +1,241 @@
+package gemini
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MelloB1989/karma/tokenizer"
+	"google.golang.org/genai"
+)
+
+// Explicit context caching.
+//
+// Gemini does implicit caching on its own, but only explicit cachedContents
+// guarantee the discount and let a long system prompt, tool schema or set of
+// documents be uploaded once instead of on every request. A cache is keyed by
+// everything that goes into it and by the credential and project that own it,
+// so an unchanged prefix is reused across clients and requests in the same
+// process, a changed one gets a fresh cache rather than stale content, and no
+// tenant is handed a cache it cannot read.
+//
+// Requests that use a cache cannot also carry system_instruction, tools or
+// tool_config; those move into the cache and are stripped from the request.
+
+const (
+	defaultCacheTTL = time.Hour
+	// The provider refuses caches below a per-model token floor.
+	minCacheTokensDefault = 1024
+	minCacheTokensPro     = 4096
+)
+
+// CachePolicy configures explicit context caching. The zero value is off.
+type CachePolicy struct {
+	// Enabled turns explicit caching on.
+	Enabled bool
+	// TTL is how long a cache lives. Zero means one hour. A cache is extended
+	// when it is reused with less than half of its TTL remaining.
+	TTL time.Duration
+	// Documents are long, static texts placed in the cached prefix ahead of
+	// the conversation. They are sent inline when caching is not possible.
+	Documents []string
+}
+
+func (p CachePolicy) ttl() time.Duration {
+	if p.TTL > 0 {
+		return p.TTL
+	}
+	return defaultCacheTTL
+}
+
+func minCacheTokensFor(model string) int {
+	if strings.Contains(strings.ToLower(model), "pro") {
+		return minCacheTokensPro
+	}
+	return minCacheTokensDefault
+}
+
+type cacheEntry struct {
+	name      string
+	expiresAt time.Time
+}
+
+// cacheRegistry remembers the caches created in this process. Entries are
+// dropped once the provider has expired them.
+var cacheRegistry = &cacheEntries{entries: make(map[string]cacheEntry)}
+
+type cacheEntries struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func (r *cacheEntries) get(key string, now time.Time) (cacheEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[key]
+	if ok && !now.Before(e.expiresAt) {
+		delete(r.entries, key)
+		return cacheEntry{}, false
+	}
+	return e, ok
+}
+
+func (r *cacheEntries) put(key string, e cacheEntry, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, old := range r.entries {
+		if !now.Before(old.expiresAt) {
+			delete(r.entries, k)
+		}
+	}
+	r.entries[key] = e
+}
+
+// cacheOwner identifies the credential and project a client's caches belong
+// to. The API key is hashed rather than kept.
+func cacheOwner(client *genai.Client) string {
+	if client == nil {
+		return ""
+	}
+	cfg := client.ClientConfig()
+	sum := sha256.Sum256([]byte(cfg.APIKey))
+	return fmt.Sprintf("%d|%s|%s|%x", cfg.Backend, cfg.Project, cfg.Location, sum[:8])
+}
+
+func documentContents(docs []string) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(docs))
+	for _, doc := range docs {
+		if doc == "" {
+			continue
+		}
+		contents = append(contents, &genai.Content{
+			Parts: []*genai.Part{{Text: doc}},
+			Role:  genai.RoleUser,
+		})
+	}
+	return contents
+}
+
+// cacheKey identifies a cache by its owner, model and everything placed in it.
+func cacheKey(owner, model string, config *genai.GenerateContentConfig, docs []string) string {
+	h := sha256.New()
+	h.Write([]byte(owner))
+	h.Write([]byte{0})
+	h.Write([]byte(model))
+	if config.SystemInstruction != nil {
+		raw, _ := json.Marshal(config.SystemInstruction)
+		h.Write(raw)
+	}
+	if len(config.Tools) > 0 {
+		raw, _ := json.Marshal(config.Tools)
+		h.Write(raw)
+	}
+	for _, doc := range docs {
+		h.Write([]byte{0})
+		h.Write([]byte(doc))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// prefixTokens estimates the size of what would be cached.
+func prefixTokens(model string, config *genai.GenerateContentConfig, docs []string) int {
+	var sb strings.Builder
+	if config.SystemInstruction != nil {
+		for _, p := range config.SystemInstruction.Parts {
+			sb.WriteString(p.Text)
+		}
+	}
+	if len(config.Tools) > 0 {
+		raw, _ := json.Marshal(config.Tools)
+		sb.Write(raw)
+	}
+	for _, doc := range docs {
+		sb.WriteString(doc)
+	}
+	return tokenizer.CountTokens(model, sb.String())
+}
+
+// applyCache points config at a cached prefix when the policy allows it,
+// creating or extending the cache as needed. On any failure, or when the
+// prefix is too small to cache, documents are sent inline instead.
+func (g *Gemini) applyCache(ctx context.Context, contents []*genai.Content, config *genai.GenerateContentConfig) []*genai.Content {
+	g.cacheWriteTokens = 0
+	p := g.Cache
+	docs := documentContents(p.Documents)
+	inline := func() []*genai.Content {
+		if len(docs) == 0 {
+			return contents
+		}
+		return append(docs, contents...)
+	}
+	if !p.Enabled || g.Client == nil {
+		return inline()
+	}
+	if prefixTokens(g.Model, config, p.Documents) < minCacheTokensFor(g.Model) {
+		return inline()
+	}
+
+	name, err := g.getOrCreateCache(ctx, config, docs)
+	if err != nil {
+		log.Printf("karma: gemini context cache unavailable, sending prefix inline: %v", err)
+		return inline()
+	}
+	config.CachedContent = name
+	config.SystemInstruction = nil
+	config.Tools = nil
+	config.ToolConfig = nil
+	return contents
+}
+
+func (g *Gemini) getOrCreateCache(ctx context.Context, config *genai.GenerateContentConfig, docs []*genai.Content) (string, error) {
+	key := cacheKey(cacheOwner(g.Client), g.Model, config, g.Cache.Documents)
+	ttl := g.Cache.ttl()
+	now := time.Now()
+
+	if entry, ok := cacheRegistry.get(key, now); ok {
+		if entry.expiresAt.Sub(now) < ttl/2 {
+			if updated, err := g.Client.Caches.Update(ctx, entry.name, &genai.UpdateCachedContentConfig{TTL: ttl}); err == nil {
+				entry.expiresAt = expiryOf(updated, now, ttl)
+				cacheRegistry.put(key, entry, now)
+			}
+		}
+		return entry.name, nil
+	}
+
+	created, err := g.Client.Caches.Create(ctx, g.Model, &genai.CreateCachedContentConfig{
+		TTL:               ttl,
+		DisplayName:       "karma-" + key[:16],
+		Contents:          docs,
+		SystemInstruction: config.SystemInstruction,
+		Tools:             config.Tools,
+		ToolConfig:        config.ToolConfig,
+	})
+	if err != nil {
+		return "", err
+	}
+	if created.UsageMetadata != nil {
+		g.cacheWriteTokens = int(created.UsageMetadata.TotalTokenCount)
+	}
+
+	cacheRegistry.put(key, cacheEntry{name: created.Name, expiresAt: expiryOf(created, now, ttl)}, now)
+	return created.Name, nil
+}
+
+func expiryOf(c *genai.CachedContent, now time.Time, ttl time.Duration) time.Time {
+	if c != nil && !c.ExpireTime.IsZero() {
+		return c.ExpireTime
+	}
+	return now.Add(ttl)
+}
+
+// LastCacheWriteTokens reports the tokens written to a new cache by the most
+// recent request, zero when an existing cache was reused.
+func (g *Gemini) LastCacheWriteTokens() int {
+	return g.cacheWriteTokens
+}
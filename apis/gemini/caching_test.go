@@ -0,0 +1,96 @@
+package gemini
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// With caching off, documents still reach the model: they are sent inline
+// ahead of the conversation.
+func TestDocumentsAreSentInlineWhenCachingIsOff(t *testing.T) {
+	g := &Gemini{Model: "gemini-2.5-flash", Cache: CachePolicy{Documents: []string{"handbook"}}}
+	contents := []*genai.Content{{Role: genai.RoleUser, Parts: []*genai.Part{{Text: "hi"}}}}
+	config := &genai.GenerateContentConfig{}
+
+	got := g.applyCache(context.Background(), contents, config)
+	if len(got) != 2 || got[0].Parts[0].Text != "handbook" {
+		t.Fatalf("expected document ahead of conversation, got %d contents", len(got))
+	}
+	if config.CachedContent != "" {
+		t.Error("no cache should be referenced when caching is off")
+	}
+}
+
+// A prefix under the provider floor cannot be cached, so it must not be
+// stripped from the request.
+func TestSmallPrefixIsNotCached(t *testing.T) {
+	g := &Gemini{Model: "gemini-2.5-flash", Cache: CachePolicy{Enabled: true}}
+	config := &genai.GenerateContentConfig{
+		SystemInstruction: &genai.Content{Parts: []*genai.Part{{Text: "be brief"}}},
+	}
+	g.applyCache(context.Background(), nil, config)
+	if config.SystemInstruction == nil || config.CachedContent != "" {
+		t.Error("a short system prompt should stay on the request")
+	}
+}
+
+// The key must change with anything that goes into the cache, and only then.
+func TestCacheKeyTracksCachedContent(t *testing.T) {
+	sys := func(text string) *genai.GenerateContentConfig {
+		return &genai.GenerateContentConfig{SystemInstruction: &genai.Content{Parts: []*genai.Part{{Text: text}}}}
+	}
+	a := cacheKey("", "gemini-2.5-pro", sys("one"), nil)
+	if a != cacheKey("", "gemini-2.5-pro", sys("one"), nil) {
+		t.Error("same prefix should give the same key")
+	}
+	if a == cacheKey("", "gemini-2.5-pro", sys("two"), nil) {
+		t.Error("a different system prompt should give a different key")
+	}
+	if a == cacheKey("", "gemini-2.5-flash", sys("one"), nil) {
+		t.Error("a different model should give a different key")
+	}
+	if a == cacheKey("", "gemini-2.5-pro", sys("one"), []string{strings.Repeat("doc ", 10)}) {
+		t.Error("documents should be part of the key")
+	}
+}
+
+// Caches belong to the credential and project that created them, and expired
+// ones are forgotten.
+func TestCacheRegistryIsScopedAndExpires(t *testing.T) {
+	ctx := context.Background()
+	a, err := genai.NewClient(ctx, &genai.ClientConfig{APIKey: "key-a", Backend: genai.BackendGeminiAPI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := genai.NewClient(ctx, &genai.ClientConfig{APIKey: "key-b", Backend: genai.BackendGeminiAPI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &genai.GenerateContentConfig{SystemInstruction: &genai.Content{Parts: []*genai.Part{{Text: "same"}}}}
+	if cacheKey(cacheOwner(a), "gemini-2.5-pro", config, nil) == cacheKey(cacheOwner(b), "gemini-2.5-pro", config, nil) {
+		t.Error("different credentials must not share a cache")
+	}
+	if strings.Contains(cacheOwner(a), "key-a") {
+		t.Error("the owner must not carry the raw API key")
+	}
+
+	r := &cacheEntries{entries: make(map[string]cacheEntry)}
+	now := time.Now()
+	r.put("old", cacheEntry{name: "cachedContents/old", expiresAt: now.Add(time.Minute)}, now)
+	r.put("stale", cacheEntry{name: "cachedContents/stale", expiresAt: now.Add(time.Second)}, now)
+	if _, ok := r.get("old", now); !ok {
+		t.Fatal("a live entry should be found")
+	}
+	later := now.Add(2 * time.Minute)
+	if _, ok := r.get("old", later); ok {
+		t.Error("an expired entry should not be returned")
+	}
+	r.put("new", cacheEntry{name: "cachedContents/new", expiresAt: later.Add(time.Hour)}, later)
+	if len(r.entries) != 1 {
+		t.Errorf("expired entries should be evicted, have %d", len(r.entries))
+	}
+}
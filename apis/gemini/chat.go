@@ -8,10 +8,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/MelloB1989/karma/ai/convert"
 	mcp "github.com/MelloB1989/karma/ai/mcp_client"
 	"github.com/MelloB1989/karma/config"
 	"github.com/MelloB1989/karma/models"
@@ -48,6 +50,11 @@ type Gemini struct {
 	maxToolPasses   int
 	RequestGate     func() error
 	RequestTimeout  time.Duration
+	// Cache configures explicit context caching; see CachePolicy.
+	Cache            CachePolicy
+	cacheWriteTokens int
+	// GoogleSearch, when set, grounds answers with Google Search.
+	GoogleSearch *GoogleSearch
 }
 
 // NewGemini creates a new Gemini client using environment variables for Vertex AI config
@@ -167,8 +174,12 @@ func (g *Gemini) requestContext() (context.Context, context.CancelFunc) {
 func (g *Gemini) CreateChat(messages *models.AIChatHistory, enableTools bool, useMCPExecution bool) (*genai.GenerateContentResponse, error) {
 	ctx, cancel := g.requestContext()
 	defer cancel()
-	contents := g.formatMessages(*messages)
+	contents, err := g.formatMessages(*messages)
+	if err != nil {
+		return nil, err
+	}
 	config := g.buildConfig(enableTools)
+	contents = g.applyCache(ctx, contents, config)
 
 	for range g.toolPassLimit() {
 		if g.RequestGate != nil {
@@ -293,8 +304,12 @@ func (g *Gemini) CreateChat(messages *models.AIChatHistory, enableTools bool, us
 func (g *Gemini) CreateChatStream(messages *models.AIChatHistory, chunkHandler func(*genai.GenerateContentResponse), enableTools bool, useMCPExecution bool) (*genai.GenerateContentResponse, error) {
 	ctx, cancel := g.requestContext()
 	defer cancel()
-	contents := g.formatMessages(*messages)
+	contents, err := g.formatMessages(*messages)
+	if err != nil {
+		return nil, err
+	}
 	config := g.buildConfig(enableTools)
+	contents = g.applyCache(ctx, contents, config)
 
 	for range g.toolPassLimit() {
 		// Accumulate the streamed response
@@ -416,96 +431,28 @@ func (g *Gemini) CreateChatStream(messages *models.AIChatHistory, chunkHandler f
 	return nil, fmt.Errorf("exceeded tool execution passes")
 }
 
-// formatMessages converts AIChatHistory to Gemini content format
-func (g *Gemini) formatMessages(messages models.AIChatHistory) []*genai.Content {
-	contents := make([]*genai.Content, 0, len(messages.Messages))
-
-	for _, msg := range messages.Messages {
-		switch msg.Role {
-		case models.User:
-			parts := []*genai.Part{{Text: msg.Message}}
-
-			// Add images if present
-			for _, image := range msg.Images {
-				imagePart := parseImageToPart(image)
-				if imagePart != nil {
-					parts = append(parts, imagePart)
-				}
-			}
-
-			contents = append(contents, &genai.Content{
-				Parts: parts,
-				Role:  genai.RoleUser,
-			})
-
-		case models.Assistant:
-			parts := []*genai.Part{}
-			if msg.Message != "" {
-				parts = append(parts, &genai.Part{Text: msg.Message})
-			}
-
-			// Add function calls if present
-			for _, tc := range msg.ToolCalls {
-				var args map[string]any
-				json.Unmarshal([]byte(tc.Function.Arguments), &args)
-				parts = append(parts, &genai.Part{
-					FunctionCall: &genai.FunctionCall{
-						Name: tc.Function.Name,
-						Args: args,
-					},
-				})
-			}
-
-			if len(parts) > 0 {
-				contents = append(contents, &genai.Content{
-					Parts: parts,
-					Role:  genai.RoleModel,
-				})
-			}
-
-		case models.Tool:
-			// Tool responses are added as function responses
-			var responseMap map[string]any
-			if err := json.Unmarshal([]byte(msg.Message), &responseMap); err != nil {
-				responseMap = map[string]any{"output": msg.Message}
-			}
-
-			// Find the corresponding function name and ID from the tool call ID
-			funcName := "function_response"
-			funcID := ""
-			for i := len(messages.Messages) - 1; i >= 0; i-- {
-				prevMsg := messages.Messages[i]
-				if prevMsg.Role == models.Assistant {
-					for _, tc := range prevMsg.ToolCalls {
-						if tc.ID == msg.ToolCallId {
-							funcName = tc.Function.Name
-							funcID = tc.ID
-							break
-						}
-					}
-					break
-				}
+// formatMessages converts AIChatHistory to Gemini contents with ai/convert.
+// System messages go through SystemInstruction in the config instead. Images
+// at http(s) URLs are fetched and sent inline; ones that cannot be fetched are
+// skipped.
+func (g *Gemini) formatMessages(messages models.AIChatHistory) ([]*genai.Content, error) {
+	wire, err := convert.ToGemini(messages)
+	if err != nil {
+		return nil, err
+	}
+	var contents []*genai.Content
+	if err := convert.Decode(wire.Contents, &contents); err != nil {
+		return nil, err
+	}
+	for _, content := range contents {
+		for i, part := range content.Parts {
+			if part.FileData != nil && !strings.HasPrefix(part.FileData.FileURI, "gs://") {
+				content.Parts[i] = parseImageToPart(part.FileData.FileURI)
 			}
-
-			contents = append(contents, &genai.Content{
-				Parts: []*genai.Part{
-					{
-						FunctionResponse: &genai.FunctionResponse{
-							ID:       funcID,
-							Name:     funcName,
-							Response: responseMap,
-						},
-					},
-				},
-			})
-
-		case models.System:
-			// System messages are handled via SystemInstruction in config
-			// Skip here as they're added to the config
 		}
+		content.Parts = slices.DeleteFunc(content.Parts, func(p *genai.Part) bool { return p == nil })
 	}
-
-	return contents
+	return contents, nil
 }
 
 // buildConfig creates the GenerateContentConfig with tools if enabled
@@ -534,6 +481,9 @@ func (g *Gemini) buildConfig(enableTools bool) *genai.GenerateContentConfig {
 			config.Tools = tools
 		}
 	}
+	if g.GoogleSearch != nil {
+		config.Tools = append(config.Tools, g.GoogleSearch.tool())
+	}
 
 	return config
 }
@@ -0,0 +1,34 @@
+package gemini
+
+import (
+	"testing"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+func TestFormatMessages(t *testing.T) {
+	call := models.OpenAIToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name, call.Function.Arguments = "lookup", `{"q":"go"}`
+	h := models.AIChatHistory{Messages: []models.AIMessage{
+		{Role: models.User, Message: "compare", Images: []string{"data:image/png;base64,iVBORw0K", "gs://bucket/b.jpg"}},
+		{Role: models.Assistant, ToolCalls: []models.OpenAIToolCall{call}},
+		{Role: models.Tool, Message: "a gopher", ToolCallId: "call_1"},
+	}}
+	contents, err := (&Gemini{}).formatMessages(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) != 3 {
+		t.Fatalf("got %d contents, want 3", len(contents))
+	}
+	user := contents[0].Parts
+	if len(user) != 3 || user[1].InlineData == nil || string(user[1].InlineData.Data) != "\x89PNG\r\n" || user[2].FileData == nil || user[2].FileData.FileURI != "gs://bucket/b.jpg" {
+		t.Errorf("user parts = %+v", user)
+	}
+	if fc := contents[1].Parts[0].FunctionCall; contents[1].Role != "model" || fc == nil || fc.Name != "lookup" || fc.Args["q"] != "go" {
+		t.Errorf("tool call was not replayed: %+v", contents[1])
+	}
+	if fr := contents[2].Parts[0].FunctionResponse; fr == nil || fr.Name != "lookup" || fr.Response["output"] != "a gopher" {
+		t.Errorf("tool result was not replayed: %+v", contents[2])
+	}
+}
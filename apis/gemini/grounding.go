@@ -0,0 +1,55 @@
+package gemini
+
+import (
+	"github.com/MelloB1989/karma/models"
+	"google.golang.org/genai"
+)
+
+// GoogleSearch enables grounding with Google Search. Searches run on Google's
+// side, so the tool is sent whether or not local tools are enabled.
+type GoogleSearch struct {
+	// ExcludeDomains drops these domains from results. Vertex AI only.
+	ExcludeDomains []string
+}
+
+func (s *GoogleSearch) tool() *genai.Tool {
+	return &genai.Tool{GoogleSearch: &genai.GoogleSearch{ExcludeDomains: s.ExcludeDomains}}
+}
+
+// Citations returns the web sources a grounded response cites, one per
+// source, with the span of the first segment it supports.
+func Citations(response *genai.GenerateContentResponse) []models.Citation {
+	if response == nil || len(response.Candidates) == 0 || response.Candidates[0].GroundingMetadata == nil {
+		return nil
+	}
+	meta := response.Candidates[0].GroundingMetadata
+
+	spans := make(map[int]*genai.Segment)
+	for _, support := range meta.GroundingSupports {
+		if support == nil || support.Segment == nil {
+			continue
+		}
+		for _, idx := range support.GroundingChunkIndices {
+			if _, ok := spans[int(idx)]; !ok {
+				spans[int(idx)] = support.Segment
+			}
+		}
+	}
+
+	var out []models.Citation
+	seen := make(map[string]bool)
+	for i, chunk := range meta.GroundingChunks {
+		if chunk == nil || chunk.Web == nil || chunk.Web.URI == "" || seen[chunk.Web.URI] {
+			continue
+		}
+		seen[chunk.Web.URI] = true
+		c := models.Citation{URL: chunk.Web.URI, Title: chunk.Web.Title}
+		if seg := spans[i]; seg != nil {
+			c.Snippet = seg.Text
+			c.StartIndex = int(seg.StartIndex)
+			c.EndIndex = int(seg.EndIndex)
+		}
+		out = append(out, c)
+	}
+	return out
+}
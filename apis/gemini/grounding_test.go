@@ -0,0 +1,37 @@
+package gemini
+
+import (
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+// Each source is reported once, with the span of the first claim it supports.
+func TestCitationsFromGroundingMetadata(t *testing.T) {
+	resp := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+		GroundingMetadata: &genai.GroundingMetadata{
+			GroundingChunks: []*genai.GroundingChunk{
+				{Web: &genai.GroundingChunkWeb{URI: "https://a.example", Title: "A"}},
+				{Web: &genai.GroundingChunkWeb{URI: "https://b.example", Title: "B"}},
+				{Web: &genai.GroundingChunkWeb{URI: "https://a.example", Title: "A again"}},
+			},
+			GroundingSupports: []*genai.GroundingSupport{
+				{GroundingChunkIndices: []int32{1}, Segment: &genai.Segment{Text: "claim", StartIndex: 4, EndIndex: 9}},
+			},
+		},
+	}}}
+
+	got := Citations(resp)
+	if len(got) != 2 {
+		t.Fatalf("got %d citations, want 2: %+v", len(got), got)
+	}
+	if got[0].URL != "https://a.example" || got[0].Snippet != "" {
+		t.Errorf("first citation = %+v", got[0])
+	}
+	if got[1].Snippet != "claim" || got[1].StartIndex != 4 || got[1].EndIndex != 9 {
+		t.Errorf("second citation = %+v", got[1])
+	}
+	if Citations(&genai.GenerateContentResponse{}) != nil {
+		t.Error("ungrounded response should have no citations")
+	}
+}
@@ -118,6 +118,68 @@ func GenImage(prompt, model, destination_dir string) (*models.AIImageResponse, e
 // GenImageWithConfig generates an image with custom configuration options
 // Supports both Gemini API (with API key) and Vertex AI (with project/location) backends
 func GenImageWithConfig(prompt, model, destination_dir string, opts ...ImageGenOption) (*models.AIImageResponse, error) {
+	return EditImageWithConfig(prompt, model, destination_dir, nil, opts...)
+}
+
+// InputImage is an image sent along with the prompt.
+type InputImage struct {
+	Data     []byte
+	MIMEType string
+}
+
+// EditImageWithConfig generates an image from a prompt and input images, e.g.
+// to edit, extend or restyle them. The prompt should say what to do with each
+// image.
+func EditImageWithConfig(prompt, model, destination_dir string, images []InputImage, opts ...ImageGenOption) (*models.AIImageResponse, error) {
+	data, err := GenerateImageData(prompt, model, images, opts...)
+	if err != nil {
+		if data != nil {
+			return &models.AIImageResponse{Safety: data.Safety}, err
+		}
+		return nil, err
+	}
+
+	if err := os.MkdirAll(destination_dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	var path string
+	for _, img := range data.Images {
+		randomName := utils.GenerateID(16)
+
+		// Determine file extension based on MIME type
+		ext := "png"
+		if img.MIMEType == "image/jpeg" {
+			ext = "jpg"
+		} else if img.MIMEType == "image/webp" {
+			ext = "webp"
+		}
+
+		outputFilename := fmt.Sprintf("%s/%s.%s", destination_dir, randomName, ext)
+		if err := os.WriteFile(outputFilename, img.Data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write image file: %w", err)
+		}
+		path = outputFilename
+	}
+
+	return &models.AIImageResponse{
+		FilePath: path,
+		Text:     data.Text,
+		Safety:   data.Safety,
+	}, nil
+}
+
+// ImageData is the in-memory result of GenerateImageData.
+type ImageData struct {
+	Images []InputImage
+	Text   string // Text the model returned alongside the images
+	Safety *models.AIImageSafety
+}
+
+// GenerateImageData runs the same request as EditImageWithConfig but returns
+// the images instead of writing them to disk. When the request is blocked the
+// returned ImageData carries the safety verdict along with the error.
+func GenerateImageData(prompt, model string, images []InputImage, opts ...ImageGenOption) (*ImageData, error) {
 	// Apply default configuration
 	cfg := &ImageGenConfig{
 		AspectRatio:      "1:1",
@@ -136,48 +198,9 @@ func GenImageWithConfig(prompt, model, destination_dir string, opts ...ImageGenO
 
 	ctx := context.Background()
 
-	// Create client based on configuration
-	var client *genai.Client
-	var err error
-
-	if cfg.APIKey != "" {
-		// Use Gemini API backend with API key
-		client, err = genai.NewClient(ctx, &genai.ClientConfig{
-			APIKey:  cfg.APIKey,
-			Backend: genai.BackendGeminiAPI,
-		})
-	} else if cfg.ProjectID != "" && cfg.Location != "" {
-		// Use Vertex AI backend
-		client, err = genai.NewClient(ctx, &genai.ClientConfig{
-			Backend:  genai.BackendVertexAI,
-			Project:  cfg.ProjectID,
-			Location: cfg.Location,
-		})
-	} else {
-		// Try environment variables for Vertex AI
-		projectID := config.GetEnvRaw("GOOGLE_PROJECT_ID")
-		location := config.GetEnvRaw("GOOGLE_LOCATION")
-		if projectID != "" && location != "" {
-			client, err = genai.NewClient(ctx, &genai.ClientConfig{
-				Backend:  genai.BackendVertexAI,
-				Project:  projectID,
-				Location: location,
-			})
-		} else {
-			// Fallback to API key from environment
-			apiKey := config.GetEnvRaw("GEMINI_API_KEY")
-			if apiKey == "" {
-				return nil, fmt.Errorf("no authentication configured: set GEMINI_API_KEY or GOOGLE_PROJECT_ID/GOOGLE_LOCATION environment variables, or use WithAPIKey/WithVertexAI options")
-			}
-			client, err = genai.NewClient(ctx, &genai.ClientConfig{
-				APIKey:  apiKey,
-				Backend: genai.BackendGeminiAPI,
-			})
-		}
-	}
-
+	client, err := newImageClient(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+		return nil, err
 	}
 
 	// Build generation config with image settings
@@ -213,6 +236,9 @@ func GenImageWithConfig(prompt, model, destination_dir string, opts ...ImageGenO
 	parts := []*genai.Part{
 		genai.NewPartFromText(prompt),
 	}
+	for _, img := range images {
+		parts = append(parts, genai.NewPartFromBytes(img.Data, img.MIMEType))
+	}
 
 	contents := []*genai.Content{
 		genai.NewContentFromParts(parts, genai.RoleUser),
@@ -232,6 +258,11 @@ func GenImageWithConfig(prompt, model, destination_dir string, opts ...ImageGenO
 		return nil, fmt.Errorf("received nil result from GenerateContent")
 	}
 
+	safety := imageSafety(result)
+	if safety.Blocked {
+		return &ImageData{Safety: safety}, fmt.Errorf("image generation blocked: %s", safety.Reason)
+	}
+
 	if len(result.Candidates) == 0 {
 		return nil, fmt.Errorf("no candidates returned from GenerateContent")
 	}
@@ -244,46 +275,99 @@ func GenImageWithConfig(prompt, model, destination_dir string, opts ...ImageGenO
 		return nil, fmt.Errorf("candidate content parts is nil")
 	}
 
-	if err := os.MkdirAll(destination_dir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create destination directory: %w", err)
-	}
-
-	var path string
-	var textResponse string
-
+	data := &ImageData{Safety: safety}
 	for _, part := range result.Candidates[0].Content.Parts {
 		if part.Text != "" {
-			textResponse = part.Text
+			data.Text = part.Text
 		} else if part.InlineData != nil {
-			randomName := utils.GenerateID(16)
-			imageBytes := part.InlineData.Data
-
-			// Determine file extension based on MIME type
-			ext := "png"
-			if part.InlineData.MIMEType == "image/jpeg" {
-				ext = "jpg"
-			} else if part.InlineData.MIMEType == "image/webp" {
-				ext = "webp"
-			}
+			data.Images = append(data.Images, InputImage{Data: part.InlineData.Data, MIMEType: part.InlineData.MIMEType})
+		}
+	}
 
-			outputFilename := fmt.Sprintf("%s/%s.%s", destination_dir, randomName, ext)
-			if err := os.WriteFile(outputFilename, imageBytes, 0644); err != nil {
-				return nil, fmt.Errorf("failed to write image file: %w", err)
-			}
-			path = outputFilename
+	// Some models return both text and image; text alone means no image
+	if len(data.Images) == 0 {
+		if data.Text != "" {
+			return nil, fmt.Errorf("no image generated, model returned text only: %s", data.Text)
 		}
+		return nil, fmt.Errorf("no image generated")
 	}
 
-	response := &models.AIImageResponse{
-		FilePath: path,
+	return data, nil
+}
+
+// newImageClient creates a client from cfg, falling back to Vertex AI and
+// then API key environment variables.
+func newImageClient(ctx context.Context, cfg *ImageGenConfig) (*genai.Client, error) {
+	var client *genai.Client
+	var err error
+
+	if cfg.APIKey != "" {
+		// Use Gemini API backend with API key
+		client, err = genai.NewClient(ctx, &genai.ClientConfig{
+			APIKey:  cfg.APIKey,
+			Backend: genai.BackendGeminiAPI,
+		})
+	} else if cfg.ProjectID != "" && cfg.Location != "" {
+		// Use Vertex AI backend
+		client, err = genai.NewClient(ctx, &genai.ClientConfig{
+			Backend:  genai.BackendVertexAI,
+			Project:  cfg.ProjectID,
+			Location: cfg.Location,
+		})
+	} else {
+		// Try environment variables for Vertex AI
+		projectID := config.GetEnvRaw("GOOGLE_PROJECT_ID")
+		location := config.GetEnvRaw("GOOGLE_LOCATION")
+		if projectID != "" && location != "" {
+			client, err = genai.NewClient(ctx, &genai.ClientConfig{
+				Backend:  genai.BackendVertexAI,
+				Project:  projectID,
+				Location: location,
+			})
+		} else {
+			// Fallback to API key from environment
+			apiKey := config.GetEnvRaw("GEMINI_API_KEY")
+			if apiKey == "" {
+				return nil, fmt.Errorf("no authentication configured: set GEMINI_API_KEY or GOOGLE_PROJECT_ID/GOOGLE_LOCATION environment variables, or use WithAPIKey/WithVertexAI options")
+			}
+			client, err = genai.NewClient(ctx, &genai.ClientConfig{
+				APIKey:  apiKey,
+				Backend: genai.BackendGeminiAPI,
+			})
+		}
 	}
 
-	// Include text response if available (some models return both text and image)
-	if textResponse != "" && path == "" {
-		return nil, fmt.Errorf("no image generated, model returned text only: %s", textResponse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
 	}
+	return client, nil
+}
 
-	return response, nil
+// imageSafety collects the prompt feedback and safety ratings of the first
+// candidate.
+func imageSafety(result *genai.GenerateContentResponse) *models.AIImageSafety {
+	safety := &models.AIImageSafety{}
+	if fb := result.PromptFeedback; fb != nil && fb.BlockReason != "" {
+		safety.Blocked = true
+		safety.Reason = string(fb.BlockReason)
+	}
+	if len(result.Candidates) == 0 {
+		return safety
+	}
+	c := result.Candidates[0]
+	switch c.FinishReason {
+	case genai.FinishReasonSafety, genai.FinishReasonProhibitedContent, genai.FinishReasonImageSafety, genai.FinishReasonBlocklist, genai.FinishReasonSPII:
+		safety.Blocked = true
+		safety.Reason = string(c.FinishReason)
+	}
+	for _, r := range c.SafetyRatings {
+		safety.Ratings = append(safety.Ratings, models.AISafetyRating{
+			Category:    string(r.Category),
+			Probability: string(r.Probability),
+			Blocked:     r.Blocked,
+		})
+	}
+	return safety
 }
 
 // GenImageVertexAI generates an image using Vertex AI backend with project and location
@@ -0,0 +1,457 @@
+package segmind
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+const (
+	segmindAPIBase      = "https://api.segmind.com/v1/"
+	segmindWorkflowBase = "https://api.segmind.com/workflows/"
+)
+
+// Model returns the endpoint for any Segmind model slug, e.g.
+// Model("flux-1.1-pro"), for models without a constant here.
+func Model(slug string) SegmindModels {
+	return SegmindModels(segmindAPIBase + strings.TrimPrefix(slug, "/"))
+}
+
+// Workflow returns the endpoint for a published PixelFlow workflow. Workflows
+// only run asynchronously, through Submit.
+func Workflow(id string) SegmindModels {
+	return SegmindModels(segmindWorkflowBase + strings.TrimPrefix(id, "/"))
+}
+
+// Params is the request body for a model or workflow. Keys and value types
+// follow the model's API page.
+type Params map[string]any
+
+// Output is the response of a synchronous call. Media models return raw bytes;
+// others return JSON, which is decoded into JSON as well.
+type Output struct {
+	Data        []byte
+	ContentType string
+	JSON        map[string]any
+}
+
+// ErrQueueFull is returned once retries are exhausted on a rate-limited or
+// queue-full response.
+var ErrQueueFull = errors.New("segmind queue is full")
+
+// ErrUntrustedURL is returned for poll or output URLs outside the hosts the
+// client is allowed to contact.
+var ErrUntrustedURL = errors.New("segmind: untrusted URL")
+
+// DefaultOutputHosts are the hosts outputs are downloaded from: Segmind and
+// the S3 and CloudFront CDNs it serves results through.
+var DefaultOutputHosts = []string{"segmind.com", "amazonaws.com", "cloudfront.net"}
+
+const (
+	// maxRetryAfter caps how long a Retry-After header can make us sleep.
+	maxRetryAfter = time.Minute
+	// maxDownloadBytes caps downloaded outputs; videos are the largest.
+	maxDownloadBytes = 512 << 20
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Minute}
+
+// downloadClient fetches outputs. It never carries the API key and re-checks
+// every redirect against the allowed hosts.
+var downloadClient = &http.Client{Timeout: 5 * time.Minute}
+
+// Invoke calls the model synchronously with params.
+func (s *Segmind) Invoke(ctx context.Context, params Params) (*Output, error) {
+	body, contentType, err := s.post(ctx, string(s.Model), params)
+	if err != nil {
+		return nil, err
+	}
+	out := &Output{Data: body, ContentType: contentType}
+	if strings.Contains(contentType, "json") {
+		if err := json.Unmarshal(body, &out.JSON); err != nil {
+			return nil, fmt.Errorf("failed to decode segmind response: %w", err)
+		}
+		out.Data = nil
+	}
+	return out, nil
+}
+
+// post sends a JSON body and retries 429 and 503 responses with exponential
+// backoff, honouring Retry-After when present.
+func (s *Segmind) post(ctx context.Context, url string, data any) ([]byte, string, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, "", err
+	}
+	backoff := s.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		body, contentType, status, retryAfter, err := s.do(ctx, http.MethodPost, url, payload)
+		if err != nil {
+			return nil, "", err
+		}
+		if status < 300 {
+			return body, contentType, nil
+		}
+		if !queueFull(status, body) {
+			return nil, "", fmt.Errorf("segmind request failed with status %d: %s", status, strings.TrimSpace(string(body)))
+		}
+		if attempt >= s.MaxRetries {
+			return nil, "", fmt.Errorf("%w after %d attempts: %s", ErrQueueFull, attempt+1, strings.TrimSpace(string(body)))
+		}
+		wait := backoff
+		if retryAfter > 0 {
+			wait = min(retryAfter, maxRetryAfter)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// do calls the Segmind API. The API key is only ever sent to the model's host,
+// so a poll URL taken from a webhook cannot redirect it elsewhere.
+func (s *Segmind) do(ctx context.Context, method, url string, payload []byte) (body []byte, contentType string, status int, retryAfter time.Duration, err error) {
+	if !s.apiHost(url) {
+		return nil, "", 0, 0, fmt.Errorf("%w: %s is not on the model's host", ErrUntrustedURL, url)
+	}
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, "", 0, 0, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("x-api-key", s.ApiKey)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", 0, 0, err
+	}
+	defer resp.Body.Close()
+	if body, err = io.ReadAll(resp.Body); err != nil {
+		return nil, "", 0, 0, err
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(secs) * time.Second
+	}
+	return body, resp.Header.Get("Content-Type"), resp.StatusCode, retryAfter, nil
+}
+
+// apiHost reports whether raw has the same scheme and host as the model URL.
+func (s *Segmind) apiHost(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	model, err := url.Parse(string(s.Model))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == model.Scheme && strings.EqualFold(u.Host, model.Host)
+}
+
+// outputHost reports whether an output may be downloaded from raw: https on
+// one of OutputHosts (or a subdomain), or the model's own host.
+func (s *Segmind) outputHost(raw string) bool {
+	if s.apiHost(raw) {
+		return true
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	hosts := s.OutputHosts
+	if hosts == nil {
+		hosts = DefaultOutputHosts
+	}
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// download fetches an output URL without credentials, refusing untrusted
+// hosts, redirects to them and bodies over maxDownloadBytes.
+func (s *Segmind) download(ctx context.Context, raw string) ([]byte, error) {
+	if !s.outputHost(raw) {
+		return nil, fmt.Errorf("%w: %s", ErrUntrustedURL, raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := *downloadClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		if !s.outputHost(req.URL.String()) {
+			return fmt.Errorf("%w: redirect to %s", ErrUntrustedURL, req.URL)
+		}
+		return nil
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to download %s: status %d", raw, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxDownloadBytes {
+		return nil, fmt.Errorf("output %s is larger than %d bytes", raw, maxDownloadBytes)
+	}
+	return body, nil
+}
+
+func queueFull(status int, body []byte) bool {
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		return true
+	}
+	lower := strings.ToLower(string(body))
+	return strings.Contains(lower, "queue is full") || strings.Contains(lower, "queue full")
+}
+
+// JobStatus is the state of an async job.
+type JobStatus string
+
+const (
+	JobQueued     JobStatus = "QUEUED"
+	JobProcessing JobStatus = "PROCESSING"
+	JobCompleted  JobStatus = "COMPLETED"
+	JobFailed     JobStatus = "FAILED"
+)
+
+// Job is an async request. Output holds the result once completed; its shape
+// depends on the model or workflow.
+type Job struct {
+	RequestID string          `json:"request_id"`
+	PollURL   string          `json:"poll_url"`
+	Status    JobStatus       `json:"status"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (j *Job) Done() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed
+}
+
+// OutputURLs returns every URL in the job output, in order. Outputs that are
+// JSON encoded as a string are unwrapped first.
+func (j *Job) OutputURLs() []string {
+	var v any
+	if err := json.Unmarshal(j.Output, &v); err != nil {
+		return nil
+	}
+	var urls []string
+	var walk func(any)
+	walk = func(v any) {
+		switch t := v.(type) {
+		case string:
+			if strings.HasPrefix(t, "http://") || strings.HasPrefix(t, "https://") {
+				urls = append(urls, t)
+				return
+			}
+			var nested any
+			if json.Unmarshal([]byte(t), &nested) == nil {
+				walk(nested)
+			}
+		case []any:
+			for _, item := range t {
+				walk(item)
+			}
+		case map[string]any:
+			for _, k := range outputKeys(t) {
+				walk(t[k])
+			}
+		}
+	}
+	walk(v)
+	return urls
+}
+
+// primaryOutputKeys are the fields Segmind puts the result in, walked before
+// any other so the first URL is the output rather than e.g. a preview.
+var primaryOutputKeys = []string{"image", "images", "output", "outputs", "url"}
+
+// outputKeys orders m's keys with primaryOutputKeys first and the rest
+// sorted, since ranging a map would change OutputURLs between calls.
+func outputKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for _, k := range primaryOutputKeys {
+		if _, ok := m[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	rest := make([]string, 0, len(m)-len(keys))
+	for k := range m {
+		if !slices.Contains(primaryOutputKeys, k) {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// Submit queues params on an async endpoint, such as a Workflow, and returns
+// without waiting. WebhookURL, when set, is sent as the "webhook" field.
+func (s *Segmind) Submit(ctx context.Context, params Params) (*Job, error) {
+	body := Params{}
+	for k, v := range params {
+		body[k] = v
+	}
+	if s.WebhookURL != "" {
+		body["webhook"] = s.WebhookURL
+	}
+	resp, _, err := s.post(ctx, string(s.Model), body)
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{}
+	if err := json.Unmarshal(resp, job); err != nil {
+		return nil, fmt.Errorf("failed to decode segmind job: %w", err)
+	}
+	if job.PollURL == "" {
+		return nil, fmt.Errorf("segmind did not return a poll URL: %s", strings.TrimSpace(string(resp)))
+	}
+	if job.Status == "" {
+		job.Status = JobQueued
+	}
+	return job, nil
+}
+
+// Poll fetches the current state of job and updates it in place.
+func (s *Segmind) Poll(ctx context.Context, job *Job) error {
+	body, _, status, _, err := s.do(ctx, http.MethodGet, job.PollURL, nil)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("segmind poll failed with status %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	var update Job
+	if err := json.Unmarshal(body, &update); err != nil {
+		return fmt.Errorf("failed to decode segmind job: %w", err)
+	}
+	job.Status = JobStatus(strings.ToUpper(string(update.Status)))
+	job.Output = update.Output
+	job.Error = update.Error
+	return nil
+}
+
+// Wait polls job every interval until it finishes or ctx is done. progress,
+// when non-nil, receives the job after every poll.
+func (s *Segmind) Wait(ctx context.Context, job *Job, interval time.Duration, progress func(*Job)) error {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	for {
+		if err := s.Poll(ctx, job); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(job)
+		}
+		switch job.Status {
+		case JobCompleted:
+			return nil
+		case JobFailed:
+			return fmt.Errorf("segmind job %s failed: %s", job.RequestID, job.Error)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// ParseWebhook decodes the job Segmind posts to WebhookURL on completion. The
+// body is untrusted: its URLs are only polled or downloaded when they are on
+// the model's host or OutputHosts.
+func ParseWebhook(r io.Reader) (*Job, error) {
+	job := &Job{}
+	if err := json.NewDecoder(r).Decode(job); err != nil {
+		return nil, fmt.Errorf("failed to decode segmind webhook: %w", err)
+	}
+	job.Status = JobStatus(strings.ToUpper(string(job.Status)))
+	return job, nil
+}
+
+// ImageResponse saves a synchronous output like RequestCreateImage does.
+// JSON outputs are resolved through their base64 "image" field or first URL.
+func (s *Segmind) ImageResponse(ctx context.Context, out *Output) (*models.AIImageResponse, error) {
+	data := out.Data
+	var hosted string
+	if data == nil {
+		if b64, ok := out.JSON["image"].(string); ok && !strings.HasPrefix(b64, "http") {
+			var err error
+			if data, err = base64.StdEncoding.DecodeString(b64); err != nil {
+				return nil, fmt.Errorf("failed to decode image: %w", err)
+			}
+		} else {
+			raw, _ := json.Marshal(out.JSON)
+			urls := (&Job{Output: raw}).OutputURLs()
+			if len(urls) == 0 {
+				return nil, errors.New("no image data found in response")
+			}
+			hosted = urls[0]
+		}
+	}
+	return s.saveResponse(ctx, data, hosted)
+}
+
+// JobImageResponse downloads and saves the first output of a completed job.
+func (s *Segmind) JobImageResponse(ctx context.Context, job *Job) (*models.AIImageResponse, error) {
+	if job.Status != JobCompleted {
+		return nil, fmt.Errorf("segmind job %s is %s", job.RequestID, job.Status)
+	}
+	urls := job.OutputURLs()
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("segmind job %s has no output URL", job.RequestID)
+	}
+	return s.saveResponse(ctx, nil, urls[0])
+}
+
+func (s *Segmind) saveResponse(ctx context.Context, data []byte, hosted string) (*models.AIImageResponse, error) {
+	if data == nil {
+		body, err := s.download(ctx, hosted)
+		if err != nil {
+			return nil, err
+		}
+		data = body
+	}
+	path, err := s.save(data)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AIImageResponse{FilePath: *path, ImageHostedUrl: hosted}, nil
+}
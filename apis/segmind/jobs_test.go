@@ -0,0 +1,155 @@
+package segmind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInvokeRetriesQueueFull(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			http.Error(w, `{"error":"queue is full"}`, http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\nfake"))
+	}))
+	defer srv.Close()
+
+	s := NewSegmind(SegmindModels(srv.URL), WithRetries(3, time.Millisecond), WithOutputDir(t.TempDir()))
+	out, err := s.Invoke(context.Background(), Params{"prompt": "x"})
+	if err != nil || calls != 3 || out.ContentType != "image/png" {
+		t.Fatalf("out = %+v, err = %v after %d calls", out, err, calls)
+	}
+	res, err := s.ImageResponse(context.Background(), out)
+	if err != nil || !strings.HasSuffix(res.FilePath, ".png") {
+		t.Fatalf("response = %+v, %v", res, err)
+	}
+
+	s.MaxRetries, calls = 0, 0
+	if _, err := s.Invoke(context.Background(), Params{}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("err = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestSubmitAndWait(t *testing.T) {
+	polls := 0
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/workflow":
+			fmt.Fprintf(w, `{"request_id":"r1","poll_url":%q,"status":"QUEUED"}`, srv.URL+"/poll")
+		case "/poll":
+			polls++
+			if polls < 2 {
+				fmt.Fprint(w, `{"status":"PROCESSING"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"status":"COMPLETED","output":"{\"video\": \"%s/out.mp4\"}"}`, srv.URL)
+		case "/out.mp4":
+			w.Write([]byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom"))
+		}
+	}))
+	defer srv.Close()
+
+	s := NewSegmind(SegmindModels(srv.URL+"/workflow"), WithOutputDir(t.TempDir()))
+	job, err := s.Submit(context.Background(), Params{"prompt": "waves"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []JobStatus
+	if err := s.Wait(context.Background(), job, time.Millisecond, func(j *Job) { seen = append(seen, j.Status) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[1] != JobCompleted {
+		t.Fatalf("progress = %v", seen)
+	}
+	res, err := s.JobImageResponse(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ImageHostedUrl != srv.URL+"/out.mp4" || !strings.HasSuffix(res.FilePath, ".mp4") {
+		t.Fatalf("response = %+v", res)
+	}
+	if _, err := os.Stat(res.FilePath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	job, err := ParseWebhook(strings.NewReader(`{"request_id":"r1","status":"completed","output":["https://cdn/x.png"]}`))
+	if err != nil || !job.Done() || job.OutputURLs()[0] != "https://cdn/x.png" {
+		t.Fatalf("job = %+v, %v", job, err)
+	}
+}
+
+func TestUntrustedURLs(t *testing.T) {
+	var sawKey bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sawKey = sawKey || r.Header.Get("x-api-key") != ""
+		w.Write([]byte("\x89PNG\r\n\x1a\nfake"))
+	}))
+	defer srv.Close()
+
+	s := NewSegmind(SegmindModels(srv.URL+"/model"), WithApiKey("secret"), WithOutputDir(t.TempDir()))
+	if _, err := s.JobImageResponse(context.Background(), &Job{Status: JobCompleted, Output: []byte(`["` + srv.URL + `/out.png"]`)}); err != nil {
+		t.Fatal(err)
+	}
+	if sawKey {
+		t.Fatal("API key was sent with the output download")
+	}
+
+	for _, out := range []string{"http://169.254.169.254/latest/meta-data", "https://attacker.example/x.png", "http://cdn.segmind.com/x.png"} {
+		job := &Job{Status: JobCompleted, Output: []byte(`["` + out + `"]`)}
+		if _, err := s.JobImageResponse(context.Background(), job); !errors.Is(err, ErrUntrustedURL) {
+			t.Errorf("download of %s: err = %v", out, err)
+		}
+	}
+	if err := s.Poll(context.Background(), &Job{PollURL: "https://attacker.example/poll"}); !errors.Is(err, ErrUntrustedURL) {
+		t.Fatalf("poll err = %v", err)
+	}
+	if !s.outputHost("https://outputs.segmind.com/x.png") || s.outputHost("https://notsegmind.com/x.png") {
+		t.Fatal("output host matching is wrong")
+	}
+}
+
+func TestOutputURLsOrder(t *testing.T) {
+	var fetched []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = append(fetched, r.URL.Path)
+		w.Write([]byte("\x89PNG\r\n\x1a\nfake"))
+	}))
+	defer srv.Close()
+
+	out := map[string]any{"preview": srv.URL + "/preview.png", "output": srv.URL + "/out.png", "mask": srv.URL + "/mask.png"}
+	raw, _ := json.Marshal(out)
+	want := []string{srv.URL + "/out.png", srv.URL + "/mask.png", srv.URL + "/preview.png"}
+	for i := 0; i < 20; i++ {
+		if got := (&Job{Output: raw}).OutputURLs(); !slices.Equal(got, want) {
+			t.Fatalf("urls = %v", got)
+		}
+	}
+
+	s := NewSegmind(SegmindModels(srv.URL+"/model"), WithOutputDir(t.TempDir()))
+	for i := 0; i < 5; i++ {
+		if _, err := s.ImageResponse(context.Background(), &Output{JSON: out}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range fetched {
+		if path != "/out.png" {
+			t.Fatalf("downloaded %v, want only the output field", fetched)
+		}
+	}
+}
@@ -1,14 +1,14 @@
 package segmind
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/MelloB1989/karma/config"
 	"github.com/MelloB1989/karma/files"
@@ -32,6 +32,16 @@ const (
 	SegmindMidjourneyAPI  SegmindModels = "https://api.segmind.com/v1/midjourney"
 	SegmindSDXLAPI        SegmindModels = "https://api.segmind.com/v1/sdxl1.0-txt2img"
 	SegmindSD15API        SegmindModels = "https://api.segmind.com/v1/sd1.5-txt2img"
+	// Editing models, used with RequestEditImage
+	SegmindInpaintAPI           SegmindModels = "https://api.segmind.com/v1/sdxl-inpaint"
+	SegmindImg2ImgAPI           SegmindModels = "https://api.segmind.com/v1/sd1.5-img2img"
+	SegmindBackgroundRemovalAPI SegmindModels = "https://api.segmind.com/v1/bg-removal-v2"
+	// Upscaling models
+	SegmindESRGANAPI          SegmindModels = "https://api.segmind.com/v1/esrgan"
+	SegmindClarityUpscalerAPI SegmindModels = "https://api.segmind.com/v1/clarity-upscaler"
+	// Video models; these take minutes, so prefer Submit over Invoke
+	SegmindKlingVideoAPI SegmindModels = "https://api.segmind.com/v1/kling-text2video"
+	SegmindLTXVideoAPI   SegmindModels = "https://api.segmind.com/v1/ltx-video"
 )
 
 var (
@@ -54,6 +64,16 @@ type Segmind struct {
 	OutputDir  string
 	UploadToS3 bool
 	S3Bucket   string
+	// MaxRetries is how many times a rate-limited or queue-full request is
+	// retried, waiting RetryBackoff and then doubling it each time. Defaults to
+	// 0; enable retries with WithRetries.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// WebhookURL is sent with Submit so Segmind reports completion there.
+	WebhookURL string
+	// OutputHosts are the https hosts outputs may be downloaded from, matched
+	// exactly or as a parent domain. Nil means DefaultOutputHosts.
+	OutputHosts []string
 }
 
 type Options func(*Segmind)
@@ -61,16 +81,21 @@ type Options func(*Segmind)
 func NewSegmind(model SegmindModels, opts ...Options) *Segmind {
 	r := R1024x1024
 	k, _ := config.GetEnv("SEGMIND_API_KEY")
-	return &Segmind{
-		Model:      model,
-		BatchSize:  1,
-		Width:      r.Width,
-		Height:     r.Height,
-		OutputDir:  "./images",
-		UploadToS3: false,
-		S3Bucket:   "",
-		ApiKey:     k,
+	s := &Segmind{
+		Model:        model,
+		BatchSize:    1,
+		Width:        r.Width,
+		Height:       r.Height,
+		OutputDir:    "./images",
+		UploadToS3:   false,
+		S3Bucket:     "",
+		ApiKey:       k,
+		RetryBackoff: 2 * time.Second,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func WithBatchSize(batchSize int) Options {
@@ -105,8 +130,40 @@ func WithApiKey(apiKey string) Options {
 	}
 }
 
+// WithRetries sets how often queue-full responses are retried
+func WithRetries(maxRetries int, backoff time.Duration) Options {
+	return func(s *Segmind) {
+		s.MaxRetries = maxRetries
+		s.RetryBackoff = backoff
+	}
+}
+
+// WithOutputHosts replaces the hosts outputs may be downloaded from
+func WithOutputHosts(hosts ...string) Options {
+	return func(s *Segmind) {
+		s.OutputHosts = hosts
+	}
+}
+
+// WithWebhook asks Segmind to call url when a submitted job finishes
+func WithWebhook(url string) Options {
+	return func(s *Segmind) {
+		s.WebhookURL = url
+	}
+}
+
 func (s *Segmind) RequestCreateImage(prompt string) (*string, error) {
-	data := map[string]any{
+	return s.request(context.Background(), s.createImageData(prompt))
+}
+
+// RequestCreateImageContext is RequestCreateImage bound to ctx, which also
+// cancels any retry waits.
+func (s *Segmind) RequestCreateImageContext(ctx context.Context, prompt string) (*string, error) {
+	return s.request(ctx, s.createImageData(prompt))
+}
+
+func (s *Segmind) createImageData(prompt string) map[string]any {
+	return map[string]any{
 		"prompt":          prompt,
 		"negative_prompt": "low quality, blurry",
 		"steps":           25,
@@ -122,69 +179,80 @@ func (s *Segmind) RequestCreateImage(prompt string) (*string, error) {
 		"image_quality":   95,
 		"base64":          true,
 	}
+}
 
-	api := string(s.Model)
-
-	jsonPayload, err := json.Marshal(data)
-	if err != nil {
-		fmt.Println("Error converting struct to json:", err)
-		return nil, err
-	}
-	req, err := http.NewRequest("POST", api, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		fmt.Println("Error creating request:", err)
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", s.ApiKey)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println("Error sending request:", err)
-		return nil, err
+func (s *Segmind) RequestCreateImageWithInputImage(prompt string, imageUrls []string) (*string, error) {
+	// Nano Banana requires at least one input image
+	if s.Model == SegmindNanoBananaAPI && len(imageUrls) == 0 {
+		return nil, fmt.Errorf("nano-banana model requires at least one input image URL")
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return nil, err
+	data := map[string]interface{}{
+		"prompt":     prompt,
+		"image_urls": imageUrls,
 	}
 
-	// Check if response is binary data (image) or JSON
-	var imageBytes []byte
+	return s.request(context.Background(), data)
+}
 
-	// Try to parse as JSON first
-	var responseData map[string]interface{}
-	if json.Unmarshal(body, &responseData) == nil {
-		// JSON response - extract base64 image
-		if imageData, ok := responseData["image"].(string); ok {
-			// Decode the base64 image data
-			imageBytes, err = base64.StdEncoding.DecodeString(imageData)
-			if err != nil {
-				fmt.Println("Error decoding base64 image data:", err)
-				return nil, err
-			}
-		} else {
-			fmt.Println("No image data found in JSON response")
-			return nil, fmt.Errorf("no image data found in response")
-		}
-	} else {
-		// Binary response - use raw body as image data
-		imageBytes = body
+// RequestEditImage sends an editing request, e.g. to SegmindInpaintAPI with
+// "image", "mask" and "prompt" fields. Images are passed as base64 strings or
+// URLs, as the model expects.
+func (s *Segmind) RequestEditImage(data map[string]any) (*string, error) {
+	return s.request(context.Background(), data)
+}
+
+// RequestCreateImageBytes generates an image like RequestCreateImage but
+// returns the raw bytes instead of saving or uploading them.
+func (s *Segmind) RequestCreateImageBytes(prompt string) ([]byte, error) {
+	return s.fetch(context.Background(), s.createImageData(prompt))
+}
+
+// RequestImageBytes posts data to the model and returns the image bytes.
+func (s *Segmind) RequestImageBytes(data map[string]any) ([]byte, error) {
+	return s.fetch(context.Background(), data)
+}
+
+// RequestImageBytesContext is RequestImageBytes bound to ctx.
+func (s *Segmind) RequestImageBytesContext(ctx context.Context, data map[string]any) ([]byte, error) {
+	return s.fetch(ctx, data)
+}
+
+// request posts data to the model, saves the returned image and uploads it
+// to S3 when enabled.
+func (s *Segmind) request(ctx context.Context, data map[string]any) (*string, error) {
+	imageBytes, err := s.fetch(ctx, data)
+	if err != nil {
+		return nil, err
 	}
+	return s.save(imageBytes)
+}
 
+// save writes output to OutputDir and uploads it to S3 when enabled,
+// returning the S3 URL or local path.
+func (s *Segmind) save(imageBytes []byte) (*string, error) {
 	// Create output directory if it doesn't exist
 	if err := os.MkdirAll(s.OutputDir, 0755); err != nil {
 		fmt.Printf("Warning: Could not create output directory: %v\n", err)
 	}
 
 	// Generate filename and save locally
-	fileId := utils.GenerateID() + ".jpeg"
+	ext := ".jpeg"
+	switch http.DetectContentType(imageBytes) {
+	case "image/png":
+		ext = ".png"
+	case "image/webp":
+		ext = ".webp"
+	case "video/mp4":
+		ext = ".mp4"
+	case "video/webm":
+		ext = ".webm"
+	}
+	fileId := utils.GenerateID() + ext
 	localFilePath := filepath.Join(s.OutputDir, fileId)
 
 	// Save image locally
-	err = os.WriteFile(localFilePath, imageBytes, 0644)
+	err := os.WriteFile(localFilePath, imageBytes, 0644)
 	if err != nil {
 		fmt.Printf("Error saving image locally: %v\n", err)
 		return nil, err
@@ -217,42 +285,10 @@ func (s *Segmind) RequestCreateImage(prompt string) (*string, error) {
 	return &localFilePath, nil
 }
 
-func (s *Segmind) RequestCreateImageWithInputImage(prompt string, imageUrls []string) (*string, error) {
-	// Nano Banana requires at least one input image
-	if s.Model == SegmindNanoBananaAPI && len(imageUrls) == 0 {
-		return nil, fmt.Errorf("nano-banana model requires at least one input image URL")
-	}
-
-	data := map[string]interface{}{
-		"prompt":     prompt,
-		"image_urls": imageUrls,
-	}
-
-	api := string(s.Model)
-
-	jsonPayload, err := json.Marshal(data)
+// fetch posts data to the model and returns the image it responds with.
+func (s *Segmind) fetch(ctx context.Context, data map[string]any) ([]byte, error) {
+	body, _, err := s.post(ctx, string(s.Model), data)
 	if err != nil {
-		fmt.Println("Error converting struct to json:", err)
-		return nil, err
-	}
-	req, err := http.NewRequest("POST", api, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		fmt.Println("Error creating request:", err)
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", s.ApiKey)
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println("Error sending request:", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
 		return nil, err
 	}
 
@@ -260,7 +296,7 @@ func (s *Segmind) RequestCreateImageWithInputImage(prompt string, imageUrls []st
 	var imageBytes []byte
 
 	// Try to parse as JSON first
-	var responseData map[string]interface{}
+	var responseData map[string]any
 	if json.Unmarshal(body, &responseData) == nil {
 		// JSON response - extract base64 image
 		if imageData, ok := responseData["image"].(string); ok {
@@ -278,46 +314,5 @@ func (s *Segmind) RequestCreateImageWithInputImage(prompt string, imageUrls []st
 		// Binary response - use raw body as image data
 		imageBytes = body
 	}
-
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(s.OutputDir, 0755); err != nil {
-		fmt.Printf("Warning: Could not create output directory: %v\n", err)
-	}
-
-	// Generate filename and save locally
-	fileId := utils.GenerateID() + ".jpeg"
-	localFilePath := filepath.Join(s.OutputDir, fileId)
-
-	// Save image locally
-	err = os.WriteFile(localFilePath, imageBytes, 0644)
-	if err != nil {
-		fmt.Printf("Error saving image locally: %v\n", err)
-		return nil, err
-	}
-
-	fmt.Printf("Image saved locally: %s\n", localFilePath)
-
-	// Try to upload to S3 if enabled
-	if s.UploadToS3 && s.S3Bucket != "" {
-		kf := files.NewKarmaFile(s.S3Bucket, files.S3)
-		image, err := files.BytesToMultipartFileHeader(imageBytes, "Karma Imager")
-		if err != nil {
-			fmt.Printf("Warning: Error converting image bytes to file for S3 upload: %v\n", err)
-			fmt.Printf("Falling back to local file: %s\n", localFilePath)
-			return &localFilePath, nil
-		}
-
-		url, err := kf.HandleSingleFileUpload(image)
-		if err != nil {
-			fmt.Printf("Warning: Error uploading image to S3: %v\n", err)
-			fmt.Printf("Falling back to local file: %s\n", localFilePath)
-			return &localFilePath, nil
-		}
-
-		fmt.Printf("Image uploaded to S3: %s\n", url)
-		return &url, nil
-	}
-
-	// Return local file path
-	return &localFilePath, nil
+	return imageBytes, nil
 }
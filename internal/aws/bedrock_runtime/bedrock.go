@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/MelloB1989/karma/ai/convert"
 	"github.com/MelloB1989/karma/config"
 	"github.com/MelloB1989/karma/models"
 	"github.com/aws/aws-sdk-go/aws"
@@ -56,21 +57,13 @@ type InferenceConfig struct {
 	TopP        float64 `json:"topP"`
 }
 
-// Message represents a single message in the messages array.
-type Message struct {
-	Content []Content `json:"content"`
-	Role    string    `json:"role"`
-}
-
-// Content represents the content of a message.
-type Content struct {
-	Text string `json:"text"`
-}
-
-// SystemMessage represents a system-level message.
-type SystemMessage struct {
-	Text string `json:"text"`
-}
+// Message, Content and SystemMessage are the Converse wire types shared with
+// ai/convert.
+type (
+	Message       = convert.BedrockMessage
+	Content       = convert.BedrockBlock
+	SystemMessage = convert.BedrockSystemBlock
+)
 
 func ProcessChatMessages(history models.AIChatHistory) []Message {
 	processedMessages := []Message{}
@@ -120,6 +120,34 @@ func TestConsumeTextAndUsage(t *testing.T) {
 	}
 }
 
+func TestConsumeURLCitations(t *testing.T) {
+	sse := strings.Join([]string{
+		`event: response.output_text.delta`,
+		`data: {"delta":"Go 1.24 is out."}`,
+		``,
+		`event: response.output_text.annotation.added`,
+		`data: {"annotation":{"type":"url_citation","url":"https://go.dev/blog","title":"Go Blog","start_index":0,"end_index":15}}`,
+		``,
+		`event: response.output_text.annotation.added`,
+		`data: {"annotation":{"type":"file_citation","file_id":"file_1"}}`,
+		``,
+		`event: response.completed`,
+		`data: {"response":{"id":"resp_2"}}`,
+		``,
+	}, "\n")
+
+	res, err := Consume(fakeResponse(sse), nil, nil)
+	if err != nil {
+		t.Fatalf("Consume error: %v", err)
+	}
+	if len(res.Citations) != 1 {
+		t.Fatalf("citations = %+v, want only the url_citation", res.Citations)
+	}
+	if c := res.Citations[0]; c.URL != "https://go.dev/blog" || c.Title != "Go Blog" || c.EndIndex != 15 {
+		t.Errorf("citation = %+v", c)
+	}
+}
+
 func TestConsumeToolCall(t *testing.T) {
 	sse := strings.Join([]string{
 		`event: response.output_item.added`,
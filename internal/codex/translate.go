@@ -25,6 +25,9 @@ type RequestOptions struct {
 	ToolChoice      any
 	ReasoningEffort string // explicit override; suffix on Model is used otherwise
 	ServiceTier     string // explicit override; suffix on Model is used otherwise
+	// ReasoningSummary requests a reasoning summary ("auto" | "concise" |
+	// "detailed") even when no effort is set. Defaults to "auto" with an effort.
+	ReasoningSummary string
 }
 
 // Service-tier and reasoning-effort suffixes recognized on model names, e.g.
@@ -111,7 +114,9 @@ func BuildRequest(opts RequestOptions) *ResponsesRequest {
 	}
 
 	if effort := firstNonEmpty(opts.ReasoningEffort, suffixEffort); effort != "" {
-		req.Reasoning = &Reasoning{Effort: effort, Summary: "auto"}
+		req.Reasoning = &Reasoning{Effort: effort, Summary: firstNonEmpty(opts.ReasoningSummary, "auto")}
+	} else if opts.ReasoningSummary != "" {
+		req.Reasoning = &Reasoning{Summary: opts.ReasoningSummary}
 	}
 	if tier := firstNonEmpty(opts.ServiceTier, suffixTier); tier != "" {
 		req.ServiceTier = tier
@@ -171,6 +176,13 @@ type sseDelta struct {
 	Delta string `json:"delta"`
 }
 
+type sseAnnotation struct {
+	Annotation struct {
+		Type string `json:"type"`
+		Citation
+	} `json:"annotation"`
+}
+
 type sseItem struct {
 	Item struct {
 		Type   string `json:"type"`
@@ -347,6 +359,7 @@ func processEvents(onText, onReasoning func(string) error, pump func(func(SSEEve
 	var text, reasoning strings.Builder
 	var usage Usage
 	var responseID string
+	var citations []Citation
 	sawTerminal := false
 
 	itemIDToCall := map[string]string{} // item_id -> call_id
@@ -401,6 +414,12 @@ func processEvents(onText, onReasoning func(string) error, pump func(func(SSEEve
 				}
 			}
 
+		case "response.output_text.annotation.added":
+			var a sseAnnotation
+			if json.Unmarshal(evt.Data, &a) == nil && a.Annotation.Type == "url_citation" && a.Annotation.URL != "" {
+				citations = append(citations, a.Annotation.Citation)
+			}
+
 		case "response.output_item.added":
 			var it sseItem
 			if json.Unmarshal(evt.Data, &it) == nil &&
@@ -486,6 +505,7 @@ func processEvents(onText, onReasoning func(string) error, pump func(func(SSEEve
 		Text:       text.String(),
 		Reasoning:  reasoning.String(),
 		ToolCalls:  toolCalls,
+		Citations:  citations,
 		Usage:      usage,
 		ResponseID: responseID,
 	}
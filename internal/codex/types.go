@@ -48,12 +48,26 @@ type InputItem struct {
 // Tool is a function tool definition in the Codex Responses format. Note the
 // flattened shape (name/parameters at the top level) — this differs from the
 // OpenAI Chat Completions nesting under `function`.
+//
+// The same struct carries the Responses API built-in tools ("web_search",
+// "file_search", "code_interpreter"), which use the trailing fields instead of
+// name/parameters.
 type Tool struct {
-	Type        string         `json:"type"` // "function"
+	Type        string         `json:"type"` // "function" | built-in tool type
 	Name        string         `json:"name,omitempty"`
 	Description string         `json:"description,omitempty"`
 	Parameters  map[string]any `json:"parameters,omitempty"`
 	Strict      bool           `json:"strict,omitempty"`
+
+	// web_search
+	SearchContextSize string         `json:"search_context_size,omitempty"` // "low" | "medium" | "high"
+	UserLocation      map[string]any `json:"user_location,omitempty"`
+	Filters           map[string]any `json:"filters,omitempty"`
+	// file_search
+	VectorStoreIDs []string `json:"vector_store_ids,omitempty"`
+	MaxNumResults  int      `json:"max_num_results,omitempty"`
+	// code_interpreter
+	Container any `json:"container,omitempty"`
 }
 
 // TextFormat carries structured-output / JSON-mode configuration.
@@ -83,6 +97,14 @@ type ResponsesRequest struct {
 	PromptCacheKey    string            `json:"prompt_cache_key,omitempty"`
 	ClientMetadata    map[string]string `json:"client_metadata,omitempty"`
 	Include           []string          `json:"include,omitempty"`
+	// PreviousResponseID chains onto a stored response (OpenAI Responses API
+	// only; requires Store). The Codex backend never stores, so it is unused there.
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
+	MaxOutputTokens    int    `json:"max_output_tokens,omitempty"`
+	// Temperature and TopP are OpenAI Responses API only; the Codex backend
+	// rejects sampling parameters.
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
 }
 
 // SSEEvent is a single parsed Server-Sent Event from the Codex stream.
@@ -106,11 +128,21 @@ type ToolCall struct {
 	Arguments string
 }
 
+// Citation is a url_citation annotation on the output text, emitted when the
+// built-in web_search tool was used.
+type Citation struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+}
+
 // Result is the fully-collected outcome of a (non-streaming) Codex response.
 type Result struct {
 	Text       string
 	Reasoning  string
 	ToolCalls  []ToolCall
+	Citations  []Citation
 	Usage      Usage
 	ResponseID string
 }
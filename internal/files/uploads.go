@@ -87,7 +87,7 @@ func HandleSingleFileUploadToLocal(fileHeader *multipart.FileHeader, uploadDir s
 	}
 	defer srcFile.Close()
 
-	err = os.Mkdir(uploadDir, os.ModePerm)
+	err = os.MkdirAll(uploadDir, os.ModePerm)
 	if err != nil {
 		return "", fmt.Errorf("Error creating directory, %w", err)
 	}
@@ -16,21 +16,25 @@ import (
 )
 
 type OpenAI struct {
-	Client            openai.Client
-	Model             string
-	Temperature       float64
-	MaxTokens         int64
-	SystemMessage     string
-	ExtraFields       map[string]any
-	MCPManager        *mcp.Manager
-	MultiMCPManager   *mcp.MultiManager
-	FunctionTools     map[string]GoFunctionTool
-	ReasoningEffort   *shared.ReasoningEffort
-	maxToolPasses     int
-	RequestGate       func() error
-	RequestTimeout    time.Duration
-	clientOptions     *CompatibleOptions
-	clientInitialized bool
+	Client          openai.Client
+	Model           string
+	Temperature     float64
+	MaxTokens       int64
+	SystemMessage   string
+	ExtraFields     map[string]any
+	MCPManager      *mcp.Manager
+	MultiMCPManager *mcp.MultiManager
+	FunctionTools   map[string]GoFunctionTool
+	ReasoningEffort *shared.ReasoningEffort
+	// PromptCacheKey groups requests that share a prefix so OpenAI routes them
+	// to the same cache. PromptCacheRetention is "in-memory" or "24h".
+	PromptCacheKey       string
+	PromptCacheRetention string
+	maxToolPasses        int
+	RequestGate          func() error
+	RequestTimeout       time.Duration
+	clientOptions        *CompatibleOptions
+	clientInitialized    bool
 	// toolNameMap maps sanitized tool names (sent upstream) back to their
 	// originals, so dotted names like "calendar.add" round-trip correctly.
 	toolNameMap map[string]string
@@ -81,6 +85,19 @@ func (o *OpenAI) requestContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), timeout)
 }
 
+// UseCredentials sends requests with apiKey to baseURL instead of the
+// configured OPENAI_KEY. An empty baseURL means api.openai.com. It must be
+// called before ApplyRequestTimeout.
+func (o *OpenAI) UseCredentials(apiKey, baseURL string) {
+	if baseURL == "" {
+		baseURL = defaultResponsesBaseURL
+	}
+	if apiKey == "" && o.clientOptions != nil {
+		apiKey = o.clientOptions.API_Key
+	}
+	o.clientOptions = &CompatibleOptions{BaseURL: baseURL, API_Key: apiKey}
+}
+
 func (o *OpenAI) ApplyRequestTimeout() {
 	if o.clientInitialized {
 		return
@@ -133,7 +150,10 @@ func extractFailedGeneration(err error) string {
 func (o *OpenAI) CreateChat(messages *models.AIChatHistory, enableTools bool, useMCPExecution bool) (*openai.ChatCompletion, error) {
 	ctx, cancel := o.requestContext()
 	defer cancel()
-	params := o.buildParams(*messages, enableTools)
+	params, err := o.buildParams(*messages, enableTools)
+	if err != nil {
+		return nil, err
+	}
 	var lastParsingErr error
 
 	for range o.toolPassLimit() {
@@ -274,7 +294,10 @@ func (o *OpenAI) CreateChat(messages *models.AIChatHistory, enableTools bool, us
 func (o *OpenAI) CreateChatStream(messages *models.AIChatHistory, chunkHandler func(chunk openai.ChatCompletionChunk), enableTools bool, useMCPExecution bool) (*openai.ChatCompletion, error) {
 	ctx, cancel := o.requestContext()
 	defer cancel()
-	params := o.buildParams(*messages, enableTools)
+	params, err := o.buildParams(*messages, enableTools)
+	if err != nil {
+		return nil, err
+	}
 	var lastParsingErr error
 
 	for range o.toolPassLimit() {
@@ -0,0 +1,166 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/models"
+	"github.com/MelloB1989/karma/utils"
+	"github.com/openai/openai-go/v3"
+)
+
+// ImageFile is an input image for edits.
+type ImageFile struct {
+	Data     []byte
+	MimeType string
+}
+
+func (f ImageFile) reader(name string) io.Reader {
+	ext := ".png"
+	switch {
+	case strings.Contains(f.MimeType, "jpeg"):
+		ext = ".jpg"
+	case strings.Contains(f.MimeType, "webp"):
+		ext = ".webp"
+	}
+	return openai.File(bytes.NewReader(f.Data), name+ext, f.MimeType)
+}
+
+// ImageEditParams describes an edit or variation request.
+type ImageEditParams struct {
+	Prompt string
+	Model  string
+	Images []ImageFile
+	// Mask marks the area to regenerate with transparent pixels.
+	Mask *ImageFile
+	// Background is "transparent", "opaque" or "auto" (GPT image models).
+	Background string
+	// Variation uses the variations endpoint instead of edits (dall-e-2).
+	Variation bool
+}
+
+// EditImage runs an image edit or variation and saves the first result to
+// destination_dir.
+func EditImage(params ImageEditParams, destination_dir string, com ...CompatibleOptions) (*models.AIImageResponse, error) {
+	if len(params.Images) == 0 {
+		return nil, errors.New("image edit needs an input image")
+	}
+	client := createClientWithTimeout(5*time.Minute, com...)
+	ctx := context.Background()
+
+	var res *openai.ImagesResponse
+	var err error
+	if params.Variation {
+		res, err = client.Images.NewVariation(ctx, openai.ImageNewVariationParams{
+			Image:          params.Images[0].reader("image"),
+			Model:          openai.ImageModel(params.Model),
+			N:              openai.Int(1),
+			ResponseFormat: openai.ImageNewVariationParamsResponseFormatB64JSON,
+		})
+	} else {
+		edit := openai.ImageEditParams{
+			Prompt: params.Prompt,
+			Model:  openai.ImageModel(params.Model),
+			N:      openai.Int(1),
+		}
+		if len(params.Images) == 1 {
+			edit.Image.OfFile = params.Images[0].reader("image")
+		} else {
+			for i, img := range params.Images {
+				edit.Image.OfFileArray = append(edit.Image.OfFileArray, img.reader(fmt.Sprintf("image_%d", i)))
+			}
+		}
+		if params.Mask != nil {
+			edit.Mask = params.Mask.reader("mask")
+		}
+		if params.Background != "" {
+			edit.Background = openai.ImageEditParamsBackground(params.Background)
+		}
+		if params.Model == string(openai.ImageModelDallE2) {
+			edit.ResponseFormat = openai.ImageEditParamsResponseFormatB64JSON
+		}
+		res, err = client.Images.Edit(ctx, edit)
+	}
+	if err != nil {
+		var apiErr *openai.Error
+		if errors.As(err, &apiErr) && (apiErr.Code == "moderation_blocked" || apiErr.Code == "content_policy_violation") {
+			return &models.AIImageResponse{Safety: &models.AIImageSafety{Blocked: true, Reason: apiErr.Code}}, err
+		}
+		return nil, err
+	}
+	if len(res.Data) == 0 {
+		return nil, errors.New("image edit returned no images")
+	}
+
+	img := res.Data[0]
+	var data []byte
+	if img.B64JSON != "" {
+		if data, err = base64.StdEncoding.DecodeString(img.B64JSON); err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+	} else if img.URL != "" {
+		if data, err = download(img.URL); err != nil {
+			return nil, err
+		}
+	}
+	path, err := saveImage(data, destination_dir)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AIImageResponse{
+		FilePath:       path,
+		ImageHostedUrl: img.URL,
+		RevisedPrompt:  img.RevisedPrompt,
+	}, nil
+}
+
+var downloadClient = &http.Client{Timeout: time.Minute}
+
+// downloadMaxBytes bounds images fetched from hosted URLs.
+const downloadMaxBytes = 50 << 20
+
+func download(url string) ([]byte, error) {
+	resp, err := downloadClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download image: status code %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, downloadMaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	if len(data) > downloadMaxBytes {
+		return nil, fmt.Errorf("failed to download image: larger than %d bytes", downloadMaxBytes)
+	}
+	return data, nil
+}
+
+func saveImage(data []byte, destination_dir string) (string, error) {
+	if err := os.MkdirAll(destination_dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	extension := ".png"
+	switch contentType := http.DetectContentType(data); {
+	case strings.Contains(contentType, "jpeg"):
+		extension = ".jpg"
+	case strings.Contains(contentType, "webp"):
+		extension = ".webp"
+	}
+	path := filepath.Join(destination_dir, utils.GenerateID(16)+extension)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+	return path, nil
+}
@@ -2,15 +2,14 @@ package openai
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/MelloB1989/karma/models"
-	"github.com/MelloB1989/karma/utils"
 	"github.com/openai/openai-go/v3"
 )
 
@@ -31,58 +30,82 @@ func GenImage(prompt, model, destination_dir string, com ...CompatibleOptions) (
 
 	imageURL := image.Data[0].URL
 
-	// Download the image
-	resp, err := http.Get(imageURL)
+	data, err := download(imageURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download image: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download image: status code %d", resp.StatusCode)
+	path, err := saveImage(data, destination_dir)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create destination directory if it doesn't exist
-	if err := os.MkdirAll(destination_dir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create destination directory: %w", err)
-	}
+	return &models.AIImageResponse{
+		FilePath:       path,
+		ImageHostedUrl: imageURL,
+		RevisedPrompt:  image.Data[0].RevisedPrompt,
+	}, nil
+}
 
-	// Generate random filename with appropriate extension
-	randomName := utils.GenerateID(16)
+// GeneratedImage is one image returned by GenImages.
+type GeneratedImage struct {
+	Data          []byte
+	MimeType      string
+	URL           string // Hosted URL, when the provider returned one
+	RevisedPrompt string
+}
 
-	// Determine file extension from Content-Type header or URL
-	var extension string
-	contentType := resp.Header.Get("Content-Type")
-	switch {
-	case strings.Contains(contentType, "png"):
-		extension = ".png"
-	case strings.Contains(contentType, "jpeg") || strings.Contains(contentType, "jpg"):
-		extension = ".jpg"
-	case strings.Contains(contentType, "webp"):
-		extension = ".webp"
-	default:
-		// Fallback to PNG if content type is unclear
-		extension = ".png"
+// GenImages generates n images and returns them in memory without writing to
+// disk. dall-e-3 only accepts n=1, so it is sent one request per image.
+func GenImages(prompt, model string, n int, com ...CompatibleOptions) ([]GeneratedImage, error) {
+	client := createClientWithTimeout(5*time.Minute, com...)
+	n = max(n, 1)
+	perRequest := n
+	if model == string(openai.ImageModelDallE3) {
+		perRequest = 1
 	}
-
-	filename := randomName + extension
-	filepath := filepath.Join(destination_dir, filename)
-
-	// Create the file
-	file, err := os.Create(filepath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create file: %w", err)
+	params := openai.ImageGenerateParams{
+		Prompt: prompt,
+		Model:  openai.ImageModel(model),
+		N:      openai.Int(int64(perRequest)),
 	}
-	defer file.Close()
-
-	// Copy the image data to the file
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to save image: %w", err)
+	// GPT image models always return base64 and reject response_format
+	if !strings.HasPrefix(model, "gpt-image") {
+		params.ResponseFormat = openai.ImageGenerateParamsResponseFormatB64JSON
 	}
 
-	return &models.AIImageResponse{
-		FilePath:       filepath,
-		ImageHostedUrl: imageURL,
-	}, nil
+	images := make([]GeneratedImage, 0, n)
+	for len(images) < n {
+		res, err := client.Images.Generate(context.Background(), params)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Data) == 0 {
+			break
+		}
+		for _, img := range res.Data {
+			var data []byte
+			if img.B64JSON != "" {
+				if data, err = base64.StdEncoding.DecodeString(img.B64JSON); err != nil {
+					return nil, fmt.Errorf("failed to decode image: %w", err)
+				}
+			} else if img.URL != "" {
+				if data, err = download(img.URL); err != nil {
+					return nil, err
+				}
+			}
+			images = append(images, GeneratedImage{
+				Data:          data,
+				MimeType:      http.DetectContentType(data),
+				URL:           img.URL,
+				RevisedPrompt: img.RevisedPrompt,
+			})
+		}
+		if perRequest == n {
+			break
+		}
+	}
+	if len(images) == 0 {
+		return nil, errors.New("image generation returned no images")
+	}
+	return images, nil
 }
@@ -0,0 +1,39 @@
+package openai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGenImagesDallE3OnePerRequest(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n"))
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			N int `json:"n"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if body.N != 1 {
+			t.Errorf("dall-e-3 request has n=%d", body.N)
+		}
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"created":1,"data":[{"b64_json":%q}]}`, png)
+	}))
+	defer srv.Close()
+
+	images, err := GenImages("a cat", "dall-e-3", 3, CompatibleOptions{BaseURL: srv.URL, API_Key: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 3 || calls.Load() != 3 {
+		t.Fatalf("got %d images from %d requests, want 3 from 3", len(images), calls.Load())
+	}
+}
@@ -0,0 +1,81 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MelloB1989/karma/config"
+	"github.com/MelloB1989/karma/internal/codex"
+)
+
+const defaultResponsesBaseURL = "https://api.openai.com/v1"
+
+// ResponsesClient talks to the OpenAI Responses API (POST /v1/responses).
+//
+// The wire format is the one the Codex backend speaks, so requests are built
+// with codex.BuildRequest and the SSE stream is consumed by codex.Consume; only
+// the endpoint, auth and the store/previous_response_id handling differ.
+type ResponsesClient struct {
+	BaseURL     string
+	APIKey      string
+	HTTPClient  *http.Client
+	RequestGate func() error
+}
+
+// NewResponsesClient creates a client for the Responses API. Empty baseURL and
+// apiKey fall back to api.openai.com and the configured OPENAI_KEY.
+func NewResponsesClient(baseURL, apiKey string, timeout time.Duration) *ResponsesClient {
+	if baseURL == "" {
+		baseURL = defaultResponsesBaseURL
+	}
+	if apiKey == "" {
+		apiKey = config.DefaultConfig().OPENAI_KEY
+	}
+	if timeout <= 0 {
+		timeout = 75 * time.Second
+	}
+	return &ResponsesClient{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Generate sends req as a streaming request and collects the result. onText and
+// onReasoning, when non-nil, receive text and reasoning-summary deltas.
+func (c *ResponsesClient) Generate(ctx context.Context, req *codex.ResponsesRequest, onText, onReasoning func(string) error) (*codex.Result, error) {
+	if c.RequestGate != nil {
+		if err := c.RequestGate(); err != nil {
+			return nil, err
+		}
+	}
+	req.Stream = true
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("openai responses: marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/responses", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("openai responses: request failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		return nil, &codex.APIError{Status: resp.StatusCode, Body: string(errBody), Headers: resp.Header}
+	}
+	return codex.Consume(resp, onText, onReasoning)
+}
@@ -1,6 +1,12 @@
 package openai
 
-import "testing"
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/MelloB1989/karma/models"
+)
 
 func TestSanitizeToolName(t *testing.T) {
 	cases := map[string]string{
@@ -31,3 +37,32 @@ func TestMapAndRestoreToolName(t *testing.T) {
 		t.Errorf("RestoreToolName(plain) = %q", got)
 	}
 }
+
+func TestFormatMessages(t *testing.T) {
+	history := models.AIChatHistory{Messages: []models.AIMessage{
+		{Role: models.User, Message: "look", Images: []string{"https://example.com/a.png"}},
+		{Role: models.Assistant, ToolCalls: []models.OpenAIToolCall{{ID: "call_1", Type: "function"}}},
+		{Role: models.Tool, Message: "done", ToolCallId: "call_1"},
+	}}
+	history.Messages[1].ToolCalls[0].Function.Name = "calendar.add"
+	history.Messages[1].ToolCalls[0].Function.Arguments = `{"day":1}`
+
+	mgs, err := formatMessages(history, "be brief")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(mgs)
+	got := string(data)
+	for _, want := range []string{
+		`"role":"system"`, `"content":"be brief"`,
+		`"image_url":{"url":"https://example.com/a.png"}`,
+		`"name":"calendar_add"`, `"tool_call_id":"call_1"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("messages lack %s: %s", want, got)
+		}
+	}
+	if history.Messages[1].ToolCalls[0].Function.Name != "calendar.add" {
+		t.Error("formatMessages renamed the caller's tool call")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/MelloB1989/karma/ai/convert"
 	mcp "github.com/MelloB1989/karma/ai/mcp_client"
 	"github.com/MelloB1989/karma/config"
 	"github.com/MelloB1989/karma/models"
@@ -35,69 +36,24 @@ func createClientWithTimeout(timeout time.Duration, opts ...CompatibleOptions) o
 	return openai.NewClient(option.WithAPIKey(config.DefaultConfig().OPENAI_KEY), clientTimeout)
 }
 
-func formatMessages(messages models.AIChatHistory, sysmgs string) []openai.ChatCompletionMessageParamUnion {
-	mgs := make([]openai.ChatCompletionMessageParamUnion, 0, len(messages.Messages)+1)
-	mgs = append(mgs, openai.SystemMessage(sysmgs))
-
-	for _, message := range messages.Messages {
-		switch message.Role {
-		case "user":
-			if len(message.Images) > 0 {
-				// Create content parts for text and images
-				content := []openai.ChatCompletionContentPartUnionParam{
-					openai.TextContentPart(message.Message),
-				}
-
-				// Add image content parts
-				for _, image := range message.Images {
-					imageContent := openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
-						URL: image,
-					})
-					content = append(content, imageContent)
-				}
-
-				// Create user message with mixed content
-				mgs = append(mgs, openai.UserMessage(content))
-			} else {
-				// Simple text-only user message
-				mgs = append(mgs, openai.UserMessage(message.Message))
-			}
-		case "assistant":
-			if len(message.ToolCalls) > 0 {
-				// Assistant message with tool calls
-				toolCalls := make([]openai.ChatCompletionMessageToolCallUnionParam, len(message.ToolCalls))
-				for i, tc := range message.ToolCalls {
-					toolCalls[i] = openai.ChatCompletionMessageToolCallUnionParam{
-						OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
-							ID: tc.ID,
-							Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
-								Name:      sanitizeToolName(tc.Function.Name),
-								Arguments: tc.Function.Arguments,
-							},
-						},
-					}
-				}
-				assistantMsg := openai.ChatCompletionAssistantMessageParam{
-					ToolCalls: toolCalls,
-				}
-				if message.Message != "" {
-					assistantMsg.Content = openai.ChatCompletionAssistantMessageParamContentUnion{
-						OfString: openai.String(message.Message),
-					}
-				}
-				mgs = append(mgs, openai.ChatCompletionMessageParamUnion{
-					OfAssistant: &assistantMsg,
-				})
-			} else {
-				mgs = append(mgs, openai.AssistantMessage(message.Message))
-			}
-		case "system":
-			mgs = append(mgs, openai.SystemMessage(message.Message))
-		case "tool":
-			mgs = append(mgs, openai.ToolMessage(message.Message, message.ToolCallId))
+// formatMessages renders history as Chat Completions messages, led by sysmgs.
+// Replayed tool call names are sanitized to match the tool definitions.
+func formatMessages(messages models.AIChatHistory, sysmgs string) ([]openai.ChatCompletionMessageParamUnion, error) {
+	messages.SystemMsg = sysmgs
+	chat, err := convert.ToOpenAIChat(messages)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range chat.Messages {
+		for i := range m.ToolCalls {
+			m.ToolCalls[i].Function.Name = sanitizeToolName(m.ToolCalls[i].Function.Name)
 		}
 	}
-	return mgs
+	var mgs []openai.ChatCompletionMessageParamUnion
+	if err := convert.Decode(chat.Messages, &mgs); err != nil {
+		return nil, err
+	}
+	return mgs, nil
 }
 
 func (o *OpenAI) hasMCPTools() bool {
@@ -186,8 +142,11 @@ func generateShortToolCallID(originalID string) string {
 	return prefix + "_" + hashStr[:23]      // Total: 8 + 1 + 23 = 32 chars (well under 40)
 }
 
-func (o *OpenAI) buildParams(messages models.AIChatHistory, enableTools bool) openai.ChatCompletionNewParams {
-	mgs := formatMessages(messages, o.SystemMessage)
+func (o *OpenAI) buildParams(messages models.AIChatHistory, enableTools bool) (openai.ChatCompletionNewParams, error) {
+	mgs, err := formatMessages(messages, o.SystemMessage)
+	if err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
 	params := openai.ChatCompletionNewParams{
 		Model:    o.Model,
 		Messages: mgs,
@@ -217,7 +176,13 @@ func (o *OpenAI) buildParams(messages models.AIChatHistory, enableTools bool) op
 	if o.ReasoningEffort != nil {
 		params.ReasoningEffort = *o.ReasoningEffort
 	}
-	return params
+	if o.PromptCacheKey != "" {
+		params.PromptCacheKey = openai.String(o.PromptCacheKey)
+	}
+	if o.PromptCacheRetention != "" {
+		params.PromptCacheRetention = openai.ChatCompletionNewParamsPromptCacheRetention(o.PromptCacheRetention)
+	}
+	return params, nil
 }
 
 func (o *OpenAI) shouldExecuteTools(chatCompletion *openai.ChatCompletion, enableTools bool, useMCPExecution bool) bool {
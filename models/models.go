@@ -98,11 +98,67 @@ type AIChatResponse struct {
 	// cache this call, billed at a premium. Zero on providers without caching.
 	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
 	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+	// Reasoning is the reasoning summary, when the provider returns one.
+	Reasoning string `json:"reasoning,omitempty"`
+	// ResponseID identifies a stored OpenAI Responses API response for
+	// previous_response_id chaining.
+	ResponseID string `json:"response_id,omitempty"`
+	// Citations are the web sources the answer is grounded in, when web search
+	// was used. See ai.WithWebSearch.
+	Citations []Citation `json:"citations,omitempty"`
+}
+
+// Citation is a source cited by a model answer.
+type Citation struct {
+	URL     string `json:"url"`
+	Title   string `json:"title,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+	// StartIndex and EndIndex delimit the cited span of the answer, when the
+	// provider reports it.
+	StartIndex int `json:"start_index,omitempty"`
+	EndIndex   int `json:"end_index,omitempty"`
 }
 
 type AIImageResponse struct {
-	ImageHostedUrl string `json:"image_hosted_url"`
-	FilePath       string `json:"file_path"`
+	ImageHostedUrl string         `json:"image_hosted_url"`
+	FilePath       string         `json:"file_path"`
+	RevisedPrompt  string         `json:"revised_prompt,omitempty"` // Prompt as rewritten by the provider, when it reports one
+	Text           string         `json:"text,omitempty"`           // Text the model returned alongside the image
+	Safety         *AIImageSafety `json:"safety,omitempty"`
+}
+
+// AIImageResult holds every image from one generation, in memory.
+type AIImageResult struct {
+	Images        []AIImage      `json:"images"`
+	RevisedPrompt string         `json:"revised_prompt,omitempty"`
+	Text          string         `json:"text,omitempty"`
+	Safety        *AIImageSafety `json:"safety,omitempty"`
+}
+
+// AIImage is one generated image. Data is always set; the location fields are
+// filled when the image was stored.
+type AIImage struct {
+	Data      []byte `json:"-"`
+	MimeType  string `json:"mime_type"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Seed      *int64 `json:"seed,omitempty"`
+	URL       string `json:"url,omitempty"`        // Provider-hosted or uploaded URL
+	SignedURL string `json:"signed_url,omitempty"` // Time-limited URL for private uploads
+	FilePath  string `json:"file_path,omitempty"`  // Set for local uploads
+}
+
+// AIImageSafety is the provider's safety verdict on an image request.
+type AIImageSafety struct {
+	Blocked bool             `json:"blocked"`
+	Reason  string           `json:"reason,omitempty"` // Block or finish reason as reported by the provider
+	Ratings []AISafetyRating `json:"ratings,omitempty"`
+}
+
+type AISafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability,omitempty"`
+	Blocked     bool   `json:"blocked,omitempty"`
 }
 
 type AIEmbeddingResponse struct {
@@ -127,6 +183,9 @@ type StreamedResponse struct {
 	TokenUsed  int        `json:"token_used"`
 	TimeTaken  int        `json:"time_taken"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	// Reasoning carries a reasoning-summary delta, on chunks where the
+	// provider streams one. AIResponse is empty on those chunks.
+	Reasoning string `json:"reasoning,omitempty"`
 }
 
 type GoogleConfig struct {
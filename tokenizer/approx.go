@@ -0,0 +1,88 @@
+package tokenizer
+
+import (
+	"math"
+	"unicode"
+)
+
+// profile holds the per-family constants used by the approximate encoder and
+// the chat message accounting.
+type profile struct {
+	// charsPerToken is the average number of ASCII bytes per token inside a
+	// single pre-tokenized piece.
+	charsPerToken float64
+	// tokensPerRune is the cost of a non-ASCII rune (CJK, Devanagari, ...).
+	tokensPerRune float64
+	// messageOverhead is added per chat message for role and delimiters.
+	messageOverhead int
+	// replyOverhead primes the assistant reply.
+	replyOverhead int
+}
+
+var profiles = map[string]profile{
+	O200kBase:  {charsPerToken: 6.0, tokensPerRune: 0.7, messageOverhead: 3, replyOverhead: 3},
+	Cl100kBase: {charsPerToken: 5.0, tokensPerRune: 1.0, messageOverhead: 3, replyOverhead: 3},
+	Claude:     {charsPerToken: 4.5, tokensPerRune: 1.1, messageOverhead: 5, replyOverhead: 3},
+	Gemini:     {charsPerToken: 5.5, tokensPerRune: 0.6, messageOverhead: 4, replyOverhead: 2},
+	Llama:      {charsPerToken: 5.5, tokensPerRune: 0.8, messageOverhead: 4, replyOverhead: 4},
+}
+
+func profileFor(name string) profile {
+	if p, ok := profiles[name]; ok {
+		return p
+	}
+	return profiles[Cl100kBase]
+}
+
+// approxEncoding estimates token counts without a vocabulary. Text is split
+// with the family's pre-tokenizer and each piece is costed by its length, so
+// short common words count as a single token while long identifiers, numbers
+// and non-Latin scripts scale up the way real BPE vocabularies do.
+type approxEncoding struct {
+	name    string
+	profile profile
+}
+
+func newApproxEncoding(name string) *approxEncoding {
+	return &approxEncoding{name: name, profile: profileFor(name)}
+}
+
+func (a *approxEncoding) Name() string {
+	return a.name
+}
+
+func (a *approxEncoding) Count(text string) int {
+	pattern := bpePatterns[a.name]
+	if pattern == nil {
+		pattern = bpePatterns[Cl100kBase]
+	}
+	total := 0
+	for _, piece := range splitPieces(pattern, text) {
+		total += a.pieceTokens(piece)
+	}
+	return total
+}
+
+func (a *approxEncoding) pieceTokens(piece string) int {
+	ascii, other := 0, 0
+	allSpace := true
+	for _, r := range piece {
+		if !unicode.IsSpace(r) {
+			allSpace = false
+		}
+		if r < unicode.MaxASCII {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	if allSpace {
+		// Runs of indentation are merged aggressively by every vocabulary.
+		return int(math.Ceil(float64(ascii) / 8))
+	}
+	est := float64(ascii)/a.profile.charsPerToken + float64(other)*a.profile.tokensPerRune
+	if est < 1 {
+		return 1
+	}
+	return int(math.Ceil(est))
+}
@@ -0,0 +1,167 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RE2 has no lookahead, so the trailing `\s+(?!\S)` alternative of the
+// tiktoken patterns is emulated in splitPieces instead.
+var bpePatterns = map[string]*regexp.Regexp{
+	Cl100kBase: regexp.MustCompile(`^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+)`),
+	O200kBase:  regexp.MustCompile(`^(?:[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+)`),
+}
+
+// splitPieces runs the pre-tokenizer over text. A whitespace run followed by
+// a non-space character gives up its last character to the next piece, which
+// matches tiktoken's `\s+(?!\S)` behaviour.
+func splitPieces(re *regexp.Regexp, text string) []string {
+	var pieces []string
+	pos := 0
+	for pos < len(text) {
+		loc := re.FindStringIndex(text[pos:])
+		if loc == nil || loc[1] == 0 {
+			_, size := utf8.DecodeRuneInString(text[pos:])
+			pieces = append(pieces, text[pos:pos+size])
+			pos += size
+			continue
+		}
+		piece := text[pos : pos+loc[1]]
+		end := pos + loc[1]
+		if end < len(text) && isAllSpace(piece) && !strings.ContainsAny(piece, "\r\n") {
+			next, _ := utf8.DecodeRuneInString(text[end:])
+			_, lastSize := utf8.DecodeLastRuneInString(piece)
+			if !unicode.IsSpace(next) && len(piece) > lastSize {
+				piece = piece[:len(piece)-lastSize]
+			}
+		}
+		pieces = append(pieces, piece)
+		pos += len(piece)
+	}
+	return pieces
+}
+
+func isAllSpace(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// BPE is a byte-level byte-pair encoder driven by tiktoken merge ranks.
+type BPE struct {
+	name    string
+	ranks   map[string]int
+	pattern *regexp.Regexp
+}
+
+// NewBPE builds a BPE encoding from merge ranks. pattern is the pre-tokenizer;
+// when nil the pattern registered for name (or cl100k_base) is used.
+func NewBPE(name string, ranks map[string]int, pattern *regexp.Regexp) *BPE {
+	if pattern == nil {
+		pattern = bpePatterns[name]
+	}
+	if pattern == nil {
+		pattern = bpePatterns[Cl100kBase]
+	}
+	return &BPE{name: name, ranks: ranks, pattern: pattern}
+}
+
+// RegisterBPE registers a BPE encoding built from ranks under name.
+func RegisterBPE(name string, ranks map[string]int) {
+	RegisterEncoding(NewBPE(name, ranks, nil))
+}
+
+func (b *BPE) Name() string {
+	return b.name
+}
+
+func (b *BPE) Count(text string) int {
+	total := 0
+	for _, piece := range splitPieces(b.pattern, text) {
+		if _, ok := b.ranks[piece]; ok {
+			total++
+			continue
+		}
+		total += b.mergeCount([]byte(piece))
+	}
+	return total
+}
+
+// mergeCount repeatedly merges the adjacent pair with the lowest rank and
+// returns the number of resulting tokens.
+func (b *BPE) mergeCount(piece []byte) int {
+	if len(piece) <= 1 {
+		return len(piece)
+	}
+	// bounds[i] is the start offset of part i; the final entry is len(piece).
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	rankOf := func(i int) int {
+		if i+2 >= len(bounds) {
+			return math.MaxInt
+		}
+		if r, ok := b.ranks[string(piece[bounds[i]:bounds[i+2]])]; ok {
+			return r
+		}
+		return math.MaxInt
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(bounds)-2; i++ {
+			if r := rankOf(i); r < bestRank {
+				best, bestRank = i, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// LoadTiktokenRanks parses a .tiktoken file: one "<base64 token> <rank>" pair
+// per line.
+func LoadTiktokenRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		parts := strings.Fields(text)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("tokenizer: malformed rank on line %d", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer: invalid token on line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer: invalid rank on line %d: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ranks, nil
+}
@@ -0,0 +1,124 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strings"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+// Default dimensions assumed for images that are referenced by URL and so
+// cannot be inspected offline.
+const (
+	defaultImageWidth  = 1024
+	defaultImageHeight = 1024
+)
+
+// CountMessageTokens estimates the prompt tokens of a full chat history for
+// model, including system prompt, context, per-message overhead, tool calls
+// and images.
+func CountMessageTokens(model string, history models.AIChatHistory) int {
+	name := EncodingNameForModel(model)
+	enc := GetEncoding(name)
+	p := profileFor(name)
+
+	total := 0
+	if history.SystemMsg != "" {
+		total += p.messageOverhead + enc.Count(history.SystemMsg)
+	}
+	if history.Context != "" {
+		total += p.messageOverhead + enc.Count(history.Context)
+	}
+	for _, msg := range history.Messages {
+		total += countMessage(name, enc, p, msg)
+	}
+	return total + p.replyOverhead
+}
+
+// CountMessage estimates the tokens of a single message for model, including
+// its per-message overhead.
+func CountMessage(model string, msg models.AIMessage) int {
+	name := EncodingNameForModel(model)
+	return countMessage(name, GetEncoding(name), profileFor(name), msg)
+}
+
+func countMessage(name string, enc Encoding, p profile, msg models.AIMessage) int {
+	total := p.messageOverhead + enc.Count(msg.Message)
+	for _, tc := range msg.ToolCalls {
+		total += enc.Count(tc.Function.Name) + enc.Count(tc.Function.Arguments) + 3
+	}
+	if msg.ToolCallId != "" {
+		total += enc.Count(msg.ToolCallId)
+	}
+	for _, img := range msg.Images {
+		total += imageTokens(name, img)
+	}
+	return total
+}
+
+// ImageTokens estimates the tokens billed for one image (a URL or base64 data
+// URL) sent to model. Dimensions are read from data URLs; URLs are assumed to
+// be 1024x1024.
+func ImageTokens(model, img string) int {
+	return imageTokens(EncodingNameForModel(model), img)
+}
+
+func imageTokens(name, img string) int {
+	w, h := imageDimensions(img)
+	switch name {
+	case Claude:
+		// Anthropic downsizes to a 1568px long edge and bills w*h/750.
+		w, h = fitWithin(w, h, 1568, 1568)
+		return int(math.Ceil(float64(w*h) / 750))
+	case Gemini:
+		// Gemini bills 258 tokens per 768x768 tile; small images are one tile.
+		if w <= 384 && h <= 384 {
+			return 258
+		}
+		tiles := int(math.Ceil(float64(w)/768)) * int(math.Ceil(float64(h)/768))
+		return 258 * tiles
+	default:
+		// OpenAI high detail: fit in 2048x2048, shortest side to 768, then
+		// 170 tokens per 512px tile plus a base of 85.
+		w, h = fitWithin(w, h, 2048, 2048)
+		if short := min(w, h); short > 768 {
+			scale := 768 / float64(short)
+			w, h = int(float64(w)*scale), int(float64(h)*scale)
+		}
+		tiles := int(math.Ceil(float64(w)/512)) * int(math.Ceil(float64(h)/512))
+		return 85 + 170*tiles
+	}
+}
+
+func fitWithin(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+	scale := math.Min(float64(maxW)/float64(w), float64(maxH)/float64(h))
+	return int(float64(w) * scale), int(float64(h) * scale)
+}
+
+func imageDimensions(img string) (int, int) {
+	if !strings.HasPrefix(img, "data:") {
+		return defaultImageWidth, defaultImageHeight
+	}
+	idx := strings.Index(img, ",")
+	if idx < 0 {
+		return defaultImageWidth, defaultImageHeight
+	}
+	raw, err := base64.StdEncoding.DecodeString(img[idx+1:])
+	if err != nil {
+		return defaultImageWidth, defaultImageHeight
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 {
+		return defaultImageWidth, defaultImageHeight
+	}
+	return cfg.Width, cfg.Height
+}
@@ -0,0 +1,246 @@
+// Package tokenizer provides offline token counting for the model families
+// supported by karma.
+//
+// OpenAI encodings (o200k_base, cl100k_base) run a real byte-level BPE when
+// their merge ranks are available: as tiktoken files (e.g.
+// o200k_base.tiktoken) in KARMA_TOKENIZER_DIR, or in the user cache directory
+// when that is unset, downloaded there once with Fetch, or registered at
+// runtime via RegisterBPE. Counting never touches the network.
+//
+// Everything else is an estimate: OpenAI encodings without ranks, and Claude,
+// Gemini and Llama always, since their vocabularies are not public in a usable
+// form. Estimates run a calibrated approximation over the same pre-tokenizer
+// and can be noticeably off for code, numbers and non-Latin scripts, so keep
+// headroom when checking context limits. Exact reports which case applies.
+package tokenizer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/MelloB1989/karma/config"
+)
+
+// Encoding names
+const (
+	O200kBase  = "o200k_base"
+	Cl100kBase = "cl100k_base"
+	Claude     = "claude"
+	Gemini     = "gemini"
+	Llama      = "llama"
+)
+
+// Encoding counts tokens for a piece of text.
+type Encoding interface {
+	Name() string
+	Count(text string) int
+}
+
+var registry = struct {
+	sync.RWMutex
+	encodings map[string]Encoding
+	loaded    map[string]bool
+}{
+	encodings: map[string]Encoding{
+		O200kBase:  newApproxEncoding(O200kBase),
+		Cl100kBase: newApproxEncoding(Cl100kBase),
+		Claude:     newApproxEncoding(Claude),
+		Gemini:     newApproxEncoding(Gemini),
+		Llama:      newApproxEncoding(Llama),
+	},
+	loaded: make(map[string]bool),
+}
+
+// RegisterEncoding installs (or replaces) an encoding by name.
+func RegisterEncoding(enc Encoding) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.encodings[enc.Name()] = enc
+	registry.loaded[enc.Name()] = true
+}
+
+// GetEncoding returns the encoding registered under name, lazily loading BPE
+// ranks from the tokenizer directory on first use. Unknown names fall back to
+// cl100k_base.
+func GetEncoding(name string) Encoding {
+	registry.RLock()
+	enc, ok := registry.encodings[name]
+	loaded := registry.loaded[name]
+	registry.RUnlock()
+	if !ok {
+		return GetEncoding(Cl100kBase)
+	}
+	if loaded {
+		return enc
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.loaded[name] {
+		return registry.encodings[name]
+	}
+	registry.loaded[name] = true
+	if bpe := loadBPEFromDir(name); bpe != nil {
+		registry.encodings[name] = bpe
+	}
+	return registry.encodings[name]
+}
+
+// Exact reports whether counts for model come from the real tokenizer rather
+// than an estimate.
+func Exact(model string) bool {
+	_, ok := EncodingForModel(model).(*BPE)
+	return ok
+}
+
+// Dir is where tiktoken rank files are looked up and fetched to:
+// KARMA_TOKENIZER_DIR, or karma/tokenizer in the user cache directory.
+func Dir() string {
+	if dir := config.GetEnvRaw("KARMA_TOKENIZER_DIR"); dir != "" {
+		return dir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "karma", "tokenizer")
+	}
+	return ""
+}
+
+func loadBPEFromDir(name string) Encoding {
+	pattern, ok := bpePatterns[name]
+	if !ok {
+		return nil
+	}
+	dir := Dir()
+	if dir == "" {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(dir, name+".tiktoken"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	ranks, err := LoadTiktokenRanks(f)
+	if err != nil {
+		return nil
+	}
+	return NewBPE(name, ranks, pattern)
+}
+
+// bpeSources are the published tiktoken rank files and their SHA-256 digests.
+var bpeSources = map[string]struct{ url, sha256 string }{
+	O200kBase:  {"https://openaipublic.blob.core.windows.net/encodings/o200k_base.tiktoken", "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d"},
+	Cl100kBase: {"https://openaipublic.blob.core.windows.net/encodings/cl100k_base.tiktoken", "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7"},
+}
+
+var fetchClient = &http.Client{}
+
+// fetchMaxBytes bounds a downloaded rank file; o200k_base is about 3.6 MB.
+const fetchMaxBytes = 16 << 20
+
+// Fetch downloads the rank files of the given OpenAI encodings (all of them
+// when none are named) into Dir, verifies their checksums and switches
+// counting for them to the real BPE. Files already present are reused. Call
+// it once at startup, or place the files in KARMA_TOKENIZER_DIR yourself where
+// there is no network access.
+func Fetch(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		names = []string{O200kBase, Cl100kBase}
+	}
+	dir := Dir()
+	if dir == "" {
+		return fmt.Errorf("tokenizer: no directory for rank files; set KARMA_TOKENIZER_DIR")
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, name+".tiktoken")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if data, err = download(ctx, name); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("tokenizer: %w", err)
+			}
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				return fmt.Errorf("tokenizer: %w", err)
+			}
+		}
+		ranks, err := LoadTiktokenRanks(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("tokenizer: %s: %w", name, err)
+		}
+		RegisterEncoding(NewBPE(name, ranks, bpePatterns[name]))
+	}
+	return nil
+}
+
+func download(ctx context.Context, name string) ([]byte, error) {
+	src, ok := bpeSources[name]
+	if !ok {
+		return nil, fmt.Errorf("tokenizer: no published ranks for %q", name)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer: fetch %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokenizer: fetch %s: status %d", name, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, fetchMaxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer: fetch %s: %w", name, err)
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != src.sha256 {
+		return nil, fmt.Errorf("tokenizer: %s ranks failed checksum verification", name)
+	}
+	return data, nil
+}
+
+// EncodingNameForModel maps a provider model string (or karma BaseModel) to
+// the encoding family used to count its tokens.
+func EncodingNameForModel(model string) string {
+	m := strings.ToLower(model)
+	switch {
+	case m == "":
+		return Cl100kBase
+	case strings.Contains(m, "claude") || strings.Contains(m, "anthropic"):
+		return Claude
+	case strings.Contains(m, "gemini") || strings.Contains(m, "gemma") || strings.Contains(m, "palm"):
+		return Gemini
+	case strings.Contains(m, "llama"):
+		return Llama
+	case strings.Contains(m, "gpt-4o"), strings.Contains(m, "gpt-4.1"), strings.Contains(m, "gpt-5"),
+		strings.Contains(m, "gpt-oss"), strings.Contains(m, "codex"),
+		strings.HasPrefix(m, "o1"), strings.HasPrefix(m, "o3"), strings.HasPrefix(m, "o4"),
+		strings.Contains(m, "/o1"), strings.Contains(m, "/o3"), strings.Contains(m, "/o4"):
+		return O200kBase
+	default:
+		return Cl100kBase
+	}
+}
+
+// EncodingForModel returns the encoding used to count tokens for model.
+func EncodingForModel(model string) Encoding {
+	return GetEncoding(EncodingNameForModel(model))
+}
+
+// CountTokens counts the tokens of text as seen by model.
+func CountTokens(model, text string) int {
+	if text == "" {
+		return 0
+	}
+	return EncodingForModel(model).Count(text)
+}
@@ -0,0 +1,187 @@
+package tokenizer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MelloB1989/karma/models"
+)
+
+func testRanks(merges ...string) map[string]int {
+	ranks := make(map[string]int)
+	for i := 0; i < 256; i++ {
+		ranks[string([]byte{byte(i)})] = i
+	}
+	for i, m := range merges {
+		ranks[m] = 256 + i
+	}
+	return ranks
+}
+
+func TestSplitPiecesEmulatesWhitespaceLookahead(t *testing.T) {
+	got := splitPieces(bpePatterns[Cl100kBase], "hello   world")
+	want := []string{"hello", "  ", " world"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitPieces = %q, want %q", got, want)
+	}
+}
+
+func TestBPEMergesByRank(t *testing.T) {
+	enc := NewBPE("test", testRanks("he", "ll", "hell", "hello"), nil)
+	if got := enc.Count("hello"); got != 1 {
+		t.Fatalf("Count(hello) = %d, want 1", got)
+	}
+	// " hello" is not a single rank: " " stays alone and "hello" merges.
+	if got := enc.Count(" hello"); got != 2 {
+		t.Fatalf("Count( hello) = %d, want 2", got)
+	}
+	// "help" merges "he" only; "l" and "p" remain.
+	if got := enc.Count("help"); got != 3 {
+		t.Fatalf("Count(help) = %d, want 3", got)
+	}
+}
+
+func TestLoadTiktokenRanks(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString(base64.StdEncoding.EncodeToString([]byte("he")) + " 0\n")
+	sb.WriteString(base64.StdEncoding.EncodeToString([]byte("llo")) + " 1\n")
+	ranks, err := LoadTiktokenRanks(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("LoadTiktokenRanks: %v", err)
+	}
+	if ranks["he"] != 0 || ranks["llo"] != 1 {
+		t.Fatalf("unexpected ranks: %v", ranks)
+	}
+	if _, err := LoadTiktokenRanks(strings.NewReader("bad")); err == nil {
+		t.Fatal("expected error for malformed line")
+	}
+}
+
+func TestEncodingNameForModel(t *testing.T) {
+	cases := map[string]string{
+		"gpt-4o-mini":                   "o200k_base",
+		"gpt-5-nano":                    "o200k_base",
+		"o1-mini":                       "o200k_base",
+		"gpt-4":                         "cl100k_base",
+		"gpt-3.5-turbo":                 "cl100k_base",
+		"claude-4-sonnet-20250514":      "claude",
+		"anthropic/claude-sonnet-4.5":   "claude",
+		"gemini-2.5-flash":              "gemini",
+		"meta.llama3-70b-instruct-v1:0": "llama",
+		"":                              "cl100k_base",
+	}
+	for model, want := range cases {
+		if got := EncodingNameForModel(model); got != want {
+			t.Errorf("EncodingNameForModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestApproxCountScalesWithText(t *testing.T) {
+	short := CountTokens("gpt-4o", "The quick brown fox")
+	if short < 3 || short > 6 {
+		t.Fatalf("short count = %d, want roughly one token per word", short)
+	}
+	long := CountTokens("gpt-4o", strings.Repeat("The quick brown fox ", 100))
+	if long < short*80 {
+		t.Fatalf("long count = %d, want it to scale with length (short=%d)", long, short)
+	}
+	if got := CountTokens("claude-3-haiku", ""); got != 0 {
+		t.Fatalf("empty text = %d, want 0", got)
+	}
+}
+
+func TestCountMessageTokensIncludesOverheadAndImages(t *testing.T) {
+	history := models.AIChatHistory{
+		SystemMsg: "You are helpful.",
+		Messages: []models.AIMessage{
+			{Role: models.User, Message: "hi"},
+		},
+	}
+	base := CountMessageTokens("gpt-4o", history)
+	text := CountTokens("gpt-4o", "You are helpful.") + CountTokens("gpt-4o", "hi")
+	if base <= text {
+		t.Fatalf("CountMessageTokens = %d, want more than raw text %d", base, text)
+	}
+
+	history.Messages[0].Images = []string{pngDataURL(t, 512, 512)}
+	withImage := CountMessageTokens("gpt-4o", history)
+	if withImage-base != 85+170 {
+		t.Fatalf("512x512 image cost %d tokens, want %d", withImage-base, 85+170)
+	}
+}
+
+func TestImageTokensPerFamily(t *testing.T) {
+	img := pngDataURL(t, 300, 300)
+	if got := ImageTokens("gemini-2.5-pro", img); got != 258 {
+		t.Errorf("gemini small image = %d, want 258", got)
+	}
+	if got := ImageTokens("claude-3-haiku", img); got != 120 {
+		t.Errorf("claude 300x300 image = %d, want 120", got)
+	}
+	if got := ImageTokens("gpt-4o", "https://example.com/cat.png"); got != 85+170*4 {
+		t.Errorf("openai default url image = %d, want %d", got, 85+170*4)
+	}
+}
+
+func pngDataURL(t *testing.T, w, h int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestFetchVerifiesAndRegistersRanks(t *testing.T) {
+	var file strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&file, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	fmt.Fprintf(&file, "%s 256\n", base64.StdEncoding.EncodeToString([]byte("hi")))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, file.String())
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	t.Setenv("KARMA_TOKENIZER_DIR", dir)
+	saved := bpeSources[Cl100kBase]
+	t.Cleanup(func() {
+		bpeSources[Cl100kBase] = saved
+		RegisterEncoding(newApproxEncoding(Cl100kBase))
+	})
+
+	bpeSources[Cl100kBase] = struct{ url, sha256 string }{srv.URL, "0000"}
+	if err := Fetch(context.Background(), Cl100kBase); err == nil {
+		t.Fatal("Fetch accepted ranks with the wrong checksum")
+	}
+	sum := sha256.Sum256([]byte(file.String()))
+	bpeSources[Cl100kBase] = struct{ url, sha256 string }{srv.URL, hex.EncodeToString(sum[:])}
+	if err := Fetch(context.Background(), Cl100kBase); err != nil {
+		t.Fatal(err)
+	}
+	if !Exact("gpt-4") || CountTokens("gpt-4", "hi") != 1 {
+		t.Fatalf("gpt-4 counts with %T after Fetch", EncodingForModel("gpt-4"))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cl100k_base.tiktoken")); err != nil {
+		t.Fatalf("ranks were not cached: %v", err)
+	}
+	if Exact("claude-3-haiku") {
+		t.Error("claude counts are estimates")
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/MelloB1989/karma/config"
+	"github.com/MelloB1989/karma/tokenizer"
 	"github.com/golang-jwt/jwt"
 	gonanoid "github.com/matoous/go-nanoid/v2"
 	"github.com/redis/go-redis/v9"
@@ -230,10 +231,10 @@ func URLEncode(input string) string {
 	return url.QueryEscape(input)
 }
 
+// CountTokens estimates the number of tokens in text using the cl100k_base
+// encoding. Use tokenizer.CountTokens for model-specific counts.
 func CountTokens(text string) int {
-	re := regexp.MustCompile(`[^\s]+`)
-	tokens := re.FindAllString(text, -1)
-	return len(tokens)
+	return tokenizer.GetEncoding(tokenizer.Cl100kBase).Count(text)
 }
 
 func OmitJSONKeys(data any, keysToOmit ...string) (any, error) {
@@ -549,9 +549,18 @@ func (qr *QueryResult) Scan(dest any) error {
 }
 
 func (o *ORM) QueryRaw(query string, args ...any) *QueryResult {
+	return o.queryRaw(context.Background(), query, args...)
+}
+
+// QueryRawContext is QueryRaw with the database query bound to ctx.
+func (o *ORM) QueryRawContext(ctx context.Context, query string, args ...any) *QueryResult {
+	return o.queryRaw(ctx, query, args...)
+}
+
+func (o *ORM) queryRaw(ctx context.Context, query string, args ...any) *QueryResult {
 	// If caching is disabled, go straight to the database
 	if o.CacheOn == nil || !*o.CacheOn {
-		result := o.executeQuery(query, args...)
+		result := o.executeQuery(ctx, query, args...)
 		result.orm = o // Set the reference to the ORM
 		return result
 	}
@@ -614,27 +623,27 @@ func (o *ORM) QueryRaw(query string, args ...any) *QueryResult {
 	}
 
 	// Cache miss - execute the query
-	result := o.executeQuery(query, args...)
+	result := o.executeQuery(ctx, query, args...)
 	result.orm = o // Set the reference to the ORM
 
 	return result
 }
 
 // executeQuery executes the actual database query
-func (o *ORM) executeQuery(query string, args ...any) *QueryResult {
+func (o *ORM) executeQuery(ctx context.Context, query string, args ...any) *QueryResult {
 	var rows *sql.Rows
 	var err error
 
 	// Use transaction if available, otherwise use the shared database connection
 	if o.tx != nil {
-		rows, err = o.tx.Query(query, args...)
+		rows, err = o.tx.QueryContext(ctx, query, args...)
 	} else {
 		db, dbErr := o.getDB()
 		if dbErr != nil {
 			log.Printf("Database connection error: %v", dbErr)
 			return &QueryResult{nil, dbErr, query, args, nil, o}
 		}
-		rows, err = db.Query(query, args...)
+		rows, err = db.QueryContext(ctx, query, args...)
 	}
 
 	if err != nil {
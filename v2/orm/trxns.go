@@ -96,15 +96,20 @@ func (t *Transaction) ORM() *ORM {
 
 // Add ExecuteRaw for non-query operations (INSERT, UPDATE, DELETE)
 func (o *ORM) ExecuteRaw(query string, args ...any) (sql.Result, error) {
+	return o.ExecuteRawContext(context.Background(), query, args...)
+}
+
+// ExecuteRawContext is ExecuteRaw bound to ctx.
+func (o *ORM) ExecuteRawContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
 	// Use transaction if available, otherwise use the shared database connection
 	if o.tx != nil {
-		return o.tx.Exec(query, args...)
+		return o.tx.ExecContext(ctx, query, args...)
 	}
 	db, err := o.getDB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database connection: %w", err)
 	}
-	return db.Exec(query, args...)
+	return db.ExecContext(ctx, query, args...)
 }
 
 // Add a helper function for transaction execution with automatic rollback on error